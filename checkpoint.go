@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"juimburser/pkg/scan"
+)
+
+// Written to the out dir of an interrupted run
+const checkpointFile = "checkpoint.json"
+
+// Where an interrupted run stopped: the chains it didn't finish and the
+// blocks it was scanning them over, so --resume can scan exactly those again.
+// What it fetched is in the tx cache, so isn't fetched twice.
+type Checkpoint struct {
+	CreatedAt time.Time `json:"createdAt"`
+	// Keyed by chain ID
+	Chains map[string]CheckpointChain `json:"chains"`
+}
+
+type CheckpointChain struct {
+	Name      string `json:"name"`
+	FromBlock uint64 `json:"fromBlock"`
+	// Omitted for a chain interrupted before its end block was resolved
+	ToBlock *uint64 `json:"toBlock,omitempty"`
+}
+
+func newCheckpoint() *Checkpoint {
+	return &Checkpoint{Chains: make(map[string]CheckpointChain)}
+}
+
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cp := newCheckpoint()
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %s: %w", path, err)
+	}
+	if len(cp.Chains) == 0 {
+		return nil, fmt.Errorf("checkpoint %s has no chains to resume", path)
+	}
+	return cp, nil
+}
+
+func (cp *Checkpoint) Save(path string) error {
+	cp.CreatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Records a chain the run didn't finish, with its result if the scan got
+// far enough to resolve its blocks
+func (cp *Checkpoint) Add(chain *scan.Chain, res *scan.Result) {
+	entry := CheckpointChain{Name: chain.Name, FromBlock: chain.StartBlock.Uint64()}
+	end := chain.EndBlock
+	if res != nil {
+		end = res.EndBlock
+	}
+	if end != nil {
+		to := end.Uint64()
+		entry.ToBlock = &to
+	}
+	cp.Chains[chain.ChainID.String()] = entry
+}
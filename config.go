@@ -0,0 +1,1841 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"juimburser/pkg/bundle"
+	"juimburser/pkg/governance"
+	"juimburser/pkg/ipfs"
+	"juimburser/pkg/notify"
+	"juimburser/pkg/safe"
+	"juimburser/pkg/scan"
+	"juimburser/pkg/screen"
+	"juimburser/pkg/sheets"
+	"juimburser/pkg/simulate"
+)
+
+// Config file structs
+type Config struct {
+	Chains []ChainConfig `yaml:"chains"`
+	// Transactions and senders never to reimburse, on any chain
+	Exclude []ExclusionConfig `yaml:"exclude"`
+	// Policies over each transaction, on every chain, the first matching
+	// one applying
+	Rules []RuleConfig `yaml:"rules"`
+	// External programs run over each transaction the groups match, in order
+	Classifiers []ClassifierConfig `yaml:"classifiers"`
+	// Senders reimbursed with --owners-only even though they don't own the
+	// Safe
+	Allow []string `yaml:"allow"`
+	// Webhooks to post a summary to after each run
+	Notify []NotifyConfig `yaml:"notify"`
+	// Where the out dir is published, for links in notifications. Artifacts
+	// are listed by path if empty.
+	ArtifactsURL string `yaml:"artifactsUrl"`
+	// Google Sheet to append each run's transactions and recipient totals to
+	Sheets *SheetsConfig `yaml:"sheets"`
+	// Pinning service to pin each run's artifacts to
+	IPFS *IPFSConfig `yaml:"ipfs"`
+	// Names for addresses, e.g. "jango" or "peel.eth hot wallet", used in
+	// reports and bundle descriptions
+	Labels map[string]string `yaml:"labels"`
+	// Senders whose reimbursements go to another address, e.g. a bot's hot
+	// wallet to its owner's cold wallet, on every chain
+	Beneficiaries map[string]string `yaml:"beneficiaries"`
+	// Senders paid as one, e.g. a team running several keepers, keyed by the
+	// group's name, on every chain
+	PayoutGroups map[string]PayoutGroupConfig `yaml:"payoutGroups"`
+	// Senders whose reimbursements are divided among several addresses, e.g.
+	// a shared operational wallet, on every chain
+	SenderSplits map[string][]ShareConfig `yaml:"senderSplits"`
+	// Where --governance snapshot proposals go
+	Governance *GovernanceConfig `yaml:"governance"`
+	// Tenderly project bundles are simulated in with --simulate tenderly
+	Tenderly *TenderlyConfig `yaml:"tenderly"`
+	// Sanctions screening of recipients, who are held back from the bundle
+	// if flagged
+	Denylist *DenylistConfig `yaml:"denylist"`
+	// Setups for each project sharing this config, selected with --profile
+	Profiles map[string]ProfileConfig `yaml:"profiles"`
+
+	// Hex sha256 of the file it was read from, for run manifests
+	sha256 string
+	// The profile selected, what's added to its artifacts' names, and its
+	// state file, all empty without one
+	profile      string
+	artifactName string
+	statePath    string
+}
+
+// A project's own chains (with their contracts, Safe, and projectId) and
+// output. Its labels, beneficiaries, payout groups, sender splits,
+// exclusions, rules (after the top-level ones), and allow list are added to
+// the top-level ones; anything else it sets replaces them.
+type ProfileConfig struct {
+	Chains []ChainConfig `yaml:"chains"`
+	// Added to its artifacts' names, e.g. report-juicecrowd.txt, the
+	// profile's name by default
+	ArtifactName string `yaml:"artifactName"`
+	// Its --state file, state-<profile>.json by default, so profiles scanning
+	// the same chain don't share one
+	State         string                       `yaml:"state"`
+	ArtifactsURL  string                       `yaml:"artifactsUrl"`
+	Governance    *GovernanceConfig            `yaml:"governance"`
+	Notify        []NotifyConfig               `yaml:"notify"`
+	Labels        map[string]string            `yaml:"labels"`
+	Beneficiaries map[string]string            `yaml:"beneficiaries"`
+	PayoutGroups  map[string]PayoutGroupConfig `yaml:"payoutGroups"`
+	SenderSplits  map[string][]ShareConfig     `yaml:"senderSplits"`
+	Exclude       []ExclusionConfig            `yaml:"exclude"`
+	Rules         []RuleConfig                 `yaml:"rules"`
+	Classifiers   []ClassifierConfig           `yaml:"classifiers"`
+	Allow         []string                     `yaml:"allow"`
+}
+
+// Senders whose reimbursements are added up and paid to one address. It's
+// labeled with the group's name unless the label book names it.
+type PayoutGroupConfig struct {
+	Address string   `yaml:"address"`
+	Senders []string `yaml:"senders"`
+}
+
+// An address's share of a split sender's reimbursement
+type ShareConfig struct {
+	Address string `yaml:"address"`
+	// e.g. "70%"; a sender's shares add up to 100%
+	Share string `yaml:"share"`
+}
+
+// A Snapshot space and voting window. Durations take the same forms as
+// --every, e.g. "1d" or "36h".
+type GovernanceConfig struct {
+	SnapshotSpace string `yaml:"snapshotSpace"`
+	// Forum thread linked from the proposal
+	Discussion string `yaml:"discussion"`
+	// Time from the run to the start of voting, none by default
+	VotingDelay string `yaml:"votingDelay"`
+	// 3 days by default
+	VotingPeriod string `yaml:"votingPeriod"`
+}
+
+// A pinning service taking Pinata's pinFileToIPFS uploads. Env vars are
+// expanded in jwt.
+type IPFSConfig struct {
+	// Pinata's API by default
+	API string `yaml:"api"`
+	JWT string `yaml:"jwt"`
+	// Where CIDs are linked, ipfs.io by default
+	Gateway string `yaml:"gateway"`
+}
+
+// A local denylist file (see screen.LoadList) and/or a Chainalysis
+// sanctions API key. Env vars are expanded in both.
+type DenylistConfig struct {
+	File              string `yaml:"file"`
+	ChainalysisAPIKey string `yaml:"chainalysisApiKey"`
+	// Chainalysis's public API by default
+	ChainalysisAPI string `yaml:"chainalysisApi"`
+}
+
+// A Tenderly project and an access key for it. Env vars are expanded in
+// accessKey.
+type TenderlyConfig struct {
+	Account   string `yaml:"account"`
+	Project   string `yaml:"project"`
+	AccessKey string `yaml:"accessKey"`
+	// Tenderly's API by default
+	API string `yaml:"api"`
+}
+
+// A spreadsheet shared with a service account. Env vars are expanded in
+// credentials.
+type SheetsConfig struct {
+	SpreadsheetID string `yaml:"spreadsheetId"`
+	// Path to the service account's JSON key
+	Credentials string `yaml:"credentials"`
+	// Tabs to append to, Transactions and Recipients by default
+	TransactionsTab string `yaml:"transactionsTab"`
+	RecipientsTab   string `yaml:"recipientsTab"`
+	// Sheets API base URL, Google's by default
+	API string `yaml:"api"`
+}
+
+// A Discord, Slack, or Telegram webhook. Env vars like ${SLACK_WEBHOOK_URL}
+// are expanded in url and botToken.
+type NotifyConfig struct {
+	Kind notify.Kind `yaml:"kind"`
+	// Incoming webhook URL for Discord and Slack
+	URL string `yaml:"url"`
+	// Telegram only
+	BotToken string `yaml:"botToken"`
+	ChatID   string `yaml:"chatId"`
+}
+
+// Excludes either one transaction or everything sent by one address
+type ExclusionConfig struct {
+	Tx      string `yaml:"tx"`
+	Address string `yaml:"address"`
+	Reason  string `yaml:"reason"`
+}
+
+// A policy over transactions: when is a Starlark expression using
+// scan.RuleVars, e.g. gas_price_gwei > 200 and label == "Execute multisig tx"
+type RuleConfig struct {
+	Name string `yaml:"name"`
+	When string `yaml:"when"`
+	// include, exclude, or cap
+	Action string `yaml:"action"`
+	// Most ETH reimbursed per transaction, for cap (e.g. "0.01")
+	Cap string `yaml:"cap"`
+	// Shown for the transactions exclude leaves out, "excluded by rule
+	// <name>" by default
+	Reason string `yaml:"reason"`
+}
+
+// A classifier program, run for each run that scans and sent every
+// transaction the groups match, answering with a label and reimbursement
+// policy for it (see scan.ProcessClassifier)
+type ClassifierConfig struct {
+	Name string `yaml:"name"`
+	// The program and its arguments, e.g. ["python3", "classify.py"]. Env vars
+	// are expanded.
+	Command []string `yaml:"command"`
+}
+
+type ChainConfig struct {
+	Name    string `yaml:"name"`
+	ChainID uint64 `yaml:"chainId"`
+	// Env vars like ${OP_RPC_URL} are expanded. Falls back to --rpc-url if empty.
+	RPCURL string `yaml:"rpcUrl"`
+	// Fallback endpoints tried in order after rpcUrl
+	RPCURLs []string `yaml:"rpcUrls"`
+	// Block explorer base URL, defaulted for known chains
+	Explorer string `yaml:"explorer"`
+	// Etherscan-compatible API used with --source etherscan, e.g. a Blockscout
+	// instance's /api. Defaults to Etherscan's multichain API.
+	EtherscanAPI string `yaml:"etherscanApi"`
+	// GraphQL endpoint used with --source subgraph, e.g. the Juicebox
+	// subgraph's. Env vars are expanded, since gateway URLs hold API keys.
+	SubgraphURL string `yaml:"subgraphUrl"`
+	// ethereum, optimism, or arbitrum, defaulted for known chains
+	GasModel scan.GasModel `yaml:"gasModel"`
+	// Chainlink ETH/USD feed used with --usd, defaulted for known chains
+	PriceFeed string `yaml:"priceFeed"`
+	// USDC token used with --pay-in usdc, defaulted for known chains
+	USDC string `yaml:"usdc"`
+	// JBX token used with --pay-in jbx, defaulted on mainnet
+	JBX string `yaml:"jbx"`
+	// JBX per ETH with --pay-in jbx, e.g. a rate set by governance. If empty,
+	// the TWAP of jbxPool is used.
+	JBXRate string `yaml:"jbxRate"`
+	// Uniswap V3 JBX/WETH pool the rate is read from without a jbxRate
+	JBXPool string `yaml:"jbxPool"`
+	// Wrapped ETH token jbxPool pairs JBX with, defaulted for known chains
+	WETH string `yaml:"weth"`
+	// The Safe that pays reimbursements on this chain
+	Safe string `yaml:"safe"`
+	// Local anvil fork of the chain bundles are simulated on with --simulate
+	// fork, http://127.0.0.1:8545 by default. Env vars are expanded.
+	ForkURL string `yaml:"forkUrl"`
+	// Multisigs whose executions are reimbursed, each found with its own
+	// ExecutionSuccess group and broken out in the reports
+	Safes []SafeConfig `yaml:"safes"`
+	// Safe Transaction Service base URL, defaulted for known chains
+	SafeService string `yaml:"safeService"`
+	// MultiSendCallOnly contract used to batch transfers
+	MultiSend string `yaml:"multiSend"`
+	// Juicebox terminal paid with --pay-via juicebox, its version (3 for
+	// JBETHPaymentTerminal, 4 for JBMultiTerminal, protocolVersion by
+	// default), and the project to pay
+	Terminal        string  `yaml:"terminal"`
+	TerminalVersion int     `yaml:"terminalVersion"`
+	ProjectID       *uint64 `yaml:"projectId"`
+	// Merkle claim contract funded with --pay-via merkle, for recipients to
+	// claim their amounts from with proofs
+	ClaimContract string `yaml:"claimContract"`
+	// Sablier V2.1 LockupLinear contract streaming reimbursements with
+	// --pay-via sablier
+	SablierLockup string `yaml:"sablierLockup"`
+	// 0xSplits split, controlled by safe, that reimbursements are routed
+	// through with --pay-via splits, and its SplitMain (0xSplits' v1
+	// deployment by default)
+	Split     string `yaml:"split"`
+	SplitMain string `yaml:"splitMain"`
+	// Juicebox version (3 or 4) whose payout and reserved token groups are
+	// built in, matching projectId (JuiceboxDAO's by default)
+	ProtocolVersion int `yaml:"protocolVersion"`
+	// Most ETH paid to one recipient per run (e.g. "0.5"); anything above is held back
+	MaxPerRecipient string `yaml:"maxPerRecipient"`
+	// Least ETH paid to one recipient (e.g. "0.005"); smaller totals are
+	// carried over to the next run
+	MinPayout string `yaml:"minPayout"`
+	// Highest effective gas price reimbursed, in gwei (e.g. "60")
+	MaxGasPrice string `yaml:"maxGasPrice"`
+	// Highest priority fee per gas a transaction should pay, in gwei (e.g.
+	// "2"), and whether those over it are only flagged for review ("flag",
+	// the default) or reimbursed as if they'd tipped that much ("cap")
+	MaxTip       string  `yaml:"maxTip"`
+	MaxTipAction string  `yaml:"maxTipAction"`
+	FromBlock    *uint64 `yaml:"fromBlock"`
+	ToBlock      *uint64 `yaml:"toBlock"`
+	// Without toBlock, scan up to this many blocks behind the latest instead
+	// of to the finalized block
+	Confirmations *uint64       `yaml:"confirmations"`
+	Groups        []GroupConfig `yaml:"groups"`
+	// ERC-2771 forwarders, relay contracts, and relayers whose transactions
+	// should be reimbursed to whoever pays the relayer. Gelato's relays are
+	// built in.
+	Relayers []RelayerConfig `yaml:"relayers"`
+	// Reimbursements besides gas, like an ENS renewal a contributor paid for,
+	// added to the recipient's payout until a run bundles them
+	LineItems []LineItemConfig `yaml:"lineItems"`
+}
+
+type LineItemConfig struct {
+	// Unique on the chain; a run that bundles the item records it in the
+	// state, so later runs skip it
+	ID      string `yaml:"id"`
+	Address string `yaml:"address"`
+	// In ETH, e.g. "0.0123"
+	Amount string `yaml:"amount"`
+	// What it's for, shown in the reports
+	Memo string `yaml:"memo"`
+}
+
+// A forwarder or relay contract called, or a relayer sending, on someone
+// else's behalf
+type RelayerConfig struct {
+	Address string `yaml:"address"`
+	// Who is reimbursed for its transactions. If empty, they're excluded to be
+	// assigned by hand (unless a Gelato 1Balance sponsor paid).
+	Payer string `yaml:"payer"`
+}
+
+// A multisig whose executed transactions are reimbursed
+type SafeConfig struct {
+	Address string `yaml:"address"`
+	// Shown in reports and used in its group's label, e.g. "JuiceboxDAO multisig"
+	Name string `yaml:"name"`
+	// Share of its executions' gas reimbursed, e.g. "50%"; all of it by default
+	Rate string `yaml:"rate"`
+}
+
+type GroupConfig struct {
+	Label string `yaml:"label"`
+	// events (the default) or approveHash
+	Type      string     `yaml:"type"`
+	Addresses []string   `yaml:"addresses"`
+	Topics    [][]string `yaml:"topics"`
+	// Project IDs to match against the topic at position ProjectIDTopic
+	ProjectIDs     []uint64 `yaml:"projectIds"`
+	ProjectIDTopic int      `yaml:"projectIdTopic"`
+	// Also reimburse reverted calls to the group's addresses. With projectIds,
+	// only calls whose first argument is one of them count.
+	IncludeFailed bool `yaml:"includeFailed"`
+	// Also match internal calls into the group's addresses found in
+	// transaction traces (see --trace-api). topics can then be left out.
+	Traces bool `yaml:"traces"`
+	// Functions reverted and traced calls must call, as signatures like
+	// "distributePayoutsOf(uint256,uint256,uint256,address,uint256,string)"
+	// or 4-byte selectors; any by default
+	Calls []string `yaml:"calls"`
+	// Where to find the group's transactions with --source subgraph
+	Subgraph *SubgraphConfig `yaml:"subgraph"`
+	// Share of its transactions' gas reimbursed, e.g. "50%"; all of it by default
+	Rate string `yaml:"rate"`
+}
+
+type SubgraphConfig struct {
+	// Event entity collection, e.g. distributePayoutsEvents
+	Entity string `yaml:"entity"`
+	// Extra GraphQL where conditions, e.g. 'project_: {handle: "juicebox"}'
+	Where string `yaml:"where"`
+}
+
+const defaultProjectIDTopic = 3
+
+// Group types
+const (
+	// Logs from the group's addresses matching its topics
+	GroupEvents = "events"
+	// Safe owners' on-chain approveHash calls, found by the ApproveHash events
+	// of the group's addresses or, if it lists none, the chain's Safes
+	GroupApproveHash = "approveHash"
+)
+
+// Where scans get logs and transactions
+const (
+	SourceRPC       = "rpc"
+	SourceEtherscan = "etherscan"
+	// The chain's subgraph for groups with a subgraph query, the RPC for the rest
+	SourceSubgraph = "subgraph"
+)
+
+const (
+	PayInETH  = "eth"
+	PayInUSDC = "usdc"
+	PayInJBX  = "jbx"
+)
+
+const (
+	PayViaTransfer = "transfer"
+	PayViaJuicebox = "juicebox"
+	PayViaMerkle   = "merkle"
+	PayViaSablier  = "sablier"
+	PayViaSplits   = "splits"
+)
+
+// Where --simulate runs bundles
+const (
+	SimulateTenderly = "tenderly"
+	SimulateFork     = "fork"
+)
+
+const defaultForkURL = "http://127.0.0.1:8545"
+
+// JuiceboxDAO's own project
+const defaultJuiceboxProject = 1
+
+// The Juicebox contracts a protocolVersion's built-in groups match on a chain
+type juiceboxDeployment struct {
+	Terminals   []string
+	Controllers []string
+}
+
+// Built-in Juicebox deployments by protocol version and chain ID. v4 has the
+// same addresses on every chain.
+var juiceboxDeployments = map[int]map[uint64]juiceboxDeployment{
+	3: {
+		1: {
+			Terminals: []string{
+				"0xFA391De95Fcbcd3157268B91d8c7af083E607A5C", // JBETHPaymentTerminal3_1
+				"0x457cD63bee88ac01f3cD4a67D5DCc921D8C0D573", // JBETHPaymentTerminal3_1_1
+				"0x1d9619E10086FdC1065B114298384aAe3F680CC0", // JBETHPaymentTerminal3_1_2
+			},
+			Controllers: []string{
+				"0xFFdD70C318915879d5192e8a0dcbFcB0285b3C98", // JBController
+				"0xA139D37275d1fF7275e6F33821898934Bc8Cb7B6", // JBController3_0_1
+				"0x97a5b9D9F0F7cD676B69f584F29048D0Ef4BB59b", // JBController3_1
+			},
+		},
+	},
+	4: {
+		1:        juiceboxV4,
+		10:       juiceboxV4,
+		8453:     juiceboxV4,
+		42161:    juiceboxV4,
+		11155111: juiceboxV4, // Sepolia
+	},
+}
+
+var juiceboxV4 = juiceboxDeployment{
+	Terminals:   []string{"0xDB9644369c79C3633cDE70D2Df50d827D7dC7Dbc"}, // JBMultiTerminal
+	Controllers: []string{"0x27da30646502e2f642bE5281322Ae8C394F7668a"}, // JBController
+}
+
+// The payout and reserved token events of each protocol version, with the
+// project ID indexed at defaultProjectIDTopic
+var juiceboxEvents = map[int]struct{ Payouts, Reserved string }{
+	3: {
+		Payouts:  "DistributePayouts(uint256,uint256,uint256,address,uint256,uint256,uint256,uint256,bytes,address)",
+		Reserved: "DistributeReservedTokens(uint256,uint256,uint256,address,uint256,uint256,string,address)",
+	},
+	4: {
+		Payouts:  "SendPayouts(uint256,uint256,uint256,address,uint256,uint256,uint256,uint256,address)",
+		Reserved: "SendReservedTokensToSplits(uint256,uint256,uint256,address,uint256,uint256,address)",
+	},
+}
+
+// Default block explorers by chain ID
+var explorers = map[uint64]string{
+	1:        "https://etherscan.io",
+	10:       "https://optimistic.etherscan.io",
+	8453:     "https://basescan.org",
+	42161:    "https://arbiscan.io",
+	42170:    "https://nova.arbiscan.io",
+	11155111: "https://sepolia.etherscan.io",
+}
+
+// Default native USDC deployments by chain ID
+var usdcAddresses = map[uint64]string{
+	1:        "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48",
+	10:       "0x0b2C639c533813f4Aa9D7837CAf62653d097Ff85",
+	8453:     "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+	42161:    "0xaf88d065e77c8cC2239327C5EDb3A432268e5831",
+	11155111: "0x1c7D4B196Cb0C7B01d743Fbc6116a902379C7238", // Circle's testnet USDC
+}
+
+// JBX by chain ID
+var jbxAddresses = map[uint64]string{
+	1: "0x4554CC10898f92D45378b98D6D6c2dD54c687Fb2",
+}
+
+// Wrapped ETH by chain ID
+var wethAddresses = map[uint64]string{
+	1:        "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+	10:       "0x4200000000000000000000000000000000000006",
+	8453:     "0x4200000000000000000000000000000000000006",
+	42161:    "0x82aF49447D8a07e3bd95BD0d56f35241523fBab1",
+	11155111: "0xfFf9976782d46CC05630D1f6eBAb18b2324d6B14",
+}
+
+// Reads and validates the config at path
+func loadConfig(path string) (*Config, error) {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Reads and validates the config at --config, then selects --profile from it
+func loadProfile(c *cli.Context) (*Config, error) {
+	cfg, err := loadConfig(c.String("config"))
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Profile(c.String("profile"))
+}
+
+// The config with the named profile's settings in place of or added to the
+// top-level ones, or the config itself with no name, as long as it has chains
+// of its own
+func (c *Config) Profile(name string) (*Config, error) {
+	names := c.profileNames()
+	if name == "" {
+		if len(c.Chains) == 0 && len(names) > 0 {
+			return nil, fmt.Errorf("the config only has profiles, so --profile is required (one of %s)", strings.Join(names, ", "))
+		}
+		return c, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile %q in the config (its profiles are %s)", name, strings.Join(names, ", "))
+	}
+
+	merged := *c
+	merged.Profiles = nil
+	merged.Chains = p.Chains
+	merged.Exclude = append(slices.Clip(c.Exclude), p.Exclude...)
+	merged.Rules = append(slices.Clip(c.Rules), p.Rules...)
+	merged.Classifiers = append(slices.Clip(c.Classifiers), p.Classifiers...)
+	merged.Allow = append(slices.Clip(c.Allow), p.Allow...)
+	merged.Labels = maps.Clone(c.Labels)
+	if merged.Labels == nil {
+		merged.Labels = make(map[string]string)
+	}
+	maps.Copy(merged.Labels, p.Labels)
+	merged.Beneficiaries = maps.Clone(c.Beneficiaries)
+	if merged.Beneficiaries == nil {
+		merged.Beneficiaries = make(map[string]string)
+	}
+	maps.Copy(merged.Beneficiaries, p.Beneficiaries)
+	merged.PayoutGroups = maps.Clone(c.PayoutGroups)
+	if merged.PayoutGroups == nil {
+		merged.PayoutGroups = make(map[string]PayoutGroupConfig)
+	}
+	maps.Copy(merged.PayoutGroups, p.PayoutGroups)
+	merged.SenderSplits = maps.Clone(c.SenderSplits)
+	if merged.SenderSplits == nil {
+		merged.SenderSplits = make(map[string][]ShareConfig)
+	}
+	maps.Copy(merged.SenderSplits, p.SenderSplits)
+	if p.ArtifactsURL != "" {
+		merged.ArtifactsURL = p.ArtifactsURL
+	}
+	if p.Governance != nil {
+		merged.Governance = p.Governance
+	}
+	if p.Notify != nil {
+		merged.Notify = p.Notify
+	}
+	merged.profile = name
+	merged.artifactName = cmp.Or(p.ArtifactName, name)
+	merged.statePath = cmp.Or(p.State, "state-"+name+".json")
+	return &merged, nil
+}
+
+func (c *Config) profileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// The state file: --state if it's set, else the profile's
+func (c *Config) State(ctx *cli.Context) string {
+	if c.statePath == "" || ctx.IsSet("state") {
+		return ctx.String("state")
+	}
+	return c.statePath
+}
+
+// The profile's own settings as a config, for validating them
+func (p ProfileConfig) config() *Config {
+	return &Config{
+		Chains:        p.Chains,
+		Exclude:       p.Exclude,
+		Rules:         p.Rules,
+		Classifiers:   p.Classifiers,
+		Allow:         p.Allow,
+		Notify:        p.Notify,
+		ArtifactsURL:  p.ArtifactsURL,
+		Labels:        p.Labels,
+		Beneficiaries: p.Beneficiaries,
+		PayoutGroups:  p.PayoutGroups,
+		SenderSplits:  p.SenderSplits,
+		Governance:    p.Governance,
+	}
+}
+
+// Reads the config at path without validating it
+func readConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	cfg.sha256 = hex.EncodeToString(sum[:])
+	return &cfg, nil
+}
+
+// Checks every chain and group and returns all problems found, not just the first
+func (c *Config) Validate() error {
+	var errs []error
+	if len(c.Chains) == 0 && len(c.Profiles) == 0 {
+		errs = append(errs, fmt.Errorf("no chains or profiles defined"))
+	}
+	for _, name := range c.profileNames() {
+		p := c.Profiles[name]
+		if strings.TrimSpace(name) == "" {
+			errs = append(errs, fmt.Errorf("profiles: a profile has an empty name"))
+		}
+		if p.ArtifactName != "" && strings.ContainsAny(p.ArtifactName, `/\`) {
+			errs = append(errs, fmt.Errorf("profiles.%s: artifactName %q can't contain a path separator", name, p.ArtifactName))
+		}
+		if err := p.config().Validate(); err != nil {
+			for _, err := range unjoin(err) {
+				errs = append(errs, fmt.Errorf("profiles.%s: %w", name, err))
+			}
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, chain := range c.Chains {
+		name := fmt.Sprintf("chains[%d]", i)
+		if chain.Name != "" {
+			name += fmt.Sprintf(" (%q)", chain.Name)
+		}
+
+		if names[chain.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate chain name", name))
+		}
+		names[chain.Name] = true
+
+		for _, err := range chain.validate() {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	for i, e := range c.Exclude {
+		for _, err := range e.validate() {
+			errs = append(errs, fmt.Errorf("exclude[%d]: %w", i, err))
+		}
+	}
+
+	ruleNames := make(map[string]bool)
+	for i, r := range c.Rules {
+		name := fmt.Sprintf("rules[%d]", i)
+		if r.Name != "" {
+			name = fmt.Sprintf("rules[%d] (%q)", i, r.Name)
+		}
+		for _, err := range r.validate() {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		if ruleNames[r.Name] && r.Name != "" {
+			errs = append(errs, fmt.Errorf("%s: duplicate rule name", name))
+		}
+		ruleNames[r.Name] = true
+	}
+
+	classifierNames := make(map[string]bool)
+	for i, cl := range c.Classifiers {
+		name := fmt.Sprintf("classifiers[%d]", i)
+		if cl.Name != "" {
+			name = fmt.Sprintf("classifiers[%d] (%q)", i, cl.Name)
+		}
+		if strings.TrimSpace(cl.Name) == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", name))
+		} else if classifierNames[cl.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate classifier name", name))
+		}
+		classifierNames[cl.Name] = true
+		if len(cl.Command) == 0 || strings.TrimSpace(cl.Command[0]) == "" {
+			errs = append(errs, fmt.Errorf("%s: command is required", name))
+		}
+	}
+
+	for i, addr := range c.Allow {
+		if !common.IsHexAddress(addr) {
+			errs = append(errs, fmt.Errorf("allow[%d]: %q is not a valid address", i, addr))
+		}
+	}
+
+	var labeled []string
+	for addr := range c.Labels {
+		labeled = append(labeled, addr)
+	}
+	slices.Sort(labeled)
+	seen := make(map[common.Address]string)
+	for _, addr := range labeled {
+		label := c.Labels[addr]
+		switch {
+		case !common.IsHexAddress(addr):
+			errs = append(errs, fmt.Errorf("labels: %q is not a valid address", addr))
+		case strings.TrimSpace(label) == "":
+			errs = append(errs, fmt.Errorf("labels: %s has an empty label", addr))
+		case seen[common.HexToAddress(addr)] != "":
+			errs = append(errs, fmt.Errorf("labels: %s is listed twice, also as %s", addr, seen[common.HexToAddress(addr)]))
+		}
+		seen[common.HexToAddress(addr)] = addr
+	}
+
+	var senders []string
+	for addr := range c.Beneficiaries {
+		senders = append(senders, addr)
+	}
+	slices.Sort(senders)
+	redirected := make(map[common.Address]string)
+	for _, addr := range senders {
+		switch {
+		case !common.IsHexAddress(addr):
+			errs = append(errs, fmt.Errorf("beneficiaries: %q is not a valid address", addr))
+		case redirected[common.HexToAddress(addr)] != "":
+			errs = append(errs, fmt.Errorf("beneficiaries: %s is listed twice, also as %s", addr, redirected[common.HexToAddress(addr)]))
+		}
+		redirected[common.HexToAddress(addr)] = addr
+	}
+	for _, addr := range senders {
+		to := c.Beneficiaries[addr]
+		switch {
+		case !common.IsHexAddress(to):
+			errs = append(errs, fmt.Errorf("beneficiaries: %s: %q is not a valid address", addr, to))
+		case common.HexToAddress(to) == common.HexToAddress(addr):
+			errs = append(errs, fmt.Errorf("beneficiaries: %s is its own beneficiary", addr))
+		case redirected[common.HexToAddress(to)] != "":
+			// Only one step is followed, so a chain of them would pay the middle
+			errs = append(errs, fmt.Errorf("beneficiaries: %s's beneficiary %s has a beneficiary of its own", addr, to))
+		}
+	}
+
+	var groups []string
+	for name := range c.PayoutGroups {
+		groups = append(groups, name)
+	}
+	slices.Sort(groups)
+	grouped := make(map[common.Address]string)
+	for _, name := range groups {
+		g := c.PayoutGroups[name]
+		if strings.TrimSpace(name) == "" {
+			errs = append(errs, fmt.Errorf("payoutGroups: a group has an empty name"))
+		}
+		if len(g.Senders) < 2 {
+			errs = append(errs, fmt.Errorf("payoutGroups: %s needs at least two senders (use beneficiaries to pay one sender to another address)", name))
+		}
+		for i, addr := range g.Senders {
+			sender := common.HexToAddress(addr)
+			switch {
+			case !common.IsHexAddress(addr):
+				errs = append(errs, fmt.Errorf("payoutGroups: %s: senders[%d]: %q is not a valid address", name, i, addr))
+			case grouped[sender] != "":
+				errs = append(errs, fmt.Errorf("payoutGroups: %s: %s is already in %s", name, addr, grouped[sender]))
+			case redirected[sender] != "":
+				errs = append(errs, fmt.Errorf("payoutGroups: %s: %s also has a beneficiary", name, addr))
+			}
+			if common.IsHexAddress(addr) && grouped[sender] == "" {
+				grouped[sender] = name
+			}
+		}
+	}
+	for _, name := range groups {
+		to := c.PayoutGroups[name].Address
+		switch {
+		case !common.IsHexAddress(to):
+			errs = append(errs, fmt.Errorf("payoutGroups: %s: address: %q is not a valid address", name, to))
+		case redirected[common.HexToAddress(to)] != "":
+			errs = append(errs, fmt.Errorf("payoutGroups: %s is paid to %s, which has a beneficiary of its own", name, to))
+		case grouped[common.HexToAddress(to)] != "" && grouped[common.HexToAddress(to)] != name:
+			errs = append(errs, fmt.Errorf("payoutGroups: %s is paid to %s, which is in %s", name, to, grouped[common.HexToAddress(to)]))
+		}
+	}
+	for _, addr := range senders {
+		// Only one step is followed, as with beneficiaries of their own
+		if name := grouped[common.HexToAddress(c.Beneficiaries[addr])]; name != "" {
+			errs = append(errs, fmt.Errorf("beneficiaries: %s's beneficiary %s is in payout group %s", addr, c.Beneficiaries[addr], name))
+		}
+	}
+
+	var split []string
+	for addr := range c.SenderSplits {
+		split = append(split, addr)
+	}
+	slices.Sort(split)
+	splitSenders := make(map[common.Address]string)
+	for _, addr := range split {
+		switch {
+		case !common.IsHexAddress(addr):
+			errs = append(errs, fmt.Errorf("senderSplits: %q is not a valid address", addr))
+		case splitSenders[common.HexToAddress(addr)] != "":
+			errs = append(errs, fmt.Errorf("senderSplits: %s is listed twice, also as %s", addr, splitSenders[common.HexToAddress(addr)]))
+		case redirected[common.HexToAddress(addr)] != "":
+			errs = append(errs, fmt.Errorf("senderSplits: %s also has a beneficiary", addr))
+		case grouped[common.HexToAddress(addr)] != "":
+			errs = append(errs, fmt.Errorf("senderSplits: %s is also in payout group %s", addr, grouped[common.HexToAddress(addr)]))
+		}
+		splitSenders[common.HexToAddress(addr)] = addr
+	}
+	for _, addr := range split {
+		shares := c.SenderSplits[addr]
+		if len(shares) < 2 {
+			errs = append(errs, fmt.Errorf("senderSplits: %s needs at least two shares (use beneficiaries to pay one address)", addr))
+		}
+		total := uint64(0)
+		payees := make(map[common.Address]bool)
+		for i, s := range shares {
+			name := fmt.Sprintf("senderSplits: %s[%d]", addr, i)
+			switch to := common.HexToAddress(s.Address); {
+			case !common.IsHexAddress(s.Address):
+				errs = append(errs, fmt.Errorf("%s: address: %q is not a valid address", name, s.Address))
+			case payees[to]:
+				errs = append(errs, fmt.Errorf("%s: %s is listed twice", name, s.Address))
+			case splitSenders[to] != "":
+				errs = append(errs, fmt.Errorf("%s: %s is split itself", name, s.Address))
+			}
+			payees[common.HexToAddress(s.Address)] = true
+			if strings.TrimSpace(s.Share) == "" {
+				errs = append(errs, fmt.Errorf("%s: share is required", name))
+				continue
+			}
+			rate, err := parseRate(s.Share)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: share: %w", name, err))
+				continue
+			}
+			total += rate
+		}
+		if total != scan.FullRate {
+			errs = append(errs, fmt.Errorf("senderSplits: %s's shares add up to %s, not 100%%", addr, scan.FormatRate(total)))
+		}
+	}
+
+	for i, n := range c.Notify {
+		for _, err := range n.validate() {
+			errs = append(errs, fmt.Errorf("notify[%d]: %w", i, err))
+		}
+	}
+	if c.Sheets != nil {
+		if c.Sheets.SpreadsheetID == "" {
+			errs = append(errs, fmt.Errorf("sheets: spreadsheetId is required"))
+		}
+		if c.Sheets.Credentials == "" {
+			errs = append(errs, fmt.Errorf("sheets: credentials is required"))
+		}
+	}
+	if c.IPFS != nil && c.IPFS.JWT == "" {
+		errs = append(errs, fmt.Errorf("ipfs: jwt is required"))
+	}
+	if d := c.Denylist; d != nil && d.File == "" && d.ChainalysisAPIKey == "" {
+		errs = append(errs, fmt.Errorf("denylist: file or chainalysisApiKey is required"))
+	}
+	if t := c.Tenderly; t != nil {
+		if t.Account == "" || t.Project == "" {
+			errs = append(errs, fmt.Errorf("tenderly: account and project are required"))
+		}
+		if t.AccessKey == "" {
+			errs = append(errs, fmt.Errorf("tenderly: accessKey is required"))
+		}
+	}
+	if g := c.Governance; g != nil {
+		if g.SnapshotSpace == "" {
+			errs = append(errs, fmt.Errorf("governance: snapshotSpace is required"))
+		}
+		if _, err := g.SnapshotOptions(); err != nil {
+			errs = append(errs, fmt.Errorf("governance: %w", err))
+		}
+	}
+	if c.ArtifactsURL != "" && !strings.HasPrefix(c.ArtifactsURL, "http://") && !strings.HasPrefix(c.ArtifactsURL, "https://") {
+		errs = append(errs, fmt.Errorf("artifactsUrl: %q is not an http(s) URL", c.ArtifactsURL))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (n NotifyConfig) validate() []error {
+	var errs []error
+	switch n.Kind {
+	case notify.KindDiscord, notify.KindSlack:
+		if n.URL == "" {
+			errs = append(errs, fmt.Errorf("url is required for %s", n.Kind))
+		}
+		if n.BotToken != "" || n.ChatID != "" {
+			errs = append(errs, fmt.Errorf("botToken and chatId are only used with telegram"))
+		}
+	case notify.KindTelegram:
+		if n.BotToken == "" && n.URL == "" {
+			errs = append(errs, fmt.Errorf("botToken is required for telegram"))
+		}
+		if n.ChatID == "" {
+			errs = append(errs, fmt.Errorf("chatId is required for telegram"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("kind must be discord, slack, or telegram, got %q", n.Kind))
+	}
+	return errs
+}
+
+// Connects to the configured spreadsheet, or returns nil if there isn't one
+func (c *Config) SheetsClient() (*sheets.Client, error) {
+	if c.Sheets == nil {
+		return nil, nil
+	}
+	creds, err := sheets.LoadCredentials(strings.TrimSpace(os.ExpandEnv(c.Sheets.Credentials)))
+	if err != nil {
+		return nil, err
+	}
+	api := c.Sheets.API
+	if api == "" {
+		api = sheets.API
+	}
+	return sheets.New(api, c.Sheets.SpreadsheetID, creds)
+}
+
+// The tabs rows are appended to, with defaults filled in
+func (s *SheetsConfig) Tabs() (transactions, recipients string) {
+	transactions, recipients = s.TransactionsTab, s.RecipientsTab
+	if transactions == "" {
+		transactions = "Transactions"
+	}
+	if recipients == "" {
+		recipients = "Recipients"
+	}
+	return transactions, recipients
+}
+
+// The configured pinning service, or nil if there isn't one
+func (c *Config) Pinner() *ipfs.Pinner {
+	if c.IPFS == nil {
+		return nil
+	}
+	api := c.IPFS.API
+	if api == "" {
+		api = ipfs.PinataAPI
+	}
+	return ipfs.New(api, strings.TrimSpace(os.ExpandEnv(c.IPFS.JWT)))
+}
+
+// Screens with the configured denylist file and Chainalysis API, or returns
+// nil if there's no denylist
+func (c *Config) Screener() (screen.Screener, error) {
+	if c.Denylist == nil {
+		return nil, nil
+	}
+	var all screen.All
+	if path := strings.TrimSpace(os.ExpandEnv(c.Denylist.File)); path != "" {
+		list, err := screen.LoadList(path)
+		if err != nil {
+			return nil, fmt.Errorf("denylist: %w", err)
+		}
+		all = append(all, list)
+	}
+	if key := strings.TrimSpace(os.ExpandEnv(c.Denylist.ChainalysisAPIKey)); key != "" {
+		api := c.Denylist.ChainalysisAPI
+		if api == "" {
+			api = screen.ChainalysisAPI
+		}
+		all = append(all, screen.NewChainalysis(api, key))
+	}
+	return all, nil
+}
+
+// The configured Tenderly project, or nil if there isn't one
+func (c *Config) TenderlySimulator() *simulate.Tenderly {
+	if c.Tenderly == nil {
+		return nil
+	}
+	api := c.Tenderly.API
+	if api == "" {
+		api = simulate.TenderlyAPI
+	}
+	return simulate.NewTenderly(api, c.Tenderly.Account, c.Tenderly.Project, strings.TrimSpace(os.ExpandEnv(c.Tenderly.AccessKey)))
+}
+
+// A gateway link to a pinned artifact
+func (c *Config) IPFSLink(cid, name string) string {
+	gateway := c.IPFS.Gateway
+	if gateway == "" {
+		gateway = ipfs.PublicGateway
+	}
+	return ipfs.GatewayURL(gateway, cid, name)
+}
+
+// The Snapshot space and voting window, with defaults filled in
+func (g *GovernanceConfig) SnapshotOptions() (governance.SnapshotOptions, error) {
+	opts := governance.SnapshotOptions{Space: g.SnapshotSpace, Discussion: g.Discussion, Period: 72 * time.Hour}
+	var err error
+	if g.VotingDelay != "" {
+		if opts.Delay, err = parseInterval(g.VotingDelay); err != nil {
+			return opts, fmt.Errorf("votingDelay: %w", err)
+		}
+	}
+	if g.VotingPeriod != "" {
+		if opts.Period, err = parseInterval(g.VotingPeriod); err != nil {
+			return opts, fmt.Errorf("votingPeriod: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// The configured webhooks with env vars expanded
+func (c *Config) Webhooks() []notify.Webhook {
+	var hooks []notify.Webhook
+	for _, n := range c.Notify {
+		hook := notify.Webhook{Kind: n.Kind, URL: strings.TrimSpace(os.ExpandEnv(n.URL)), ChatID: n.ChatID}
+		if n.Kind == notify.KindTelegram && n.BotToken != "" {
+			hook.URL = notify.TelegramURL(strings.TrimSpace(os.ExpandEnv(n.BotToken)))
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+func (e ExclusionConfig) validate() []error {
+	var errs []error
+	switch {
+	case e.Tx == "" && e.Address == "":
+		errs = append(errs, fmt.Errorf("one of tx or address is required"))
+	case e.Tx != "" && e.Address != "":
+		errs = append(errs, fmt.Errorf("only one of tx or address can be set"))
+	case e.Tx != "" && !isHexHash(e.Tx):
+		errs = append(errs, fmt.Errorf("tx: %q is not a 32-byte hex hash", e.Tx))
+	case e.Address != "" && !common.IsHexAddress(e.Address):
+		errs = append(errs, fmt.Errorf("address: %q is not a valid address", e.Address))
+	}
+	if strings.TrimSpace(e.Reason) == "" {
+		errs = append(errs, fmt.Errorf("reason is required"))
+	}
+	return errs
+}
+
+// The allowlisted senders, keyed for lookup
+func (c *Config) Allowed() map[common.Address]bool {
+	allowed := make(map[common.Address]bool)
+	for _, addr := range c.Allow {
+		allowed[common.HexToAddress(addr)] = true
+	}
+	return allowed
+}
+
+// The configured address labels, keyed for lookup
+func (c *Config) AddressLabels() scan.Labels {
+	labels := make(scan.Labels)
+	for addr, label := range c.Labels {
+		labels[common.HexToAddress(addr)] = strings.TrimSpace(label)
+	}
+	for name, g := range c.PayoutGroups {
+		if _, ok := labels[common.HexToAddress(g.Address)]; !ok {
+			labels[common.HexToAddress(g.Address)] = strings.TrimSpace(name)
+		}
+	}
+	return labels
+}
+
+// The configured beneficiaries and payout groups' addresses, keyed by the
+// sender they're paid in place of
+func (c *Config) BeneficiaryAddresses() map[common.Address]common.Address {
+	beneficiaries := make(map[common.Address]common.Address)
+	for addr, to := range c.Beneficiaries {
+		beneficiaries[common.HexToAddress(addr)] = common.HexToAddress(to)
+	}
+	for _, g := range c.PayoutGroups {
+		for _, addr := range g.Senders {
+			// A group can be paid to one of its own senders
+			if common.HexToAddress(addr) != common.HexToAddress(g.Address) {
+				beneficiaries[common.HexToAddress(addr)] = common.HexToAddress(g.Address)
+			}
+		}
+	}
+	return beneficiaries
+}
+
+// The configured sender splits, keyed by the sender they divide
+func (c *Config) SenderShares() map[common.Address][]scan.SenderShare {
+	splits := make(map[common.Address][]scan.SenderShare)
+	for addr, shares := range c.SenderSplits {
+		for _, s := range shares {
+			rate, _ := parseRate(s.Share)
+			splits[common.HexToAddress(addr)] = append(splits[common.HexToAddress(addr)], scan.SenderShare{To: common.HexToAddress(s.Address), Rate: rate})
+		}
+	}
+	return splits
+}
+
+// The configured rules, compiled, in order
+func (c *Config) ScanRules() ([]scan.Rule, error) {
+	var rules []scan.Rule
+	for _, r := range c.Rules {
+		var cap *big.Int
+		if r.Cap != "" {
+			var err error
+			if cap, err = parseEther(r.Cap); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid cap: %w", r.Name, err)
+			}
+		}
+		rule, err := scan.CompileRule(r.Name, r.When, scan.RuleAction(r.Action), cap, r.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// The configured classifiers, in order. Their processes start when a scan
+// first needs them and are shared by every chain.
+func (c *Config) ScanClassifiers() []scan.Classifier {
+	var classifiers []scan.Classifier
+	for _, cl := range c.Classifiers {
+		command := make([]string, len(cl.Command))
+		for i, arg := range cl.Command {
+			command[i] = os.ExpandEnv(arg)
+		}
+		classifiers = append(classifiers, scan.NewProcessClassifier(cl.Name, command))
+	}
+	return classifiers
+}
+
+// The configured exclusions, keyed for lookup
+func (c *Config) Exclusions() scan.Exclusions {
+	ex := scan.Exclusions{Txs: make(map[common.Hash]string), Senders: make(map[common.Address]string)}
+	for _, e := range c.Exclude {
+		if e.Tx != "" {
+			ex.Txs[common.HexToHash(e.Tx)] = e.Reason
+		} else {
+			ex.Senders[common.HexToAddress(e.Address)] = e.Reason
+		}
+	}
+	return ex
+}
+
+func (r RuleConfig) validate() []error {
+	var errs []error
+	if strings.TrimSpace(r.Name) == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	}
+	if strings.TrimSpace(r.When) == "" {
+		errs = append(errs, fmt.Errorf("when is required"))
+	} else if _, err := scan.CompileRule(r.Name, r.When, scan.RuleAction(r.Action), nil, r.Reason); err != nil {
+		errs = append(errs, fmt.Errorf("when: %w", err))
+	}
+	switch scan.RuleAction(r.Action) {
+	case scan.RuleInclude, scan.RuleExclude:
+		if r.Cap != "" {
+			errs = append(errs, fmt.Errorf("cap only applies to action cap"))
+		}
+	case scan.RuleCap:
+		if r.Cap == "" {
+			errs = append(errs, fmt.Errorf("action cap needs a cap"))
+		} else if _, err := parseEther(r.Cap); err != nil {
+			errs = append(errs, fmt.Errorf("cap: %w", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("action: %q is not include, exclude, or cap", r.Action))
+	}
+	if r.Reason != "" && scan.RuleAction(r.Action) != scan.RuleExclude {
+		errs = append(errs, fmt.Errorf("reason only applies to action exclude"))
+	}
+	return errs
+}
+
+func (c ChainConfig) validate() []error {
+	var errs []error
+	if strings.TrimSpace(c.Name) == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	}
+	if c.ChainID == 0 {
+		errs = append(errs, fmt.Errorf("chainId is required"))
+	}
+	if c.ExplorerURL() == "" {
+		errs = append(errs, fmt.Errorf("explorer is required for chain ID %d", c.ChainID))
+	}
+	switch c.Gas() {
+	case scan.GasModelEthereum, scan.GasModelOptimism, scan.GasModelArbitrum:
+	default:
+		errs = append(errs, fmt.Errorf("unknown gasModel %q", c.GasModel))
+	}
+	if c.PriceFeed != "" && !common.IsHexAddress(c.PriceFeed) {
+		errs = append(errs, fmt.Errorf("priceFeed: %q is not a valid address", c.PriceFeed))
+	}
+	if c.USDC != "" && !common.IsHexAddress(c.USDC) {
+		errs = append(errs, fmt.Errorf("usdc: %q is not a valid address", c.USDC))
+	}
+	for field, addr := range map[string]string{"jbx": c.JBX, "jbxPool": c.JBXPool, "weth": c.WETH} {
+		if addr != "" && !common.IsHexAddress(addr) {
+			errs = append(errs, fmt.Errorf("%s: %q is not a valid address", field, addr))
+		}
+	}
+	if c.JBXRate != "" {
+		if _, err := parseTokenRate(c.JBXRate); err != nil {
+			errs = append(errs, fmt.Errorf("jbxRate: %w", err))
+		}
+	}
+	switch c.TerminalVersion {
+	case 0, 3, 4:
+	default:
+		errs = append(errs, fmt.Errorf("terminalVersion must be 3 or 4, got %d", c.TerminalVersion))
+	}
+	switch c.ProtocolVersion {
+	case 0:
+	case 3, 4:
+		if _, ok := juiceboxDeployments[c.ProtocolVersion][c.ChainID]; !ok {
+			errs = append(errs, fmt.Errorf("protocolVersion: no Juicebox v%d contracts are built in for chain ID %d", c.ProtocolVersion, c.ChainID))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("protocolVersion must be 3 or 4, got %d", c.ProtocolVersion))
+	}
+	for field, addr := range map[string]string{"safe": c.Safe, "multiSend": c.MultiSend, "terminal": c.Terminal, "claimContract": c.ClaimContract, "sablierLockup": c.SablierLockup, "split": c.Split, "splitMain": c.SplitMain} {
+		if addr != "" && !common.IsHexAddress(addr) {
+			errs = append(errs, fmt.Errorf("%s: %q is not a valid address", field, addr))
+		}
+	}
+	if c.MaxPerRecipient != "" {
+		if _, err := parseEther(c.MaxPerRecipient); err != nil {
+			errs = append(errs, fmt.Errorf("maxPerRecipient: %w", err))
+		}
+	}
+	if c.MinPayout != "" {
+		if _, err := parseEther(c.MinPayout); err != nil {
+			errs = append(errs, fmt.Errorf("minPayout: %w", err))
+		}
+	}
+	if c.MaxGasPrice != "" {
+		if _, err := parseGwei(c.MaxGasPrice); err != nil {
+			errs = append(errs, fmt.Errorf("maxGasPrice: %w", err))
+		}
+	}
+	if c.MaxTip != "" {
+		if _, err := parseGwei(c.MaxTip); err != nil {
+			errs = append(errs, fmt.Errorf("maxTip: %w", err))
+		}
+	}
+	switch c.MaxTipAction {
+	case "", "flag", "cap":
+	default:
+		errs = append(errs, fmt.Errorf("maxTipAction: %q is not flag or cap", c.MaxTipAction))
+	}
+	if c.FromBlock != nil && c.ToBlock != nil && *c.ToBlock < *c.FromBlock {
+		errs = append(errs, fmt.Errorf("toBlock %d is before fromBlock %d", *c.ToBlock, *c.FromBlock))
+	}
+
+	if len(c.Groups) == 0 && len(c.Safes) == 0 && c.ProtocolVersion == 0 {
+		errs = append(errs, fmt.Errorf("no groups, safes, or protocolVersion defined"))
+	}
+	safes := make(map[common.Address]bool)
+	for i, s := range c.Safes {
+		name := fmt.Sprintf("safes[%d]", i)
+		switch {
+		case !common.IsHexAddress(s.Address):
+			errs = append(errs, fmt.Errorf("%s: address: %q is not a valid address", name, s.Address))
+		case safes[common.HexToAddress(s.Address)]:
+			errs = append(errs, fmt.Errorf("%s: %s is listed twice", name, s.Address))
+		}
+		safes[common.HexToAddress(s.Address)] = true
+		if strings.TrimSpace(s.Name) == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", name))
+		}
+		if s.Rate != "" {
+			if _, err := parseRate(s.Rate); err != nil {
+				errs = append(errs, fmt.Errorf("%s: rate: %w", name, err))
+			}
+		}
+	}
+	relayers := make(map[common.Address]bool)
+	for i, r := range c.Relayers {
+		name := fmt.Sprintf("relayers[%d]", i)
+		switch {
+		case !common.IsHexAddress(r.Address):
+			errs = append(errs, fmt.Errorf("%s: address: %q is not a valid address", name, r.Address))
+		case relayers[common.HexToAddress(r.Address)]:
+			errs = append(errs, fmt.Errorf("%s: %s is listed twice", name, r.Address))
+		}
+		relayers[common.HexToAddress(r.Address)] = true
+		if r.Payer != "" && !common.IsHexAddress(r.Payer) {
+			errs = append(errs, fmt.Errorf("%s: payer: %q is not a valid address", name, r.Payer))
+		}
+	}
+	ids := make(map[string]bool)
+	for i, item := range c.LineItems {
+		name := fmt.Sprintf("lineItems[%d]", i)
+		switch id := strings.TrimSpace(item.ID); {
+		case id == "":
+			errs = append(errs, fmt.Errorf("%s: id is required", name))
+		case ids[id]:
+			errs = append(errs, fmt.Errorf("%s: id %q is used twice", name, id))
+		}
+		ids[strings.TrimSpace(item.ID)] = true
+		if !common.IsHexAddress(item.Address) {
+			errs = append(errs, fmt.Errorf("%s: address: %q is not a valid address", name, item.Address))
+		}
+		if wei, err := parseEther(item.Amount); err != nil {
+			errs = append(errs, fmt.Errorf("%s: amount: %w", name, err))
+		} else if wei.Sign() == 0 {
+			errs = append(errs, fmt.Errorf("%s: amount must be more than 0", name))
+		}
+		if strings.TrimSpace(item.Memo) == "" {
+			errs = append(errs, fmt.Errorf("%s: memo is required", name))
+		}
+	}
+	for i, g := range c.Groups {
+		name := fmt.Sprintf("groups[%d]", i)
+		if g.Label != "" {
+			name += fmt.Sprintf(" (%q)", g.Label)
+		}
+		for _, err := range g.validate() {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		if g.Type == GroupApproveHash && len(g.Addresses) == 0 && len(c.safeAddresses()) == 0 {
+			errs = append(errs, fmt.Errorf("%s: addresses are required when the chain has no safe or safes", name))
+		}
+	}
+
+	return errs
+}
+
+// The chain's line items, in config order
+func (c ChainConfig) Items() ([]scan.LineItem, error) {
+	var items []scan.LineItem
+	for _, item := range c.LineItems {
+		wei, err := parseEther(item.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("line item %q: %w", item.ID, err)
+		}
+		items = append(items, scan.LineItem{ID: strings.TrimSpace(item.ID), Recipient: common.HexToAddress(item.Address), Wei: wei, Memo: strings.TrimSpace(item.Memo)})
+	}
+	return items, nil
+}
+
+// The chain's relayers, each mapped to its payer (zero if it has none)
+func (c ChainConfig) RelayPayers() map[common.Address]common.Address {
+	payers := make(map[common.Address]common.Address)
+	for _, r := range c.Relayers {
+		var payer common.Address
+		if r.Payer != "" {
+			payer = common.HexToAddress(r.Payer)
+		}
+		payers[common.HexToAddress(r.Address)] = payer
+	}
+	return payers
+}
+
+func (c ChainConfig) ExplorerURL() string {
+	if c.Explorer != "" {
+		return strings.TrimSuffix(c.Explorer, "/")
+	}
+	return explorers[c.ChainID]
+}
+
+func (c ChainConfig) Gas() scan.GasModel {
+	if c.GasModel != "" {
+		return c.GasModel
+	}
+	if m, ok := scan.DefaultGasModels[c.ChainID]; ok {
+		return m
+	}
+	return scan.GasModelEthereum
+}
+
+// The chain's Chainlink ETH/USD feed, or nil if there is none
+func (c ChainConfig) Feed() *common.Address {
+	feed := c.PriceFeed
+	if feed == "" {
+		feed = scan.DefaultChainlinkFeeds[c.ChainID]
+	}
+	if feed == "" {
+		return nil
+	}
+	addr := common.HexToAddress(feed)
+	return &addr
+}
+
+// The chain's USDC token, or nil if there is none
+func (c ChainConfig) USDCAddress() *common.Address {
+	token := c.USDC
+	if token == "" {
+		token = usdcAddresses[c.ChainID]
+	}
+	if token == "" {
+		return nil
+	}
+	addr := common.HexToAddress(token)
+	return &addr
+}
+
+// Paying out in JBX, at jbxRate if set or jbxPool's TWAP over window
+// otherwise, or nil if the chain has no JBX token or no way to price it
+func (c ChainConfig) JBXPayout(rate string, window time.Duration) (*scan.TokenPayout, error) {
+	token := c.JBX
+	if token == "" {
+		token = jbxAddresses[c.ChainID]
+	}
+	if token == "" {
+		return nil, fmt.Errorf("no jbx token address for chain ID %d", c.ChainID)
+	}
+	payout := &scan.TokenPayout{
+		Token:  scan.PayoutToken{Symbol: "JBX", Address: common.HexToAddress(token), Decimals: 18},
+		Window: window,
+	}
+
+	if rate == "" {
+		rate = c.JBXRate
+	}
+	if rate != "" {
+		var err error
+		if payout.Rate, err = parseTokenRate(rate); err != nil {
+			return nil, fmt.Errorf("invalid JBX rate: %w", err)
+		}
+		return payout, nil
+	}
+
+	weth := c.WETH
+	if weth == "" {
+		weth = wethAddresses[c.ChainID]
+	}
+	if c.JBXPool == "" || weth == "" {
+		return nil, fmt.Errorf("no JBX rate (set jbxRate or --jbx-rate, or jbxPool and weth for a TWAP)")
+	}
+	payout.Pool, payout.WETH = common.HexToAddress(c.JBXPool), common.HexToAddress(weth)
+	return payout, nil
+}
+
+func (c ChainConfig) SafeServiceURL() string {
+	if c.SafeService != "" {
+		return strings.TrimSuffix(c.SafeService, "/")
+	}
+	return safe.DefaultServices[c.ChainID]
+}
+
+func (c ChainConfig) MultiSendAddress() common.Address {
+	if c.MultiSend != "" {
+		return common.HexToAddress(c.MultiSend)
+	}
+	return common.HexToAddress(bundle.DefaultMultiSend)
+}
+
+// The chain's fork URL with env vars expanded, defaulted
+func (c ChainConfig) Fork() string {
+	if u := strings.TrimSpace(os.ExpandEnv(c.ForkURL)); u != "" {
+		return u
+	}
+	return defaultForkURL
+}
+
+// The chain's subgraph URL with env vars expanded
+func (c ChainConfig) Subgraph() string {
+	return strings.TrimSpace(os.ExpandEnv(c.SubgraphURL))
+}
+
+// The chain's RPC endpoints with env vars expanded, in failover order
+func (c ChainConfig) Endpoints() []string {
+	var urls []string
+	for _, u := range append([]string{c.RPCURL}, c.RPCURLs...) {
+		if u = strings.TrimSpace(os.ExpandEnv(u)); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// The chain's Juicebox terminal, or nil if there is none
+func (c ChainConfig) JuiceboxTerminal() *scan.JuiceboxTerminal {
+	if c.Terminal == "" {
+		return nil
+	}
+	t := &scan.JuiceboxTerminal{Address: common.HexToAddress(c.Terminal), Version: 3, ProjectID: c.juiceboxProject()}
+	if c.TerminalVersion != 0 {
+		t.Version = c.TerminalVersion
+	} else if c.ProtocolVersion != 0 {
+		t.Version = c.ProtocolVersion
+	}
+	return t
+}
+
+func (c ChainConfig) juiceboxProject() uint64 {
+	if c.ProjectID != nil {
+		return *c.ProjectID
+	}
+	return defaultJuiceboxProject
+}
+
+// The built-in groups for protocolVersion: the project's payouts from the
+// version's terminals and its reserved tokens from its controllers
+func (c ChainConfig) protocolGroups() []GroupConfig {
+	deployment, ok := juiceboxDeployments[c.ProtocolVersion][c.ChainID]
+	if !ok {
+		return nil
+	}
+	project := c.juiceboxProject()
+	name := fmt.Sprintf("project %d", project)
+	if project == defaultJuiceboxProject {
+		name = "JuiceboxDAO"
+	}
+	verb := "Distribute"
+	if c.ProtocolVersion >= 4 {
+		verb = "Send"
+	}
+	events := juiceboxEvents[c.ProtocolVersion]
+	return []GroupConfig{
+		{
+			Label:      fmt.Sprintf("%s %s payouts", verb, name),
+			Addresses:  deployment.Terminals,
+			Topics:     [][]string{{events.Payouts}},
+			ProjectIDs: []uint64{project},
+		},
+		{
+			Label:      fmt.Sprintf("%s %s reserved tokens", verb, name),
+			Addresses:  deployment.Controllers,
+			Topics:     [][]string{{events.Reserved}},
+			ProjectIDs: []uint64{project},
+		},
+	}
+}
+
+// Finds the chain with the given ID, if one is configured
+func (c *Config) ChainByID(chainID uint64) (ChainConfig, bool) {
+	for _, chain := range c.Chains {
+		if chain.ChainID == chainID {
+			return chain, true
+		}
+	}
+	return ChainConfig{}, false
+}
+
+func (g GroupConfig) validate() []error {
+	var errs []error
+	if strings.TrimSpace(g.Label) == "" {
+		errs = append(errs, fmt.Errorf("label is required"))
+	}
+
+	for j, a := range g.Addresses {
+		if !common.IsHexAddress(a) {
+			errs = append(errs, fmt.Errorf("addresses[%d]: %q is not a valid address", j, a))
+		}
+	}
+
+	switch g.Type {
+	case "", GroupEvents:
+		if len(g.Addresses) == 0 {
+			errs = append(errs, fmt.Errorf("at least one address is required"))
+		}
+		if (len(g.Topics) == 0 || len(g.Topics[0]) == 0) && !(g.Traces && len(g.Topics) == 0) {
+			errs = append(errs, fmt.Errorf("topics[0] must contain at least one event signature or topic hash"))
+		}
+	case GroupApproveHash:
+		if len(g.Topics) > 0 || len(g.ProjectIDs) > 0 || g.IncludeFailed || g.Traces || len(g.Calls) > 0 || g.Subgraph != nil {
+			errs = append(errs, fmt.Errorf("approveHash groups only match ApproveHash events, so can't set topics, projectIds, includeFailed, traces, calls, or subgraph"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown type %q (use %s or %s)", g.Type, GroupEvents, GroupApproveHash))
+	}
+	if len(g.Topics) > 4 {
+		errs = append(errs, fmt.Errorf("at most 4 topic positions are allowed, got %d", len(g.Topics)))
+	}
+	for j, position := range g.Topics {
+		for k, t := range position {
+			if _, err := parseTopic(t); err != nil {
+				errs = append(errs, fmt.Errorf("topics[%d][%d]: %w", j, k, err))
+			}
+		}
+	}
+
+	if len(g.ProjectIDs) > 0 {
+		pos := g.projectIDTopic()
+		if pos < 1 || pos > 3 {
+			errs = append(errs, fmt.Errorf("projectIdTopic must be between 1 and 3, got %d", pos))
+		} else if pos < len(g.Topics) && len(g.Topics[pos]) > 0 {
+			errs = append(errs, fmt.Errorf("topics[%d] is already set; it can't also be used for projectIds", pos))
+		}
+	}
+
+	if len(g.Calls) > 0 && !g.IncludeFailed && !g.Traces {
+		errs = append(errs, fmt.Errorf("calls only applies with includeFailed or traces"))
+	}
+	for j, call := range g.Calls {
+		if _, err := parseSelector(call); err != nil {
+			errs = append(errs, fmt.Errorf("calls[%d]: %w", j, err))
+		}
+	}
+
+	if g.Rate != "" {
+		if _, err := parseRate(g.Rate); err != nil {
+			errs = append(errs, fmt.Errorf("rate: %w", err))
+		}
+	}
+
+	if g.Subgraph != nil {
+		if err := g.subgraphQuery().Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("subgraph: %w", err))
+		}
+	}
+
+	return errs
+}
+
+func (g GroupConfig) subgraphQuery() scan.SubgraphQuery {
+	return scan.SubgraphQuery{Entity: strings.TrimSpace(g.Subgraph.Entity), Where: g.Subgraph.Where}
+}
+
+func (g GroupConfig) projectIDTopic() int {
+	if g.ProjectIDTopic == 0 {
+		return defaultProjectIDTopic
+	}
+	return g.ProjectIDTopic
+}
+
+// Every Safe configured for the chain: safes, then safe if it isn't one of
+// them. Invalid addresses are skipped.
+func (c ChainConfig) safeAddresses() []common.Address {
+	var addrs []common.Address
+	for _, s := range append(c.Safes, SafeConfig{Address: c.Safe}) {
+		if !common.IsHexAddress(s.Address) {
+			continue
+		}
+		if addr := common.HexToAddress(s.Address); !slices.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// The groups to scan the chain for: one per Safe first, so a Safe
+// transaction that also matches another group is attributed to the Safe,
+// then the protocolVersion's built-in groups, then the configured groups
+func (c ChainConfig) TxGroups() []scan.TxGroup {
+	var groups []scan.TxGroup
+	for _, s := range c.Safes {
+		groups = append(groups, s.TxGroup())
+	}
+	for _, g := range c.protocolGroups() {
+		groups = append(groups, g.TxGroup())
+	}
+	for _, g := range c.Groups {
+		group := g.TxGroup()
+		if g.Type == GroupApproveHash && len(group.Addresses) == 0 {
+			group.Addresses = c.safeAddresses()
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// The Safe's group: its ExecutionSuccess events, and ExecutionFromModuleSuccess
+// for what its modules execute, labeled with its name
+func (s SafeConfig) TxGroup() scan.TxGroup {
+	addr := common.HexToAddress(s.Address)
+	name := strings.TrimSpace(s.Name)
+	rate, _ := parseRate(s.Rate)
+	return scan.TxGroup{
+		Label:       "Execute " + name + " tx",
+		ModuleLabel: "Execute " + name + " tx via module",
+		Addresses:   []common.Address{addr},
+		Topics:      [][]common.Hash{{scan.ExecutionSuccessTopic, scan.ExecutionFromModuleSuccessTopic}},
+		Safe:        &addr,
+		Rate:        rate,
+	}
+}
+
+// Converts a validated group config into a TxGroup
+func (g GroupConfig) TxGroup() scan.TxGroup {
+	group := scan.TxGroup{Label: g.Label, IncludeFailed: g.IncludeFailed, Traces: g.Traces}
+	group.Rate, _ = parseRate(g.Rate)
+	for _, call := range g.Calls {
+		selector, _ := parseSelector(call)
+		group.CallSelectors = append(group.CallSelectors, selector)
+	}
+	if g.Subgraph != nil {
+		q := g.subgraphQuery()
+		group.Subgraph = &q
+	}
+
+	for _, a := range g.Addresses {
+		group.Addresses = append(group.Addresses, common.HexToAddress(a))
+	}
+	if g.Type == GroupApproveHash {
+		group.Topics = [][]common.Hash{{scan.ApproveHashTopic}}
+	}
+
+	for _, position := range g.Topics {
+		hashes := []common.Hash{}
+		for _, t := range position {
+			hash, _ := parseTopic(t)
+			hashes = append(hashes, hash)
+		}
+		group.Topics = append(group.Topics, hashes)
+	}
+	// Groups matching module executions tell them apart from the rest
+	if len(group.Topics) > 0 && slices.Contains(group.Topics[0], scan.ExecutionFromModuleSuccessTopic) {
+		group.ModuleLabel = g.Label + " via module"
+	}
+
+	if len(g.ProjectIDs) > 0 {
+		pos := g.projectIDTopic()
+		for len(group.Topics) <= pos {
+			group.Topics = append(group.Topics, []common.Hash{})
+		}
+		for _, id := range g.ProjectIDs {
+			topic := scan.UintTopic(new(big.Int).SetUint64(id))
+			group.Topics[pos] = append(group.Topics[pos], topic)
+			if g.IncludeFailed || g.Traces {
+				group.CallArgs = append(group.CallArgs, topic)
+			}
+		}
+	}
+
+	return group
+}
+
+// Parses a topic filter value: a 32-byte hex hash, an event signature like
+// "DistributePayouts(uint256,uint256,...)", or an indexed value written as
+// "uint:<n>" or "address:<0x...>"
+func parseTopic(s string) (common.Hash, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case isHexHash(s):
+		return common.HexToHash(s), nil
+	case strings.Contains(s, "("):
+		return scan.EventTopic(s)
+	}
+
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok && strings.HasPrefix(s, "0x") {
+		return common.Hash{}, fmt.Errorf("%q is %d hex digits; topic hashes are 32 bytes (64 digits)", s, len(s)-2)
+	}
+	if !ok {
+		return common.Hash{}, fmt.Errorf("%q is not a 32-byte hex hash, event signature, or uint:/address: value", s)
+	}
+	value = strings.TrimSpace(value)
+	switch strings.TrimSpace(kind) {
+	case "uint", "uint256":
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+			return common.Hash{}, fmt.Errorf("%q is not a valid uint256", value)
+		}
+		return scan.UintTopic(n), nil
+	case "address":
+		if !common.IsHexAddress(value) {
+			return common.Hash{}, fmt.Errorf("%q is not a valid address", value)
+		}
+		return scan.AddressTopic(common.HexToAddress(value)), nil
+	}
+	return common.Hash{}, fmt.Errorf("unknown topic type %q (use uint or address)", kind)
+}
+
+// Parses a non-negative decimal ETH amount like "0.25" into wei
+func parseEther(s string) (*big.Int, error) {
+	return parseUnits(s, 18, "ETH")
+}
+
+// Parses a non-negative decimal gwei amount like "60" into wei
+func parseGwei(s string) (*big.Int, error) {
+	return parseUnits(s, 9, "gwei")
+}
+
+// Parses a positive decimal number of tokens per ETH like "350000"
+func parseTokenRate(s string) (*big.Float, error) {
+	rate, ok := new(big.Float).SetString(strings.TrimSpace(s))
+	if !ok || rate.Sign() <= 0 || rate.IsInf() {
+		return nil, fmt.Errorf("%q is not a positive number", s)
+	}
+	return rate, nil
+}
+
+// Parses a function signature or a 0x-prefixed 4-byte selector
+func parseSelector(s string) ([4]byte, error) {
+	s = strings.TrimSpace(s)
+	if hex, ok := strings.CutPrefix(s, "0x"); ok && len(hex) == 8 {
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return [4]byte{}, fmt.Errorf("%q is not a valid selector", s)
+		}
+		return [4]byte(b), nil
+	}
+	return scan.FunctionSelector(s)
+}
+
+// Parses a percentage like "50%" or "12.5" into basis points. Empty is 100%.
+func parseRate(s string) (uint64, error) {
+	if strings.TrimSpace(s) == "" {
+		return scan.FullRate, nil
+	}
+	bps, err := parseUnits(strings.TrimSuffix(strings.TrimSpace(s), "%"), 2, "percentage")
+	if err != nil {
+		return 0, err
+	}
+	if bps.Sign() == 0 || bps.Cmp(big.NewInt(scan.FullRate)) > 0 {
+		return 0, fmt.Errorf("%q must be above 0%% and at most 100%%", s)
+	}
+	return bps.Uint64(), nil
+}
+
+func parseUnits(s string, decimals int, unit string) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("%q has more than %d decimals", s, decimals)
+	}
+	wei, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", decimals-len(frac)), 10)
+	if !ok || wei.Sign() < 0 || whole == "" && frac == "" {
+		return nil, fmt.Errorf("%q is not a valid %s amount", s, unit)
+	}
+	return wei, nil
+}
+
+func isHexHash(s string) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s) != 2*common.HashLength {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var daemonFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "every",
+		Usage:   "time between runs, as a Go duration or a number of days (e.g. 14d)",
+		Value:   "14d",
+		EnvVars: []string{"EVERY"},
+	},
+	&cli.StringFlag{
+		Name:        "anchor",
+		Usage:       "align runs to this time (RFC 3339 or YYYY-MM-DD in UTC), e.g. the start of a funding cycle, so they happen at anchor + n * every",
+		DefaultText: "when the daemon starts",
+		EnvVars:     []string{"ANCHOR"},
+	},
+	&cli.BoolFlag{
+		Name:  "run-now",
+		Usage: "also run once at startup when --anchor is set",
+	},
+	&cli.BoolFlag{
+		Name:    "propose",
+		Usage:   "propose each bundle with transfers to the chain's Safe after writing it",
+		EnvVars: []string{"PROPOSE"},
+	},
+	&cli.StringFlag{
+		Name:    "metrics-listen",
+		Usage:   "address to serve Prometheus metrics on at /metrics",
+		EnvVars: []string{"METRICS_LISTEN"},
+	},
+}
+
+// Runs at a fixed interval, aligned to an anchor time
+type schedule struct {
+	every  time.Duration
+	anchor time.Time
+}
+
+// The first run time strictly after t
+func (s schedule) next(t time.Time) time.Time {
+	if t.Before(s.anchor) {
+		return s.anchor
+	}
+	n := t.Sub(s.anchor)/s.every + 1
+	return s.anchor.Add(n * s.every)
+}
+
+// Parses a Go duration, or a whole number of days like "14d"
+func parseInterval(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseUint(days, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid interval %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid interval %q", s)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("interval %q must be positive", s)
+	}
+	return d, nil
+}
+
+func parseAnchor(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid anchor %q (use RFC 3339 or YYYY-MM-DD)", s)
+	}
+	return t, nil
+}
+
+// Runs `run --since-last-run` on a schedule until interrupted. A failed run
+// is logged and retried at the next scheduled time.
+func daemonAction(c *cli.Context) error {
+	cfg, err := loadProfile(c)
+	if err != nil {
+		return err
+	}
+
+	every, err := parseInterval(c.String("every"))
+	if err != nil {
+		return err
+	}
+	sched := schedule{every: every, anchor: time.Now()}
+	runNow := true
+	if c.IsSet("anchor") {
+		if sched.anchor, err = parseAnchor(c.String("anchor")); err != nil {
+			return err
+		}
+		runNow = c.Bool("run-now")
+	}
+
+	// Every run picks up where the previous one left off
+	if err := c.Set("since-last-run", "true"); err != nil {
+		return err
+	}
+	if c.Bool("propose") && c.String("private-key") == "" {
+		return fmt.Errorf("--propose needs a signing key (use --private-key or the PROPOSER_PRIVATE_KEY env var)")
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if addr := c.String("metrics-listen"); addr != "" {
+		serveMetrics(addr)
+	}
+	if runNow {
+		daemonRun(ctx, c, cfg)
+	}
+	for {
+		next := sched.next(time.Now())
+		slog.Info("Waiting for the next run", "at", next.UTC().Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("Stopping")
+			return nil
+		case <-timer.C:
+		}
+		daemonRun(ctx, c, cfg)
+	}
+}
+
+// A directory under base named for the date of t, with the time appended if
+// an earlier run that day already used it
+func datedDir(base string, t time.Time) (name, dir string) {
+	t = t.UTC()
+	name = t.Format(time.DateOnly)
+	if _, err := os.Stat(filepath.Join(base, name)); err == nil {
+		name = t.Format("2006-01-02T150405Z")
+	}
+	return name, filepath.Join(base, name)
+}
+
+// A copy of cfg whose notification links point into the out dir's subdirectory name
+func configForDir(cfg *Config, name string) *Config {
+	runCfg := *cfg
+	if runCfg.ArtifactsURL != "" {
+		runCfg.ArtifactsURL = strings.TrimSuffix(runCfg.ArtifactsURL, "/") + "/" + name
+	}
+	return &runCfg
+}
+
+// One scheduled run, writing to <out-dir>/<date>
+func daemonRun(ctx context.Context, c *cli.Context, cfg *Config) {
+	started := time.Now()
+	name, dir := datedDir(c.String("out-dir"), started)
+
+	slog.Info("Starting run", "outDir", dir)
+	run := &RunRecord{Trigger: "daemon", OutDir: dir}
+	out, err := recordedRun(ctx, c, configForDir(cfg, name), run, true, true)
+	if err != nil {
+		slog.Error("Run failed", "id", run.ID, "err", err)
+	}
+	if out == nil {
+		// Dry run, or nothing was written
+		return
+	}
+	if err == nil {
+		slog.Info("Run finished", "id", run.ID, "duration", time.Since(started).Round(time.Millisecond))
+	}
+
+	if !c.Bool("propose") {
+		return
+	}
+	for i, paths := range out.Bundles {
+		if len(paths) == 0 {
+			// Incomplete, so the next run retries it
+			continue
+		}
+		if len(out.Results[i].Txs) == 0 {
+			slog.Info("Nothing to reimburse, not proposing", "chain", out.Results[i].Chain.Name)
+			continue
+		}
+		// Each file of a split bundle is its own Safe transaction, at the
+		// next nonce after the last
+		for _, path := range paths {
+			proposeCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			if err := proposeBundle(proposeCtx, c, cfg, path); err != nil {
+				errorsTotal.Inc("propose")
+				slog.Error("Proposing failed", "chain", out.Results[i].Chain.Name, "bundle", path, "err", err)
+			}
+			cancel()
+		}
+	}
+}
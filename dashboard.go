@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"html/template"
+	"math/big"
+	"net/http"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/report"
+	"juimburser/pkg/scan"
+)
+
+//go:embed web/*.html
+var webTemplates embed.FS
+
+var dashboard = template.Must(template.New("").Funcs(template.FuncMap{
+	"eth": weiToETH,
+}).ParseFS(webTemplates, "web/*.html"))
+
+// Formats a decimal wei string as ETH, or returns it unchanged if it isn't one
+func weiToETH(wei string) string {
+	v, ok := new(big.Int).SetString(wei, 10)
+	if !ok {
+		return wei
+	}
+	return scan.FormatEther(v)
+}
+
+type indexPage struct {
+	Running *RunRecord
+	Runs    []RunRecord
+	// Successful runs oldest first, the columns of the recipient table
+	Periods    []RunRecord
+	Recipients []recipientRow
+}
+
+// A recipient's total in each period, across chains
+type recipientRow struct {
+	Address common.Address
+	// ETH per period, empty if the recipient had nothing that period
+	Periods []string
+	Total   string
+}
+
+type runPage struct {
+	Run    *RunRecord
+	Chains []chainView
+}
+
+type chainView struct {
+	report.JSONChainReport
+	Explorer string
+}
+
+type recipientPage struct {
+	Address common.Address
+	Total   string
+	Periods []recipientPeriod
+}
+
+// What one recipient was reimbursed on one chain in one run
+type recipientPeriod struct {
+	Run   RunRecord
+	Chain chainView
+	ETH   string
+	// Relative to the recipient's largest period, for the bar chart
+	Percent int
+	Txs     []report.JSONTx
+}
+
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.history.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := indexPage{Runs: runs}
+	s.mu.Lock()
+	page.Running = s.running
+	s.mu.Unlock()
+
+	totals := make(map[common.Address][]*big.Int)
+	for i := len(runs) - 1; i >= 0; i-- {
+		rep, err := s.history.Report(runs[i].ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if rep == nil {
+			continue
+		}
+
+		col := len(page.Periods)
+		page.Periods = append(page.Periods, runs[i])
+		for _, chain := range rep.Chains {
+			for _, recipient := range chain.Recipients {
+				if totals[recipient.Address] == nil {
+					totals[recipient.Address] = make([]*big.Int, 0, len(runs))
+				}
+				for len(totals[recipient.Address]) <= col {
+					totals[recipient.Address] = append(totals[recipient.Address], nil)
+				}
+				wei, _ := new(big.Int).SetString(recipient.TotalWei, 10)
+				if cell := totals[recipient.Address][col]; cell != nil {
+					wei.Add(wei, cell)
+				}
+				totals[recipient.Address][col] = wei
+			}
+		}
+	}
+
+	for _, addr := range scan.SortedAddresses(totals) {
+		row := recipientRow{Address: addr, Periods: make([]string, len(page.Periods))}
+		sum := big.NewInt(0)
+		for i, wei := range totals[addr] {
+			if wei != nil {
+				row.Periods[i] = scan.FormatEther(wei)
+				sum.Add(sum, wei)
+			}
+		}
+		row.Total = scan.FormatEther(sum)
+		page.Recipients = append(page.Recipients, row)
+	}
+
+	s.render(w, "index.html", page)
+}
+
+func (s *server) handleRunPage(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookupRun(w, r)
+	if !ok {
+		return
+	}
+	rep, err := s.history.Report(run.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := runPage{Run: run}
+	if rep != nil {
+		for _, chain := range rep.Chains {
+			page.Chains = append(page.Chains, s.chainView(chain))
+		}
+	}
+	s.render(w, "run.html", page)
+}
+
+func (s *server) handleRecipientPage(w http.ResponseWriter, r *http.Request) {
+	if !common.IsHexAddress(r.PathValue("address")) {
+		writeError(w, http.StatusBadRequest, errors.New("invalid address"))
+		return
+	}
+	addr := common.HexToAddress(r.PathValue("address"))
+
+	runs, err := s.history.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	page := recipientPage{Address: addr}
+	sum, largest := big.NewInt(0), big.NewInt(0)
+	var amounts []*big.Int
+	for i := len(runs) - 1; i >= 0; i-- {
+		rep, err := s.history.Report(runs[i].ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if rep == nil {
+			continue
+		}
+		for _, chain := range rep.Chains {
+			j := slices.IndexFunc(chain.Recipients, func(r report.JSONRecipient) bool { return r.Address == addr })
+			if j < 0 {
+				continue
+			}
+			wei, _ := new(big.Int).SetString(chain.Recipients[j].TotalWei, 10)
+			period := recipientPeriod{Run: runs[i], Chain: s.chainView(chain), ETH: scan.FormatEther(wei)}
+			for _, tx := range chain.Transactions {
+				if tx.From == addr {
+					period.Txs = append(period.Txs, tx)
+				}
+			}
+			page.Periods = append(page.Periods, period)
+			amounts = append(amounts, wei)
+			sum.Add(sum, wei)
+			if wei.Cmp(largest) > 0 {
+				largest = wei
+			}
+		}
+	}
+	if len(page.Periods) == 0 {
+		writeError(w, http.StatusNotFound, errors.New("no reimbursements to "+addr.Hex()))
+		return
+	}
+
+	for i, wei := range amounts {
+		if largest.Sign() > 0 {
+			pct := new(big.Int).Mul(wei, big.NewInt(100))
+			page.Periods[i].Percent = int(pct.Div(pct, largest).Int64())
+		}
+	}
+	page.Total = scan.FormatEther(sum)
+	s.render(w, "recipient.html", page)
+}
+
+// Adds the explorer from the config, or the chain's default
+func (s *server) chainView(chain report.JSONChainReport) chainView {
+	view := chainView{JSONChainReport: chain}
+	if id, ok := new(big.Int).SetString(chain.ChainID, 10); ok && id.IsUint64() {
+		cc, found := s.cfg.ChainByID(id.Uint64())
+		if !found {
+			cc = ChainConfig{ChainID: id.Uint64()}
+		}
+		view.Explorer = cc.ExplorerURL()
+	}
+	return view
+}
+
+func (s *server) render(w http.ResponseWriter, name string, data any) {
+	var buf bytes.Buffer
+	if err := dashboard.ExecuteTemplate(&buf, name, data); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
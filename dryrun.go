@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/bundle"
+	"juimburser/pkg/scan"
+)
+
+// Prints what a run would write: each chain's recipients and totals, and the
+// transfers its bundle would contain
+func printDryRun(w io.Writer, results []*scan.Result, multiSend bool, maxTransfers int) error {
+	for _, res := range results {
+		fmt.Fprintf(w, "%s (chain ID %s): blocks %s to %s, %d transactions", res.Chain.Name, res.Chain.ChainID, res.StartBlock, res.EndBlock, len(res.Txs))
+		if len(res.Excluded) > 0 {
+			fmt.Fprintf(w, ", %d excluded", len(res.Excluded))
+		}
+		if len(res.Errors) > 0 {
+			fmt.Fprintf(w, ", %d couldn't be fetched", len(res.Errors))
+		}
+		fmt.Fprintln(w)
+
+		totals, usdTotals, payable, over, carried := res.Totals(), res.USDTotals(), res.Payable(), res.OverCap(), res.CarriedOver()
+		for _, addr := range scan.SortedAddresses(totals) {
+			name := addr.Hex()
+			if label := res.Chain.Labels[addr]; label != "" {
+				name += " (" + label + ")"
+			}
+			line := fmt.Sprintf("  %s  %s ETH", name, scan.FormatEther(totals[addr]))
+			if usdTotals != nil {
+				line += fmt.Sprintf(" (%s)", scan.FormatUSD(usdTotals[addr]))
+			}
+			if res.Chain.SenderSplits[addr] != nil {
+				line += " -> split"
+			} else {
+				line += " -> " + res.Payout.Format(res.Payout.Amount(payable[addr]))
+			}
+			if held := over[addr]; held != nil {
+				line += fmt.Sprintf(", %s ETH held back", scan.FormatEther(held))
+			}
+			if carried[addr] != nil {
+				line += " (below the minimum payout, carried over)"
+			}
+			fmt.Fprintln(w, line)
+		}
+		if len(res.CarriedIn) > 0 {
+			total := big.NewInt(0)
+			for _, v := range res.CarriedIn {
+				total.Add(total, v)
+			}
+			fmt.Fprintf(w, "  Carried in from earlier runs: %s ETH to %d recipients\n", scan.FormatEther(total), len(res.CarriedIn))
+		}
+		for _, split := range res.SplitPayouts() {
+			for i, s := range split.Shares {
+				fmt.Fprintf(w, "  Split %s: %s (%s) to %s\n", res.Chain.Labels.Name(split.Sender), scan.FormatRate(s.Rate), res.Payout.Format(res.Payout.Amount(split.Wei[i])), res.Chain.Labels.Name(s.To))
+			}
+		}
+		for _, item := range res.LineItems {
+			fmt.Fprintf(w, "  Line item %s: %s ETH to %s, %s\n", item.ID, scan.FormatEther(item.Wei), res.Chain.Labels.Name(item.Recipient), item.Memo)
+		}
+
+		for _, t := range res.TypeTotals() {
+			rate := ""
+			if t.Rate != 0 {
+				rate = " at " + scan.FormatRate(t.Rate)
+			}
+			fmt.Fprintf(w, "  %s: %d transactions, %s ETH%s\n", t.Label, t.Txs, scan.FormatEther(t.GasWei), rate)
+		}
+		safes, other := res.SafeTotals()
+		for _, t := range safes {
+			fmt.Fprintf(w, "  Executed by %s: %d transactions, %s ETH\n", res.Chain.Labels.Name(t.Safe), t.Txs, scan.FormatEther(t.GasWei))
+		}
+		if len(safes) > 0 && other.Txs > 0 {
+			fmt.Fprintf(w, "  Not executed by a Safe: %d transactions, %s ETH\n", other.Txs, scan.FormatEther(other.GasWei))
+		}
+
+		builder := bundle.BundleBuilder{MaxTransfers: maxTransfers}
+		if multiSend {
+			builder.MultiSend = &res.Chain.MultiSend
+		}
+		parts, err := builder.BuildParts(res)
+		if err != nil {
+			return err
+		}
+
+		total := make(map[common.Address]*big.Int)
+		count, txs := 0, 0
+		for _, part := range parts {
+			transfers, err := bundle.Transfers(part.Bundle)
+			if err != nil {
+				return err
+			}
+			for _, t := range transfers {
+				if total[t.Token] == nil {
+					total[t.Token] = new(big.Int)
+				}
+				total[t.Token].Add(total[t.Token], t.Amount)
+			}
+			count += len(transfers)
+			txs += len(part.Bundle.Transactions)
+		}
+		fmt.Fprintf(w, "  Bundle: %d transfers in %d transactions", count, txs)
+		for _, token := range scan.SortedAddresses(total) {
+			amount := total[token]
+			if token == (common.Address{}) {
+				fmt.Fprintf(w, ", %s ETH", scan.FormatEther(amount))
+			} else {
+				fmt.Fprintf(w, ", %s", res.Payout.Format(amount))
+			}
+		}
+		if len(parts) > 1 {
+			fmt.Fprintf(w, ", split into %d files", len(parts))
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "Dry run: no files written")
+	return nil
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var (
+	update    = flag.Bool("update", false, "rewrite each golden case's want/ with what it writes now, instead of comparing")
+	record    = flag.Bool("record", false, "re-record each golden case's RPC fixtures from -rpc-url, then rewrite its want/")
+	recordURL = flag.String("rpc-url", "", "node to record golden cases from with -record, e.g. an anvil fork of mainnet")
+)
+
+// Set for the runs TestGolden makes of the test binary, which then runs as
+// juimburser instead of running the tests
+const goldenMainEnv = "JUIMBURSER_GOLDEN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(goldenMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Golden runs are made at this time (via SOURCE_DATE_EPOCH), so their
+// artifacts' dates and timestamps are the same every time
+const goldenEpoch = "1700000000"
+
+// Golden runs scan from the fixtures, so their endpoint is only a name, kept
+// the same so manifests are too
+const goldenRPCURL = "http://replay.invalid"
+
+// Bundle checksums cover the build version, which is masked in golden files
+var checksumPattern = regexp.MustCompile(`"checksum":"0x[0-9a-f]{64}"`)
+
+// A golden case: a directory holding the run's config.yaml, optionally args
+// (extra run flags, one per line, # for comments), the RPC fixtures in rpc/,
+// and the artifacts the run should write in want/
+type goldenCase struct {
+	name, dir string
+}
+
+func (g goldenCase) args() ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(g.dir, "args"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			args = append(args, line)
+		}
+	}
+	return args, nil
+}
+
+// Runs each case in testdata/golden from its fixtures and compares what it
+// writes to want/, failing on any difference. With -record, the fixtures are
+// first re-recorded from -rpc-url (e.g. an anvil fork pinned to the case's
+// blocks); with -record or -update, want/ is rewritten instead. Run a single
+// case with -run TestGolden/<case>.
+func TestGolden(t *testing.T) {
+	cases, err := goldenCases(filepath.Join("testdata", "golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gc := range cases {
+		t.Run(gc.name, func(t *testing.T) {
+			if *record {
+				if err := os.RemoveAll(filepath.Join(gc.dir, "rpc")); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := runGolden(exe, gc, true); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Replayed after recording too, so what's written is what
+			// replays will compare against, and a fixture missing calls
+			// fails now
+			got, err := runGolden(exe, gc, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *record || *update {
+				if err := writeGolden(filepath.Join(gc.dir, "want"), got); err != nil {
+					t.Fatal(err)
+				}
+				t.Logf("wrote %d files", len(got))
+				return
+			}
+
+			diffs, err := compareGolden(filepath.Join(gc.dir, "want"), got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range diffs {
+				t.Error(d)
+			}
+			if len(diffs) > 0 {
+				t.Log("rerun with -update if the changes are intended")
+			}
+		})
+	}
+}
+
+// The cases in dir
+func goldenCases(dir string) ([]goldenCase, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cases []goldenCase
+	for _, e := range entries {
+		if e.IsDir() {
+			cases = append(cases, goldenCase{name: e.Name(), dir: filepath.Join(dir, e.Name())})
+		}
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no golden cases in %s", dir)
+	}
+	return cases, nil
+}
+
+// Runs the case with the test binary as juimburser, in a scratch directory
+// with a clean environment, replaying its fixtures or recording them, and
+// returns what it wrote by file name, with the build version and the bundle
+// checksums covering it masked
+func runGolden(exe string, gc goldenCase, record bool) (map[string][]byte, error) {
+	scratch, err := os.MkdirTemp("", "juimburser-golden-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+	out := filepath.Join(scratch, "out")
+
+	args := []string{
+		"run",
+		"--config", "config.yaml",
+		"--out-dir", out,
+		"--state", filepath.Join(scratch, "state.json"),
+		"--history", filepath.Join(scratch, "history.db"),
+		"--plain-names",
+		"--no-pin", "--no-notify", "--no-sheets",
+	}
+	rpcURL := goldenRPCURL
+	if record {
+		if rpcURL = *recordURL; rpcURL == "" {
+			return nil, errors.New("-record needs -rpc-url")
+		}
+		args = append(args, "--record-rpc", "rpc")
+	} else {
+		args = append(args, "--replay-rpc", "rpc")
+	}
+	args = append(args, "--rpc-url", rpcURL)
+	extra, err := gc.args()
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, extra...)
+
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = gc.dir
+	cmd.Env = []string{goldenMainEnv + "=1", "PATH=" + os.Getenv("PATH"), "HOME=" + scratch, "SOURCE_DATE_EPOCH=" + goldenEpoch}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("run failed: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	got := make(map[string][]byte)
+	err = filepath.WalkDir(out, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(out, path)
+		got[filepath.ToSlash(name)] = maskGolden(data)
+		return nil
+	})
+	return got, err
+}
+
+// data with this build's version (the only thing a rerun of the same
+// fixtures changes) replaced, as written and as HTML reports escape it, and
+// its bundle checksum with it
+func maskGolden(data []byte) []byte {
+	v := version()
+	for _, written := range []string{v, strings.ReplaceAll(v, "+", "&#43;")} {
+		data = bytes.ReplaceAll(data, []byte(written), []byte("(golden)"))
+	}
+	return checksumPattern.ReplaceAll(data, []byte(`"checksum":"(golden)"`))
+}
+
+func writeGolden(dir string, got map[string][]byte) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	for name, data := range got {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The files in want that got is missing, doesn't match, or has in addition,
+// each with the first line that differs
+func compareGolden(want string, got map[string][]byte) ([]string, error) {
+	expected := make(map[string][]byte)
+	err := filepath.WalkDir(want, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(want, path)
+		expected[filepath.ToSlash(name)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range expected {
+		names[name] = true
+	}
+	for name := range got {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	slices.Sort(sorted)
+
+	var diffs []string
+	for _, name := range sorted {
+		w, inWant := expected[name]
+		g, inGot := got[name]
+		switch {
+		case !inGot:
+			diffs = append(diffs, name+": not written")
+		case !inWant:
+			diffs = append(diffs, name+": written, but not in want/")
+		case !bytes.Equal(w, g):
+			diffs = append(diffs, fmt.Sprintf("%s: %s", name, firstDiff(w, g)))
+		}
+	}
+	return diffs, nil
+}
+
+// Where want and got first differ, by line
+func firstDiff(want, got []byte) string {
+	wl, gl := strings.Split(string(want), "\n"), strings.Split(string(got), "\n")
+	for i := 0; i < max(len(wl), len(gl)); i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			// Long lines (bundles are one) are shown from just before the
+			// first byte that differs
+			col := 0
+			for col < min(len(w), len(g)) && w[col] == g[col] {
+				col++
+			}
+			return fmt.Sprintf("line %d, column %d is %q, want %q", i+1, col+1, excerpt(g, col), excerpt(w, col))
+		}
+	}
+	return "differs"
+}
+
+func excerpt(s string, from int) string {
+	from = max(0, min(from-20, len(s)))
+	if s = s[from:]; len(s) > 60 {
+		s = s[:60] + "..."
+	}
+	return s
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"juimburser/pkg/report"
+)
+
+const (
+	RunRunning = "running"
+	RunOK      = "ok"
+	RunFailed  = "failed"
+	// Wrote what it could, but some chains or transactions are missing
+	RunIncomplete = "incomplete"
+)
+
+var (
+	runsBucket = []byte("runs")
+	// Each successful run's report.json, keyed by run ID
+	reportsBucket = []byte("reports")
+)
+
+// A database of past runs. It's opened for each read or write so the CLI,
+// daemon, and server can share one file.
+type History struct {
+	path string
+}
+
+type RunRecord struct {
+	ID uint64 `json:"id"`
+	// cli, daemon, or api
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	OutDir     string     `json:"outDir"`
+	// Files written to OutDir
+	Artifacts []string   `json:"artifacts"`
+	Chains    []RunChain `json:"chains"`
+}
+
+type RunChain struct {
+	Name       string `json:"name"`
+	ChainID    string `json:"chainId"`
+	StartBlock uint64 `json:"startBlock"`
+	EndBlock   uint64 `json:"endBlock"`
+	TotalWei   string `json:"totalWei"`
+	Recipients int    `json:"recipients"`
+	Txs        int    `json:"txs"`
+}
+
+func (h History) update(name []byte, fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(h.path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("opening history %s: %w", h.path, err)
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		return fn(bucket)
+	})
+}
+
+func (h History) view(name []byte, fn func(*bolt.Bucket) error) error {
+	db, err := bolt.Open(h.path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("opening history %s: %w", h.path, err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(name)
+		if bucket == nil {
+			return nil
+		}
+		return fn(bucket)
+	})
+}
+
+func runKey(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+// Adds a run, assigning its ID
+func (h History) Start(run *RunRecord) error {
+	return h.update(runsBucket, func(b *bolt.Bucket) error {
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		run.ID = id
+		data, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		return b.Put(runKey(id), data)
+	})
+}
+
+// Overwrites a run already added with Start
+func (h History) Save(run *RunRecord) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return h.update(runsBucket, func(b *bolt.Bucket) error {
+		return b.Put(runKey(run.ID), data)
+	})
+}
+
+// Every run, newest first
+func (h History) List() ([]RunRecord, error) {
+	runs := []RunRecord{}
+	err := h.view(runsBucket, func(b *bolt.Bucket) error {
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var run RunRecord
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("run %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// The run with id, or nil if there isn't one
+func (h History) Get(id uint64) (*RunRecord, error) {
+	var run *RunRecord
+	err := h.view(runsBucket, func(b *bolt.Bucket) error {
+		data := b.Get(runKey(id))
+		if data == nil {
+			return nil
+		}
+		run = &RunRecord{}
+		return json.Unmarshal(data, run)
+	})
+	return run, err
+}
+
+// Stores the report of a finished run
+func (h History) SaveReport(id uint64, r report.JSONReport) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return h.update(reportsBucket, func(b *bolt.Bucket) error {
+		return b.Put(runKey(id), data)
+	})
+}
+
+// The report of the run with id, or nil if it has none
+func (h History) Report(id uint64) (*report.JSONReport, error) {
+	var r *report.JSONReport
+	err := h.view(reportsBucket, func(b *bolt.Bucket) error {
+		data := b.Get(runKey(id))
+		if data == nil {
+			return nil
+		}
+		r = &report.JSONReport{}
+		return json.Unmarshal(data, r)
+	})
+	return r, err
+}
+
+// Marks a run finished, with what it scanned or why it failed
+func (r *RunRecord) Finish(out *runOutput, err error) {
+	now := time.Now().UTC()
+	r.FinishedAt = &now
+	if err != nil {
+		r.Status = RunFailed
+		r.Error = err.Error()
+		if out == nil {
+			return
+		}
+		r.Status = RunIncomplete
+	} else {
+		r.Status = RunOK
+	}
+
+	r.Artifacts = out.Files
+	for _, res := range out.Results {
+		totals := res.Totals()
+		total := big.NewInt(0)
+		for _, wei := range totals {
+			total.Add(total, wei)
+		}
+		r.Chains = append(r.Chains, RunChain{
+			Name:       res.Chain.Name,
+			ChainID:    res.Chain.ChainID.String(),
+			StartBlock: res.StartBlock.Uint64(),
+			EndBlock:   res.EndBlock.Uint64(),
+			TotalWei:   total.String(),
+			Recipients: len(totals),
+			Txs:        len(res.Txs),
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"juimburser/pkg/scan"
+)
+
+// Flags added to every command
+var logFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "log-level",
+		Usage:   "debug (which logs every RPC request), info, warn, or error",
+		Value:   "info",
+		EnvVars: []string{"LOG_LEVEL"},
+	},
+	&cli.StringFlag{
+		Name:    "log-format",
+		Usage:   "text or json",
+		Value:   "text",
+		EnvVars: []string{"LOG_FORMAT"},
+	},
+}
+
+// Sets the default slog logger from the log flags. The standard log package
+// writes through it too.
+func setupLogging(c *cli.Context) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.String("log-level"))); err != nil {
+		return fmt.Errorf("invalid --log-level %q (use debug, info, warn, or error)", c.String("log-level"))
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format := c.String("log-format"); format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid --log-format %q (use text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Logs a scan's progress every interval until stopped
+func logProgress(chain string, p *scan.Progress, every time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			s := p.Snapshot()
+			if s.Phase == "" {
+				continue
+			}
+			attrs := []any{"chain", chain, "phase", s.Phase, s.Unit, fmt.Sprintf("%d/%d", s.Done, s.Total), "logs", s.Logs, "rpcCalls", s.RPCCalls, "elapsed", s.Elapsed.Round(time.Second)}
+			if s.Done > 0 {
+				attrs = append(attrs, "eta", s.ETA.Round(time.Second))
+			}
+			slog.Info("Scanning", attrs...)
+		}
+	}()
+	return func() { close(done) }
+}
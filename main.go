@@ -1,49 +1,399 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"math/big"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/joho/godotenv"
+	"github.com/urfave/cli/v2"
+
+	"juimburser/pkg/bundle"
+	"juimburser/pkg/governance"
+	"juimburser/pkg/notify"
+	"juimburser/pkg/report"
+	"juimburser/pkg/safe"
+	"juimburser/pkg/scan"
+	"juimburser/pkg/scan/rpcfixture"
+	"juimburser/pkg/screen"
+	"juimburser/pkg/sign"
+	"juimburser/pkg/simulate"
 )
 
-// Gnosis Safe transaction bundle structs
-type TransactionBundle struct {
-	ChainID      string        `json:"chainId"`
-	CreatedAt    int64         `json:"createdAt"`
-	Meta         Meta          `json:"meta"`
-	Transactions []Transaction `json:"transactions"`
+func fatalLog(err error) {
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
 }
 
-type Meta struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+// Flags shared by every command that scans the chain
+var scanFlags = []cli.Flag{
+	&cli.StringSliceFlag{
+		Name:    "rpc-url",
+		Usage:   "JSON-RPC endpoint(s) for chains without an rpcUrl in the config; repeat or comma-separate for failover",
+		EnvVars: []string{"RPC_URL", "RPC_URLS"},
+	},
+	&cli.StringFlag{
+		Name:    "source",
+		Usage:   "where to find transactions: rpc, etherscan for an Etherscan-compatible API (no archive RPC needed), or subgraph for groups with a subgraph query",
+		Value:   SourceRPC,
+		EnvVars: []string{"DATA_SOURCE"},
+	},
+	&cli.StringFlag{
+		Name:    "etherscan-api-key",
+		Usage:   "Etherscan API key for --source etherscan",
+		EnvVars: []string{"ETHERSCAN_API_KEY"},
+	},
+	&cli.StringFlag{
+		Name:    "subgraph-url",
+		Usage:   "GraphQL endpoint for --source subgraph, for chains without a subgraphUrl in the config",
+		EnvVars: []string{"SUBGRAPH_URL"},
+	},
+	&cli.IntFlag{
+		Name:    "etherscan-rate",
+		Usage:   "most Etherscan API requests per second",
+		Value:   5,
+		EnvVars: []string{"ETHERSCAN_RATE"},
+	},
+	&cli.StringFlag{
+		Name:    "config",
+		Usage:   "path to the transaction group config",
+		Value:   "config.yaml",
+		EnvVars: []string{"CONFIG_PATH"},
+	},
+	profileFlag,
+	&cli.StringSliceFlag{
+		Name:        "chain",
+		Usage:       "only scan the named chain(s) from the config",
+		DefaultText: "all",
+	},
+	&cli.Uint64Flag{
+		Name:    "from-block",
+		Usage:   "first block to scan, overriding the config's fromBlock",
+		EnvVars: []string{"FROM_BLOCK"},
+	},
+	&cli.Uint64Flag{
+		Name:        "to-block",
+		Usage:       "last block to scan",
+		DefaultText: "finalized",
+		EnvVars:     []string{"TO_BLOCK"},
+	},
+	&cli.Uint64Flag{
+		Name:        "confirmations",
+		Usage:       "without a to block, scan up to this many blocks behind the latest instead of to the finalized block, overriding the config's confirmations",
+		DefaultText: "finalized",
+		EnvVars:     []string{"CONFIRMATIONS"},
+	},
+	&cli.BoolFlag{
+		Name:    "usd",
+		Usage:   "value gas costs in USD at each transaction's block",
+		EnvVars: []string{"USD"},
+	},
+	&cli.StringFlag{
+		Name:    "price-source",
+		Usage:   "USD price source: chainlink, coingecko, or auto (Chainlink where a feed is configured, else CoinGecko)",
+		Value:   scan.PriceSourceAuto,
+		EnvVars: []string{"PRICE_SOURCE"},
+	},
+	&cli.StringFlag{
+		Name:    "coingecko-api-key",
+		Usage:   "CoinGecko demo API key",
+		EnvVars: []string{"COINGECKO_API_KEY"},
+	},
+	&cli.StringFlag{
+		Name:    "pay-in",
+		Usage:   "reimburse in eth, usdc (converted at the price source's rate at the end block), or jbx (at --jbx-rate or a Uniswap TWAP)",
+		Value:   PayInETH,
+		EnvVars: []string{"PAY_IN"},
+	},
+	&cli.StringFlag{
+		Name:    "jbx-rate",
+		Usage:   "JBX per ETH with --pay-in jbx, overriding the config's jbxRate; without either, the TWAP of the chain's jbxPool is used",
+		EnvVars: []string{"JBX_RATE"},
+	},
+	&cli.DurationFlag{
+		Name:    "twap-window",
+		Usage:   "how far back the Uniswap TWAP used with --pay-in jbx averages",
+		Value:   30 * time.Minute,
+		EnvVars: []string{"TWAP_WINDOW"},
+	},
+	&cli.StringFlag{
+		Name:    "max-per-recipient",
+		Usage:   "most ETH paid to one recipient per run, overriding the config's maxPerRecipient; the excess is held back for review",
+		EnvVars: []string{"MAX_PER_RECIPIENT"},
+	},
+	&cli.StringFlag{
+		Name:    "min-payout",
+		Usage:   "least ETH paid to one recipient, overriding the config's minPayout; smaller totals are carried over to the next run",
+		EnvVars: []string{"MIN_PAYOUT"},
+	},
+	&cli.StringFlag{
+		Name:    "bucket",
+		Usage:   "also break each recipient's gas down by calendar week or month (UTC) in the reports",
+		EnvVars: []string{"BUCKET"},
+	},
+	&cli.StringFlag{
+		Name:    "max-gas-price",
+		Usage:   "highest effective gas price reimbursed in gwei, overriding the config's maxGasPrice",
+		EnvVars: []string{"MAX_GAS_PRICE"},
+	},
+	&cli.StringFlag{
+		Name:    "max-tip",
+		Usage:   "highest priority fee per gas in gwei before a transaction is flagged (or capped, per the config's maxTipAction), overriding the config's maxTip",
+		EnvVars: []string{"MAX_TIP"},
+	},
+	&cli.StringFlag{
+		Name:    "pay-via",
+		Usage:   "transfer to pay recipients directly, juicebox to pay the chain's Juicebox terminal with each recipient as beneficiary, merkle to fund the chain's claimContract for recipients to claim from with Merkle proofs, sablier to stream each recipient's tokens through the chain's sablierLockup, or splits to route everything through the chain's 0xSplits split",
+		Value:   PayViaTransfer,
+		EnvVars: []string{"PAY_VIA"},
+	},
+	&cli.StringFlag{
+		Name:    "stream-duration",
+		Usage:   "how long --pay-via sablier streams last, as a Go duration or a number of days (e.g. 30d)",
+		Value:   "30d",
+		EnvVars: []string{"STREAM_DURATION"},
+	},
+	&cli.StringFlag{
+		Name:    "stream-cliff",
+		Usage:   "how long into a --pay-via sablier stream before anything can be withdrawn",
+		EnvVars: []string{"STREAM_CLIFF"},
+	},
+	&cli.BoolFlag{
+		Name:    "stream-cancelable",
+		Usage:   "let the Safe cancel --pay-via sablier streams and take back what hasn't streamed",
+		EnvVars: []string{"STREAM_CANCELABLE"},
+	},
+	&cli.BoolFlag{
+		Name:    "multisend",
+		Usage:   "batch all transfers into a single MultiSendCallOnly delegatecall",
+		EnvVars: []string{"MULTISEND"},
+	},
+	&cli.IntFlag{
+		Name:    "max-transfers",
+		Usage:   "most transfers per bundle file; larger bundles are split into bundle-1.json, bundle-2.json, and so on (0 for no limit)",
+		EnvVars: []string{"MAX_TRANSFERS"},
+	},
+	&cli.BoolFlag{
+		Name:  "no-balance-check",
+		Usage: "don't check each chain's safe holds enough to pay its bundle plus execution gas",
+	},
+	&cli.BoolFlag{
+		Name:  "no-recipient-check",
+		Usage: "don't check that recipients of ETH transfers that are contracts accept ETH",
+	},
+	&cli.StringFlag{
+		Name:    "simulate",
+		Usage:   "before writing each bundle, simulate executing it from the chain's safe in the config's tenderly project (tenderly) or on the chain's local anvil fork (fork), failing if any call would revert",
+		EnvVars: []string{"SIMULATE"},
+	},
+	&cli.StringFlag{
+		Name:    "cache-dir",
+		Usage:   "directory for on-disk caches",
+		Value:   ".cache",
+		EnvVars: []string{"CACHE_DIR"},
+	},
+	&cli.IntFlag{
+		Name:    "concurrency",
+		Usage:   "maximum transactions fetched from the RPC at once",
+		Value:   8,
+		EnvVars: []string{"CONCURRENCY"},
+	},
+	&cli.IntFlag{
+		Name:    "batch-size",
+		Usage:   "transactions whose sender and receipt are fetched per batched JSON-RPC request, or 0 to send each call on its own",
+		Value:   50,
+		EnvVars: []string{"BATCH_SIZE"},
+	},
+	&cli.Uint64Flag{
+		Name:    "log-range",
+		Usage:   "blocks per eth_getLogs query, halved automatically if the provider rejects a query as too large (0 for the whole range at once)",
+		Value:   10000,
+		EnvVars: []string{"LOG_RANGE"},
+	},
+	&cli.StringFlag{
+		Name:    "trace-api",
+		Usage:   "how groups with traces are traced: trace_filter (Erigon, Nethermind, reth) or debug (geth's debug_traceBlockByNumber, one request per block)",
+		Value:   scan.TraceFilter,
+		EnvVars: []string{"TRACE_API"},
+	},
+	&cli.IntFlag{
+		Name:    "retries",
+		Usage:   "times to retry an RPC request after a network error, 429, or 5xx response",
+		Value:   5,
+		EnvVars: []string{"RPC_RETRIES"},
+	},
+	&cli.DurationFlag{
+		Name:    "retry-backoff",
+		Usage:   "wait before the first RPC retry, doubled for each retry after (with jitter, up to 30s)",
+		Value:   500 * time.Millisecond,
+		EnvVars: []string{"RPC_RETRY_BACKOFF"},
+	},
+	&cli.DurationFlag{
+		Name:    "rpc-timeout",
+		Usage:   "how long each HTTP RPC (or Etherscan) request may take before it's abandoned and retried, or 0 for no limit",
+		Value:   30 * time.Second,
+		EnvVars: []string{"RPC_TIMEOUT"},
+	},
+	&cli.DurationFlag{
+		Name:    "timeout",
+		Usage:   "how long the whole run may take scanning, or 0 for no limit",
+		EnvVars: []string{"RUN_TIMEOUT"},
+	},
+	&cli.IntFlag{
+		Name:    "item-retries",
+		Usage:   "times to retry a transaction that failed to fetch, after trying all the others; what still fails is listed in the report",
+		Value:   2,
+		EnvVars: []string{"ITEM_RETRIES"},
+	},
+	&cli.DurationFlag{
+		Name:    "progress-interval",
+		Usage:   "how often to log a long scan's progress (blocks scanned, logs found, RPC calls made, and time left), or 0 not to",
+		Value:   10 * time.Second,
+		EnvVars: []string{"PROGRESS_INTERVAL"},
+	},
+	&cli.BoolFlag{
+		Name:    "no-cache",
+		Usage:   "fetch every transaction and receipt from the RPC instead of the cache",
+		EnvVars: []string{"NO_CACHE"},
+	},
+	&cli.StringFlag{
+		Name:  "record-rpc",
+		Usage: "record every RPC answer to <dir>/<chain>.json, for replaying the run with --replay-rpc (implies --no-cache)",
+	},
+	&cli.StringFlag{
+		Name:  "replay-rpc",
+		Usage: "answer RPC calls from the <dir>/<chain>.json fixtures --record-rpc wrote instead of the node (implies --no-cache)",
+	},
+	&cli.StringFlag{
+		Name:    "state",
+		Usage:   "file recording the last block and transactions reimbursed on each chain",
+		Value:   "state.json",
+		EnvVars: []string{"STATE_PATH"},
+	},
+	&cli.StringFlag{
+		Name:    "history",
+		Usage:   "database recording every run, listed by serve",
+		Value:   "history.db",
+		EnvVars: []string{"HISTORY_PATH"},
+	},
+	&cli.BoolFlag{
+		Name:    "owners-only",
+		Usage:   "only reimburse senders who own the Safe (or are in the config's allow list); others are excluded for manual review",
+		EnvVars: []string{"OWNERS_ONLY"},
+	},
+	&cli.BoolFlag{
+		Name:    "since-last-run",
+		Usage:   "start each chain after the last block in the state file",
+		EnvVars: []string{"SINCE_LAST_RUN"},
+	},
+	&cli.StringFlag{
+		Name:  "resume",
+		Usage: "rescan the chains and blocks an interrupted run didn't finish, from the checkpoint.json it wrote",
+	},
+	&cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "print the summary and bundle totals instead of writing any artifacts or state",
+	},
+	&cli.BoolFlag{
+		Name:  "force",
+		Usage: "include transactions and line items the state file records as already reimbursed",
+	},
+	&cli.BoolFlag{
+		Name:  "forgive-held",
+		Usage: "drop what earlier runs held back over the per-recipient cap instead of adding it to this run",
+	},
+	&cli.BoolFlag{
+		Name:  "no-notify",
+		Usage: "don't post a summary to the webhooks in the config",
+	},
+	&cli.BoolFlag{
+		Name:  "no-sheets",
+		Usage: "don't append rows to the Google Sheet in the config",
+	},
+	&cli.BoolFlag{
+		Name:  "no-pin",
+		Usage: "don't pin artifacts to the IPFS pinning service in the config",
+	},
+	&cli.StringFlag{
+		Name:    "sign-key",
+		Usage:   "hex private key to sign report.txt and each bundle with, as EIP-191 signatures over their keccak256 hashes written to <file>.sig",
+		EnvVars: []string{"ARTIFACT_SIGNING_KEY"},
+	},
+	&cli.StringFlag{
+		Name:    "gpg-key",
+		Usage:   "GPG key ID to write detached signatures of report.txt and each bundle with, to <file>.asc",
+		EnvVars: []string{"GPG_KEY_ID"},
+	},
+	&cli.StringSliceFlag{
+		Name:  "template",
+		Usage: "also render the report with this text/template file, written to the out dir under its name without .tmpl (repeatable)",
+	},
+	&cli.StringSliceFlag{
+		Name:    "governance",
+		Usage:   "also write a governance payload with the Markdown report as its body: snapshot (snapshot.json) or nance (nance.json), or a Discourse post for the forum (forum.md) (repeatable)",
+		EnvVars: []string{"GOVERNANCE"},
+	},
+	&cli.BoolFlag{
+		Name:    "archive",
+		Usage:   "also write inputs.json with every transaction's matched log, raw receipt, and block header fields, so the totals can be re-derived without an archive node",
+		EnvVars: []string{"ARCHIVE"},
+	},
+	outDirFlag,
+	&cli.BoolFlag{
+		Name:    "plain-names",
+		Usage:   "name artifacts report.txt, bundle.json, and so on, replacing the last run's, instead of adding the date and block range",
+		EnvVars: []string{"PLAIN_NAMES"},
+	},
 }
 
-type Transaction struct {
-	To    string `json:"to"`
-	Value string `json:"value"`
+var profileFlag = &cli.StringFlag{
+	Name:    "profile",
+	Usage:   "profile from the config's profiles to use: its chains, labels, and artifact and state file names",
+	EnvVars: []string{"PROFILE"},
 }
 
-// Util structs
-type TxInfo struct {
-	From   common.Address
-	GasWei *big.Int
+var outDirFlag = &cli.StringFlag{
+	Name:    "out-dir",
+	Usage:   "directory to write artifacts to",
+	Value:   ".",
+	EnvVars: []string{"OUT_DIR"},
 }
 
-func fatalLog(err error) {
-	if err != nil {
-		log.Fatalf("Error: %v\n", err)
-	}
+// Flags for signing and submitting bundles to the Safe Transaction Service
+var proposeFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "safe-service-url",
+		Usage:   "Safe Transaction Service base URL, overriding the config",
+		EnvVars: []string{"SAFE_SERVICE_URL"},
+	},
+	&cli.StringFlag{
+		Name:    "safe-api-key",
+		Usage:   "Safe Transaction Service API key",
+		EnvVars: []string{"SAFE_API_KEY"},
+	},
+	&cli.StringFlag{
+		Name:    "private-key",
+		Usage:   "hex private key of a Safe owner or registered delegate",
+		EnvVars: []string{"PROPOSER_PRIVATE_KEY"},
+	},
 }
 
 func main() {
@@ -53,168 +403,1750 @@ func main() {
 		fatalLog(err)
 	}
 
-	var rpcURL string
-	if rpcURL = os.Getenv("RPC_URL"); rpcURL == "" {
-		fatalLog(fmt.Errorf("RPC_URL not set"))
-	}
-
-	// 10 second timeout for all RPC requests
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Set up the client
-	client, err := ethclient.Dial(rpcURL)
-	fatalLog(err)
-	defer client.Close()
-
-	// Create a buffer to store a text report
-	var report bytes.Buffer
-
-	// Get block bounds for report
-	startBlockNumber := big.NewInt(18949176) // STARTING BLOCK
-	startBlock, err := client.BlockByNumber(ctx, startBlockNumber)
-	fatalLog(err)
-
-	latestBlock, err := client.BlockByNumber(ctx, nil)
-	fatalLog(err)
-
-	startBlockTime, latestBlockTime := time.Unix(int64(startBlock.Time()), 0), time.Unix(int64(latestBlock.Time()), 0)
-	report.WriteString("# JuiceboxDAO Gas Reimbursements\n\n")
-	report.WriteString(fmt.Sprintf("From %s to %s (block %s to block %s)\n\n", startBlockTime.Format(time.RFC1123),
-		latestBlockTime.Format(time.RFC1123), startBlockNumber.String(), latestBlock.Number().String()))
-
-	// The groups of transactions to get, specified by addresses and event topics
-	txGroups := []struct {
-		Label     string
-		Addresses []common.Address
-		Topics    [][]common.Hash
-	}{
-		{
-			// Multisig
-			Label: "Execute multisig tx",
-			Addresses: []common.Address{
-				common.HexToAddress("0xAF28bcB48C40dBC86f52D459A6562F658fc94B1e"),
+	app := &cli.App{
+		Name:    "juimburser",
+		Usage:   "calculate gas reimbursements for JuiceboxDAO operations",
+		Version: version(),
+		Commands: []*cli.Command{
+			{
+				Name:   "run",
+				Usage:  "scan the chain and write both report.txt and bundle.json",
+				Flags:  scanFlags,
+				Action: runAction(true, true),
 			},
-			Topics: [][]common.Hash{
-				// ExecutionSuccess
-				{common.HexToHash("0x442e715f626346e8c54381002da614f62bee8d27386535b2521ec8540898556e")},
+			{
+				Name:   "report",
+				Usage:  "scan the chain and write report.txt",
+				Flags:  scanFlags,
+				Action: runAction(true, false),
 			},
-		},
-		{
-			// Terminals
-			Label: "Distribute JuiceboxDAO payouts",
-			Addresses: []common.Address{
-				common.HexToAddress("0xFA391De95Fcbcd3157268B91d8c7af083E607A5C"), // JBETHPaymentTerminal3_1
-				common.HexToAddress("0x457cD63bee88ac01f3cD4a67D5DCc921D8C0D573"), // JBETHPaymentTerminal3_1_1
-				common.HexToAddress("0x1d9619E10086FdC1065B114298384aAe3F680CC0"), // JBETHPaymentTerminal3_1_2
+			{
+				Name:   "bundle",
+				Usage:  "scan the chain and write bundle.json",
+				Flags:  scanFlags,
+				Action: runAction(false, true),
 			},
-			Topics: [][]common.Hash{
-				// DistributePayouts
-				{common.HexToHash("0xc41a8d26c70cfcf1b9ea10f82482ac947b8be5bea2750bc729af844bbfde1e28")},
-				{}, {},
-				{common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001")}, // projectId 1
+			{
+				Name:  "compare",
+				Usage: "scan the chain and list the transactions and recipients that are new, changed, or missing since a previous report.json, without writing anything",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "previous",
+						Usage:    "report.json from the run to compare against",
+						Required: true,
+					},
+				}, scanFlags...),
+				Action: compareAction,
 			},
-		},
-		{
-			Label: "Distribute JuiceboxDAO reserved tokens",
-			Addresses: []common.Address{
-				common.HexToAddress("0xFFdD70C318915879d5192e8a0dcbFcB0285b3C98"), // JBController
-				common.HexToAddress("0xA139D37275d1fF7275e6F33821898934Bc8Cb7B6"), // JBController3_0_1
-				common.HexToAddress("0x97a5b9D9F0F7cD676B69f584F29048D0Ef4BB59b"), // JBController3_1
+			{
+				Name:  "config",
+				Usage: "work with the config",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "validate",
+						Usage: "check the config without scanning: that it's valid, that checksummed addresses are right, and for likely mistakes like events in the wrong topic position or groups matching the same logs",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "config",
+								Usage:   "config to validate",
+								Value:   "config.yaml",
+								EnvVars: []string{"CONFIG_PATH"},
+							},
+						},
+						Action: configValidateAction,
+					},
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "check that a bundle.json is well-formed and its checksum matches, and that it pays what its report.json says",
+				Flags: []cli.Flag{
+					outDirFlag,
+					&cli.StringFlag{
+						Name:        "bundle",
+						Usage:       "path to the bundle to verify",
+						DefaultText: "<out-dir>/bundle.json, or else the newest bundle*.json there",
+					},
+					&cli.StringFlag{
+						Name:  "report",
+						Usage: "report.json from the same run, to also check the bundle pays exactly what it reports",
+					},
+				},
+				Action: verifyAction,
 			},
-			Topics: [][]common.Hash{
-				// DistributeReservedTokens
-				{common.HexToHash("0xb12d7a78048433f69fe6d30145bf08aad8e82985b96e4db6d5c6a7e94d57086e")},
-				{}, {},
-				{common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001")}, // projectId 1
+			{
+				Name:      "verify-signature",
+				Usage:     "check a file against the detached <file>.sig signature written with --sign-key",
+				ArgsUsage: "FILE",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "signature",
+						Usage:       "path to the signature",
+						DefaultText: "FILE.sig",
+					},
+					&cli.StringFlag{
+						Name:  "signer",
+						Usage: "address the signature must be from",
+					},
+				},
+				Action: verifySignatureAction,
+			},
+			{
+				Name:  "propose",
+				Usage: "sign a bundle as a single Safe transaction and submit it to the Safe Transaction Service",
+				Flags: append([]cli.Flag{
+					outDirFlag,
+					&cli.StringFlag{
+						Name:        "bundle",
+						Usage:       "path to the bundle to propose",
+						DefaultText: "<out-dir>/bundle.json, or else the newest bundle*.json there",
+					},
+					&cli.StringFlag{
+						Name:    "config",
+						Usage:   "config to read the chain's Safe and Transaction Service URL from",
+						Value:   "config.yaml",
+						EnvVars: []string{"CONFIG_PATH"},
+					},
+					profileFlag,
+					&cli.StringFlag{
+						Name:  "safe",
+						Usage: "Safe address, overriding the config",
+					},
+					&cli.Uint64Flag{
+						Name:        "nonce",
+						Usage:       "Safe nonce to use",
+						DefaultText: "next unused nonce",
+					},
+				}, proposeFlags...),
+				Action: proposeAction,
+			},
+			{
+				Name:   "daemon",
+				Usage:  "run on a schedule, each time scanning from where the last run left off and writing artifacts to a dated directory in the out dir",
+				Flags:  append(append(scanFlags, daemonFlags...), proposeFlags...),
+				Action: daemonAction,
+			},
+			{
+				Name:   "serve",
+				Usage:  "serve an HTTP API to trigger runs, check their status, and download their artifacts",
+				Flags:  append(scanFlags, serveFlags...),
+				Action: serveAction,
 			},
 		},
 	}
 
-	includedTxs := make(map[common.Hash]TxInfo)
-	reportDetails := make(map[common.Address]string)
+	for _, cmd := range app.Commands {
+		cmd.Flags = append(cmd.Flags, logFlags...)
+		cmd.Before = setupLogging
+	}
+
+	fatalLog(app.Run(os.Args))
+}
+
+// The module version it was built as (or "(devel)"), with the commit it was
+// built from when known, e.g. "v1.4.0 (3f2a9c1b7e0d)" or "(devel) (3f2a9c1b7e0d, modified)"
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value[:min(12, len(s.Value))]
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = ", modified"
+			}
+		}
+	}
+	if revision != "" {
+		v += fmt.Sprintf(" (%s%s)", revision, modified)
+	}
+	return v
+}
 
-	for _, txGroup := range txGroups {
-		query := ethereum.FilterQuery{
-			FromBlock: startBlockNumber,
-			ToBlock:   latestBlock.Number(),
-			Addresses: txGroup.Addresses,
-			Topics:    txGroup.Topics,
+// When a run is made, as recorded in its artifacts: SOURCE_DATE_EPOCH (Unix
+// seconds) if it's set, as for reproducible builds, or else now
+func runTime() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if secs, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC()
 		}
+		slog.Warn("Ignoring SOURCE_DATE_EPOCH, which isn't a number of seconds", "value", epoch)
+	}
+	return time.Now()
+}
 
-		logs, err := client.FilterLogs(ctx, query)
-		fatalLog(err)
+// Scans the chain and writes the requested artifacts
+func runAction(writeReport, writeBundle bool) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		cfg, err := loadProfile(c)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := interruptible(c.Context)
+		defer stop()
+
+		run := &RunRecord{Trigger: "cli", OutDir: c.String("out-dir")}
+		_, err = recordedRun(ctx, c, cfg, run, writeReport, writeBundle)
+		return err
+	}
+}
+
+// A context canceled on the first SIGINT or SIGTERM, so the run stops
+// scanning and writes what it has. A second one quits right away.
+func interruptible(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			slog.Warn("Interrupted; stopping the scan and writing what it has (interrupt again to quit now)")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Whether ctx was canceled by an interrupt rather than timing out
+func interrupted(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// Scans the chain like a dry run and compares the results to --previous
+func compareAction(c *cli.Context) error {
+	cfg, err := loadProfile(c)
+	if err != nil {
+		return err
+	}
+	_, err = scanAndWrite(c.Context, c, cfg, c.String("out-dir"), false, false)
+	return err
+}
+
+// Runs scanAndWrite to run.OutDir, recording it in the history unless it's a
+// dry run. A run with no ID is added to the history first.
+func recordedRun(ctx context.Context, c *cli.Context, cfg *Config, run *RunRecord, writeReport, writeBundle bool) (*runOutput, error) {
+	if c.Bool("dry-run") {
+		return scanAndWrite(ctx, c, cfg, run.OutDir, writeReport, writeBundle)
+	}
 
-		for _, lg := range logs {
-			// If we've already seen this transaction, skip it
-			_, ok := includedTxs[lg.TxHash]
-			if ok {
+	// The history is only a record, so failing to write it doesn't fail the run
+	history := History{path: c.String("history")}
+	if run.ID == 0 {
+		run.Status = RunRunning
+		run.StartedAt = time.Now().UTC()
+		if err := history.Start(run); err != nil {
+			slog.Warn("Couldn't record the run", "err", err)
+		}
+	}
+
+	out, err := scanAndWrite(ctx, c, cfg, run.OutDir, writeReport, writeBundle)
+	run.Finish(out, err)
+	observeRun(run, out, err)
+	if run.ID != 0 {
+		if err := history.Save(run); err != nil {
+			slog.Warn("Couldn't record the run", "id", run.ID, "err", err)
+		}
+		if out != nil {
+			if err := history.SaveReport(run.ID, report.BuildJSON(out.Results)); err != nil {
+				slog.Warn("Couldn't record the run's report", "id", run.ID, "err", err)
+			}
+		}
+	}
+	return out, err
+}
+
+// What a run scanned and wrote
+type runOutput struct {
+	Results []*scan.Result
+	// Paths of each result's bundle files, none if it wasn't written
+	Bundles [][]string
+	// Every file written, relative to the out dir
+	Files []string
+}
+
+// Stops the classifier processes the chains' scans started. The chains
+// share them, so each is closed once.
+func closeClassifiers(chains []*scan.Chain) {
+	closed := make(map[scan.Classifier]bool)
+	for _, chain := range chains {
+		for _, cl := range chain.Classifiers {
+			closer, ok := cl.(io.Closer)
+			if !ok || closed[cl] {
 				continue
 			}
+			closed[cl] = true
+			if err := closer.Close(); err != nil {
+				slog.Warn("Classifier didn't exit cleanly", "classifier", cl.Name(), "err", err)
+			}
+		}
+	}
+}
+
+// Scans the chains selected by c's flags and writes the requested artifacts
+// to outDir. Returns nil output for a dry run.
+func scanAndWrite(parent context.Context, c *cli.Context, cfg *Config, outDir string, writeReport, writeBundle bool) (*runOutput, error) {
+	chains, err := resolveChains(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeClassifiers(chains)
+
+	// Catch template errors before spending time scanning
+	for _, path := range c.StringSlice("template") {
+		if _, err := report.ParseTemplate(path); err != nil {
+			return nil, err
+		}
+	}
+	for _, target := range c.StringSlice("governance") {
+		switch target {
+		case governance.Snapshot:
+			if cfg.Governance == nil {
+				return nil, fmt.Errorf("--governance snapshot needs a governance section with a snapshotSpace in the config")
+			}
+		case governance.Nance, governance.Forum:
+		default:
+			return nil, fmt.Errorf("--governance must be snapshot, nance, or forum, got %q", target)
+		}
+	}
 
-			tx, _, err := client.TransactionByHash(ctx, lg.TxHash)
-			fatalLog(err)
+	var signKey *ecdsa.PrivateKey
+	if c.String("sign-key") != "" {
+		if signKey, err = crypto.HexToECDSA(strings.TrimPrefix(c.String("sign-key"), "0x")); err != nil {
+			return nil, fmt.Errorf("invalid --sign-key: %w", err)
+		}
+	}
 
-			from, err := client.TransactionSender(ctx, tx, lg.BlockHash, lg.Index)
-			fatalLog(err)
+	state, err := loadState(cfg.State(c))
+	if err != nil {
+		return nil, err
+	}
+	if c.Bool("since-last-run") {
+		if c.IsSet("from-block") {
+			return nil, fmt.Errorf("--from-block can't be used with --since-last-run")
+		}
+		for _, chain := range chains {
+			if last, ok := state.Chains[chain.ChainID.String()]; ok {
+				chain.StartBlock = new(big.Int).SetUint64(last.LastBlock + 1)
+			} else if chain.StartBlock == nil {
+				return nil, fmt.Errorf("%s: no previous run in %s and no fromBlock in the config", chain.Name, cfg.State(c))
+			}
+			if chain.EndBlock != nil && chain.EndBlock.Cmp(chain.StartBlock) < 0 {
+				return nil, fmt.Errorf("%s: end block %s is before block %s, where the last run left off", chain.Name, chain.EndBlock, chain.StartBlock)
+			}
+		}
+	}
 
-			receipt, err := client.TransactionReceipt(ctx, lg.TxHash)
-			fatalLog(err)
+	// A comparison is against what the previous run found, before the state
+	// recorded it as reimbursed
+	comparing := c.String("previous") != ""
 
-			// get the actual gas used
-			gasCost := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
-			fmted := new(big.Float).Quo(new(big.Float).SetInt(gasCost), new(big.Float).SetInt(big.NewInt(1e18)))
+	// Each RPC request has its own deadline (--rpc-timeout); the run only
+	// has one with --timeout
+	ctx, cancel := parent, context.CancelFunc(func() {})
+	if timeout := c.Duration("timeout"); timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	}
+	defer cancel()
 
-			reportDetails[from] += fmt.Sprintf("Type: %s", txGroup.Label) +
-				fmt.Sprintf("\nTxHash: [`%s`](https://etherscan.io/tx/%s)", lg.TxHash.Hex(), lg.TxHash.Hex()) +
-				fmt.Sprintf("\nGas: %s ETH\nBlock: %d\n\n", fmted.String(), lg.BlockNumber)
+	var coingecko *scan.CoinGecko
+	for _, chain := range chains {
+		if needsPrices(c) && chain.PriceFeed == nil && coingecko == nil {
+			if coingecko, err = scan.NewCoinGecko(c.String("coingecko-api-key"), c.String("cache-dir")); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-			includedTxs[lg.TxHash] = TxInfo{from, gasCost}
+	var cache *scan.TxCache
+	if !c.Bool("no-cache") && c.String("record-rpc") == "" && c.String("replay-rpc") == "" {
+		if cache, err = scan.OpenTxCache(c.String("cache-dir")); err != nil {
+			return nil, err
 		}
+		defer cache.Close()
 	}
 
-	// Calculate totals
-	totals := make(map[common.Address]*big.Int)
-	for _, v := range includedTxs {
-		if totals[v.From] == nil {
-			totals[v.From] = big.NewInt(0)
+	retry := scan.RetryPolicy{
+		Retries:    c.Int("retries"),
+		Backoff:    c.Duration("retry-backoff"),
+		MaxBackoff: 30 * time.Second,
+		Timeout:    c.Duration("rpc-timeout"),
+	}
+
+	// A chain that fails doesn't stop the others from being scanned
+	results := []*scan.Result{}
+	var (
+		chainErrs []error
+		failed    []string
+	)
+	// The chains an interrupt stopped the run from finishing
+	checkpoint := newCheckpoint()
+	screener, err := cfg.Screener()
+	if err != nil {
+		return nil, err
+	}
+	for _, chain := range chains {
+		if interrupted(ctx) {
+			chainErrs = append(chainErrs, fmt.Errorf("%s: interrupted before it was scanned", chain.Name))
+			failed = append(failed, chain.Name)
+			checkpoint.Add(chain, nil)
+			continue
+		}
+		res, err := scanChain(ctx, c, chain, retry, cache, coingecko)
+		if err != nil {
+			if interrupted(ctx) {
+				checkpoint.Add(chain, nil)
+			} else if ctx.Err() != nil {
+				return nil, fmt.Errorf("%s: the run took longer than --timeout %s: %w", chain.Name, c.Duration("timeout"), err)
+			}
+			chainErrs = append(chainErrs, err)
+			failed = append(failed, chain.Name)
+			continue
+		}
+		if res.Interrupted {
+			checkpoint.Add(chain, res)
+			slog.Warn("Scan interrupted", "chain", chain.Name, "found", len(res.Txs), "missing", len(res.Errors))
+		} else {
+			for _, e := range res.Errors {
+				slog.Warn("Couldn't fetch after retrying", "chain", chain.Name, "tx", e.Hash.Hex(), "block", e.BlockNumber, "err", e.Err)
+			}
+		}
+		if comparing {
+			results = append(results, res)
+			continue
 		}
-		totals[v.From] = new(big.Int).Add(totals[v.From], v.GasWei)
+
+		if err := settle(ctx, res, state, screener, c.Bool("force"), c.Bool("forgive-held")); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, errors.Join(chainErrs...)
+	}
+	for i, err := range chainErrs {
+		slog.Error("Scan failed, continuing with the other chains", "chain", failed[i], "err", err)
 	}
 
-	// Finish the report
-	for k, v := range reportDetails {
-		report.WriteString(fmt.Sprintf("## Summary for [`%s`](https://etherscan.io/address/%s)\n\n", k.Hex(), k.Hex()))
+	if comparing {
+		data, err := os.ReadFile(c.String("previous"))
+		if err != nil {
+			return nil, err
+		}
+		var previous report.JSONReport
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", c.String("previous"), err)
+		}
+		report.WriteComparison(os.Stdout, previous, report.BuildJSON(results))
+		return nil, incompleteError(results, chainErrs)
+	}
+	if c.Bool("dry-run") {
+		if err := printDryRun(os.Stdout, results, c.Bool("multisend"), c.Int("max-transfers")); err != nil {
+			return nil, err
+		}
+		return nil, incompleteError(results, chainErrs)
+	}
 
-		fmted := new(big.Float).Quo(new(big.Float).SetInt(totals[k]), new(big.Float).SetInt(big.NewInt(1e18)))
-		report.WriteString("Total gas to reimburse: " + fmted.String() + " ETH\n\n")
-		report.WriteString("### Transactions\n\n")
-		report.WriteString(v)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
 	}
 
-	bundle := TransactionBundle{
-		ChainID:   "1",
-		CreatedAt: time.Now().Unix(),
-		Meta: Meta{
-			Name:        "JuiceboxDAO Gas Reimbursements",
-			Description: fmt.Sprintf("Gas reimbursements from block %s to %s", startBlockNumber.String(), latestBlock.Number().String()),
-		},
-		Transactions: []Transaction{},
+	out := &runOutput{Results: results}
+	var artifacts []string
+	now := runTime()
+	for _, res := range results {
+		res.Manifest = scan.NewManifest(res, cfg.sha256, version(), now)
+		res.Manifest.Profile = cfg.profile
+	}
+	for _, res := range results {
+		preflight(parent, c, res, retry)
+	}
+	writer := report.ReportWriter{OutDir: outDir, Suffix: artifactSuffix(c, cfg, now, results), Templates: c.StringSlice("template"), Archive: c.Bool("archive")}
+	if writeBundle {
+		bundles, files, err := writeBundles(parent, c, cfg, outDir, now, state, results)
+		if err != nil {
+			return nil, err
+		}
+		out.Bundles = bundles
+		artifacts = append(artifacts, files...)
 	}
 
-	for k, v := range totals {
-		bundle.Transactions = append(bundle.Transactions, Transaction{
-			To:    k.Hex(),
-			Value: v.String(),
-		})
+	if writeReport {
+		if err := writer.Write(results); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, writer.Files()...)
+
+		if targets := c.StringSlice("governance"); len(targets) > 0 {
+			files, err := writeGovernance(cfg, outDir, writer.Suffix, results, targets)
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, files...)
+		}
+	}
+
+	if len(checkpoint.Chains) > 0 {
+		name := writer.Name(checkpointFile)
+		path := filepath.Join(outDir, name)
+		if err := checkpoint.Save(path); err != nil {
+			return nil, err
+		}
+		out.Files = append(artifacts, name)
+		slog.Warn("Wrote a partial report of what was scanned before the interrupt, without signing, pinning, or notifying", "checkpoint", path)
+		return out, fmt.Errorf("interrupted before the scan finished; rerun with --resume %s to finish it", path)
+	}
+
+	if out.Files, err = publish(parent, c, cfg, outDir, writer, signKey, writeReport, results, out.Bundles, artifacts); err != nil {
+		return nil, err
+	}
+	return out, incompleteError(results, chainErrs)
+}
+
+// Writes each complete result's bundle files (and Merkle claims, when
+// recipients claim) to outDir and records them in the state, which it saves.
+// Returns the paths of each result's bundle files, nil for an incomplete
+// one, and the names of every file written.
+func writeBundles(ctx context.Context, c *cli.Context, cfg *Config, outDir string, now time.Time, state *State, results []*scan.Result) (bundles [][]string, artifacts []string, err error) {
+	for _, res := range results {
+		// Paying part of a chain's reimbursements and recording it as done
+		// would skip the rest on the next --since-last-run
+		if len(res.Errors) > 0 {
+			slog.Warn("Not writing a bundle or state for an incomplete chain; rerun to retry", "chain", res.Chain.Name, "errors", len(res.Errors))
+			bundles = append(bundles, nil)
+			continue
+		}
+
+		builder := bundleBuilder(c, res)
+		parts, err := builder.BuildParts(res)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := simulateBundle(ctx, c, cfg, res, parts); err != nil {
+			return nil, nil, err
+		}
+
+		var paths []string
+		for i, part := range parts {
+			json, err := json.Marshal(part.Bundle)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			name := bundleName(res.Chain.Name, len(results) > 1, i+1, len(parts), artifactSuffix(c, cfg, now, []*scan.Result{res}))
+			path := filepath.Join(outDir, name)
+			if err := os.WriteFile(path, json, 0644); err != nil {
+				return nil, nil, err
+			}
+			artifacts = append(artifacts, name)
+			paths = append(paths, path)
+			if len(parts) > 1 {
+				res.BundleFiles = append(res.BundleFiles, scan.BundleFile{Name: name, Recipients: part.Recipients})
+			}
+		}
+		if len(parts) > 1 {
+			slog.Info("Split the bundle across several files", "chain", res.Chain.Name, "files", len(parts), "maxTransfers", builder.MaxTransfers)
+		}
+		if claim := res.Payout.Claim; claim != nil {
+			tree := bundle.ClaimTree(res)
+			claim.Root = tree.Root
+			data, err := json.MarshalIndent(bundle.NewClaimsFile(res, tree), "", "  ")
+			if err != nil {
+				return nil, nil, err
+			}
+			name := "claims" + strings.TrimPrefix(bundleName(res.Chain.Name, len(results) > 1, 1, 1, artifactSuffix(c, cfg, now, []*scan.Result{res})), "bundle")
+			if err := os.WriteFile(filepath.Join(outDir, name), data, 0644); err != nil {
+				return nil, nil, err
+			}
+			artifacts = append(artifacts, name)
+			slog.Info("Wrote the Merkle claims", "chain", res.Chain.Name, "file", name, "root", tree.Root.Hex(), "recipients", len(tree.Claims))
+		}
+		bundles = append(bundles, paths)
+		state.Record(res)
+	}
+
+	if err := state.Save(cfg.State(c)); err != nil {
+		return nil, nil, err
+	}
+	return bundles, artifacts, nil
+}
+
+// Squares res with the state's ledger before it's bundled: skips the
+// transactions earlier runs already reimbursed (unless force) and the line
+// items they bundled, adds what they carried over or held back over the cap
+// (dropping the latter if forgiveHeld), and screens the recipients, holding
+// back any the screener flags
+func settle(ctx context.Context, res *scan.Result, state *State, screener screen.Screener, force, forgiveHeld bool) error {
+	chain := res.Chain
+	if force {
+		if n := state.CountReimbursed(res); n > 0 {
+			slog.Info("Including transactions already reimbursed by a previous run (--force)", "chain", chain.Name, "count", n)
+		}
+	} else if dropped := state.Exclude(res); dropped > 0 {
+		slog.Info("Skipped transactions already reimbursed by a previous run (use --force to include them)", "chain", chain.Name, "count", dropped)
+	}
+	if skipped := state.AddLineItems(res, force); skipped > 0 {
+		slog.Info("Skipped line items already bundled by a previous run (use --force to include them)", "chain", chain.Name, "count", skipped)
+	}
+	if forgiveHeld {
+		if n := state.ForgiveHeld(chain); n > 0 {
+			slog.Info("Dropped what earlier runs held back over the cap (--forgive-held)", "chain", chain.Name, "recipients", n)
+		}
+	}
+	if n, err := state.CarryIn(res); err != nil {
+		return err
+	} else if n > 0 {
+		slog.Info("Adding amounts carried over from earlier runs below the minimum payout or over the cap", "chain", chain.Name, "recipients", n)
+	}
+	if screener == nil {
+		return nil
+	}
+	// Paying a recipient that couldn't be screened risks paying a sanctioned
+	// one, so a failure fails the run
+	recipients := res.Owed()
+	for _, addr := range res.SplitPayees() {
+		recipients[addr] = nil
+	}
+	var err error
+	if res.Denied, err = screener.Screen(ctx, scan.SortedAddresses(recipients)); err != nil {
+		return fmt.Errorf("%s: screening recipients: %w", chain.Name, err)
+	}
+	for _, addr := range scan.SortedAddresses(res.Denied) {
+		slog.Warn("Holding back a denylisted recipient for manual handling", "chain", chain.Name, "recipient", addr.Hex(), "reason", res.Denied[addr])
+	}
+	return nil
+}
+
+// Publishes a finished run's artifacts: signs the report and bundles, pins
+// everything to IPFS, writes the forum post (which links the pinned
+// bundles), exports to Google Sheets, and notifies. bundles are the paths of
+// each result's bundle files. Returns every artifact, including the ones it
+// wrote.
+func publish(ctx context.Context, c *cli.Context, cfg *Config, outDir string, writer report.ReportWriter, signKey *ecdsa.PrivateKey, writeReport bool, results []*scan.Result, bundles [][]string, artifacts []string) ([]string, error) {
+	// Signed before pinning so the signatures are pinned too
+	var toSign []string
+	for _, name := range artifacts {
+		if name == writer.Name("report.txt") || strings.HasPrefix(name, "bundle") {
+			toSign = append(toSign, name)
+		}
+	}
+	signed, err := signArtifacts(ctx, outDir, toSign, signKey, c.String("gpg-key"))
+	if err != nil {
+		return nil, err
+	}
+	artifacts = append(artifacts, signed...)
+
+	var pinned map[string]string
+	if cfg.IPFS != nil && !c.Bool("no-pin") {
+		pinned = pinArtifacts(ctx, cfg, outDir, artifacts)
+		if len(pinned) > 0 {
+			data, err := json.MarshalIndent(pinned, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			name := writer.Name("ipfs.json")
+			if err := os.WriteFile(filepath.Join(outDir, name), data, 0644); err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, name)
+		}
+	}
+
+	link := func(name string) string {
+		if cid, ok := pinned[name]; ok {
+			return cfg.IPFSLink(cid, name)
+		} else if cfg.ArtifactsURL != "" {
+			return strings.TrimSuffix(cfg.ArtifactsURL, "/") + "/" + name
+		}
+		return filepath.Join(outDir, name)
+	}
+
+	// Written after pinning so the post can link the pinned bundles. A local
+	// path means nothing to the forum, so those are left as names to attach.
+	if writeReport && slices.Contains(c.StringSlice("governance"), governance.Forum) {
+		bundleLinks := make([][]string, len(results))
+		for i, paths := range bundles {
+			for _, path := range paths {
+				name := filepath.Base(path)
+				if _, ok := pinned[name]; ok || cfg.ArtifactsURL != "" {
+					name = link(name)
+				}
+				bundleLinks[i] = append(bundleLinks[i], name)
+			}
+		}
+		name := writer.Name("forum.md")
+		if err := os.WriteFile(filepath.Join(outDir, name), []byte(governance.NewForumPost(results, bundleLinks)), 0644); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, name)
+	}
+
+	if cfg.Sheets != nil && !c.Bool("no-sheets") {
+		// Like notifications, a failed export is logged without failing the run
+		if err := exportSheets(ctx, cfg, results); err != nil {
+			errorsTotal.Inc("sheets")
+			slog.Warn("Google Sheets export failed", "err", err)
+		}
+	}
+
+	if hooks := cfg.Webhooks(); len(hooks) > 0 && !c.Bool("no-notify") {
+		links := make([]string, len(artifacts))
+		for i, name := range artifacts {
+			links[i] = link(name)
+		}
+		// The artifacts are already written, so a failed notification
+		// shouldn't fail the run
+		if err := notify.New(hooks).Send(ctx, notify.NewSummary(results, links)); err != nil {
+			errorsTotal.Inc("notify")
+			slog.Warn("Notification failed", "err", err)
+		}
+	}
+
+	return artifacts, nil
+}
+
+// Signs each of names in outDir with key (next to it as <name>.sig) and with
+// the GPG key gpgKey (as <name>.asc), for whichever are set. Returns the
+// signatures' names.
+func signArtifacts(ctx context.Context, outDir string, names []string, key *ecdsa.PrivateKey, gpgKey string) ([]string, error) {
+	var signed []string
+	for _, name := range names {
+		path := filepath.Join(outDir, name)
+		if key != nil {
+			if _, err := sign.WriteFile(path, key); err != nil {
+				return nil, fmt.Errorf("signing %s: %w", name, err)
+			}
+			signed = append(signed, name+".sig")
+		}
+		if gpgKey != "" {
+			if _, err := sign.GPG(ctx, path, gpgKey); err != nil {
+				return nil, fmt.Errorf("signing %s: %w", name, err)
+			}
+			signed = append(signed, name+".asc")
+		}
+	}
+	return signed, nil
+}
+
+// How the bundles of res are built
+func bundleBuilder(c *cli.Context, res *scan.Result) bundle.BundleBuilder {
+	builder := bundle.BundleBuilder{MaxTransfers: c.Int("max-transfers")}
+	if c.Bool("multisend") {
+		builder.MultiSend = &res.Chain.MultiSend
+	}
+	return builder
+}
+
+// Checks, over the chain's RPC endpoints, that the chain's Safe can afford
+// its bundle (setting res.Balance) and that no recipient of a plain ETH
+// transfer rejects it (setting res.RejectsETH), unless the --no-*-check flags
+// say not to. A failed check is logged without failing the run.
+func preflight(ctx context.Context, c *cli.Context, res *scan.Result, retry scan.RetryPolicy) {
+	checkBalance := !c.Bool("no-balance-check") && res.Chain.Safe != nil
+	p := res.Payout
+	checkRecipients := !c.Bool("no-recipient-check") && p.Token == nil && p.Terminal == nil && p.Claim == nil && p.Split == nil
+	if (!checkBalance && !checkRecipients) || len(res.Chain.RPCURLs) == 0 || len(res.Errors) > 0 {
+		return
+	}
+	client, err := dialChain(ctx, c, res.Chain, retry)
+	if err != nil {
+		slog.Warn("Couldn't connect to check the Safe and recipients", "chain", res.Chain.Name, "err", err)
+		return
 	}
+	defer client.Close()
+
+	if checkBalance {
+		parts, err := bundleBuilder(c, res).BuildParts(res)
+		if err == nil {
+			res.Balance, err = simulate.CheckBalance(ctx, client, res, parts)
+		}
+		if err != nil {
+			slog.Warn("Couldn't check the Safe's balance", "chain", res.Chain.Name, "err", err)
+		} else if res.Balance.Short() {
+			slog.Warn("The Safe holds less than the bundle needs; see the report", "chain", res.Chain.Name, "safe", res.Chain.Safe.Hex(), "balance", scan.FormatEther(res.Balance.Balance), "needed", scan.FormatEther(res.Balance.Needed()))
+		}
+	}
+
+	if checkRecipients {
+		var from common.Address
+		if res.Chain.Safe != nil {
+			from = *res.Chain.Safe
+		}
+		if res.RejectsETH, err = simulate.RejectingRecipients(ctx, client, from, bundle.Paid(res)); err != nil {
+			slog.Warn("Couldn't check recipients accept ETH", "chain", res.Chain.Name, "err", err)
+		}
+		for _, addr := range scan.SortedAddresses(res.RejectsETH) {
+			slog.Warn("A recipient's contract rejects ETH, so its transfer would revert the bundle; see the report", "chain", res.Chain.Name, "recipient", addr.Hex(), "err", res.RejectsETH[addr])
+		}
+	}
+}
+
+// Simulates the calls of all of a chain's bundle parts from its Safe, one
+// after another, as --simulate says to
+func simulateBundle(ctx context.Context, c *cli.Context, cfg *Config, res *scan.Result, parts []bundle.Part) error {
+	var calls []bundle.Transaction
+	for _, part := range parts {
+		partCalls, err := bundle.Calls(part.Bundle)
+		if err != nil {
+			return err
+		}
+		calls = append(calls, partCalls...)
+	}
+
+	var sim simulate.Simulator
+	switch c.String("simulate") {
+	case "":
+		return nil
+	case SimulateTenderly:
+		sim = cfg.TenderlySimulator()
+	case SimulateFork:
+		cc, _ := cfg.ChainByID(res.Chain.ChainID.Uint64())
+		fork, err := simulate.DialFork(ctx, cc.Fork())
+		if err != nil {
+			return fmt.Errorf("%s: connecting to the fork: %w", res.Chain.Name, err)
+		}
+		defer fork.Close()
+		sim = fork
+	}
+
+	if err := sim.Simulate(ctx, res.Chain.ChainID, *res.Chain.Safe, calls); err != nil {
+		return fmt.Errorf("%s: simulating the bundle from Safe %s: %w", res.Chain.Name, res.Chain.Safe.Hex(), err)
+	}
+	slog.Info("Simulated the bundle", "chain", res.Chain.Name, "calls", len(calls), "with", c.String("simulate"))
+	return nil
+}
+
+// The file name of part of parts of a chain's bundle: bundle.json, or
+// bundle-<chain>.json when several chains are scanned, with -<part> when it's
+// split and suffix before .json
+func bundleName(chain string, multiChain bool, part, parts int, suffix string) string {
+	name := "bundle"
+	if multiChain {
+		name += "-" + chain
+	}
+	if parts > 1 {
+		name += fmt.Sprintf("-%d", part)
+	}
+	return name + suffix + ".json"
+}
+
+// What's added to the names of the artifacts for results so runs don't
+// overwrite each other's: -<date>_<start block>-<end block>, with each
+// chain's name and range (-<date>_<chain>-<start block>-<end block>_...) for
+// several chains, or nothing with --plain-names, after the profile's artifact
+// name if there is one
+func artifactSuffix(c *cli.Context, cfg *Config, now time.Time, results []*scan.Result) string {
+	var suffix string
+	if cfg.artifactName != "" {
+		suffix = "-" + cfg.artifactName
+	}
+	if c.Bool("plain-names") {
+		return suffix
+	}
+	suffix += "-" + now.UTC().Format(time.DateOnly)
+	if len(results) == 1 {
+		return suffix + fmt.Sprintf("_%s-%s", results[0].StartBlock, results[0].EndBlock)
+	}
+	for _, res := range results {
+		suffix += fmt.Sprintf("_%s-%s-%s", res.Chain.Name, res.StartBlock, res.EndBlock)
+	}
+	return suffix
+}
+
+// Writes snapshot.json and/or nance.json (with suffix before .json) with the
+// Markdown report as the proposal body, returning the files written
+func writeGovernance(cfg *Config, outDir, suffix string, results []*scan.Result, targets []string) ([]string, error) {
+	body, err := report.RenderMarkdown(results)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	write := func(name string, v any) error {
+		name = strings.TrimSuffix(name, ".json") + suffix + ".json"
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(outDir, name), data, 0644); err != nil {
+			return err
+		}
+		files = append(files, name)
+		return nil
+	}
+
+	if slices.Contains(targets, governance.Snapshot) {
+		opts, err := cfg.Governance.SnapshotOptions()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > governance.SnapshotBodyLimit {
+			slog.Warn("Report is longer than Snapshot allows most spaces, so the proposal may be rejected", "chars", len(body), "limit", governance.SnapshotBodyLimit)
+		}
+		if err := write("snapshot.json", governance.NewSnapshotProposal(results, string(body), opts, runTime())); err != nil {
+			return nil, err
+		}
+	}
+	if slices.Contains(targets, governance.Nance) {
+		if err := write("nance.json", governance.NewNanceProposal(results, string(body))); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Pins each artifact, returning their CIDs by file name. Like notifications,
+// failures are logged without failing the run.
+func pinArtifacts(ctx context.Context, cfg *Config, outDir string, artifacts []string) map[string]string {
+	pinner := cfg.Pinner()
+	pinned := make(map[string]string)
+	for _, name := range artifacts {
+		cid, err := pinner.PinFile(ctx, filepath.Join(outDir, name), name)
+		if err != nil {
+			errorsTotal.Inc("pin")
+			slog.Warn("IPFS pinning failed", "file", name, "err", err)
+			continue
+		}
+		pinned[name] = cid
+		slog.Info("Pinned to IPFS", "file", name, "cid", cid, "url", cfg.IPFSLink(cid, name))
+	}
+	return pinned
+}
+
+// Appends the complete chains' rows to the configured spreadsheet. Incomplete
+// chains are left out, since they'll be scanned again.
+func exportSheets(ctx context.Context, cfg *Config, results []*scan.Result) error {
+	client, err := cfg.SheetsClient()
+	if err != nil {
+		return err
+	}
+	var complete []*scan.Result
+	for _, res := range results {
+		if len(res.Errors) == 0 {
+			complete = append(complete, res)
+		}
+	}
+	txTab, recipientTab := cfg.Sheets.Tabs()
+	return client.Export(ctx, txTab, recipientTab, complete)
+}
 
-	json, err := json.Marshal(bundle)
-	fatalLog(err)
+// Dials the chain's RPC endpoints, recording the answers with --record-rpc, or
+// replays the chain's fixture instead with --replay-rpc
+func dialChain(ctx context.Context, c *cli.Context, chain *scan.Chain, retry scan.RetryPolicy) (scan.Client, error) {
+	if dir := c.String("replay-rpc"); dir != "" {
+		fixture, err := rpcfixture.Load(filepath.Join(dir, chain.Name+".json"))
+		if err != nil {
+			return nil, fmt.Errorf("replaying %s: %w", chain.Name, err)
+		}
+		return rpcfixture.Replay(fixture), nil
+	}
+	client, err := scan.Dial(ctx, chain.RPCURLs, retry)
+	if err != nil {
+		return nil, err
+	}
+	if dir := c.String("record-rpc"); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		return rpcfixture.Record(client, filepath.Join(dir, chain.Name+".json")), nil
+	}
+	return client, nil
+}
 
-	err = os.WriteFile("bundle.json", json, 0644)
-	fatalLog(err)
+// Scans one chain and sets its payout
+func scanChain(ctx context.Context, c *cli.Context, chain *scan.Chain, retry scan.RetryPolicy, cache *scan.TxCache, coingecko *scan.CoinGecko) (*scan.Result, error) {
+	var client scan.Client
+	if chain.EtherscanAPI != "" {
+		client = scan.NewEtherscan(chain.EtherscanAPI, c.String("etherscan-api-key"), chain.ChainID, c.Int("etherscan-rate"), retry)
+	} else {
+		var err error
+		if client, err = dialChain(ctx, c, chain, retry); err != nil {
+			return nil, err
+		}
+	}
+	defer client.Close()
+
+	var prices scan.PriceSource
+	if needsPrices(c) {
+		if chain.PriceFeed != nil {
+			prices = scan.NewChainlinkFeed(client, *chain.PriceFeed)
+		} else {
+			prices = coingecko
+		}
+	}
+
+	opts := scan.Options{
+		Cache:       cache,
+		Concurrency: c.Int("concurrency"),
+		BatchSize:   c.Int("batch-size"),
+		LogRange:    c.Uint64("log-range"),
+		ItemRetries: c.Int("item-retries"),
+		TraceAPI:    c.String("trace-api"),
+		Archive:     c.Bool("archive"),
+	}
+	if every := c.Duration("progress-interval"); every > 0 {
+		opts.Progress = scan.NewProgress()
+		stop := logProgress(chain.Name, opts.Progress, every)
+		defer stop()
+	}
+	if chain.SubgraphURL != "" {
+		opts.Subgraph = scan.NewSubgraph(chain.SubgraphURL, retry)
+	}
+	if c.Bool("usd") {
+		opts.Prices = prices
+	}
+
+	res, err := scan.NewScanner(client, opts).Scan(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+	// A partial result is still priced and paid out like a full one
+	if res.Interrupted {
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	if c.Bool("usd") || chain.USDC != nil {
+		// Convert at the price as of the end of the period
+		price, err := prices.ETHUSD(ctx, res.EndBlock.Uint64(), res.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if c.Bool("usd") {
+			res.ReportETHUSD = price
+		}
+		if chain.USDC != nil {
+			res.Payout = scan.Payout{
+				Token:      &scan.PayoutToken{Symbol: "USDC", Address: *chain.USDC, Decimals: 6},
+				Rate:       price,
+				RateSource: fmt.Sprintf("price at block %s", res.EndBlock),
+			}
+		}
+	}
+	if chain.JBX != nil {
+		if res.Payout, err = chain.JBX.Payout(ctx, client, res.EndBlock); err != nil {
+			return nil, err
+		}
+	}
+	res.Payout.Terminal = chain.Terminal
+	if chain.ClaimContract != nil {
+		res.Payout.Claim = &scan.ClaimContract{Address: *chain.ClaimContract}
+	}
+	res.Payout.Stream = chain.Stream
+	res.Payout.Split = chain.Split
+	return res, nil
+}
+
+// The --stream-* flags, without the lockup contract
+func streamSettings(c *cli.Context) (*scan.SablierStream, error) {
+	duration, err := parseInterval(c.String("stream-duration"))
+	if err != nil {
+		return nil, fmt.Errorf("--stream-duration: %w", err)
+	}
+	stream := &scan.SablierStream{Duration: duration, Cancelable: c.Bool("stream-cancelable")}
+	if cliff := c.String("stream-cliff"); cliff != "" {
+		if stream.Cliff, err = parseInterval(cliff); err != nil {
+			return nil, fmt.Errorf("--stream-cliff: %w", err)
+		}
+		if stream.Cliff >= duration {
+			return nil, fmt.Errorf("--stream-cliff %s must be shorter than --stream-duration %s", cliff, c.String("stream-duration"))
+		}
+	}
+	return stream, nil
+}
+
+// What's missing from a run's results, or nil if nothing is
+func incompleteError(results []*scan.Result, chainErrs []error) error {
+	errs := chainErrs
+	for _, res := range results {
+		if n := len(res.Errors); n > 0 {
+			errs = append(errs, fmt.Errorf("%s: %d transactions or blocks couldn't be fetched (listed in the report)", res.Chain.Name, n))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("results are incomplete: %w", errors.Join(errs...))
+}
+
+// Selects the chains to scan and applies flag overrides. Block and RPC flags
+// are only allowed to override a single chain.
+func resolveChains(c *cli.Context, cfg *Config) ([]*scan.Chain, error) {
+	selected := []ChainConfig{}
+	if names := c.StringSlice("chain"); len(names) > 0 {
+		for _, name := range names {
+			found := false
+			for _, chain := range cfg.Chains {
+				if chain.Name == name {
+					selected = append(selected, chain)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("chain %q not found in config", name)
+			}
+		}
+	} else {
+		selected = cfg.Chains
+	}
+
+	switch api := c.String("trace-api"); api {
+	case scan.TraceFilter, scan.TraceDebug:
+	default:
+		return nil, fmt.Errorf("--trace-api must be %s or %s, got %q", scan.TraceFilter, scan.TraceDebug, api)
+	}
+
+	var checkpoint *Checkpoint
+	if path := c.String("resume"); path != "" {
+		for _, flag := range []string{"chain", "from-block", "to-block", "since-last-run"} {
+			if c.IsSet(flag) {
+				return nil, fmt.Errorf("--%s can't be used with --resume, which takes the chains and blocks from the checkpoint", flag)
+			}
+		}
+		var err error
+		if checkpoint, err = loadCheckpoint(path); err != nil {
+			return nil, err
+		}
+		selected = slices.DeleteFunc(slices.Clone(selected), func(cc ChainConfig) bool {
+			_, ok := checkpoint.Chains[strconv.FormatUint(cc.ChainID, 10)]
+			return !ok
+		})
+		if len(selected) < len(checkpoint.Chains) {
+			return nil, fmt.Errorf("checkpoint %s has chains that aren't in the config", path)
+		}
+	}
+
+	if len(selected) > 1 {
+		for _, flag := range []string{"from-block", "to-block"} {
+			if c.IsSet(flag) {
+				return nil, fmt.Errorf("--%s can only be used with a single chain; set it per chain in the config or select one with --chain", flag)
+			}
+		}
+	}
+
+	classifiers := cfg.ScanClassifiers()
+	chains := []*scan.Chain{}
+	for _, cc := range selected {
+		chain := &scan.Chain{
+			Name:      cc.Name,
+			ChainID:   new(big.Int).SetUint64(cc.ChainID),
+			RPCURLs:   cc.Endpoints(),
+			Explorer:  cc.ExplorerURL(),
+			GasModel:  cc.Gas(),
+			MultiSend: cc.MultiSendAddress(),
+		}
+		if cc.Safe != "" {
+			addr := common.HexToAddress(cc.Safe)
+			chain.Safe = &addr
+		}
+
+		switch payIn := c.String("pay-in"); payIn {
+		case PayInETH:
+		case PayInUSDC:
+			if chain.USDC = cc.USDCAddress(); chain.USDC == nil {
+				return nil, fmt.Errorf("%s: no usdc token address for chain ID %d", cc.Name, cc.ChainID)
+			}
+		case PayInJBX:
+			var err error
+			if chain.JBX, err = cc.JBXPayout(c.String("jbx-rate"), c.Duration("twap-window")); err != nil {
+				return nil, fmt.Errorf("%s: %w", cc.Name, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown --pay-in %q", payIn)
+		}
+
+		switch payVia := c.String("pay-via"); payVia {
+		case PayViaTransfer:
+		case PayViaJuicebox:
+			if chain.USDC != nil || chain.JBX != nil {
+				return nil, fmt.Errorf("--pay-via juicebox only supports paying in ETH")
+			}
+			if chain.Terminal = cc.JuiceboxTerminal(); chain.Terminal == nil {
+				return nil, fmt.Errorf("%s: no Juicebox terminal (set terminal in the config)", cc.Name)
+			}
+		case PayViaMerkle:
+			if cc.ClaimContract == "" {
+				return nil, fmt.Errorf("%s: no claim contract (set claimContract in the config)", cc.Name)
+			}
+			addr := common.HexToAddress(cc.ClaimContract)
+			chain.ClaimContract = &addr
+		case PayViaSablier:
+			if chain.USDC == nil && chain.JBX == nil {
+				return nil, fmt.Errorf("--pay-via sablier streams tokens, so needs --pay-in usdc or jbx")
+			}
+			if cc.SablierLockup == "" {
+				return nil, fmt.Errorf("%s: no Sablier LockupLinear contract (set sablierLockup in the config)", cc.Name)
+			}
+			if chain.Safe == nil {
+				return nil, fmt.Errorf("%s: --pay-via sablier needs the chain's safe, which sends the streams", cc.Name)
+			}
+			stream, err := streamSettings(c)
+			if err != nil {
+				return nil, err
+			}
+			stream.Lockup = common.HexToAddress(cc.SablierLockup)
+			chain.Stream = stream
+		case PayViaSplits:
+			if cc.Split == "" {
+				return nil, fmt.Errorf("%s: no 0xSplits split (set split in the config)", cc.Name)
+			}
+			if chain.Safe == nil {
+				return nil, fmt.Errorf("%s: --pay-via splits needs the chain's safe, which controls the split", cc.Name)
+			}
+			chain.Split = &scan.SplitContract{Address: common.HexToAddress(cc.Split), Main: scan.SplitMainAddress}
+			if cc.SplitMain != "" {
+				chain.Split.Main = common.HexToAddress(cc.SplitMain)
+			}
+		default:
+			return nil, fmt.Errorf("unknown --pay-via %q", payVia)
+		}
+
+		switch target := c.String("simulate"); target {
+		case "":
+		case SimulateTenderly, SimulateFork:
+			if target == SimulateTenderly && cfg.Tenderly == nil {
+				return nil, fmt.Errorf("--simulate tenderly needs a tenderly project in the config")
+			}
+			if chain.Safe == nil {
+				return nil, fmt.Errorf("%s: --simulate needs the chain's safe to simulate from", cc.Name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown --simulate %q", target)
+		}
+
+		if needsPrices(c) {
+			switch source := c.String("price-source"); source {
+			case scan.PriceSourceAuto:
+				// Etherscan's eth_call can't read a feed at past blocks
+				if c.String("source") != SourceEtherscan {
+					chain.PriceFeed = cc.Feed()
+				}
+			case scan.PriceSourceChainlink:
+				if chain.PriceFeed = cc.Feed(); chain.PriceFeed == nil {
+					return nil, fmt.Errorf("%s: no Chainlink priceFeed for chain ID %d", cc.Name, cc.ChainID)
+				}
+			case scan.PriceSourceCoinGecko:
+			default:
+				return nil, fmt.Errorf("unknown --price-source %q", source)
+			}
+		}
+
+		switch source := c.String("source"); source {
+		case SourceRPC:
+		case SourceEtherscan:
+			if chain.EtherscanAPI = cc.EtherscanAPI; chain.EtherscanAPI == "" {
+				chain.EtherscanAPI = scan.EtherscanAPI
+			}
+		case SourceSubgraph:
+			if chain.SubgraphURL = cc.Subgraph(); chain.SubgraphURL == "" {
+				if chain.SubgraphURL = c.String("subgraph-url"); chain.SubgraphURL == "" || len(selected) > 1 {
+					return nil, fmt.Errorf("%s: no subgraph URL (set subgraphUrl in the config, or --subgraph-url when scanning one chain)", cc.Name)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown --source %q", source)
+		}
+
+		if len(chain.RPCURLs) == 0 && chain.EtherscanAPI == "" {
+			if len(selected) > 1 {
+				return nil, fmt.Errorf("%s: rpcUrl must be set in the config when scanning multiple chains", cc.Name)
+			}
+			if chain.RPCURLs = c.StringSlice("rpc-url"); len(chain.RPCURLs) == 0 {
+				return nil, fmt.Errorf("%s: no RPC URL (set rpcUrl in the config, --rpc-url, or the RPC_URL env var)", cc.Name)
+			}
+		}
+
+		resumed, resuming := CheckpointChain{}, checkpoint != nil
+		if resuming {
+			resumed = checkpoint.Chains[chain.ChainID.String()]
+		}
+		switch {
+		case resuming:
+			chain.StartBlock = new(big.Int).SetUint64(resumed.FromBlock)
+		case c.IsSet("from-block"):
+			chain.StartBlock = new(big.Int).SetUint64(c.Uint64("from-block"))
+		case cc.FromBlock != nil:
+			chain.StartBlock = new(big.Int).SetUint64(*cc.FromBlock)
+		case c.Bool("since-last-run"):
+			// Set from the state file
+		default:
+			return nil, fmt.Errorf("%s: no start block (set fromBlock in the config or --from-block)", cc.Name)
+		}
+
+		switch {
+		case resumed.ToBlock != nil:
+			chain.EndBlock = new(big.Int).SetUint64(*resumed.ToBlock)
+		case c.IsSet("to-block"):
+			chain.EndBlock = new(big.Int).SetUint64(c.Uint64("to-block"))
+		case cc.ToBlock != nil:
+			chain.EndBlock = new(big.Int).SetUint64(*cc.ToBlock)
+		}
+		chain.Confirmations = cc.Confirmations
+		if c.IsSet("confirmations") {
+			confirmations := c.Uint64("confirmations")
+			chain.Confirmations = &confirmations
+		}
+		if chain.EndBlock != nil && chain.StartBlock != nil && chain.EndBlock.Cmp(chain.StartBlock) < 0 {
+			return nil, fmt.Errorf("%s: end block %s is before start block %s", cc.Name, chain.EndBlock, chain.StartBlock)
+		}
+
+		chain.Groups = cc.TxGroups()
+		chain.Classifiers = classifiers
+		if chain.EtherscanAPI != "" {
+			for _, g := range chain.Groups {
+				if g.Traces {
+					return nil, fmt.Errorf("%s: group %q sets traces, which --source etherscan can't fetch", cc.Name, g.Label)
+				}
+			}
+		}
+		for _, sc := range cc.Safes {
+			chain.Safes = append(chain.Safes, common.HexToAddress(sc.Address))
+		}
+		chain.Exclusions = cfg.Exclusions()
+		chain.Relayers = cc.RelayPayers()
+		chain.Beneficiaries = cfg.BeneficiaryAddresses()
+		chain.SenderSplits = cfg.SenderShares()
+		var err error
+		if chain.LineItems, err = cc.Items(); err != nil {
+			return nil, fmt.Errorf("%s: %w", cc.Name, err)
+		}
+		if chain.Rules, err = cfg.ScanRules(); err != nil {
+			return nil, fmt.Errorf("%s: %w", cc.Name, err)
+		}
+		if chain.OwnersOnly = c.Bool("owners-only"); chain.OwnersOnly {
+			if chain.Safe == nil && len(chain.Safes) == 0 && len(cfg.Allow) == 0 {
+				return nil, fmt.Errorf("%s: --owners-only needs a safe, safes, or an allow list in the config", cc.Name)
+			}
+			chain.Allowed = cfg.Allowed()
+		}
+		chain.Labels = cfg.AddressLabels()
+		// Safes are shown by name unless the label book names them otherwise
+		for _, sc := range cc.Safes {
+			addr := common.HexToAddress(sc.Address)
+			if chain.Labels[addr] == "" {
+				chain.Labels[addr] = strings.TrimSpace(sc.Name)
+			}
+		}
+
+		maxPerRecipient := cc.MaxPerRecipient
+		if c.IsSet("max-per-recipient") {
+			maxPerRecipient = c.String("max-per-recipient")
+		}
+		if maxPerRecipient != "" {
+			var err error
+			if chain.RecipientCap, err = parseEther(maxPerRecipient); err != nil {
+				return nil, fmt.Errorf("%s: invalid max per recipient: %w", cc.Name, err)
+			}
+		}
+
+		switch bucket := scan.Bucket(c.String("bucket")); bucket {
+		case "", scan.BucketWeek, scan.BucketMonth:
+			chain.Bucket = bucket
+		default:
+			return nil, fmt.Errorf("--bucket must be week or month, got %q", bucket)
+		}
+
+		minPayout := cc.MinPayout
+		if c.IsSet("min-payout") {
+			minPayout = c.String("min-payout")
+		}
+		if minPayout != "" {
+			var err error
+			if chain.MinPayout, err = parseEther(minPayout); err != nil {
+				return nil, fmt.Errorf("%s: invalid min payout: %w", cc.Name, err)
+			}
+		}
+
+		maxGasPrice := cc.MaxGasPrice
+		if c.IsSet("max-gas-price") {
+			maxGasPrice = c.String("max-gas-price")
+		}
+		if maxGasPrice != "" {
+			var err error
+			if chain.MaxGasPrice, err = parseGwei(maxGasPrice); err != nil {
+				return nil, fmt.Errorf("%s: invalid max gas price: %w", cc.Name, err)
+			}
+		}
+		maxTip := cc.MaxTip
+		if c.IsSet("max-tip") {
+			maxTip = c.String("max-tip")
+		}
+		if maxTip != "" {
+			var err error
+			if chain.MaxTip, err = parseGwei(maxTip); err != nil {
+				return nil, fmt.Errorf("%s: invalid max tip: %w", cc.Name, err)
+			}
+			chain.CapTips = cc.MaxTipAction == "cap"
+		}
+
+		chains = append(chains, chain)
+	}
+
+	return chains, nil
+}
+
+// Signs a bundle as one Safe transaction and submits it to the Transaction Service
+func proposeAction(c *cli.Context) error {
+	path, err := bundlePath(c)
+	if err != nil {
+		return err
+	}
+
+	// Chain settings come from the config if there's one for the bundle's chain ID
+	cfg, err := loadProfile(c)
+	if err != nil && (c.IsSet("config") || c.IsSet("profile")) {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, time.Minute)
+	defer cancel()
+
+	return proposeBundle(ctx, c, cfg, path)
+}
+
+// Proposes the bundle at path, with the Safe, service, and key from cfg (if
+// non-nil) and c's flags
+func proposeBundle(ctx context.Context, c *cli.Context, cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var b bundle.TransactionBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	chainID, ok := new(big.Int).SetString(b.ChainID, 10)
+	if !ok || !chainID.IsUint64() {
+		return fmt.Errorf("invalid chainId %q", b.ChainID)
+	}
+
+	var chain ChainConfig
+	if cfg != nil {
+		chain, _ = cfg.ChainByID(chainID.Uint64())
+	}
+	chain.ChainID = chainID.Uint64()
+
+	safeAddr := chain.Safe
+	if c.IsSet("safe") {
+		safeAddr = c.String("safe")
+	}
+	if !common.IsHexAddress(safeAddr) {
+		return fmt.Errorf("no valid Safe address for chain ID %s (set safe in the config or --safe)", chainID)
+	}
+	safeAddress := common.HexToAddress(safeAddr)
+
+	serviceURL := chain.SafeServiceURL()
+	if c.IsSet("safe-service-url") {
+		serviceURL = c.String("safe-service-url")
+	}
+	if serviceURL == "" {
+		return fmt.Errorf("no Safe Transaction Service URL for chain ID %s (set safeService in the config or --safe-service-url)", chainID)
+	}
+
+	if c.String("private-key") == "" {
+		return fmt.Errorf("no signing key (use --private-key or the PROPOSER_PRIVATE_KEY env var)")
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(c.String("private-key"), "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	transfers, err := bundle.Transfers(b)
+	if err != nil {
+		return err
+	}
+
+	tx, err := safe.FromBundle(b, chain.MultiSendAddress())
+	if err != nil {
+		return err
+	}
+
+	service := safe.NewService(serviceURL, c.String("safe-api-key"))
+	if c.IsSet("nonce") {
+		tx.Nonce = c.Uint64("nonce")
+	} else if tx.Nonce, err = service.NextNonce(ctx, safeAddress); err != nil {
+		return err
+	}
+
+	hash := tx.Hash(chainID, safeAddress)
+	signature, err := safe.Sign(hash, key)
+	if err != nil {
+		return err
+	}
+
+	if err := service.Propose(ctx, safeAddress, tx, hash, sender, signature); err != nil {
+		return err
+	}
+
+	fmt.Printf("Proposed %d transfers to Safe %s on chain %s\nNonce: %d\nSafe tx hash: %s\nProposer: %s\n",
+		len(transfers), safeAddress.Hex(), chainID, tx.Nonce, hash.Hex(), sender.Hex())
+	return nil
+}
+
+// The bundle to propose or verify: --bundle, or else bundle.json in the out
+// dir, or else the newest bundle there (they're named by date and block range)
+func bundlePath(c *cli.Context) (string, error) {
+	if path := c.String("bundle"); path != "" {
+		return path, nil
+	}
+	dir := c.String("out-dir")
+	path := filepath.Join(dir, "bundle.json")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "bundle*.json"))
+	if err != nil {
+		return "", err
+	}
+	var newest time.Time
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.ModTime().After(newest) {
+			path, newest = m, info.ModTime()
+		}
+	}
+	if newest.IsZero() {
+		return "", fmt.Errorf("no bundle in %s; pass --bundle", dir)
+	}
+	slog.Info("Using the newest bundle", "path", path)
+	return path, nil
+}
+
+// Whether the run needs an ETH/USD price source
+func needsPrices(c *cli.Context) bool {
+	return c.Bool("usd") || c.String("pay-in") == PayInUSDC
+}
+
+// Checks that every transfer in a bundle has a valid recipient and amount,
+// and with --report, that the transfers are the ones the report lists
+func verifyAction(c *cli.Context) error {
+	path, err := bundlePath(c)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var b bundle.TransactionBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	if _, ok := new(big.Int).SetString(b.ChainID, 10); !ok {
+		return fmt.Errorf("invalid chainId %q", b.ChainID)
+	}
+
+	// The Safe UI rejects files whose checksum doesn't match; older bundles
+	// don't have one
+	if b.Meta.Checksum != "" {
+		sum, err := bundle.Checksum(data)
+		if err != nil {
+			return err
+		}
+		if sum != b.Meta.Checksum {
+			return fmt.Errorf("meta.checksum is %s, but the bundle's checksum is %s (was it edited?)", b.Meta.Checksum, sum)
+		}
+	}
+
+	transfers, err := bundle.Transfers(b)
+	if err != nil {
+		return err
+	}
+
+	// Totals keyed by token, with the zero address for ETH
+	totals := make(map[common.Address]*big.Int)
+	seen := make(map[common.Address]bool)
+	for i, t := range transfers {
+		if seen[t.Recipient] {
+			return fmt.Errorf("transfer %d: duplicate recipient %s", i, t.Recipient.Hex())
+		}
+		seen[t.Recipient] = true
+
+		if t.Amount.Sign() <= 0 {
+			return fmt.Errorf("transfer %d: invalid amount %s to %s", i, t.Amount, t.Recipient.Hex())
+		}
+		if totals[t.Token] == nil {
+			totals[t.Token] = big.NewInt(0)
+		}
+		totals[t.Token].Add(totals[t.Token], t.Amount)
+	}
+
+	fmt.Printf("%s OK: %d transfers in %d transactions on chain %s\n", path, len(transfers), len(b.Transactions), b.ChainID)
+	for _, token := range scan.SortedAddresses(totals) {
+		fmt.Printf("  %s\n", formatAmount(token, totals[token]))
+	}
+
+	if reportPath := c.String("report"); reportPath != "" {
+		summary, err := verifyAgainstReport(b, filepath.Base(path), transfers, reportPath)
+		if err != nil {
+			return fmt.Errorf("%s doesn't match %s: %w", path, reportPath, err)
+		}
+		fmt.Printf("  matches %s: %s\n", reportPath, summary)
+	}
+	return nil
+}
+
+// Checks transfers, from the bundle file named name, against the chain with
+// the bundle's chain ID in the report at reportPath: every recipient it pays
+// (in that file, when its bundle is split) is paid what it says, in its
+// payout asset, and nobody else is, for the total it reports. Paying through
+// a claim contract or split, the bundle's one transfer funds it with the
+// total instead. transfers must pay each recipient once, as verifyAction
+// checks first.
+func verifyAgainstReport(b bundle.TransactionBundle, name string, transfers []bundle.Transfer, reportPath string) (string, error) {
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		return "", err
+	}
+	var r report.JSONReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return "", fmt.Errorf("parsing %s: %w", reportPath, err)
+	}
+
+	var chain *report.JSONChainReport
+	var ids []string
+	for i := range r.Chains {
+		if r.Chains[i].ChainID == b.ChainID {
+			chain = &r.Chains[i]
+		}
+		ids = append(ids, r.Chains[i].ChainID)
+	}
+	if chain == nil {
+		return "", fmt.Errorf("the bundle is for chain %s, but the report only covers chains %s", b.ChainID, strings.Join(ids, ", "))
+	}
+
+	expected := make(map[common.Address]*big.Int)
+	for _, rec := range chain.Recipients {
+		amount, ok := new(big.Int).SetString(rec.PayoutAmount, 10)
+		if !ok {
+			return "", fmt.Errorf("invalid payoutAmount %q for %s", rec.PayoutAmount, rec.Address.Hex())
+		}
+		if amount.Sign() > 0 {
+			expected[rec.Address] = amount
+		}
+	}
+	// A split bundle's files each pay their own recipients
+	if len(chain.BundleFiles) > 0 {
+		i := slices.IndexFunc(chain.BundleFiles, func(f report.JSONBundleFile) bool { return f.Name == name })
+		if i < 0 {
+			return "", fmt.Errorf("%s isn't one of %s's bundle files", name, chain.Name)
+		}
+		inFile := make(map[common.Address]*big.Int)
+		for _, addr := range chain.BundleFiles[i].Recipients {
+			if expected[addr] != nil {
+				inFile[addr] = expected[addr]
+			}
+		}
+		expected = inFile
+	}
+
+	var token common.Address
+	if chain.Payout.Token != nil {
+		token = *chain.Payout.Token
+	}
+	want := big.NewInt(0)
+	for _, amount := range expected {
+		want.Add(want, amount)
+	}
+	got := big.NewInt(0)
+	for i, t := range transfers {
+		if t.Token != token {
+			return "", fmt.Errorf("transfer %d pays %s in %s, not the report's payout asset %s", i, t.Recipient.Hex(), assetName(t.Token), chain.Payout.Asset)
+		}
+		got.Add(got, t.Amount)
+	}
+
+	var funded *common.Address
+	switch p := chain.Payout; {
+	case p.Claim != nil:
+		funded = &p.Claim.Contract
+	case p.Split != nil:
+		funded = &p.Split.Address
+	}
+	if funded != nil {
+		if len(expected) > 0 && (len(transfers) != 1 || transfers[0].Recipient != *funded) {
+			return "", fmt.Errorf("the report pays through %s, but the bundle doesn't make one transfer to it", funded.Hex())
+		}
+		if got.Cmp(want) != 0 {
+			return "", fmt.Errorf("the bundle transfers %s in total, but the report pays %s", got, want)
+		}
+		return fmt.Sprintf("%d recipients through %s, %s in total", len(expected), funded.Hex(), formatAmount(token, want)), nil
+	}
+
+	paid := make(map[common.Address]bool)
+	for i, t := range transfers {
+		amount := expected[t.Recipient]
+		if amount == nil {
+			return "", fmt.Errorf("transfer %d pays %s, who the report doesn't pay", i, t.Recipient.Hex())
+		}
+		if t.Amount.Cmp(amount) != 0 {
+			return "", fmt.Errorf("transfer %d pays %s %s, but the report pays them %s", i, t.Recipient.Hex(), t.Amount, amount)
+		}
+		paid[t.Recipient] = true
+	}
+	for _, addr := range scan.SortedAddresses(expected) {
+		if !paid[addr] {
+			return "", fmt.Errorf("the report pays %s %s, but the bundle doesn't", addr.Hex(), expected[addr])
+		}
+	}
+	if got.Cmp(want) != 0 {
+		return "", fmt.Errorf("the bundle transfers %s in total, but the report pays %s", got, want)
+	}
+	return fmt.Sprintf("%d recipients, %s in total", len(expected), formatAmount(token, want)), nil
+}
+
+func assetName(token common.Address) string {
+	if token == (common.Address{}) {
+		return "ETH"
+	}
+	return "token " + token.Hex()
+}
+
+func formatAmount(token common.Address, amount *big.Int) string {
+	if token == (common.Address{}) {
+		return scan.FormatEther(amount) + " ETH"
+	}
+	return fmt.Sprintf("%s base units of token %s", amount, token.Hex())
+}
+
+func verifySignatureAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected the path of the signed file")
+	}
+	path := c.Args().First()
+	sigPath := c.String("signature")
+	if sigPath == "" {
+		sigPath = path + ".sig"
+	}
+
+	sig, err := sign.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	signer, err := sign.Verify(path, sig)
+	if err != nil {
+		return err
+	}
+	if want := c.String("signer"); want != "" {
+		if !common.IsHexAddress(want) {
+			return fmt.Errorf("invalid --signer %q", want)
+		}
+		if common.HexToAddress(want) != signer {
+			return fmt.Errorf("%s is signed by %s, not %s", path, signer.Hex(), common.HexToAddress(want).Hex())
+		}
+	}
 
-	err = os.WriteFile("report.txt", report.Bytes(), 0644)
-	fatalLog(err)
+	fmt.Printf("%s OK: keccak256 %s signed by %s\n", path, sig.Keccak256.Hex(), signer.Hex())
+	return nil
 }
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli/v2"
+
+	"juimburser/pkg/bundle"
+	"juimburser/pkg/report"
+	"juimburser/pkg/scan"
+	"juimburser/pkg/screen"
+)
+
+func TestSettle(t *testing.T) {
+	alice := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	paid := common.HexToHash("0x01")
+	unpaid := common.HexToHash("0x02")
+
+	// A state that already reimbursed paid, still owes bob, and held back
+	// some of alice's over the cap
+	ledger := func() *State {
+		return &State{Chains: map[string]*ChainState{
+			"1": {
+				Txs:      []common.Hash{paid},
+				Deferred: []DeferredTx{{Hash: common.HexToHash("0x03"), From: bob, Wei: "500"}},
+				Held:     []HeldAmount{{From: alice, Wei: "50"}},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		state    *State
+		screener screen.Screener
+		force    bool
+		// Drop what was held over the cap
+		forgiveHeld bool
+		wantTxs     []common.Hash
+		wantIn      map[common.Address]int64
+		denied      []common.Address
+	}{
+		{
+			name:    "first run",
+			state:   &State{Chains: map[string]*ChainState{}},
+			wantTxs: []common.Hash{paid, unpaid},
+		},
+		{
+			name:    "skips what was reimbursed",
+			state:   ledger(),
+			wantTxs: []common.Hash{unpaid},
+			wantIn:  map[common.Address]int64{alice: 50, bob: 500},
+		},
+		{
+			name:    "force includes it",
+			state:   ledger(),
+			force:   true,
+			wantTxs: []common.Hash{paid, unpaid},
+			wantIn:  map[common.Address]int64{alice: 50, bob: 500},
+		},
+		{
+			name:        "forgives what was held over the cap",
+			state:       ledger(),
+			forgiveHeld: true,
+			wantTxs:     []common.Hash{unpaid},
+			wantIn:      map[common.Address]int64{bob: 500},
+		},
+		{
+			name:     "holds back denied recipients",
+			state:    ledger(),
+			screener: screen.List{bob: "sanctioned"},
+			wantTxs:  []common.Hash{unpaid},
+			wantIn:   map[common.Address]int64{alice: 50, bob: 500},
+			denied:   []common.Address{bob},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &scan.Result{
+				Chain: &scan.Chain{Name: "mainnet", ChainID: big.NewInt(1)},
+				Txs: []scan.TxInfo{
+					{Hash: paid, From: alice, GasWei: big.NewInt(100)},
+					{Hash: unpaid, From: alice, GasWei: big.NewInt(200)},
+				},
+			}
+			if err := settle(context.Background(), res, tt.state, tt.screener, tt.force, tt.forgiveHeld); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []common.Hash
+			for _, tx := range res.Txs {
+				got = append(got, tx.Hash)
+			}
+			if len(got) != len(tt.wantTxs) {
+				t.Fatalf("kept %v, want %v", got, tt.wantTxs)
+			}
+			for i := range got {
+				if got[i] != tt.wantTxs[i] {
+					t.Fatalf("kept %v, want %v", got, tt.wantTxs)
+				}
+			}
+			if len(res.CarriedIn) != len(tt.wantIn) {
+				t.Fatalf("carried in %v, want %v", res.CarriedIn, tt.wantIn)
+			}
+			for addr, wei := range tt.wantIn {
+				if res.CarriedIn[addr] == nil || res.CarriedIn[addr].Int64() != wei {
+					t.Errorf("carried in %v for %s, want %d", res.CarriedIn[addr], addr.Hex(), wei)
+				}
+			}
+			if len(res.Denied) != len(tt.denied) {
+				t.Fatalf("denied %v, want %v", res.Denied, tt.denied)
+			}
+			for _, addr := range tt.denied {
+				if _, ok := res.Denied[addr]; !ok {
+					t.Errorf("%s isn't denied", addr.Hex())
+				}
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	alice := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol := common.HexToAddress("0x00000000000000000000000000000000000ca201")
+
+	// A report paying alice and bob 100 wei each
+	dir := t.TempDir()
+	data, err := json.Marshal(report.JSONReport{Chains: []report.JSONChainReport{{
+		Name:    "mainnet",
+		ChainID: "1",
+		Recipients: []report.JSONRecipient{
+			{Address: alice, PayoutAmount: "100"},
+			{Address: bob, PayoutAmount: "100"},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportPath := filepath.Join(dir, "report.json")
+	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := func(to common.Address, wei string) bundle.Transaction {
+		return bundle.Transaction{To: to.Hex(), Value: wei}
+	}
+	tests := []struct {
+		name string
+		txs  []bundle.Transaction
+		// Part of the error, empty if it should verify
+		err string
+	}{
+		{
+			name: "pays what it reports",
+			txs:  []bundle.Transaction{transfer(alice, "100"), transfer(bob, "100")},
+		},
+		{
+			name: "pays a recipient twice",
+			txs:  []bundle.Transaction{transfer(alice, "100"), transfer(alice, "100")},
+			err:  "transfer 1: duplicate recipient " + alice.Hex(),
+		},
+		{
+			name: "pays someone else",
+			txs:  []bundle.Transaction{transfer(alice, "100"), transfer(bob, "100"), transfer(carol, "100")},
+			err:  "who the report doesn't pay",
+		},
+		{
+			name: "pays the wrong amount",
+			txs:  []bundle.Transaction{transfer(alice, "100"), transfer(bob, "99")},
+			err:  "but the report pays them 100",
+		},
+		{
+			name: "misses a recipient",
+			txs:  []bundle.Transaction{transfer(alice, "100")},
+			err:  "but the bundle doesn't",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(bundle.TransactionBundle{Version: "1.0", ChainID: "1", Transactions: tt.txs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			bundlePath := filepath.Join(dir, "bundle.json")
+			if err := os.WriteFile(bundlePath, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			set := flag.NewFlagSet("verify", flag.ContinueOnError)
+			set.String("bundle", bundlePath, "")
+			set.String("report", reportPath, "")
+
+			err = verifyAction(cli.NewContext(cli.NewApp(), set, nil))
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("got %v, want it to verify", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestArtifactSuffix(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	result := func(chain string, start, end int64) *scan.Result {
+		return &scan.Result{Chain: &scan.Chain{Name: chain}, StartBlock: big.NewInt(start), EndBlock: big.NewInt(end)}
+	}
+	mainnet, optimism := result("mainnet", 100, 149), result("optimism", 5000, 5999)
+	tests := []struct {
+		name       string
+		plainNames bool
+		profile    string
+		results    []*scan.Result
+		want       string
+	}{
+		{name: "one chain", results: []*scan.Result{mainnet}, want: "-2024-07-01_100-149"},
+		{name: "several chains", results: []*scan.Result{mainnet, optimism}, want: "-2024-07-01_mainnet-100-149_optimism-5000-5999"},
+		{name: "profile", profile: "nana", results: []*scan.Result{mainnet}, want: "-nana-2024-07-01_100-149"},
+		{name: "plain names", plainNames: true, profile: "nana", results: []*scan.Result{mainnet, optimism}, want: "-nana"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := flag.NewFlagSet("run", flag.ContinueOnError)
+			set.Bool("plain-names", tt.plainNames, "")
+			c := cli.NewContext(cli.NewApp(), set, nil)
+			if got := artifactSuffix(c, &Config{artifactName: tt.profile}, now, tt.results); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// What's held over the cap is recorded, and owed again by the next run
+func TestRecordHeld(t *testing.T) {
+	alice := common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	chain := &scan.Chain{Name: "mainnet", ChainID: big.NewInt(1), RecipientCap: big.NewInt(100)}
+	state := &State{Chains: map[string]*ChainState{}}
+
+	first := &scan.Result{
+		Chain:      chain,
+		StartBlock: big.NewInt(1),
+		EndBlock:   big.NewInt(10),
+		Txs: []scan.TxInfo{
+			{Hash: common.HexToHash("0x01"), From: alice, GasWei: big.NewInt(250)},
+			{Hash: common.HexToHash("0x02"), From: bob, GasWei: big.NewInt(80)},
+		},
+	}
+	state.Record(first)
+	if held := state.Chains["1"].Held; len(held) != 1 || held[0].From != alice || held[0].Wei != "150" {
+		t.Fatalf("held %+v, want 150 wei for alice", held)
+	}
+
+	// alice is paid the cap again, and the rest is still held
+	second := &scan.Result{
+		Chain:      chain,
+		StartBlock: big.NewInt(11),
+		EndBlock:   big.NewInt(20),
+		Txs:        []scan.TxInfo{{Hash: common.HexToHash("0x03"), From: bob, GasWei: big.NewInt(10)}},
+	}
+	if err := settle(context.Background(), second, state, nil, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := second.Payable()[alice]; got == nil || got.Int64() != 100 {
+		t.Errorf("paying alice %v, want 100", got)
+	}
+	state.Record(second)
+	if held := state.Chains["1"].Held; len(held) != 1 || held[0].Wei != "50" {
+		t.Fatalf("held %+v after the second run, want 50 wei for alice", held)
+	}
+
+	// Once it's all paid, nothing's held
+	third := &scan.Result{Chain: chain, StartBlock: big.NewInt(21), EndBlock: big.NewInt(30)}
+	if err := settle(context.Background(), third, state, nil, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := third.Payable()[alice]; got == nil || got.Int64() != 50 {
+		t.Errorf("paying alice %v, want 50", got)
+	}
+	state.Record(third)
+	if held := state.Chains["1"].Held; len(held) != 0 {
+		t.Errorf("held %+v after paying it all", held)
+	}
+}
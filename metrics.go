@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"time"
+
+	"juimburser/pkg/metrics"
+)
+
+var (
+	runsTotal = metrics.Default.NewCounter("juimburser_runs_total",
+		"Runs finished, by trigger (cli, daemon, or api) and status (ok or failed)", "trigger", "status")
+	runDuration = metrics.Default.NewGauge("juimburser_last_run_duration_seconds",
+		"How long the last run took", "trigger")
+	periodReimbursed = metrics.Default.NewGauge("juimburser_period_reimbursed_eth",
+		"ETH paid in the last period's bundle", "chain")
+	periodEndBlock = metrics.Default.NewGauge("juimburser_period_end_block",
+		"Last block of the last period scanned", "chain")
+	reimbursedTotal = metrics.Default.NewCounter("juimburser_reimbursed_eth_total",
+		"ETH paid across every period scanned since startup", "chain")
+	errorsTotal = metrics.Default.NewCounter("juimburser_errors_total",
+		"Failures by kind: run, propose, notify, sheets, or pin", "kind")
+)
+
+// Records a finished run's outcome and totals
+func observeRun(run *RunRecord, out *runOutput, err error) {
+	runsTotal.Inc(run.Trigger, run.Status)
+	if run.FinishedAt != nil {
+		runDuration.Set(run.FinishedAt.Sub(run.StartedAt).Seconds(), run.Trigger)
+	}
+	if err != nil {
+		errorsTotal.Inc("run")
+	}
+	if out == nil {
+		return
+	}
+
+	for _, res := range out.Results {
+		if len(res.Errors) > 0 {
+			// No bundle was written for it
+			continue
+		}
+		paid := big.NewInt(0)
+		for _, wei := range res.Payable() {
+			paid.Add(paid, wei)
+		}
+		eth, _ := new(big.Float).Quo(new(big.Float).SetInt(paid), big.NewFloat(1e18)).Float64()
+		periodReimbursed.Set(eth, res.Chain.Name)
+		periodEndBlock.Set(float64(res.EndBlock.Uint64()), res.Chain.Name)
+		reimbursedTotal.Add(eth, res.Chain.Name)
+	}
+}
+
+// Serves /metrics on addr in the background
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Default.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		slog.Info("Serving metrics", "url", "http://"+addr+"/metrics")
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("Metrics server stopped", "err", err)
+		}
+	}()
+}
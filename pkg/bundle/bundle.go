@@ -0,0 +1,377 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"juimburser/pkg/scan"
+)
+
+// The Safe Transaction Builder's batch file version
+const Version = "1.0"
+
+// Safe Transaction Builder batch file structs
+type TransactionBundle struct {
+	Version string `json:"version"`
+	ChainID string `json:"chainId"`
+	// Unix milliseconds, like the Transaction Builder's own exports
+	CreatedAt    int64         `json:"createdAt"`
+	Meta         Meta          `json:"meta"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+type Meta struct {
+	Name                    string `json:"name"`
+	Description             string `json:"description"`
+	TxBuilderVersion        string `json:"txBuilderVersion,omitempty"`
+	CreatedFromSafeAddress  string `json:"createdFromSafeAddress,omitempty"`
+	CreatedFromOwnerAddress string `json:"createdFromOwnerAddress,omitempty"`
+	// What the bundle was made from. The Transaction Builder ignores it, but
+	// it's covered by the checksum.
+	Manifest *scan.Manifest `json:"manifest,omitempty"`
+	// See Checksum
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Plain transfers have null data, contractMethod, and contractInputsValues,
+// as in Transaction Builder exports
+type Transaction struct {
+	To                   string            `json:"to"`
+	Value                string            `json:"value"`
+	Data                 *string           `json:"data"`
+	Operation            uint8             `json:"operation,omitempty"`
+	ContractMethod       *ContractMethod   `json:"contractMethod"`
+	ContractInputsValues map[string]string `json:"contractInputsValues"`
+}
+
+type ContractMethod struct {
+	Inputs  []ContractInput `json:"inputs"`
+	Name    string          `json:"name"`
+	Payable bool            `json:"payable"`
+}
+
+type ContractInput struct {
+	InternalType string `json:"internalType"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+}
+
+// Builds Safe transaction bundles from scan results
+type BundleBuilder struct {
+	// If set, transfers are batched into a single delegatecall to this
+	// MultiSendCallOnly contract
+	MultiSend *common.Address
+	// Most transfers in each bundle BuildParts returns, or 0 for no limit
+	MaxTransfers int
+}
+
+// One of the bundles BuildParts splits a result into
+type Part struct {
+	Bundle TransactionBundle
+	// The recipients it pays, in address order
+	Recipients []common.Address
+}
+
+// Builds a Safe transaction bundle paying each sender their gas total
+func (b BundleBuilder) Build(res *scan.Result) (TransactionBundle, error) {
+	return b.build(res, Paid(res), 1, 1)
+}
+
+// The recipients the bundle pays, in address order, leaving out those
+// carried over to the next run
+func Paid(res *scan.Result) []common.Address {
+	payable := res.Payable()
+	var recipients []common.Address
+	for _, k := range scan.SortedAddresses(payable) {
+		if payable[k].Sign() > 0 {
+			recipients = append(recipients, k)
+		}
+	}
+	return recipients
+}
+
+// Builds the bundle split into parts of at most MaxTransfers transfers,
+// paying recipients in address order. Returns a single part if they fit in
+// one, as they always do when paying through a claim contract or split.
+func (b BundleBuilder) BuildParts(res *scan.Result) ([]Part, error) {
+	recipients := Paid(res)
+	size := len(recipients)
+	if b.MaxTransfers > 0 && size > b.MaxTransfers && res.Payout.Claim == nil && res.Payout.Split == nil {
+		size = b.MaxTransfers
+	}
+	count := 1
+	if size > 0 {
+		count = (len(recipients) + size - 1) / size
+	}
+
+	parts := make([]Part, count)
+	for i := range parts {
+		chunk := recipients[i*size : min((i+1)*size, len(recipients))]
+		bundle, err := b.build(res, chunk, i+1, count)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = Part{Bundle: bundle, Recipients: chunk}
+	}
+	return parts, nil
+}
+
+// Builds part of parts, paying recipients
+func (b BundleBuilder) build(res *scan.Result, recipients []common.Address, part, parts int) (TransactionBundle, error) {
+	bundle := TransactionBundle{
+		Version:   Version,
+		ChainID:   res.Chain.ChainID.String(),
+		CreatedAt: scan.GeneratedAt([]*scan.Result{res}).UnixMilli(),
+		Meta: Meta{
+			Name:        "JuiceboxDAO Gas Reimbursements",
+			Description: fmt.Sprintf("Gas reimbursements on %s from block %s to %s", res.Chain.Name, res.StartBlock.String(), res.EndBlock.String()),
+		},
+		Transactions: []Transaction{},
+	}
+	if parts > 1 {
+		bundle.Meta.Name += fmt.Sprintf(" (%d of %d)", part, parts)
+		bundle.Meta.Description += fmt.Sprintf(", part %d of %d", part, parts)
+	}
+	// Name the recipients when the label book knows any of them, so signers
+	// can see who's paid without decoding every transfer
+	names, labeled := make([]string, len(recipients)), false
+	for i, k := range recipients {
+		names[i] = res.Chain.Labels.Name(k)
+		labeled = labeled || res.Chain.Labels[k] != ""
+	}
+	if labeled {
+		bundle.Meta.Description += ", paying " + strings.Join(names, ", ")
+	}
+	bundle.Meta.Manifest = res.Manifest
+	if res.Chain.Safe != nil {
+		bundle.Meta.CreatedFromSafeAddress = res.Chain.Safe.Hex()
+	}
+
+	if claim := res.Payout.Claim; claim != nil {
+		// One transfer of the total funds every claim
+		tree := ClaimTree(res)
+		if len(recipients) > 0 {
+			tx, err := transferTx(scan.Payout{Token: res.Payout.Token}, claim.Address, tree.Total)
+			if err != nil {
+				return TransactionBundle{}, err
+			}
+			bundle.Transactions = append(bundle.Transactions, tx)
+			bundle.Meta.Description += fmt.Sprintf(", funding claim contract %s for %d recipients to claim with proofs of Merkle root %s", claim.Address.Hex(), len(recipients), tree.Root.Hex())
+		}
+	} else if split := res.Payout.Split; split != nil {
+		if len(recipients) > 0 {
+			payable, total := res.Payable(), big.NewInt(0)
+			for _, k := range recipients {
+				total.Add(total, res.Payout.Amount(payable[k]))
+			}
+			txs, err := splitTxs(res, *split, total)
+			if err != nil {
+				return TransactionBundle{}, err
+			}
+			bundle.Transactions = append(bundle.Transactions, txs...)
+			bundle.Meta.Description += fmt.Sprintf(", routed through %s to %d recipients", split, len(recipients))
+		}
+	} else if stream := res.Payout.Stream; stream != nil {
+		if res.Payout.Token == nil || res.Chain.Safe == nil {
+			return TransactionBundle{}, fmt.Errorf("%s: Sablier streams need a payout token and the chain's Safe", res.Chain.Name)
+		}
+		// The lockup pulls each stream's tokens from the Safe, so it's
+		// approved for all of them first
+		payable, total := res.Payable(), big.NewInt(0)
+		var streams []Transaction
+		for _, k := range recipients {
+			amount := res.Payout.Amount(payable[k])
+			tx, err := sablierCreateTx(*stream, *res.Chain.Safe, res.Payout.Token.Address, k, amount)
+			if err != nil {
+				return TransactionBundle{}, err
+			}
+			streams = append(streams, tx)
+			total.Add(total, amount)
+		}
+		if len(streams) > 0 {
+			approve, err := approveTx(res.Payout.Token.Address, stream.Lockup, total)
+			if err != nil {
+				return TransactionBundle{}, err
+			}
+			bundle.Transactions = append(append(bundle.Transactions, approve), streams...)
+			bundle.Meta.Description += fmt.Sprintf(", streamed through %s", stream)
+		}
+	} else {
+		payable := res.Payable()
+		for _, k := range recipients {
+			tx, err := transferTx(res.Payout, k, res.Payout.Amount(payable[k]))
+			if err != nil {
+				return TransactionBundle{}, err
+			}
+			bundle.Transactions = append(bundle.Transactions, tx)
+		}
+	}
+
+	if multiSend := b.MultiSend; multiSend != nil && len(bundle.Transactions) > 0 {
+		data, err := EncodeMultiSend(bundle.Transactions)
+		if err != nil {
+			return TransactionBundle{}, err
+		}
+		encoded := hexutil.Encode(data)
+
+		bundle.Meta.Description += fmt.Sprintf(" (%d transfers batched with MultiSendCallOnly)", len(bundle.Transactions))
+		bundle.Transactions = []Transaction{{
+			To:        multiSend.Hex(),
+			Value:     "0",
+			Data:      &encoded,
+			Operation: OperationDelegateCall,
+		}}
+	}
+
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		return TransactionBundle{}, err
+	}
+	if bundle.Meta.Checksum, err = Checksum(data); err != nil {
+		return TransactionBundle{}, err
+	}
+	return bundle, nil
+}
+
+// A transfer of amount (in the payout token's base units) to recipient
+func transferTx(payout scan.Payout, recipient common.Address, amount *big.Int) (Transaction, error) {
+	if payout.Terminal != nil {
+		return juiceboxPayTx(*payout.Terminal, recipient, amount)
+	}
+	if payout.Token == nil {
+		return Transaction{
+			To:    recipient.Hex(),
+			Value: amount.String(),
+		}, nil
+	}
+
+	data, err := erc20ABI.Pack("transfer", recipient, amount)
+	if err != nil {
+		return Transaction{}, err
+	}
+	encoded := hexutil.Encode(data)
+
+	return Transaction{
+		To:    payout.Token.Address.Hex(),
+		Value: "0",
+		Data:  &encoded,
+		ContractMethod: &ContractMethod{
+			Inputs: []ContractInput{
+				{InternalType: "address", Name: "to", Type: "address"},
+				{InternalType: "uint256", Name: "value", Type: "uint256"},
+			},
+			Name:    "transfer",
+			Payable: false,
+		},
+		ContractInputsValues: map[string]string{
+			"to":    recipient.Hex(),
+			"value": amount.String(),
+		},
+	}, nil
+}
+
+// A single payment described by a bundle
+type Transfer struct {
+	// Zero address for ETH
+	Token     common.Address
+	Recipient common.Address
+	Amount    *big.Int
+}
+
+// Decodes every payment in a bundle, looking inside MultiSend batches
+func Transfers(bundle TransactionBundle) ([]Transfer, error) {
+	var transfers []Transfer
+	for i, tx := range bundle.Transactions {
+		txTransfers, err := decodeTransfers(tx)
+		if err != nil {
+			return nil, fmt.Errorf("transactions[%d]: %w", i, err)
+		}
+		transfers = append(transfers, txTransfers...)
+	}
+	return transfers, nil
+}
+
+// The calls the Safe makes executing a bundle, in order, with MultiSend
+// batches unpacked into the calls they batch
+func Calls(bundle TransactionBundle) ([]Transaction, error) {
+	var calls []Transaction
+	for i, tx := range bundle.Transactions {
+		if tx.Operation != OperationDelegateCall {
+			calls = append(calls, tx)
+			continue
+		}
+		_, data, err := DecodeTx(tx)
+		if err != nil {
+			return nil, fmt.Errorf("transactions[%d]: %w", i, err)
+		}
+		inner, err := decodeMultiSend(data)
+		if err != nil {
+			return nil, fmt.Errorf("transactions[%d]: delegatecall to %s: %w", i, tx.To, err)
+		}
+		calls = append(calls, inner...)
+	}
+	return calls, nil
+}
+
+func decodeTransfers(tx Transaction) ([]Transfer, error) {
+	value, data, err := DecodeTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	to := common.HexToAddress(tx.To)
+
+	if tx.Operation == OperationDelegateCall {
+		inner, err := decodeMultiSend(data)
+		if err != nil {
+			return nil, fmt.Errorf("delegatecall to %s: %w", to.Hex(), err)
+		}
+
+		var transfers []Transfer
+		for j, innerTx := range inner {
+			innerTransfers, err := decodeTransfers(innerTx)
+			if err != nil {
+				return nil, fmt.Errorf("multiSend[%d]: %w", j, err)
+			}
+			transfers = append(transfers, innerTransfers...)
+		}
+		return transfers, nil
+	}
+
+	if len(data) == 0 {
+		return []Transfer{{Recipient: to, Amount: value}}, nil
+	}
+
+	for _, decode := range []func(common.Address, *big.Int, []byte) (Transfer, bool, error){decodePay, decodeSablierCreate} {
+		if transfer, ok, err := decode(to, value, data); ok {
+			if err != nil {
+				return nil, err
+			}
+			return []Transfer{transfer}, nil
+		}
+	}
+	// Approving a Sablier stream's tokens, or setting up and distributing a
+	// 0xSplits split (funding it is a transfer like any other)
+	if (isApprove(data) || isSplitMainCall(data)) && value.Sign() == 0 {
+		return nil, nil
+	}
+
+	transfer := erc20ABI.Methods["transfer"]
+	if len(data) < 4 || !bytes.Equal(data[:4], transfer.ID) {
+		return nil, fmt.Errorf("call to %s is not an ERC-20 transfer, Juicebox pay(), or Sablier createWithDurations()", to.Hex())
+	}
+	if value.Sign() != 0 {
+		return nil, fmt.Errorf("token transfer also sends %s wei", value)
+	}
+	args, err := transfer.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("decoding transfer to %s: %w", to.Hex(), err)
+	}
+
+	return []Transfer{{Token: to, Recipient: args[0].(common.Address), Amount: args[1].(*big.Int)}}, nil
+}
@@ -0,0 +1,331 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"juimburser/pkg/scan"
+	"juimburser/pkg/scan/scantest"
+)
+
+var (
+	terminal = common.HexToAddress("0x000000000000000000000000000000000000fee1")
+	payTopic = crypto.Keccak256Hash([]byte("Pay()"))
+	// In address order, which bundles pay them in
+	senders = []common.Address{
+		common.HexToAddress("0x0000000000000000000000000000000000000b0b"),
+		common.HexToAddress("0x00000000000000000000000000000000000a11ce"),
+		common.HexToAddress("0x00000000000000000000000000000000000ca201"),
+	}
+)
+
+// Scans a chain where each sender sent one transaction costing i+1 times
+// 0.001 ETH, and the first sent another costing 0.001 ETH
+func scanned(t *testing.T) *scan.Result {
+	t.Helper()
+	chain := scantest.NewChain(1)
+	for i, from := range append(senders, senders[0]) {
+		chain.AddTx(scantest.Tx{
+			Block:    uint64(100 + i),
+			From:     from,
+			To:       &terminal,
+			GasUsed:  100000,
+			GasPrice: big.NewInt(int64(10e9 * ((i % len(senders)) + 1))),
+			Logs:     []scantest.Log{{Address: terminal, Topics: []common.Hash{payTopic}}},
+		})
+	}
+	safe := common.HexToAddress("0x0000000000000000000000000000000000005afe")
+	res, err := scan.NewScanner(chain, scan.Options{}).Scan(context.Background(), &scan.Chain{
+		Name:       "mainnet",
+		ChainID:    big.NewInt(1),
+		GasModel:   scan.GasModelEthereum,
+		Safe:       &safe,
+		StartBlock: big.NewInt(90),
+		EndBlock:   big.NewInt(103),
+		Groups:     []scan.TxGroup{{Label: "Pay", Addresses: []common.Address{terminal}, Topics: [][]common.Hash{{payTopic}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func finney(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e15))
+}
+
+func TestBuildParts(t *testing.T) {
+	multiSend := common.HexToAddress(DefaultMultiSend)
+	want := map[common.Address]*big.Int{senders[0]: finney(2), senders[1]: finney(2), senders[2]: finney(3)}
+	tests := []struct {
+		name    string
+		builder BundleBuilder
+		// Recipients paid by each part
+		parts [][]common.Address
+		// Whether each part is one MultiSend delegatecall
+		batched bool
+	}{
+		{
+			name:  "one part",
+			parts: [][]common.Address{senders},
+		},
+		{
+			name:    "splits at MaxTransfers",
+			builder: BundleBuilder{MaxTransfers: 2},
+			parts:   [][]common.Address{senders[:2], senders[2:]},
+		},
+		{
+			name:    "batches with MultiSend",
+			builder: BundleBuilder{MultiSend: &multiSend},
+			parts:   [][]common.Address{senders},
+			batched: true,
+		},
+		{
+			name:    "batches each part",
+			builder: BundleBuilder{MultiSend: &multiSend, MaxTransfers: 2},
+			parts:   [][]common.Address{senders[:2], senders[2:]},
+			batched: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := scanned(t)
+			if len(res.Txs) != 4 {
+				t.Fatalf("scanned %d transactions, want 4", len(res.Txs))
+			}
+			parts, err := tt.builder.BuildParts(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parts) != len(tt.parts) {
+				t.Fatalf("%d parts, want %d", len(parts), len(tt.parts))
+			}
+			for i, part := range parts {
+				if !slicesEqual(part.Recipients, tt.parts[i]) {
+					t.Errorf("part %d pays %v, want %v", i+1, part.Recipients, tt.parts[i])
+				}
+				if suffix := fmt.Sprintf(" (%d of %d)", i+1, len(parts)); len(parts) > 1 && !strings.HasSuffix(part.Bundle.Meta.Name, suffix) {
+					t.Errorf("part %d is named %q, without its number", i+1, part.Bundle.Meta.Name)
+				}
+				txs := part.Bundle.Transactions
+				if batched := len(txs) == 1 && txs[0].Operation == OperationDelegateCall; batched != tt.batched {
+					t.Errorf("part %d batched: %v, want %v", i+1, batched, tt.batched)
+				}
+
+				transfers, err := Transfers(part.Bundle)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(transfers) != len(tt.parts[i]) {
+					t.Fatalf("part %d has %d transfers, want %d", i+1, len(transfers), len(tt.parts[i]))
+				}
+				for j, transfer := range transfers {
+					if transfer.Token != (common.Address{}) || transfer.Recipient != tt.parts[i][j] || transfer.Amount.Cmp(want[transfer.Recipient]) != 0 {
+						t.Errorf("part %d transfer %d: %s of %s to %s, want %s ETH to %s", i+1, j, transfer.Amount, transfer.Token.Hex(), transfer.Recipient.Hex(), want[tt.parts[i][j]], tt.parts[i][j].Hex())
+					}
+				}
+			}
+		})
+	}
+}
+
+func slicesEqual(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMultiSendRoundTrip(t *testing.T) {
+	data := "0xa9059cbb00000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002"
+	empty := "0x"
+	tests := []struct {
+		name string
+		txs  []Transaction
+	}{
+		{
+			name: "transfers",
+			txs: []Transaction{
+				{To: senders[0].Hex(), Value: "1000000000000000"},
+				{To: senders[1].Hex(), Value: "0"},
+			},
+		},
+		{
+			name: "calls with data",
+			txs: []Transaction{
+				{To: senders[0].Hex(), Value: "0", Data: &data},
+				{To: senders[1].Hex(), Value: "5"},
+				// Calldata that doesn't fill a word, so the batch is padded
+				{To: senders[2].Hex(), Value: "0", Data: ptr("0x01")},
+			},
+		},
+		{
+			name: "empty data is none",
+			txs:  []Transaction{{To: senders[0].Hex(), Value: "7", Data: &empty}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := EncodeMultiSend(tt.txs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(encoded)-4)%32 != 0 {
+				t.Errorf("calldata is %d bytes after the selector, not whole words", len(encoded)-4)
+			}
+			decoded, err := decodeMultiSend(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded) != len(tt.txs) {
+				t.Fatalf("decoded %d transactions, want %d", len(decoded), len(tt.txs))
+			}
+			for i, tx := range decoded {
+				want := tt.txs[i]
+				wantData := ""
+				if want.Data != nil && *want.Data != "0x" {
+					wantData = *want.Data
+				}
+				gotData := ""
+				if tx.Data != nil {
+					gotData = *tx.Data
+				}
+				if tx.To != want.To || tx.Value != want.Value || tx.Operation != OperationCall || gotData != wantData {
+					t.Errorf("transaction %d: got %+v (data %q), want %+v (data %q)", i, tx, gotData, want, wantData)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiSendRejects(t *testing.T) {
+	if _, err := EncodeMultiSend([]Transaction{{To: senders[0].Hex(), Value: "0", Operation: OperationDelegateCall}}); err == nil {
+		t.Error("encoded a delegatecall")
+	}
+	encoded, err := EncodeMultiSend([]Transaction{{To: senders[0].Hex(), Value: "1", Data: ptr("0xdeadbeef")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, calldata := range map[string][]byte{
+		"wrong selector": append([]byte{0, 0, 0, 0}, encoded[4:]...),
+		"truncated":      encoded[:len(encoded)-64],
+		"too short":      encoded[:4+32],
+	} {
+		if _, err := decodeMultiSend(calldata); err == nil {
+			t.Errorf("%s: decoded without an error", name)
+		}
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestSerializeJSON(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`"a"`, `"a"`},
+		{`12`, `12`},
+		{`null`, `null`},
+		{`[1,"x",true]`, `[1,"x",true]`},
+		// Keys are listed sorted, then each value followed by a comma
+		{`{"b":1,"a":["x",null]}`, `{["a","b"]["x",null],1,}`},
+		{`{"o":{"z":false}}`, `{["o"]{["z"]false,},}`},
+		// Unlike encoding/json, JSON.stringify doesn't escape HTML
+		{`"<a&b>\n"`, `"<a&b>\n"`},
+	}
+	for _, tt := range tests {
+		dec := json.NewDecoder(strings.NewReader(tt.in))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		serializeJSON(&b, v)
+		if b.String() != tt.want {
+			t.Errorf("serializeJSON(%s) = %s, want %s", tt.in, b.String(), tt.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	res := scanned(t)
+	bundle, err := BundleBuilder{}.Build(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(bundle.Meta.Checksum, "0x") || len(bundle.Meta.Checksum) != 66 {
+		t.Fatalf("checksum %q isn't a hash", bundle.Meta.Checksum)
+	}
+	checksum := func(b TransactionBundle) string {
+		t.Helper()
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := Checksum(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+
+	// The checksum leaves out meta.name and itself
+	if sum := checksum(bundle); sum != bundle.Meta.Checksum {
+		t.Errorf("recomputed %s, bundle has %s", sum, bundle.Meta.Checksum)
+	}
+	renamed := bundle
+	renamed.Meta.Name, renamed.Meta.Checksum = "Renamed", ""
+	if sum := checksum(renamed); sum != bundle.Meta.Checksum {
+		t.Errorf("renaming changed the checksum to %s", sum)
+	}
+
+	// Anything else changes it
+	changed := bundle
+	changed.Transactions = append([]Transaction{}, bundle.Transactions...)
+	changed.Transactions[0].Value = "1"
+	if checksum(changed) == bundle.Meta.Checksum {
+		t.Error("changing a transfer kept the checksum")
+	}
+
+	if _, err := Checksum([]byte(`{"version":"1.0"}`)); err == nil {
+		t.Error("checksummed a bundle without meta")
+	}
+}
+
+// Guards the fixture: what scanned pays, decoded from the bundle's hex
+func TestTransferAmounts(t *testing.T) {
+	bundle, err := BundleBuilder{}.Build(scanned(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total big.Int
+	for _, tx := range bundle.Transactions {
+		value, data, err := DecodeTx(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != 0 {
+			t.Errorf("plain transfer to %s has data %s", tx.To, hexutil.Encode(data))
+		}
+		total.Add(&total, value)
+	}
+	if total.Cmp(finney(7)) != 0 {
+		t.Errorf("bundle pays %s wei, want %s", &total, finney(7))
+	}
+}
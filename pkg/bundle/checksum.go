@@ -0,0 +1,111 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The checksum the Safe Transaction Builder stores in meta.checksum and
+// checks on import: keccak256 of its own serialization of the bundle's JSON
+// (see serializeJSON), with meta.name nulled and meta.checksum removed
+func Checksum(data []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var file map[string]any
+	if err := dec.Decode(&file); err != nil {
+		return "", err
+	}
+	meta, ok := file["meta"].(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("bundle has no meta object")
+	}
+	delete(meta, "checksum")
+	meta["name"] = nil
+
+	var b strings.Builder
+	serializeJSON(&b, file)
+	return crypto.Keccak256Hash([]byte(b.String())).Hex(), nil
+}
+
+// The Transaction Builder's serializeJSONObject: objects become their sorted
+// keys as a JSON array followed by each value and a comma, arrays are
+// serialized element by element, and everything else is JSON.stringify'd
+func serializeJSON(b *strings.Builder, v any) {
+	switch v := v.(type) {
+	case []any:
+		b.WriteByte('[')
+		for i, el := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			serializeJSON(b, el)
+		}
+		b.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// Object.keys().sort() compares UTF-16 code units, which matches byte
+		// order for the ASCII keys bundles have
+		slices.Sort(keys)
+
+		b.WriteString("{[")
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSString(b, k)
+		}
+		b.WriteByte(']')
+		for _, k := range keys {
+			serializeJSON(b, v[k])
+			b.WriteByte(',')
+		}
+		b.WriteByte('}')
+	case string:
+		writeJSString(b, v)
+	case json.Number:
+		b.WriteString(v.String())
+	case bool:
+		fmt.Fprint(b, v)
+	default:
+		b.WriteString("null")
+	}
+}
+
+// Writes s quoted the way JSON.stringify does, which unlike encoding/json
+// leaves <, >, &, U+2028, and U+2029 alone
+func writeJSString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
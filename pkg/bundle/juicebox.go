@@ -0,0 +1,122 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"juimburser/pkg/scan"
+)
+
+// Juicebox's placeholder address for native ETH (JBTokens.ETH / JBConstants.NATIVE_TOKEN)
+var juiceboxETH = common.HexToAddress("0x000000000000000000000000000000000000EEEe")
+
+const juiceboxMemo = "Gas reimbursement"
+
+var (
+	// JBETHPaymentTerminal (v3)
+	jbTerminalV3ABI = mustParseABI(`[{"type":"function","name":"pay","stateMutability":"payable","inputs":[{"name":"_projectId","type":"uint256"},{"name":"_amount","type":"uint256"},{"name":"_token","type":"address"},{"name":"_beneficiary","type":"address"},{"name":"_minReturnedTokens","type":"uint256"},{"name":"_preferClaimedTokens","type":"bool"},{"name":"_memo","type":"string"},{"name":"_metadata","type":"bytes"}],"outputs":[{"name":"","type":"uint256"}]}]`)
+	// JBMultiTerminal (v4)
+	jbTerminalV4ABI = mustParseABI(`[{"type":"function","name":"pay","stateMutability":"payable","inputs":[{"name":"projectId","type":"uint256"},{"name":"token","type":"address"},{"name":"amount","type":"uint256"},{"name":"beneficiary","type":"address"},{"name":"minReturnedTokens","type":"uint256"},{"name":"memo","type":"string"},{"name":"metadata","type":"bytes"}],"outputs":[{"name":"","type":"uint256"}]}]`)
+)
+
+// A pay() call sending amount wei to the project with recipient as beneficiary
+func juiceboxPayTx(t scan.JuiceboxTerminal, recipient common.Address, amount *big.Int) (Transaction, error) {
+	projectID := new(big.Int).SetUint64(t.ProjectID)
+
+	var (
+		data   []byte
+		err    error
+		values map[string]string
+	)
+	switch t.Version {
+	case 3:
+		data, err = jbTerminalV3ABI.Pack("pay", projectID, amount, juiceboxETH, recipient, big.NewInt(0), false, juiceboxMemo, []byte{})
+		values = map[string]string{
+			"_projectId":           projectID.String(),
+			"_amount":              amount.String(),
+			"_token":               juiceboxETH.Hex(),
+			"_beneficiary":         recipient.Hex(),
+			"_minReturnedTokens":   "0",
+			"_preferClaimedTokens": "false",
+			"_memo":                juiceboxMemo,
+			"_metadata":            "0x",
+		}
+	case 4:
+		data, err = jbTerminalV4ABI.Pack("pay", projectID, juiceboxETH, amount, recipient, big.NewInt(0), juiceboxMemo, []byte{})
+		values = map[string]string{
+			"projectId":         projectID.String(),
+			"token":             juiceboxETH.Hex(),
+			"amount":            amount.String(),
+			"beneficiary":       recipient.Hex(),
+			"minReturnedTokens": "0",
+			"memo":              juiceboxMemo,
+			"metadata":          "0x",
+		}
+	default:
+		return Transaction{}, fmt.Errorf("unsupported Juicebox terminal version %d", t.Version)
+	}
+	if err != nil {
+		return Transaction{}, err
+	}
+	encoded := hexutil.Encode(data)
+
+	method := juiceboxPayMethod(t)
+	inputs := []ContractInput{}
+	for _, in := range method.Inputs {
+		inputs = append(inputs, ContractInput{InternalType: in.Type.String(), Name: in.Name, Type: in.Type.String()})
+	}
+
+	return Transaction{
+		To:                   t.Address.Hex(),
+		Value:                amount.String(),
+		Data:                 &encoded,
+		ContractMethod:       &ContractMethod{Inputs: inputs, Name: "pay", Payable: true},
+		ContractInputsValues: values,
+	}, nil
+}
+
+func juiceboxPayMethod(t scan.JuiceboxTerminal) abi.Method {
+	if t.Version == 4 {
+		return jbTerminalV4ABI.Methods["pay"]
+	}
+	return jbTerminalV3ABI.Methods["pay"]
+}
+
+// Decodes a v3 or v4 terminal pay() call as a transfer to its beneficiary.
+// ok is false if data isn't a pay() call.
+func decodePay(to common.Address, value *big.Int, data []byte) (transfer Transfer, ok bool, err error) {
+	if len(data) < 4 {
+		return Transfer{}, false, nil
+	}
+
+	// Argument positions differ between versions
+	v3, v4 := jbTerminalV3ABI.Methods["pay"], jbTerminalV4ABI.Methods["pay"]
+	var method abi.Method
+	var amountArg, tokenArg int
+	switch {
+	case bytes.Equal(data[:4], v3.ID):
+		method, amountArg, tokenArg = v3, 1, 2
+	case bytes.Equal(data[:4], v4.ID):
+		method, amountArg, tokenArg = v4, 2, 1
+	default:
+		return Transfer{}, false, nil
+	}
+
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return Transfer{}, true, fmt.Errorf("decoding pay() to %s: %w", to.Hex(), err)
+	}
+	amount, token, beneficiary := args[amountArg].(*big.Int), args[tokenArg].(common.Address), args[3].(common.Address)
+	if token != juiceboxETH {
+		return Transfer{}, true, fmt.Errorf("pay() to %s pays token %s, not ETH", to.Hex(), token.Hex())
+	}
+	if amount.Cmp(value) != 0 {
+		return Transfer{}, true, fmt.Errorf("pay() to %s sends %s wei but pays %s", to.Hex(), value, amount)
+	}
+	return Transfer{Recipient: beneficiary, Amount: value}, true, nil
+}
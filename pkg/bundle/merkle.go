@@ -0,0 +1,139 @@
+package bundle
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"juimburser/pkg/scan"
+)
+
+// The leaf encoding of the trees NewMerkleTree builds
+var merkleLeafEncoding = []string{"address", "uint256"}
+
+// A Merkle tree of what each recipient can claim, laid out like
+// OpenZeppelin's StandardMerkleTree with leaves (address, uint256 amount), so
+// a claim contract can check proofs with MerkleProof.verify
+type MerkleTree struct {
+	Root common.Hash
+	// What the claim contract is funded with
+	Total *big.Int
+	// Each recipient's amount and proof
+	Claims map[common.Address]MerkleClaim
+}
+
+type MerkleClaim struct {
+	Amount *big.Int
+	Proof  []common.Hash
+}
+
+// Builds the tree of amounts, in the payout token's base units. The root is
+// zero if there are none.
+func NewMerkleTree(amounts map[common.Address]*big.Int) MerkleTree {
+	type leaf struct {
+		hash common.Hash
+		addr common.Address
+	}
+	leaves := make([]leaf, 0, len(amounts))
+	tree := MerkleTree{Total: big.NewInt(0), Claims: make(map[common.Address]MerkleClaim)}
+	for addr, amount := range amounts {
+		leaves = append(leaves, leaf{merkleLeaf(addr, amount), addr})
+		tree.Total.Add(tree.Total, amount)
+	}
+	if len(leaves) == 0 {
+		return tree
+	}
+	sort.Slice(leaves, func(i, j int) bool { return bytes.Compare(leaves[i].hash[:], leaves[j].hash[:]) < 0 })
+
+	// A complete binary tree in an array, root first and leaves last in
+	// reverse order, as StandardMerkleTree builds it
+	nodes := make([]common.Hash, 2*len(leaves)-1)
+	for i, l := range leaves {
+		nodes[len(nodes)-1-i] = l.hash
+	}
+	for i := len(nodes) - 1 - len(leaves); i >= 0; i-- {
+		nodes[i] = hashPair(nodes[2*i+1], nodes[2*i+2])
+	}
+	tree.Root = nodes[0]
+
+	for i, l := range leaves {
+		proof := []common.Hash{}
+		for n := len(nodes) - 1 - i; n > 0; n = (n - 1) / 2 {
+			sibling := n + 1
+			if n%2 == 0 {
+				sibling = n - 1
+			}
+			proof = append(proof, nodes[sibling])
+		}
+		tree.Claims[l.addr] = MerkleClaim{Amount: amounts[l.addr], Proof: proof}
+	}
+	return tree
+}
+
+// keccak256(bytes.concat(keccak256(abi.encode(addr, amount)))), hashed twice
+// so a leaf can't pass as an inner node
+func merkleLeaf(addr common.Address, amount *big.Int) common.Hash {
+	encoded := append(common.LeftPadBytes(addr.Bytes(), 32), common.LeftPadBytes(amount.Bytes(), 32)...)
+	return crypto.Keccak256Hash(crypto.Keccak256(encoded))
+}
+
+// Inner nodes hash their children in sorted order, so proofs don't need to
+// say which side each sibling is on
+func hashPair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a[:], b[:])
+}
+
+// The tree of what the bundle for res lets each recipient claim
+func ClaimTree(res *scan.Result) MerkleTree {
+	payable := res.Payable()
+	amounts := make(map[common.Address]*big.Int)
+	for _, k := range Paid(res) {
+		amounts[k] = res.Payout.Amount(payable[k])
+	}
+	return NewMerkleTree(amounts)
+}
+
+// Recipients' claims as written next to the bundle, for them (or a claim
+// page) to claim with. Amounts are decimal strings in the payout token's
+// base units.
+type ClaimsFile struct {
+	ChainID  string         `json:"chainId"`
+	Contract common.Address `json:"claimContract"`
+	// Omitted for ETH
+	Token        *common.Address `json:"token,omitempty"`
+	Root         common.Hash     `json:"merkleRoot"`
+	Total        string          `json:"total"`
+	LeafEncoding []string        `json:"leafEncoding"`
+	// Keyed by checksummed address
+	Claims map[string]ClaimsFileEntry `json:"claims"`
+}
+
+type ClaimsFileEntry struct {
+	Amount string        `json:"amount"`
+	Proof  []common.Hash `json:"proof"`
+}
+
+// The claims file for res, which must be paid through a claim contract
+func NewClaimsFile(res *scan.Result, tree MerkleTree) ClaimsFile {
+	f := ClaimsFile{
+		ChainID:      res.Chain.ChainID.String(),
+		Contract:     res.Payout.Claim.Address,
+		Root:         tree.Root,
+		Total:        tree.Total.String(),
+		LeafEncoding: merkleLeafEncoding,
+		Claims:       make(map[string]ClaimsFileEntry),
+	}
+	if res.Payout.Token != nil {
+		f.Token = &res.Payout.Token.Address
+	}
+	for addr, c := range tree.Claims {
+		f.Claims[addr.Hex()] = ClaimsFileEntry{Amount: c.Amount.String(), Proof: c.Proof}
+	}
+	return f
+}
@@ -0,0 +1,115 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Canonical MultiSendCallOnly v1.3.0 deployment, the same on every chain
+const DefaultMultiSend = "0x40A2aCCbd92BCA938b02010E17A5b8929b49130D"
+
+const (
+	OperationCall         uint8 = 0
+	OperationDelegateCall uint8 = 1
+)
+
+var (
+	// multiSend(bytes)
+	multiSendSelector = common.FromHex("0x8d80ff0a")
+)
+
+func DecodeTx(tx Transaction) (*big.Int, []byte, error) {
+	if !common.IsHexAddress(tx.To) {
+		return nil, nil, fmt.Errorf("invalid to address %q", tx.To)
+	}
+
+	value, ok := new(big.Int).SetString(tx.Value, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid value %q", tx.Value)
+	}
+
+	var data []byte
+	if tx.Data != nil {
+		var err error
+		if data, err = hexutil.Decode(*tx.Data); err != nil {
+			return nil, nil, fmt.Errorf("invalid data for transaction to %s: %w", tx.To, err)
+		}
+	}
+
+	return value, data, nil
+}
+
+// ABI-encodes a multiSend(bytes) call where each transaction is packed as
+// operation (uint8), to (address), value (uint256), data length (uint256), data
+func EncodeMultiSend(txs []Transaction) ([]byte, error) {
+	var packed bytes.Buffer
+	for _, tx := range txs {
+		value, data, err := DecodeTx(tx)
+		if err != nil {
+			return nil, err
+		}
+		if tx.Operation != OperationCall {
+			return nil, fmt.Errorf("MultiSendCallOnly can't batch a delegatecall to %s", tx.To)
+		}
+
+		packed.WriteByte(OperationCall)
+		packed.Write(common.HexToAddress(tx.To).Bytes())
+		packed.Write(math.U256Bytes(value))
+		packed.Write(math.U256Bytes(big.NewInt(int64(len(data)))))
+		packed.Write(data)
+	}
+
+	out := append([]byte{}, multiSendSelector...)
+	out = append(out, math.U256Bytes(big.NewInt(32))...)
+	out = append(out, math.U256Bytes(big.NewInt(int64(packed.Len())))...)
+	out = append(out, common.RightPadBytes(packed.Bytes(), (packed.Len()+31)/32*32)...)
+	return out, nil
+}
+
+// Reverses encodeMultiSend, returning the batched calls
+func decodeMultiSend(calldata []byte) ([]Transaction, error) {
+	if len(calldata) < 4+64 || !bytes.Equal(calldata[:4], multiSendSelector) {
+		return nil, fmt.Errorf("not a multiSend(bytes) call")
+	}
+
+	args := calldata[4:]
+	offset := new(big.Int).SetBytes(args[:32])
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(args)) {
+		return nil, fmt.Errorf("invalid multiSend offset")
+	}
+	length := new(big.Int).SetBytes(args[offset.Uint64() : offset.Uint64()+32])
+	start := offset.Uint64() + 32
+	if !length.IsUint64() || start+length.Uint64() > uint64(len(args)) {
+		return nil, fmt.Errorf("invalid multiSend length")
+	}
+	packed := args[start : start+length.Uint64()]
+
+	var txs []Transaction
+	for len(packed) > 0 {
+		if len(packed) < 1+20+32+32 {
+			return nil, fmt.Errorf("truncated multiSend transaction")
+		}
+		operation := packed[0]
+		to := common.BytesToAddress(packed[1:21])
+		value := new(big.Int).SetBytes(packed[21:53])
+		dataLen := new(big.Int).SetBytes(packed[53:85])
+		if !dataLen.IsUint64() || 85+dataLen.Uint64() > uint64(len(packed)) {
+			return nil, fmt.Errorf("truncated multiSend transaction data")
+		}
+
+		tx := Transaction{To: to.Hex(), Value: value.String(), Operation: operation}
+		if dataLen.Sign() > 0 {
+			encoded := hexutil.Encode(packed[85 : 85+dataLen.Uint64()])
+			tx.Data = &encoded
+		}
+		txs = append(txs, tx)
+		packed = packed[85+dataLen.Uint64():]
+	}
+
+	return txs, nil
+}
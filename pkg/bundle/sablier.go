@@ -0,0 +1,122 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"juimburser/pkg/scan"
+)
+
+// Sablier V2.1's SablierV2LockupLinear
+var sablierLockupABI = mustParseABI(`[{"type":"function","name":"createWithDurations","stateMutability":"nonpayable","inputs":[{"name":"params","type":"tuple","internalType":"struct LockupLinear.CreateWithDurations","components":[
+	{"name":"sender","type":"address"},
+	{"name":"recipient","type":"address"},
+	{"name":"totalAmount","type":"uint128"},
+	{"name":"asset","type":"address"},
+	{"name":"cancelable","type":"bool"},
+	{"name":"transferable","type":"bool"},
+	{"name":"durations","type":"tuple","components":[{"name":"cliff","type":"uint40"},{"name":"total","type":"uint40"}]},
+	{"name":"broker","type":"tuple","components":[{"name":"account","type":"address"},{"name":"fee","type":"uint256"}]}
+]}],"outputs":[{"name":"streamId","type":"uint256"}]}]`)
+
+// createWithDurations's params, in the shape abi packs tuples from
+type sablierCreateParams struct {
+	Sender       common.Address
+	Recipient    common.Address
+	TotalAmount  *big.Int
+	Asset        common.Address
+	Cancelable   bool
+	Transferable bool
+	Durations    struct {
+		Cliff *big.Int
+		Total *big.Int
+	}
+	Broker struct {
+		Account common.Address
+		Fee     *big.Int
+	}
+}
+
+// An ERC-20 approve() letting spender take amount of token from the Safe
+func approveTx(token, spender common.Address, amount *big.Int) (Transaction, error) {
+	data, err := erc20ABI.Pack("approve", spender, amount)
+	if err != nil {
+		return Transaction{}, err
+	}
+	encoded := hexutil.Encode(data)
+
+	return Transaction{
+		To:    token.Hex(),
+		Value: "0",
+		Data:  &encoded,
+		ContractMethod: &ContractMethod{
+			Inputs: []ContractInput{
+				{InternalType: "address", Name: "spender", Type: "address"},
+				{InternalType: "uint256", Name: "value", Type: "uint256"},
+			},
+			Name:    "approve",
+			Payable: false,
+		},
+		ContractInputsValues: map[string]string{
+			"spender": spender.Hex(),
+			"value":   amount.String(),
+		},
+	}, nil
+}
+
+// A createWithDurations() call streaming amount of token from sender (the
+// Safe) to recipient, with no broker fee. Streams can be transferred, so a
+// recipient can move theirs to another wallet. Sent as raw data: the
+// Transaction Builder can't show its nested tuples as inputs.
+func sablierCreateTx(s scan.SablierStream, sender, token, recipient common.Address, amount *big.Int) (Transaction, error) {
+	params := sablierCreateParams{
+		Sender:       sender,
+		Recipient:    recipient,
+		TotalAmount:  amount,
+		Asset:        token,
+		Cancelable:   s.Cancelable,
+		Transferable: true,
+	}
+	params.Durations.Cliff = big.NewInt(int64(s.Cliff.Seconds()))
+	params.Durations.Total = big.NewInt(int64(s.Duration.Seconds()))
+	params.Broker.Fee = big.NewInt(0)
+
+	data, err := sablierLockupABI.Pack("createWithDurations", params)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("encoding a Sablier stream to %s: %w", recipient.Hex(), err)
+	}
+	encoded := hexutil.Encode(data)
+	return Transaction{
+		To:    s.Lockup.Hex(),
+		Value: "0",
+		Data:  &encoded,
+	}, nil
+}
+
+// Decodes a createWithDurations() call as a transfer of the streamed amount
+// to its recipient. ok is false if data isn't one.
+func decodeSablierCreate(to common.Address, value *big.Int, data []byte) (transfer Transfer, ok bool, err error) {
+	method := sablierLockupABI.Methods["createWithDurations"]
+	if len(data) < 4 || !bytes.Equal(data[:4], method.ID) {
+		return Transfer{}, false, nil
+	}
+	if value.Sign() != 0 {
+		return Transfer{}, true, fmt.Errorf("createWithDurations() to %s also sends %s wei", to.Hex(), value)
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return Transfer{}, true, fmt.Errorf("decoding createWithDurations() to %s: %w", to.Hex(), err)
+	}
+	params := abi.ConvertType(args[0], new(sablierCreateParams)).(*sablierCreateParams)
+	return Transfer{Token: params.Asset, Recipient: params.Recipient, Amount: params.TotalAmount}, true, nil
+}
+
+// Whether data is an ERC-20 approve(), which pays nobody
+func isApprove(data []byte) bool {
+	return len(data) >= 4 && bytes.Equal(data[:4], erc20ABI.Methods["approve"].ID)
+}
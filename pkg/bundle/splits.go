@@ -0,0 +1,73 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"juimburser/pkg/scan"
+)
+
+// 0xSplits' SplitMain (v1)
+var splitMainABI = mustParseABI(`[
+	{"type":"function","name":"updateSplit","stateMutability":"nonpayable","inputs":[{"name":"split","type":"address"},{"name":"accounts","type":"address[]"},{"name":"percentAllocations","type":"uint32[]"},{"name":"distributorFee","type":"uint32"}],"outputs":[]},
+	{"type":"function","name":"distributeETH","stateMutability":"nonpayable","inputs":[{"name":"split","type":"address"},{"name":"accounts","type":"address[]"},{"name":"percentAllocations","type":"uint32[]"},{"name":"distributorFee","type":"uint32"},{"name":"distributorAddress","type":"address"}],"outputs":[]},
+	{"type":"function","name":"distributeERC20","stateMutability":"nonpayable","inputs":[{"name":"split","type":"address"},{"name":"token","type":"address"},{"name":"accounts","type":"address[]"},{"name":"percentAllocations","type":"uint32[]"},{"name":"distributorFee","type":"uint32"},{"name":"distributorAddress","type":"address"}],"outputs":[]}
+]`)
+
+// Sets the split's recipients to res's allocations, funds it with amount
+// of the payout asset, and distributes it, with no distributor fee.
+// Recipients then withdraw from SplitMain. Sent as raw data: the
+// Transaction Builder's array inputs are easy to get wrong.
+func splitTxs(res *scan.Result, split scan.SplitContract, amount *big.Int) ([]Transaction, error) {
+	allocations := res.SplitAllocations()
+	if len(allocations) < 2 {
+		return nil, fmt.Errorf("%s: a 0xSplits split needs at least two recipients, but there are %d", res.Chain.Name, len(allocations))
+	}
+	accounts := make([]common.Address, len(allocations))
+	percents := make([]uint32, len(allocations))
+	for i, a := range allocations {
+		accounts[i], percents[i] = a.Account, a.Percent
+	}
+
+	update, err := splitMainABI.Pack("updateSplit", split.Address, accounts, percents, uint32(0))
+	if err != nil {
+		return nil, err
+	}
+	fund, err := transferTx(scan.Payout{Token: res.Payout.Token}, split.Address, amount)
+	if err != nil {
+		return nil, err
+	}
+	var distribute []byte
+	if token := res.Payout.Token; token != nil {
+		distribute, err = splitMainABI.Pack("distributeERC20", split.Address, token.Address, accounts, percents, uint32(0), common.Address{})
+	} else {
+		distribute, err = splitMainABI.Pack("distributeETH", split.Address, accounts, percents, uint32(0), common.Address{})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw := func(data []byte) Transaction {
+		encoded := hexutil.Encode(data)
+		return Transaction{To: split.Main.Hex(), Value: "0", Data: &encoded}
+	}
+	return []Transaction{raw(update), fund, raw(distribute)}, nil
+}
+
+// Whether data is a SplitMain call that pays nobody itself: updating a
+// split, or distributing what it was already sent
+func isSplitMainCall(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	for _, name := range []string{"updateSplit", "distributeETH", "distributeERC20"} {
+		if bytes.Equal(data[:4], splitMainABI.Methods[name].ID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package governance
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/bundle"
+	"juimburser/pkg/scan"
+)
+
+// A forum post proposing the reimbursements, in the sections of JuiceboxDAO's
+// proposal template (Synopsis, Motivation, Specification, Rationale, Risks &
+// Considerations, Timeline), as Markdown with Discourse's [details] blocks
+// folding each recipient's transactions. bundleLinks holds the links to each
+// result's bundle files, nil for a chain without one; a bare file name, for a
+// bundle that's neither pinned nor hosted, is named for attaching to the post.
+func NewForumPost(results []*scan.Result, bundleLinks [][]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", Title(results))
+
+	txs, recipients := 0, make(map[common.Address]bool)
+	var chains []string
+	for _, res := range results {
+		txs += len(res.Txs)
+		for _, k := range bundle.Paid(res) {
+			recipients[k] = true
+		}
+		chains = append(chains, res.Chain.Name)
+	}
+
+	b.WriteString("## Synopsis\n\n")
+	fmt.Fprintf(&b, "Reimburse %d contributors for the gas they spent on %d JuiceboxDAO transactions on %s, paying %s.\n\n",
+		len(recipients), txs, strings.Join(chains, ", "), strings.Join(payoutTotals(results), " and "))
+
+	b.WriteString("## Motivation\n\n")
+	b.WriteString("Contributors pay gas out of pocket to execute the DAO's multisig transactions, distribute payouts, and keep its projects running. Reimbursing it means that work doesn't cost them.\n\n")
+
+	b.WriteString("## Specification\n\n")
+	b.WriteString("| Chain | Period | Blocks | Transactions | Recipients | Gas (ETH) | Paid |\n")
+	b.WriteString("| --- | --- | --- | ---: | ---: | ---: | ---: |\n")
+	for _, res := range results {
+		fmt.Fprintf(&b, "| %s | %s to %s | %s to %s | %d | %d | %s | %s |\n", res.Chain.Name,
+			res.StartTime.UTC().Format(time.DateOnly), res.EndTime.UTC().Format(time.DateOnly), res.StartBlock, res.EndBlock,
+			len(res.Txs), len(bundle.Paid(res)), scan.FormatEther(sum(res.Totals())), res.Payout.Format(paidTotal(res)))
+	}
+	b.WriteString("\n")
+
+	b.WriteString("The Safe executes these transaction bundles, which can be checked with `juimburser verify`:\n\n")
+	for i, res := range results {
+		var links []string
+		if i < len(bundleLinks) {
+			links = bundleLinks[i]
+		}
+		if len(links) == 0 {
+			fmt.Fprintf(&b, "- %s: no bundle written\n", res.Chain.Name)
+			continue
+		}
+		names := make([]string, len(links))
+		for j, link := range links {
+			if !strings.Contains(link, "/") {
+				names[j] = "`" + link + "` (attached)"
+				continue
+			}
+			names[j] = fmt.Sprintf("[%s](%s)", link[strings.LastIndex(link, "/")+1:], link)
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", res.Chain.Name, strings.Join(names, ", "))
+	}
+	b.WriteString("\n")
+
+	writeRecipientSections(&b, results)
+
+	b.WriteString("## Rationale\n\n")
+	b.WriteString("Each transaction is reimbursed what it cost, read from its receipt, so nobody profits from or is out of pocket for doing the DAO's work.\n\n")
+
+	b.WriteString("## Risks & Considerations\n\n")
+	b.WriteString("The amounts come straight from chain data, and rerunning juimburser over the same blocks with the config in each report's manifest gives the same bundles.")
+	for _, res := range results {
+		if n := len(res.OverCap()) + len(res.DeniedOwed()) + len(res.CarriedOver()); n > 0 {
+			fmt.Fprintf(&b, " On %s, %d recipients aren't paid in full this time; see their sections.", res.Chain.Name, n)
+		}
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("## Timeline\n\n")
+	b.WriteString("The multisig executes the bundles once this proposal passes.\n")
+	return b.String()
+}
+
+// A section per recipient across every chain, in address order
+func writeRecipientSections(b *strings.Builder, results []*scan.Result) {
+	owed := make(map[common.Address]bool)
+	for _, res := range results {
+		for k := range res.Owed() {
+			owed[k] = true
+		}
+	}
+	if len(owed) == 0 {
+		return
+	}
+
+	b.WriteString("### Recipients\n\n")
+	for _, k := range scan.SortedAddresses(owed) {
+		name := "`" + k.Hex() + "`"
+		for _, res := range results {
+			if label := res.Chain.Labels.Name(k); label != k.Hex() {
+				name = fmt.Sprintf("%s (`%s`)", label, k.Hex())
+				break
+			}
+		}
+		fmt.Fprintf(b, "#### %s\n\n", name)
+
+		for _, res := range results {
+			owedHere := res.Owed()[k]
+			if owedHere == nil {
+				continue
+			}
+			var txs []scan.TxInfo
+			for _, tx := range res.Txs {
+				if tx.From == k {
+					txs = append(txs, tx)
+				}
+			}
+			paid := res.Payout.Format(res.Payout.Amount(res.Payable()[k]))
+			for _, split := range res.SplitPayouts() {
+				if split.Sender != k {
+					continue
+				}
+				parts := make([]string, len(split.Shares))
+				for i, s := range split.Shares {
+					parts[i] = fmt.Sprintf("%s (%s) to `%s`", scan.FormatRate(s.Rate), res.Payout.Format(res.Payout.Amount(split.Wei[i])), s.To.Hex())
+				}
+				paid = strings.Join(parts, ", ")
+			}
+			line := fmt.Sprintf("- %s: %d transactions, %s ETH owed, paid %s", res.Chain.Name, len(txs), scan.FormatEther(owedHere), paid)
+			if in := res.CarriedIn[k]; in != nil {
+				line += fmt.Sprintf(" (including %s ETH carried in from earlier runs)", scan.FormatEther(in))
+			}
+			for _, item := range res.LineItems {
+				if item.Recipient == k {
+					line += fmt.Sprintf(" (including %s ETH for %s)", scan.FormatEther(item.Wei), item.Memo)
+				}
+			}
+			if held := res.OverCap()[k]; held != nil {
+				line += fmt.Sprintf(" (%s ETH over the cap held back for review)", scan.FormatEther(held))
+			}
+			if reason, ok := res.Denied[k]; ok {
+				line += fmt.Sprintf(" (held back for manual handling: %s)", reason)
+			}
+			if res.CarriedOver()[k] != nil {
+				line += " (below the minimum payout, so carried over to the next run)"
+			}
+			b.WriteString(line + "\n")
+			if len(txs) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(b, "\n[details=\"%s transactions\"]\n\n", res.Chain.Name)
+			for _, tx := range txs {
+				fmt.Fprintf(b, "- [%s](%s/tx/%s) %s, %s ETH\n", tx.Hash.Hex()[:10], res.Chain.Explorer, tx.Hash.Hex(), tx.Label, scan.FormatEther(tx.GasWei))
+			}
+			b.WriteString("\n[/details]\n")
+		}
+		b.WriteString("\n")
+	}
+}
+
+// What the bundles pay in total, one entry per payout asset
+func payoutTotals(results []*scan.Result) []string {
+	totals := make(map[string]*big.Int)
+	var order []string
+	formats := make(map[string]scan.Payout)
+	for _, res := range results {
+		asset := "ETH"
+		if t := res.Payout.Token; t != nil {
+			asset = t.Symbol
+		}
+		if totals[asset] == nil {
+			totals[asset] = big.NewInt(0)
+			formats[asset] = res.Payout
+			order = append(order, asset)
+		}
+		totals[asset].Add(totals[asset], paidTotal(res))
+	}
+	out := make([]string, len(order))
+	for i, asset := range order {
+		out[i] = formats[asset].Format(totals[asset])
+	}
+	return out
+}
+
+// What res's bundle pays, in the payout token's base units
+func paidTotal(res *scan.Result) *big.Int {
+	payable := res.Payable()
+	total := big.NewInt(0)
+	for _, k := range bundle.Paid(res) {
+		total.Add(total, res.Payout.Amount(payable[k]))
+	}
+	return total
+}
+
+func sum(amounts map[common.Address]*big.Int) *big.Int {
+	total := big.NewInt(0)
+	for _, v := range amounts {
+		total.Add(total, v)
+	}
+	return total
+}
@@ -0,0 +1,156 @@
+package governance
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"juimburser/pkg/bundle"
+	"juimburser/pkg/scan"
+)
+
+// Output targets for --governance
+const (
+	Snapshot = "snapshot"
+	Nance    = "nance"
+	Forum    = "forum"
+)
+
+// Snapshot rejects proposal bodies longer than this for most spaces
+const SnapshotBodyLimit = 10000
+
+// A proposal in the shape snapshot.js's client.proposal takes it. Start and
+// end are Unix seconds.
+type SnapshotProposal struct {
+	Space      string   `json:"space"`
+	Type       string   `json:"type"`
+	Title      string   `json:"title"`
+	Body       string   `json:"body"`
+	Discussion string   `json:"discussion"`
+	Choices    []string `json:"choices"`
+	Start      int64    `json:"start"`
+	End        int64    `json:"end"`
+	// Block voting power is read at: the end of the mainnet scan, or 0 to
+	// fill in if mainnet wasn't scanned
+	Snapshot uint64 `json:"snapshot"`
+	Plugins  string `json:"plugins"`
+	App      string `json:"app"`
+}
+
+// A Nance proposal with one Transfer action per payout
+type NanceProposal struct {
+	Title   string        `json:"title"`
+	Body    string        `json:"body"`
+	Status  string        `json:"status"`
+	Actions []NanceAction `json:"actions"`
+}
+
+type NanceAction struct {
+	Type    string        `json:"type"`
+	Name    string        `json:"name"`
+	ChainID uint64        `json:"chainId"`
+	Payload NanceTransfer `json:"payload"`
+}
+
+// Amount is in whole tokens, with decimals to convert it. Contract is "ETH"
+// for native transfers.
+type NanceTransfer struct {
+	Contract string `json:"contract"`
+	To       string `json:"to"`
+	Amount   string `json:"amount"`
+	Decimals int    `json:"decimals"`
+}
+
+// Where a Snapshot proposal goes and when it's voted on
+type SnapshotOptions struct {
+	Space      string
+	Discussion string
+	// Voting starts Delay after now and lasts Period
+	Delay  time.Duration
+	Period time.Duration
+}
+
+// The proposal title for a set of scans, naming the dates they cover
+func Title(results []*scan.Result) string {
+	if len(results) == 0 {
+		return "JuiceboxDAO Gas Reimbursements"
+	}
+	start, end := results[0].StartTime, results[0].EndTime
+	for _, res := range results[1:] {
+		if res.StartTime.Before(start) {
+			start = res.StartTime
+		}
+		if res.EndTime.After(end) {
+			end = res.EndTime
+		}
+	}
+	return fmt.Sprintf("JuiceboxDAO Gas Reimbursements, %s to %s", start.UTC().Format(time.DateOnly), end.UTC().Format(time.DateOnly))
+}
+
+// A single-choice For/Against/Abstain proposal with body (the Markdown report)
+// as its description
+func NewSnapshotProposal(results []*scan.Result, body string, opts SnapshotOptions, now time.Time) SnapshotProposal {
+	start := now.Add(opts.Delay)
+	proposal := SnapshotProposal{
+		Space:      opts.Space,
+		Type:       "single-choice",
+		Title:      Title(results),
+		Body:       body,
+		Discussion: opts.Discussion,
+		Choices:    []string{"For", "Against", "Abstain"},
+		Start:      start.Unix(),
+		End:        start.Add(opts.Period).Unix(),
+		Plugins:    "{}",
+		App:        "juimburser",
+	}
+	for _, res := range results {
+		if res.Chain.ChainID.Uint64() == 1 {
+			proposal.Snapshot = res.EndBlock.Uint64()
+		}
+	}
+	return proposal
+}
+
+// A proposal transferring each recipient's payout, in the same order as the
+// bundles. Incomplete chains have no bundle, so are left out.
+func NewNanceProposal(results []*scan.Result, body string) NanceProposal {
+	proposal := NanceProposal{Title: Title(results), Body: body, Status: "Discussion", Actions: []NanceAction{}}
+	for _, res := range results {
+		if len(res.Errors) > 0 {
+			continue
+		}
+		contract, decimals := "ETH", 18
+		if token := res.Payout.Token; token != nil {
+			contract, decimals = token.Address.Hex(), int(token.Decimals)
+		}
+
+		payable := res.Payable()
+		for _, addr := range bundle.Paid(res) {
+			proposal.Actions = append(proposal.Actions, NanceAction{
+				Type:    "Transfer",
+				Name:    fmt.Sprintf("Gas reimbursement for %s on %s", res.Chain.Labels.Name(addr), res.Chain.Name),
+				ChainID: res.Chain.ChainID.Uint64(),
+				Payload: NanceTransfer{
+					Contract: contract,
+					To:       addr.Hex(),
+					Amount:   units(res.Payout.Amount(payable[addr]), decimals),
+					Decimals: decimals,
+				},
+			})
+		}
+	}
+	return proposal
+}
+
+// amount in base units as an exact decimal in whole tokens
+func units(amount *big.Int, decimals int) string {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	whole, frac := new(big.Int).QuoRem(amount, scale, new(big.Int))
+	if frac.Sign() == 0 {
+		return whole.String()
+	}
+	digits := frac.String()
+	digits = strings.Repeat("0", decimals-len(digits)) + digits
+	return whole.String() + "." + strings.TrimRight(digits, "0")
+}
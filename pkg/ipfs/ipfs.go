@@ -0,0 +1,98 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	PinataAPI     = "https://api.pinata.cloud"
+	PublicGateway = "https://ipfs.io"
+)
+
+// Uploads and pins files through a pinning service's pinFileToIPFS
+// endpoint (Pinata's API, which several other services also accept)
+type Pinner struct {
+	baseURL string
+	jwt     string
+	http    *http.Client
+}
+
+func New(baseURL, jwt string) *Pinner {
+	return &Pinner{baseURL: strings.TrimSuffix(baseURL, "/"), jwt: jwt, http: &http.Client{Timeout: 2 * time.Minute}}
+}
+
+// Pins the file at path under name, returning its CID
+func (p *Pinner) PinFile(ctx context.Context, path, name string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	metadata, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return "", err
+	}
+	if err := w.WriteField("pinataMetadata", string(metadata)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/pinning/pinFileToIPFS", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+p.jwt)
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("pinning %s: %s: %s", name, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var pinned struct {
+		IpfsHash string `json:"IpfsHash"`
+	}
+	if err := json.Unmarshal(respBody, &pinned); err != nil {
+		return "", fmt.Errorf("pinning %s: decoding response: %w", name, err)
+	}
+	if pinned.IpfsHash == "" {
+		return "", fmt.Errorf("pinning %s: no CID in response", name)
+	}
+	return pinned.IpfsHash, nil
+}
+
+// A gateway link to a CID, downloaded as name
+func GatewayURL(gateway, cid, name string) string {
+	return strings.TrimSuffix(gateway, "/") + "/ipfs/" + cid + "?filename=" + url.QueryEscape(name)
+}
@@ -0,0 +1,141 @@
+// Package metrics keeps counters and gauges in memory and serves them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The registry the scanner and CLI record to
+var Default = NewRegistry()
+
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	// Keyed by the label values joined with \xff
+	values map[string]float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// A counter with a value for each combination of its labels
+type CounterVec struct {
+	r *Registry
+	f *family
+}
+
+// A gauge with a value for each combination of its labels
+type GaugeVec struct {
+	r *Registry
+	f *family
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r: r, f: r.add(name, help, "counter", labels)}
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{r: r, f: r.add(name, help, "gauge", labels)}
+}
+
+func (r *Registry) add(name, help, kind string, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.name == name {
+			panic("metrics: " + name + " registered twice")
+		}
+	}
+	f := &family{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	r.families = append(r.families, f)
+	return f
+}
+
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Adds v, which must not be negative
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.f.name + " decreased")
+	}
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.f.values[c.f.key(labelValues)] += v
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+	g.f.values[g.f.key(labelValues)] = v
+}
+
+// Every metric in the text exposition format, with series sorted by label values
+func (r *Registry) Text() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, f := range r.families {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+		keys := make([]string, 0, len(f.values))
+		for k := range f.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.WriteString(f.name)
+			if len(f.labels) > 0 {
+				buf.WriteByte('{')
+				for i, value := range strings.Split(k, "\xff") {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					fmt.Fprintf(&buf, "%s=\"%s\"", f.labels[i], escapeLabel(value))
+				}
+				buf.WriteByte('}')
+			}
+			buf.WriteString(" " + strconv.FormatFloat(f.values[k], 'g', -1, 64) + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// Serves Text to Prometheus scrapes
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(r.Text())
+	})
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"juimburser/pkg/scan"
+)
+
+type Kind string
+
+const (
+	KindDiscord  Kind = "discord"
+	KindSlack    Kind = "slack"
+	KindTelegram Kind = "telegram"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// Discord rejects messages longer than this
+const discordMaxLength = 2000
+
+// Where to post run summaries
+type Webhook struct {
+	Kind Kind
+	// The incoming webhook URL for Discord and Slack. For Telegram, the bot
+	// API base URL including the token, like https://api.telegram.org/bot<token>
+	URL string
+	// Telegram only
+	ChatID string
+}
+
+// What a run found, per chain, and where its artifacts are
+type Summary struct {
+	Title  string
+	Chains []ChainSummary
+	// URLs or paths of the files the run wrote
+	Artifacts []string
+}
+
+type ChainSummary struct {
+	Name       string
+	StartBlock *big.Int
+	EndBlock   *big.Int
+	StartTime  time.Time
+	EndTime    time.Time
+	Txs        int
+	Recipients int
+	TotalWei   *big.Int
+	// Empty when paying in ETH
+	Payout string
+	// Transactions and blocks that couldn't be fetched
+	Errors int
+}
+
+func NewSummary(results []*scan.Result, artifacts []string) Summary {
+	summary := Summary{Title: "JuiceboxDAO Gas Reimbursements", Artifacts: artifacts}
+	for _, res := range results {
+		totals := res.Totals()
+		total := big.NewInt(0)
+		for _, v := range totals {
+			total.Add(total, v)
+		}
+
+		chain := ChainSummary{
+			Name:       res.Chain.Name,
+			StartBlock: res.StartBlock,
+			EndBlock:   res.EndBlock,
+			StartTime:  res.StartTime,
+			EndTime:    res.EndTime,
+			Txs:        len(res.Txs),
+			Recipients: len(totals),
+			TotalWei:   total,
+			Errors:     len(res.Errors),
+		}
+		if res.Payout.Token != nil {
+			payable := big.NewInt(0)
+			for _, v := range res.Payable() {
+				payable.Add(payable, v)
+			}
+			chain.Payout = res.Payout.Format(res.Payout.Amount(payable))
+		}
+		summary.Chains = append(summary.Chains, chain)
+	}
+	return summary
+}
+
+// The summary as a plain text message
+func (s Summary) Text() string {
+	var b strings.Builder
+	b.WriteString(s.Title + "\n")
+	for _, c := range s.Chains {
+		fmt.Fprintf(&b, "\n%s: %s to %s (blocks %s to %s)\n", c.Name, c.StartTime.UTC().Format("2006-01-02"),
+			c.EndTime.UTC().Format("2006-01-02"), c.StartBlock, c.EndBlock)
+		fmt.Fprintf(&b, "%s ETH to %d recipients over %d transactions", scan.FormatEther(c.TotalWei), c.Recipients, c.Txs)
+		if c.Payout != "" {
+			fmt.Fprintf(&b, ", paid as %s", c.Payout)
+		}
+		b.WriteString("\n")
+		if c.Errors > 0 {
+			fmt.Fprintf(&b, "Incomplete: %d transactions or blocks couldn't be fetched, so no bundle was written\n", c.Errors)
+		}
+	}
+	if len(s.Artifacts) > 0 {
+		b.WriteString("\nArtifacts:\n")
+		for _, a := range s.Artifacts {
+			b.WriteString("- " + a + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Posts summaries to every configured webhook
+type Notifier struct {
+	hooks []Webhook
+	http  *http.Client
+}
+
+func New(hooks []Webhook) *Notifier {
+	return &Notifier{hooks: hooks, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Posts the summary to each webhook, returning every failure
+func (n *Notifier) Send(ctx context.Context, s Summary) error {
+	text := s.Text()
+	var errs []error
+	for _, hook := range n.hooks {
+		if err := n.send(ctx, hook, text); err != nil {
+			errs = append(errs, fmt.Errorf("%s webhook: %w", hook.Kind, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) send(ctx context.Context, hook Webhook, text string) error {
+	var url string
+	var body any
+	switch hook.Kind {
+	case KindDiscord:
+		if len(text) > discordMaxLength {
+			text = text[:discordMaxLength-1] + "…"
+		}
+		url, body = hook.URL, map[string]any{"content": text}
+	case KindSlack:
+		url, body = hook.URL, map[string]any{"text": text}
+	case KindTelegram:
+		url = strings.TrimSuffix(hook.URL, "/") + "/sendMessage"
+		body = map[string]any{"chat_id": hook.ChatID, "text": text, "disable_web_page_preview": true}
+	default:
+		return fmt.Errorf("unknown webhook kind %q", hook.Kind)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		// Webhook URLs are secrets, so don't let them end up in logs
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// The Telegram bot API base URL for a bot token
+func TelegramURL(botToken string) string {
+	return telegramAPI + "/bot" + botToken
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"juimburser/pkg/scan"
+)
+
+// The raw inputs behind a report, written to inputs.json: every fetched
+// transaction's matched log, receipt, and block header fields, per chain
+type Archive struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Chains      []ArchiveChain `json:"chains"`
+}
+
+type ArchiveChain struct {
+	Name       string         `json:"name"`
+	ChainID    string         `json:"chainId"`
+	StartBlock uint64         `json:"startBlock"`
+	EndBlock   uint64         `json:"endBlock"`
+	Manifest   *scan.Manifest `json:"manifest,omitempty"`
+	// Excluded ones too; report.json says which were excluded and why
+	Transactions []scan.ArchivedTx `json:"transactions"`
+}
+
+// Writes the results' raw inputs to path. Results scanned without
+// scan.Options.Archive have no transactions in it.
+func WriteArchive(path string, results []*scan.Result) error {
+	archive := Archive{GeneratedAt: scan.GeneratedAt(results), Chains: []ArchiveChain{}}
+	for _, res := range results {
+		chain := ArchiveChain{
+			Name:         res.Chain.Name,
+			ChainID:      res.Chain.ChainID.String(),
+			StartBlock:   res.StartBlock.Uint64(),
+			EndBlock:     res.EndBlock.Uint64(),
+			Manifest:     res.Manifest,
+			Transactions: res.Archive,
+		}
+		if chain.Transactions == nil {
+			chain.Transactions = []scan.ArchivedTx{}
+		}
+		archive.Chains = append(archive.Chains, chain)
+	}
+
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
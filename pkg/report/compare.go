@@ -0,0 +1,184 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+)
+
+// Writes how current differs from previous, chain by chain: transactions in
+// the blocks both scanned that are new, missing, or reimbursed differently,
+// and recipients whose totals over those blocks changed
+func WriteComparison(w io.Writer, previous, current JSONReport) {
+	prevChains := make(map[string]JSONChainReport)
+	for _, chain := range previous.Chains {
+		prevChains[chain.ChainID] = chain
+	}
+
+	differences := 0
+	for _, cur := range current.Chains {
+		prev, ok := prevChains[cur.ChainID]
+		if !ok {
+			fmt.Fprintf(w, "%s (chain ID %s): not in the previous report\n", cur.Name, cur.ChainID)
+			differences++
+			continue
+		}
+		delete(prevChains, cur.ChainID)
+		differences += compareChain(w, prev, cur)
+	}
+	for _, prev := range previous.Chains {
+		if _, ok := prevChains[prev.ChainID]; ok {
+			fmt.Fprintf(w, "%s (chain ID %s): not scanned this time\n", prev.Name, prev.ChainID)
+			differences++
+		}
+	}
+
+	if differences == 0 {
+		fmt.Fprintln(w, "No differences")
+	} else {
+		fmt.Fprintf(w, "%d differences\n", differences)
+	}
+}
+
+// Writes one chain's differences, returning how many there were
+func compareChain(w io.Writer, prev, cur JSONChainReport) int {
+	fmt.Fprintf(w, "%s (chain ID %s): blocks %d to %d, previously %d to %d\n", cur.Name, cur.ChainID, cur.StartBlock, cur.EndBlock, prev.StartBlock, prev.EndBlock)
+	from, to := max(prev.StartBlock, cur.StartBlock), min(prev.EndBlock, cur.EndBlock)
+	if from > to {
+		fmt.Fprintln(w, "  The ranges don't overlap, so there's nothing to compare")
+		return 1
+	}
+	if from != prev.StartBlock || to != prev.EndBlock || from != cur.StartBlock || to != cur.EndBlock {
+		fmt.Fprintf(w, "  Comparing transactions in blocks %d to %d\n", from, to)
+	}
+
+	// Included and excluded transactions in the overlap, by hash
+	txs := func(chain JSONChainReport) (map[common.Hash]JSONTx, map[common.Hash]string) {
+		included, excluded := make(map[common.Hash]JSONTx), make(map[common.Hash]string)
+		for _, tx := range chain.Transactions {
+			if from <= tx.BlockNumber && tx.BlockNumber <= to {
+				included[tx.Hash] = tx
+			}
+		}
+		for _, tx := range chain.Excluded {
+			if from <= tx.BlockNumber && tx.BlockNumber <= to {
+				excluded[tx.Hash] = tx.Reason
+			}
+		}
+		return included, excluded
+	}
+	prevTxs, prevExcluded := txs(prev)
+	curTxs, curExcluded := txs(cur)
+
+	var lines []string
+	for _, tx := range cur.Transactions {
+		old, ok := prevTxs[tx.Hash]
+		switch {
+		case tx.BlockNumber < from || tx.BlockNumber > to:
+		case !ok && prevExcluded[tx.Hash] != "":
+			lines = append(lines, fmt.Sprintf("  Now included: %s (%s, block %d), %s ETH; previously excluded: %s", tx.Hash.Hex(), tx.Label, tx.BlockNumber, ether(tx.TotalWei), prevExcluded[tx.Hash]))
+		case !ok:
+			lines = append(lines, fmt.Sprintf("  New: %s (%s, block %d) from %s, %s ETH", tx.Hash.Hex(), tx.Label, tx.BlockNumber, tx.From.Hex(), ether(tx.TotalWei)))
+		default:
+			var changes []string
+			if old.Label != tx.Label {
+				changes = append(changes, fmt.Sprintf("type %s -> %s", old.Label, tx.Label))
+			}
+			if old.From != tx.From {
+				changes = append(changes, fmt.Sprintf("sender %s -> %s", old.From.Hex(), tx.From.Hex()))
+			}
+			if old.TotalWei != tx.TotalWei {
+				changes = append(changes, fmt.Sprintf("%s -> %s ETH", ether(old.TotalWei), ether(tx.TotalWei)))
+			}
+			if len(changes) > 0 {
+				lines = append(lines, fmt.Sprintf("  Changed: %s (block %d): %s", tx.Hash.Hex(), tx.BlockNumber, strings.Join(changes, ", ")))
+			}
+		}
+	}
+	for _, tx := range prev.Transactions {
+		if _, ok := curTxs[tx.Hash]; ok || tx.BlockNumber < from || tx.BlockNumber > to {
+			continue
+		}
+		if reason := curExcluded[tx.Hash]; reason != "" {
+			lines = append(lines, fmt.Sprintf("  Now excluded: %s (%s, block %d), %s ETH: %s", tx.Hash.Hex(), tx.Label, tx.BlockNumber, ether(tx.TotalWei), reason))
+		} else {
+			lines = append(lines, fmt.Sprintf("  Missing: %s (%s, block %d) from %s, %s ETH", tx.Hash.Hex(), tx.Label, tx.BlockNumber, tx.From.Hex(), ether(tx.TotalWei)))
+		}
+	}
+
+	// Recipients' totals over the overlap, so a longer range doesn't count as
+	// a change
+	prevSent, curSent := sentBy(prevTxs), sentBy(curTxs)
+	names := make(map[common.Address]string)
+	for _, r := range append(prev.Recipients, cur.Recipients...) {
+		if r.Label != "" {
+			names[r.Address] = fmt.Sprintf("%s (%s)", r.Address.Hex(), r.Label)
+		}
+	}
+	name := func(addr common.Address) string {
+		if names[addr] != "" {
+			return names[addr]
+		}
+		return addr.Hex()
+	}
+	for _, addr := range scan.SortedAddresses(curSent) {
+		old, ok := prevSent[addr]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("  New recipient: %s, %d transactions, %s ETH", name(addr), curSent[addr].txs, scan.FormatEther(curSent[addr].wei)))
+		case old.wei.Cmp(curSent[addr].wei) != 0:
+			lines = append(lines, fmt.Sprintf("  Changed recipient: %s, %d -> %d transactions, %s -> %s ETH", name(addr), old.txs, curSent[addr].txs, scan.FormatEther(old.wei), scan.FormatEther(curSent[addr].wei)))
+		}
+	}
+	for _, addr := range scan.SortedAddresses(prevSent) {
+		if _, ok := curSent[addr]; !ok {
+			lines = append(lines, fmt.Sprintf("  Missing recipient: %s, previously %d transactions, %s ETH", name(addr), prevSent[addr].txs, scan.FormatEther(prevSent[addr].wei)))
+		}
+	}
+
+	if len(cur.Errors) > 0 {
+		fmt.Fprintf(w, "  %d transactions or blocks couldn't be fetched this time, so may show as missing\n", len(cur.Errors))
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(w, "  No differences")
+		return 0
+	}
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return len(lines)
+}
+
+type sent struct {
+	txs int
+	wei *big.Int
+}
+
+// What each sender's transactions add up to
+func sentBy(txs map[common.Hash]JSONTx) map[common.Address]sent {
+	totals := make(map[common.Address]sent)
+	for _, tx := range txs {
+		t := totals[tx.From]
+		if t.wei == nil {
+			t.wei = new(big.Int)
+		}
+		if v, ok := new(big.Int).SetString(tx.TotalWei, 10); ok {
+			t.wei.Add(t.wei, v)
+		}
+		t.txs++
+		totals[tx.From] = t
+	}
+	return totals
+}
+
+// A wei amount from a report as ETH, or as is if it doesn't parse
+func ether(wei string) string {
+	v, ok := new(big.Int).SetString(wei, 10)
+	if !ok {
+		return wei
+	}
+	return scan.FormatEther(v)
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+)
+
+// Writes the transactions CSV (one row per reimbursed transaction) to txPath
+// and the recipients CSV (one row per recipient per chain) to recipientPath
+func WriteCSVs(txPath, recipientPath string, results []*scan.Result) error {
+	txRows := [][]string{{"chain", "chain_id", "tx_hash", "sender", "label", "block", "gas_used",
+		"effective_gas_price_wei", "l1_fee_wei", "cost_wei", "cost_eth", "cost_usd", "actual_cost_wei", "failed", "blob_fee_wei", "base_fee_wei", "tip_wei"}}
+	recipientRows := [][]string{{"chain", "chain_id", "recipient", "tx_count", "total_wei", "total_eth", "total_usd", "held_wei", "payout", "total_usd_at_report"}}
+
+	for _, res := range results {
+		chainID := res.Chain.ChainID.String()
+		counts := make(map[common.Address]int)
+		for _, tx := range res.Txs {
+			counts[tx.From]++
+			txRows = append(txRows, []string{
+				res.Chain.Name,
+				chainID,
+				tx.Hash.Hex(),
+				tx.From.Hex(),
+				tx.Label,
+				strconv.FormatUint(tx.BlockNumber, 10),
+				strconv.FormatUint(tx.GasUsed, 10),
+				tx.EffectiveGasPrice.String(),
+				optionalInt(tx.Cost.L1FeeWei),
+				tx.GasWei.String(),
+				scan.FormatEther(tx.GasWei),
+				optionalUSD(tx.USD),
+				optionalInt(tx.ActualWei),
+				strconv.FormatBool(tx.Failed),
+				optionalInt(tx.Cost.BlobWei),
+				optionalInt(tx.Cost.BaseFeeWei),
+				optionalInt(tx.Cost.TipWei),
+			})
+		}
+
+		usdTotals, reportUSD, payable, over := res.USDTotals(), res.ReportUSDTotals(), res.Payable(), res.OverCap()
+		totals := res.Totals()
+		for _, k := range scan.SortedAddresses(totals) {
+			v := totals[k]
+			var usd, usdAtReport *big.Float
+			if usdTotals != nil {
+				usd = usdTotals[k]
+			}
+			if reportUSD != nil {
+				usdAtReport = reportUSD[k]
+			}
+			recipientRows = append(recipientRows, []string{
+				res.Chain.Name,
+				chainID,
+				k.Hex(),
+				strconv.Itoa(counts[k]),
+				v.String(),
+				scan.FormatEther(v),
+				optionalUSD(usd),
+				optionalInt(over[k]),
+				res.Payout.Format(res.Payout.Amount(payable[k])),
+				optionalUSD(usdAtReport),
+			})
+		}
+	}
+
+	if err := writeCSV(txPath, txRows); err != nil {
+		return err
+	}
+	return writeCSV(recipientPath, recipientRows)
+}
+
+func writeCSV(path string, rows [][]string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func optionalInt(v *big.Int) string {
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
+
+func optionalUSD(v *big.Float) string {
+	if v == nil {
+		return ""
+	}
+	return v.Text('f', 2)
+}
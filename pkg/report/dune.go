@@ -0,0 +1,50 @@
+package report
+
+import (
+	"strconv"
+	"time"
+
+	"juimburser/pkg/scan"
+)
+
+// Dune reads timestamps in this layout, in UTC
+const duneTime = "2006-01-02 15:04:05"
+
+// Writes a CSV to path with one row per reimbursed transaction across every
+// chain, in the columns of a Dune dataset upload. Each row carries its
+// reimbursement period so uploads from successive runs can be appended to one
+// table.
+func WriteDuneCSV(path string, results []*scan.Result) error {
+	rows := [][]string{{"tx_hash", "chain", "chain_id", "label", "sender", "block_number", "block_time",
+		"gas_wei", "gas_eth", "usd", "period_start", "period_end"}}
+
+	for _, res := range results {
+		chainID := res.Chain.ChainID.String()
+		start, end := res.StartTime.UTC().Format(duneTime), res.EndTime.UTC().Format(duneTime)
+		for _, tx := range res.Txs {
+			rows = append(rows, []string{
+				tx.Hash.Hex(),
+				res.Chain.Name,
+				chainID,
+				tx.Label,
+				tx.From.Hex(),
+				strconv.FormatUint(tx.BlockNumber, 10),
+				duneTimestamp(tx.BlockTime),
+				tx.GasWei.String(),
+				scan.FormatEther(tx.GasWei),
+				optionalUSD(tx.USD),
+				start,
+				end,
+			})
+		}
+	}
+
+	return writeCSV(path, rows)
+}
+
+func duneTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(duneTime)
+}
@@ -0,0 +1,24 @@
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+
+	"juimburser/pkg/scan"
+)
+
+//go:embed templates/report.html
+var htmlReportTemplate string
+
+var htmlReport = template.Must(template.New("report.html").Parse(htmlReportTemplate))
+
+// Renders a self-contained HTML report with explorer links and collapsible
+// per-recipient sections
+func RenderHTML(results []*scan.Result) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, BuildData(results)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
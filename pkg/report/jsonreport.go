@@ -0,0 +1,614 @@
+package report
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+)
+
+// Machine-readable report written to report.json. Amounts are decimal strings
+// in wei (or the payout token's base units) so they survive JSON parsers
+// without losing precision; USD values are decimal strings with cents.
+type JSONReport struct {
+	Title       string            `json:"title"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+	Chains      []JSONChainReport `json:"chains"`
+}
+
+type JSONChainReport struct {
+	Name       string     `json:"name"`
+	ChainID    string     `json:"chainId"`
+	StartBlock uint64     `json:"startBlock"`
+	EndBlock   uint64     `json:"endBlock"`
+	StartTime  time.Time  `json:"startTime"`
+	EndTime    time.Time  `json:"endTime"`
+	Payout     JSONPayout `json:"payout"`
+	// week or month with --bucket
+	Bucket string `json:"bucket,omitempty"`
+	// Per-recipient cap in wei, omitted if there's none
+	CapWei *string `json:"capWei,omitempty"`
+	// Gas price cap in wei, omitted if there's none
+	MaxGasPriceWei *string `json:"maxGasPriceWei,omitempty"`
+	// Priority fee limit per gas in wei, omitted if there's none, and whether
+	// transactions over it are capped rather than only flagged
+	MaxTipWei *string `json:"maxTipWei,omitempty"`
+	CapTips   bool    `json:"capTips,omitempty"`
+	// Minimum payout in wei, omitted if there's none
+	MinPayoutWei *string `json:"minPayoutWei,omitempty"`
+	// Owed from earlier runs, and carried over to the next, for being below
+	// the minimum payout
+	CarriedIn   []JSONCarried `json:"carriedIn,omitempty"`
+	CarriedOver []JSONCarried `json:"carriedOver,omitempty"`
+	// Reimbursements besides gas from the config, included in recipients'
+	// payouts
+	LineItems []JSONLineItem `json:"lineItems,omitempty"`
+	TotalWei  string         `json:"totalWei"`
+	TotalUSD  *string        `json:"totalUsd,omitempty"`
+	// totalUsd values each transaction at its block; this values the total
+	// at reportEthUsd, the end block's price
+	TotalUSDAtReport *string `json:"totalUsdAtReport,omitempty"`
+	ReportETHUSD     *string `json:"reportEthUsd,omitempty"`
+	// Execution costs split into base and priority fees, omitted before EIP-1559
+	BaseFeeWei *string `json:"baseFeeWei,omitempty"`
+	TipWei     *string `json:"tipWei,omitempty"`
+	// tipWei in basis points of baseFeeWei
+	TipOverheadBps *uint64         `json:"tipOverheadBps,omitempty"`
+	Recipients     []JSONRecipient `json:"recipients"`
+	Transactions   []JSONTx        `json:"transactions"`
+	// Matching transactions left out of the reimbursement
+	Excluded []JSONExcludedTx `json:"excluded"`
+	// What couldn't be fetched, so is missing from the totals
+	Errors []JSONScanError `json:"errors"`
+	// Set if the run was interrupted before the chain's scan finished, so
+	// the totals are partial
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Omitted unless the bundle is split across several files
+	BundleFiles []JSONBundleFile `json:"bundleFiles,omitempty"`
+	// Recipients flagged by sanctions screening, held back from the bundle
+	Denied []JSONDenied `json:"denied,omitempty"`
+	// What the chain's report and bundle were made from
+	Manifest *scan.Manifest `json:"manifest,omitempty"`
+	// Omitted unless the Safe's balance was checked
+	Balance *JSONBalance `json:"balanceCheck,omitempty"`
+	// Gas by transaction type, in group order
+	Types []JSONType `json:"types"`
+	// What each of the chain's Safes executed, omitted if it tracks none
+	Safes []JSONSafe `json:"safes,omitempty"`
+}
+
+type JSONType struct {
+	Label   string `json:"label"`
+	TxCount int    `json:"txCount"`
+	GasWei  string `json:"gasWei"`
+	RateBps uint64 `json:"rateBps,omitempty"`
+}
+
+type JSONSafe struct {
+	Address common.Address `json:"address"`
+	Name    string         `json:"name,omitempty"`
+	TxCount int            `json:"txCount"`
+	GasWei  string         `json:"gasWei"`
+}
+
+// The Safe's balance against what executing the bundle needs. Token
+// amounts are in the payout token's base units, omitted when paying in ETH.
+type JSONBalance struct {
+	Safe        common.Address `json:"safe"`
+	BalanceWei  string         `json:"balanceWei"`
+	SendsWei    string         `json:"sendsWei"`
+	Gas         uint64         `json:"gas"`
+	GasPriceWei string         `json:"gasPriceWei"`
+	GasCostWei  string         `json:"gasCostWei"`
+	// Calls costed at a flat rate because they couldn't be estimated
+	UnestimatedCalls int     `json:"unestimatedCalls"`
+	TokenBalance     *string `json:"tokenBalance,omitempty"`
+	TokenSends       *string `json:"tokenSends,omitempty"`
+	// Whether the Safe holds less than what the bundle sends plus gas
+	Short bool `json:"short"`
+}
+
+type JSONDenied struct {
+	Address common.Address `json:"address"`
+	OwedWei string         `json:"owedWei"`
+	Reason  string         `json:"reason"`
+}
+
+type JSONCarried struct {
+	Address common.Address `json:"address"`
+	Wei     string         `json:"wei"`
+}
+
+type JSONLineItem struct {
+	ID      string         `json:"id"`
+	Address common.Address `json:"address"`
+	Wei     string         `json:"wei"`
+	Memo    string         `json:"memo"`
+}
+
+type JSONBundleFile struct {
+	Name       string           `json:"name"`
+	Recipients []common.Address `json:"recipients"`
+	// In the payout asset's base units
+	PayoutAmount string `json:"payoutAmount"`
+}
+
+type JSONScanError struct {
+	// Omitted for a block walked for reverted calls
+	Hash        *common.Hash `json:"hash,omitempty"`
+	Label       string       `json:"label"`
+	BlockNumber uint64       `json:"blockNumber"`
+	Error       string       `json:"error"`
+}
+
+type JSONExcludedTx struct {
+	JSONTx
+	Reason string `json:"reason"`
+}
+
+type JSONPayout struct {
+	// "ETH" or the token symbol
+	Asset string `json:"asset"`
+	// Token contract, omitted for ETH
+	Token    *common.Address `json:"token,omitempty"`
+	Decimals uint8           `json:"decimals"`
+	// Token units per ETH, omitted for ETH
+	Rate *string `json:"rate,omitempty"`
+	// Where rate came from, e.g. "price at block 19000000"
+	RateSource string `json:"rateSource,omitempty"`
+	// Set when paying through a Juicebox terminal
+	Terminal *JSONTerminal `json:"terminal,omitempty"`
+	// Set when recipients claim from a Merkle claim contract
+	Claim *JSONClaim `json:"claim,omitempty"`
+	// Set when paying through Sablier streams
+	Stream *JSONStream `json:"stream,omitempty"`
+	// Set when paying through a 0xSplits split
+	Split *JSONSplit `json:"split,omitempty"`
+}
+
+type JSONSplit struct {
+	Address   common.Address `json:"address"`
+	SplitMain common.Address `json:"splitMain"`
+	// In SplitMain's order (by address)
+	Allocations []JSONAllocation `json:"allocations"`
+}
+
+type JSONAllocation struct {
+	Address common.Address `json:"address"`
+	// Out of 1000000
+	PercentAllocation uint32 `json:"percentAllocation"`
+}
+
+type JSONStream struct {
+	Lockup          common.Address `json:"lockup"`
+	DurationSeconds uint64         `json:"durationSeconds"`
+	CliffSeconds    uint64         `json:"cliffSeconds,omitempty"`
+	Cancelable      bool           `json:"cancelable"`
+}
+
+type JSONClaim struct {
+	Contract common.Address `json:"contract"`
+	// Omitted unless a bundle was built
+	MerkleRoot *common.Hash `json:"merkleRoot,omitempty"`
+}
+
+type JSONTerminal struct {
+	Address   common.Address `json:"address"`
+	Version   int            `json:"version"`
+	ProjectID uint64         `json:"projectId"`
+}
+
+type JSONRecipient struct {
+	Address common.Address `json:"address"`
+	// From the config's label book, omitted if it has none
+	Label    string  `json:"label,omitempty"`
+	TxCount  int     `json:"txCount"`
+	TotalWei string  `json:"totalWei"`
+	TotalUSD *string `json:"totalUsd,omitempty"`
+	// At the chain's reportEthUsd
+	TotalUSDAtReport *string `json:"totalUsdAtReport,omitempty"`
+	// Wei over the per-recipient cap, held back from the payout
+	HeldWei *string `json:"heldWei,omitempty"`
+	// In the payout asset's base units
+	PayoutAmount string `json:"payoutAmount"`
+	// How their payout is divided, omitted unless they're a split sender
+	// (whose payoutAmount is then 0)
+	Shares []JSONShare `json:"shares,omitempty"`
+	// Each sender's part, omitted unless they're paid for other senders'
+	// transactions
+	Sources []JSONSource `json:"sources,omitempty"`
+	// Effective gas prices paid, and gas used per transaction type
+	GasStats *JSONGasStats `json:"gasStats,omitempty"`
+	// The bundle file paying them, omitted unless the bundle is split
+	BundleFile string `json:"bundleFile,omitempty"`
+	// Why a plain ETH transfer to them reverts, omitted if it doesn't (or
+	// wasn't checked)
+	RejectsETH string `json:"rejectsEth,omitempty"`
+	// Gas per calendar period with --bucket, including empty ones
+	Buckets []JSONBucket `json:"buckets,omitempty"`
+}
+
+type JSONGasStats struct {
+	MinGasPriceWei string        `json:"minGasPriceWei"`
+	AvgGasPriceWei string        `json:"avgGasPriceWei"`
+	MaxGasPriceWei string        `json:"maxGasPriceWei"`
+	Types          []JSONTypeGas `json:"types"`
+}
+
+type JSONTypeGas struct {
+	Label          string `json:"label"`
+	TxCount        int    `json:"txCount"`
+	AvgGasUsed     uint64 `json:"avgGasUsed"`
+	AvgGasPriceWei string `json:"avgGasPriceWei"`
+}
+
+type JSONSource struct {
+	Address common.Address `json:"address"`
+	TxCount int            `json:"txCount"`
+	GasWei  string         `json:"gasWei"`
+}
+
+type JSONShare struct {
+	Address common.Address `json:"address"`
+	// Basis points of the sender's payout
+	RateBps      uint64 `json:"rateBps"`
+	PayoutAmount string `json:"payoutAmount"`
+}
+
+type JSONBucket struct {
+	Name    string    `json:"name"`
+	Start   time.Time `json:"start"`
+	TxCount int       `json:"txCount"`
+	GasWei  string    `json:"gasWei"`
+}
+
+type JSONTx struct {
+	Hash                 common.Hash    `json:"hash"`
+	Label                string         `json:"label"`
+	From                 common.Address `json:"from"`
+	BlockNumber          uint64         `json:"blockNumber"`
+	BlockTime            *time.Time     `json:"blockTime,omitempty"`
+	GasUsed              uint64         `json:"gasUsed"`
+	EffectiveGasPriceWei string         `json:"effectiveGasPriceWei"`
+	ExecutionWei         string         `json:"executionWei"`
+	L1FeeWei             *string        `json:"l1FeeWei,omitempty"`
+	// ExecutionWei split into base and priority fees, omitted before EIP-1559
+	BaseFeePerGasWei *string `json:"baseFeePerGasWei,omitempty"`
+	BaseFeeWei       *string `json:"baseFeeWei,omitempty"`
+	TipWei           *string `json:"tipWei,omitempty"`
+	// Set when the tip per gas was more than the base fee
+	ExcessiveTip bool `json:"excessiveTip,omitempty"`
+	// Set when the tip per gas was over the chain's maxTipWei
+	OverMaxTip bool `json:"overMaxTip,omitempty"`
+	// Only set for transactions that carry blobs
+	BlobGasUsed     uint64  `json:"blobGasUsed,omitempty"`
+	BlobGasPriceWei *string `json:"blobGasPriceWei,omitempty"`
+	BlobWei         *string `json:"blobWei,omitempty"`
+	TotalWei        string  `json:"totalWei"`
+	// What the transaction actually cost, when the gas price cap reduced totalWei
+	ActualWei *string `json:"actualWei,omitempty"`
+	// The gas price totalWei is at when it's capped
+	CappedGasPriceWei *string `json:"cappedGasPriceWei,omitempty"`
+	// The config rule that capped totalWei, if one did
+	CapRule string `json:"capRule,omitempty"`
+	// Basis points of the cost in totalWei, omitted when it's all reimbursed
+	RateBps uint64  `json:"rateBps,omitempty"`
+	ETHUSD  *string `json:"ethUsd,omitempty"`
+	USD     *string `json:"usd,omitempty"`
+	// The transaction reverted and was included by includeFailed
+	Failed bool `json:"failed,omitempty"`
+	// The Safe that executed it, omitted unless found by a Safe's group
+	Safe *common.Address `json:"safe,omitempty"`
+	// The Safe module that executed it, omitted unless it was one
+	Module *common.Address `json:"module,omitempty"`
+	// Omitted unless it was an ERC-4337 UserOperation, in which case from is
+	// whoever funded it and the costs are the operation's
+	UserOp *JSONUserOp `json:"userOp,omitempty"`
+	// Omitted unless a relayer sent it, in which case from is the relayer's
+	// payer
+	Relay *JSONRelay `json:"relay,omitempty"`
+	// Omitted unless from is the beneficiary the config pays in place of
+	// the sender, in which case this is the sender
+	SentBy *common.Address `json:"sentBy,omitempty"`
+}
+
+type JSONUserOp struct {
+	Hash    common.Hash    `json:"hash"`
+	Sender  common.Address `json:"sender"`
+	Bundler common.Address `json:"bundler"`
+	// Omitted if the sender paid its own gas
+	Paymaster *common.Address `json:"paymaster,omitempty"`
+}
+
+type JSONRelay struct {
+	Relayer common.Address `json:"relayer"`
+	// Each omitted if unknown
+	Forwarder *common.Address `json:"forwarder,omitempty"`
+	Signer    *common.Address `json:"signer,omitempty"`
+	Sponsor   *common.Address `json:"sponsor,omitempty"`
+}
+
+func BuildJSON(results []*scan.Result) JSONReport {
+	report := JSONReport{
+		Title:       "JuiceboxDAO Gas Reimbursements",
+		GeneratedAt: scan.GeneratedAt(results),
+		Chains:      []JSONChainReport{},
+	}
+
+	for _, res := range results {
+		chain := JSONChainReport{
+			Name:         res.Chain.Name,
+			ChainID:      res.Chain.ChainID.String(),
+			StartBlock:   res.StartBlock.Uint64(),
+			EndBlock:     res.EndBlock.Uint64(),
+			StartTime:    res.StartTime.UTC(),
+			EndTime:      res.EndTime.UTC(),
+			Payout:       JSONPayout{Asset: "ETH", Decimals: 18},
+			Recipients:   []JSONRecipient{},
+			Transactions: []JSONTx{},
+			Excluded:     []JSONExcludedTx{},
+			Errors:       []JSONScanError{},
+			Types:        []JSONType{},
+			Interrupted:  res.Interrupted,
+			Manifest:     res.Manifest,
+		}
+		if b := res.Balance; b != nil {
+			chain.Balance = &JSONBalance{
+				Safe:             b.Safe,
+				BalanceWei:       b.Balance.String(),
+				SendsWei:         b.Sends.String(),
+				Gas:              b.Gas,
+				GasPriceWei:      b.GasPrice.String(),
+				GasCostWei:       b.GasCost().String(),
+				UnestimatedCalls: b.Unestimated,
+				Short:            b.Short(),
+			}
+			if b.TokenBalance != nil {
+				balance, sends := b.TokenBalance.String(), b.TokenSends.String()
+				chain.Balance.TokenBalance, chain.Balance.TokenSends = &balance, &sends
+			}
+		}
+		if token := res.Payout.Token; token != nil {
+			rate := res.Payout.Rate.Text('f', -1)
+			chain.Payout = JSONPayout{Asset: token.Symbol, Token: &token.Address, Decimals: token.Decimals, Rate: &rate, RateSource: res.Payout.RateSource}
+		}
+
+		total, totalUSD := big.NewInt(0), new(big.Float)
+		totals, usdTotals, reportUSD, payable, over := res.Totals(), res.USDTotals(), res.ReportUSDTotals(), res.Payable(), res.OverCap()
+		// Everyone owed or paid, so recipients owed only carried in amounts
+		// or line items, or paid only shares of split senders, are listed
+		// with their payouts too
+		listed := res.Owed()
+		for addr := range payable {
+			listed[addr] = nil
+		}
+		index := make(map[common.Address]int)
+		for _, addr := range scan.SortedAddresses(listed) {
+			index[addr] = len(chain.Recipients)
+			chain.Recipients = append(chain.Recipients, JSONRecipient{Address: addr, Label: res.Chain.Labels[addr]})
+		}
+		for _, tx := range res.Txs {
+			if tx.USD != nil {
+				totalUSD.Add(totalUSD, tx.USD)
+			}
+			jt := jsonTx(tx)
+			jt.OverMaxTip = res.Chain.OverMaxTip(tx)
+			chain.Transactions = append(chain.Transactions, jt)
+			total.Add(total, tx.GasWei)
+			chain.Recipients[index[tx.From]].TxCount++
+		}
+
+		chain.Bucket = string(res.Chain.Bucket)
+		buckets := res.BucketTotals()
+		files, sources, gasStats := bundleFiles(res), res.Sources(), res.GasStats()
+		for i, r := range chain.Recipients {
+			if s, ok := gasStats[r.Address]; ok {
+				stats := &JSONGasStats{MinGasPriceWei: s.MinPrice.String(), AvgGasPriceWei: s.AvgPrice.String(), MaxGasPriceWei: s.MaxPrice.String()}
+				for _, t := range s.Types {
+					stats.Types = append(stats.Types, JSONTypeGas{Label: t.Label, TxCount: t.Txs, AvgGasUsed: t.AvgGasUsed, AvgGasPriceWei: t.AvgPrice.String()})
+				}
+				chain.Recipients[i].GasStats = stats
+			}
+			for _, s := range sources[r.Address] {
+				chain.Recipients[i].Sources = append(chain.Recipients[i].Sources, JSONSource{Address: s.Sender, TxCount: s.Txs, GasWei: s.GasWei.String()})
+			}
+			for _, t := range buckets[r.Address] {
+				chain.Recipients[i].Buckets = append(chain.Recipients[i].Buckets, JSONBucket{Name: res.Chain.Bucket.Name(t.Start), Start: t.Start, TxCount: t.Txs, GasWei: t.GasWei.String()})
+			}
+			chain.Recipients[i].BundleFile = files[r.Address]
+			chain.Recipients[i].RejectsETH = res.RejectsETH[r.Address]
+			chain.Recipients[i].TotalWei = "0"
+			if t := totals[r.Address]; t != nil {
+				chain.Recipients[i].TotalWei = t.String()
+			}
+			chain.Recipients[i].HeldWei = optionalString(over[r.Address])
+			chain.Recipients[i].PayoutAmount = res.Payout.Amount(payable[r.Address]).String()
+			if usdTotals != nil {
+				chain.Recipients[i].TotalUSD = optionalString(usdTotals[r.Address])
+			}
+			if reportUSD != nil {
+				chain.Recipients[i].TotalUSDAtReport = optionalString(reportUSD[r.Address])
+			}
+		}
+
+		denied := res.DeniedOwed()
+		for _, split := range res.SplitPayouts() {
+			i := index[split.Sender]
+			for j, s := range split.Shares {
+				chain.Recipients[i].Shares = append(chain.Recipients[i].Shares, JSONShare{Address: s.To, RateBps: s.Rate, PayoutAmount: res.Payout.Amount(split.Wei[j]).String()})
+			}
+		}
+
+		for _, addr := range scan.SortedAddresses(denied) {
+			chain.Denied = append(chain.Denied, JSONDenied{Address: addr, OwedWei: denied[addr].String(), Reason: res.Denied[addr]})
+		}
+		for _, f := range res.BundleFiles {
+			chain.BundleFiles = append(chain.BundleFiles, JSONBundleFile{Name: f.Name, Recipients: f.Recipients, PayoutAmount: bundleFilePayout(res, f).String()})
+		}
+		for _, t := range res.TypeTotals() {
+			chain.Types = append(chain.Types, JSONType{Label: t.Label, TxCount: t.Txs, GasWei: t.GasWei.String(), RateBps: t.Rate})
+		}
+		safes, _ := res.SafeTotals()
+		for _, t := range safes {
+			chain.Safes = append(chain.Safes, JSONSafe{Address: t.Safe, Name: res.Chain.Labels[t.Safe], TxCount: t.Txs, GasWei: t.GasWei.String()})
+		}
+
+		for _, tx := range res.Excluded {
+			chain.Excluded = append(chain.Excluded, JSONExcludedTx{JSONTx: jsonTx(tx.TxInfo), Reason: tx.Reason})
+		}
+		for _, e := range res.Errors {
+			je := JSONScanError{Label: e.Label, BlockNumber: e.BlockNumber, Error: e.Err.Error()}
+			if e.Hash != (common.Hash{}) {
+				hash := e.Hash
+				je.Hash = &hash
+			}
+			chain.Errors = append(chain.Errors, je)
+		}
+
+		if t := res.Payout.Terminal; t != nil {
+			chain.Payout.Terminal = &JSONTerminal{Address: t.Address, Version: t.Version, ProjectID: t.ProjectID}
+		}
+		if claim := res.Payout.Claim; claim != nil {
+			chain.Payout.Claim = &JSONClaim{Contract: claim.Address}
+			if claim.Root != (common.Hash{}) {
+				chain.Payout.Claim.MerkleRoot = &claim.Root
+			}
+		}
+		if s := res.Payout.Stream; s != nil {
+			chain.Payout.Stream = &JSONStream{Lockup: s.Lockup, DurationSeconds: uint64(s.Duration.Seconds()), CliffSeconds: uint64(s.Cliff.Seconds()), Cancelable: s.Cancelable}
+		}
+		if s := res.Payout.Split; s != nil {
+			chain.Payout.Split = &JSONSplit{Address: s.Address, SplitMain: s.Main, Allocations: []JSONAllocation{}}
+			for _, a := range res.SplitAllocations() {
+				chain.Payout.Split.Allocations = append(chain.Payout.Split.Allocations, JSONAllocation{Address: a.Account, PercentAllocation: a.Percent})
+			}
+		}
+		chain.CapWei = optionalString(res.Chain.RecipientCap)
+		chain.MaxGasPriceWei = optionalString(res.Chain.MaxGasPrice)
+		chain.MaxTipWei = optionalString(res.Chain.MaxTip)
+		chain.CapTips = res.Chain.CapTips
+		chain.MinPayoutWei = optionalString(res.Chain.MinPayout)
+		chain.CarriedIn = jsonCarried(res.CarriedIn)
+		chain.CarriedOver = jsonCarried(res.CarriedOver())
+		for _, item := range res.LineItems {
+			chain.LineItems = append(chain.LineItems, JSONLineItem{ID: item.ID, Address: item.Recipient, Wei: item.Wei.String(), Memo: item.Memo})
+		}
+		chain.TotalWei = total.String()
+		if baseFee, tip := res.FeeTotals(); baseFee != nil && len(res.Txs) > 0 {
+			chain.BaseFeeWei = optionalString(baseFee)
+			chain.TipWei = optionalString(tip)
+			if overhead, ok := res.TipOverhead(); ok {
+				chain.TipOverheadBps = &overhead
+			}
+		}
+		if usdTotals != nil {
+			chain.TotalUSD = optionalString(totalUSD)
+		}
+		if reportUSD != nil {
+			chain.TotalUSDAtReport = optionalString(sumUSD(reportUSD))
+			chain.ReportETHUSD = optionalString(res.ReportETHUSD)
+		}
+		report.Chains = append(report.Chains, chain)
+	}
+
+	return report
+}
+
+func jsonCarried(amounts map[common.Address]*big.Int) []JSONCarried {
+	var carried []JSONCarried
+	for _, addr := range scan.SortedAddresses(amounts) {
+		carried = append(carried, JSONCarried{Address: addr, Wei: amounts[addr].String()})
+	}
+	return carried
+}
+
+func jsonTx(tx scan.TxInfo) JSONTx {
+	jtx := JSONTx{
+		Hash:                 tx.Hash,
+		Label:                tx.Label,
+		From:                 tx.From,
+		BlockNumber:          tx.BlockNumber,
+		GasUsed:              tx.GasUsed,
+		EffectiveGasPriceWei: tx.EffectiveGasPrice.String(),
+		ExecutionWei:         tx.Cost.ExecutionWei.String(),
+		L1FeeWei:             optionalString(tx.Cost.L1FeeWei),
+		BaseFeePerGasWei:     optionalString(tx.BaseFee),
+		BaseFeeWei:           optionalString(tx.Cost.BaseFeeWei),
+		TipWei:               optionalString(tx.Cost.TipWei),
+		ExcessiveTip:         tx.ExcessiveTip(),
+		BlobWei:              optionalString(tx.Cost.BlobWei),
+		TotalWei:             tx.GasWei.String(),
+		ActualWei:            optionalString(tx.ActualWei),
+		CappedGasPriceWei:    optionalString(tx.CappedPrice),
+		CapRule:              tx.CapRule,
+		RateBps:              tx.Rate,
+		Failed:               tx.Failed,
+		Safe:                 tx.Safe,
+		Module:               tx.Module,
+		UserOp:               jsonUserOp(tx.UserOp),
+		Relay:                jsonRelay(tx.Relay),
+		SentBy:               tx.SentBy,
+	}
+	if tx.Cost.BlobWei != nil {
+		jtx.BlobGasUsed = tx.BlobGasUsed
+		jtx.BlobGasPriceWei = optionalString(tx.BlobGasPrice)
+	}
+	if !tx.BlockTime.IsZero() {
+		t := tx.BlockTime.UTC()
+		jtx.BlockTime = &t
+	}
+	if tx.USD != nil {
+		jtx.ETHUSD = optionalString(tx.ETHUSD)
+		jtx.USD = optionalString(tx.USD)
+	}
+	return jtx
+}
+
+func RenderJSON(results []*scan.Result) ([]byte, error) {
+	return json.MarshalIndent(BuildJSON(results), "", "  ")
+}
+
+func jsonUserOp(op *scan.UserOp) *JSONUserOp {
+	if op == nil {
+		return nil
+	}
+	return &JSONUserOp{Hash: op.Hash, Sender: op.Sender, Bundler: op.Bundler, Paymaster: nonZero(op.Paymaster)}
+}
+
+func jsonRelay(r *scan.Relay) *JSONRelay {
+	if r == nil {
+		return nil
+	}
+	return &JSONRelay{Relayer: r.Relayer, Forwarder: nonZero(r.Forwarder), Signer: nonZero(r.Signer), Sponsor: nonZero(r.Sponsor)}
+}
+
+// The address, or nil if it's zero
+func nonZero(addr common.Address) *common.Address {
+	if addr == (common.Address{}) {
+		return nil
+	}
+	return &addr
+}
+
+// Formats a *big.Int or *big.Float (USD, to cents) as an optional JSON string
+func optionalString(v any) *string {
+	var s string
+	switch v := v.(type) {
+	case *big.Int:
+		if v == nil {
+			return nil
+		}
+		s = v.String()
+	case *big.Float:
+		if v == nil {
+			return nil
+		}
+		s = v.Text('f', 2)
+	default:
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,37 @@
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"text/template"
+
+	"juimburser/pkg/scan"
+)
+
+//go:embed templates/report.md
+var markdownReportTemplate string
+
+var markdownReport = template.Must(template.New("report.md").Funcs(templateFuncs).Parse(markdownReportTemplate))
+
+var templateFuncs = template.FuncMap{
+	"short": shortHex,
+}
+
+// Renders a Markdown report with a summary table and explorer links, ready
+// to paste into the governance forum
+func RenderMarkdown(results []*scan.Result) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownReport.Execute(&buf, BuildData(results)); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// Shortens a hex string like 0xabcdef…1234
+func shortHex(s string) string {
+	if len(s) <= 14 {
+		return s
+	}
+	return s[:8] + "…" + s[len(s)-4:]
+}
@@ -0,0 +1,538 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+)
+
+// Renders a combined text report for the scans of one or more chains
+func RenderText(results []*scan.Result) []byte {
+	var report bytes.Buffer
+
+	report.WriteString("# JuiceboxDAO Gas Reimbursements\n\n")
+	for _, res := range results {
+		if res.Interrupted {
+			report.WriteString("**Partial report:** the run was interrupted before it finished scanning, so the results below are incomplete and no bundle was written for the chains it didn't finish. Rerun with --resume to pick up where it stopped.\n\n")
+			break
+		}
+	}
+	for _, res := range results {
+		for _, w := range warnings(res) {
+			report.WriteString(fmt.Sprintf("**Warning:** %s: %s.\n\n", res.Chain.Name, w))
+		}
+	}
+
+	if len(results) > 1 {
+		report.WriteString("## Totals across chains\n\n")
+		totals := make(map[common.Address]*big.Int)
+		usdTotals := make(map[common.Address]*big.Float)
+		priced := true
+		for _, res := range results {
+			for k, v := range res.Totals() {
+				if totals[k] == nil {
+					totals[k] = big.NewInt(0)
+				}
+				totals[k].Add(totals[k], v)
+			}
+
+			chainUSD := res.USDTotals()
+			if chainUSD == nil {
+				priced = false
+			}
+			for k, v := range chainUSD {
+				if usdTotals[k] == nil {
+					usdTotals[k] = new(big.Float)
+				}
+				usdTotals[k].Add(usdTotals[k], v)
+			}
+		}
+		for _, k := range scan.SortedAddresses(totals) {
+			line := fmt.Sprintf("- %s: %s ETH", labeled(results[0].Chain.Labels, k), scan.FormatEther(totals[k]))
+			if priced {
+				line += fmt.Sprintf(" (%s)", scan.FormatUSD(usdTotals[k]))
+			}
+			report.WriteString(line + "\n")
+		}
+		report.WriteString("\n")
+	}
+
+	for _, res := range results {
+		writeChainReport(&report, res)
+	}
+
+	writeErrorsSection(&report, results)
+	writeExcludedAppendix(&report, results)
+
+	return report.Bytes()
+}
+
+// Lists what couldn't be fetched, so is missing from the totals above
+func writeErrorsSection(report *bytes.Buffer, results []*scan.Result) {
+	count := 0
+	for _, res := range results {
+		count += len(res.Errors)
+	}
+	if count == 0 {
+		return
+	}
+
+	report.WriteString("## Errors\n\n")
+	report.WriteString("These couldn't be fetched even after retrying, so the results above are incomplete and no bundle was written for their chains. Rerun to retry them.\n\n")
+	for _, res := range results {
+		if len(res.Errors) == 0 {
+			continue
+		}
+		explorer := res.Chain.Explorer
+		report.WriteString(fmt.Sprintf("### %s (chain ID %s)\n\n", res.Chain.Name, res.Chain.ChainID))
+		for _, e := range res.Errors {
+			if e.Hash == (common.Hash{}) {
+				report.WriteString(fmt.Sprintf("- Block [%d](%s/block/%d) (%s): %v\n", e.BlockNumber, explorer, e.BlockNumber, e.Label, e.Err))
+			} else {
+				report.WriteString(fmt.Sprintf("- [`%s`](%s/tx/%s) (%s, block %d): %v\n", e.Hash.Hex(), explorer, e.Hash.Hex(), e.Label, e.BlockNumber, e.Err))
+			}
+		}
+		report.WriteString("\n")
+	}
+}
+
+// Lists every transaction left out of the reimbursement and why
+func writeExcludedAppendix(report *bytes.Buffer, results []*scan.Result) {
+	count := 0
+	for _, res := range results {
+		count += len(res.Excluded)
+	}
+	if count == 0 {
+		return
+	}
+
+	report.WriteString("## Appendix: excluded transactions\n\n")
+	for _, res := range results {
+		if len(res.Excluded) == 0 {
+			continue
+		}
+		explorer := res.Chain.Explorer
+		report.WriteString(fmt.Sprintf("### %s (chain ID %s)\n\n", res.Chain.Name, res.Chain.ChainID))
+		for _, tx := range res.Excluded {
+			report.WriteString(fmt.Sprintf("- [`%s`](%s/tx/%s) from %s: %s, %s ETH, block %d. Reason: %s\n",
+				tx.Hash.Hex(), explorer, tx.Hash.Hex(), linked(res.Chain.Labels, tx.From, explorer),
+				tx.Label, scan.FormatEther(tx.GasWei), tx.BlockNumber, tx.Reason))
+		}
+		report.WriteString("\n")
+	}
+}
+
+func writeChainReport(report *bytes.Buffer, res *scan.Result) {
+	explorer := res.Chain.Explorer
+
+	report.WriteString(fmt.Sprintf("## %s (chain ID %s)\n\n", res.Chain.Name, res.Chain.ChainID))
+	report.WriteString(fmt.Sprintf("From %s to %s (block %s to block %s)\n\n", res.StartTime.Format(time.RFC1123),
+		res.EndTime.Format(time.RFC1123), res.StartBlock.String(), res.EndBlock.String()))
+	if res.Manifest != nil {
+		report.WriteString(fmt.Sprintf("Manifest: %s\n\n", res.Manifest))
+	}
+	if res.Balance != nil {
+		report.WriteString(res.Balance.Summary(res.Payout) + "\n\n")
+	}
+	if res.Payout.Token != nil {
+		report.WriteString(fmt.Sprintf("Paid in %s at %s/ETH (%s)\n\n", res.Payout.Token.Symbol,
+			res.Payout.FormatRate(), res.Payout.RateSource))
+	}
+	if spent, now := sumUSD(res.USDTotals()), sumUSD(res.ReportUSDTotals()); spent != nil && now != nil {
+		report.WriteString(fmt.Sprintf("USD at spend time (each transaction at its block's price): %s; at report time (%s/ETH at block %s): %s\n\n",
+			scan.FormatUSD(spent), scan.FormatUSD(res.ReportETHUSD), res.EndBlock, scan.FormatUSD(now)))
+	}
+
+	over := res.OverCap()
+	if t := res.Payout.Terminal; t != nil {
+		report.WriteString(fmt.Sprintf("Paid to %s, with each recipient as beneficiary\n\n", t))
+	}
+	if claim := res.Payout.Claim; claim != nil {
+		report.WriteString(fmt.Sprintf("Claimed from %s with Merkle proofs%s\n\n", claim.Address.Hex(), claimRoot(claim)))
+	}
+	if s := res.Payout.Stream; s != nil {
+		report.WriteString(fmt.Sprintf("Streamed through %s, starting when the bundle is executed\n\n", s))
+	}
+	if s := res.Payout.Split; s != nil {
+		report.WriteString(fmt.Sprintf("Routed through %s, which the bundle updates to the allocation below, funds, and distributes; recipients withdraw from SplitMain %s\n\n", s, s.Main.Hex()))
+	}
+	if cap := res.Chain.RecipientCap; cap != nil {
+		report.WriteString(fmt.Sprintf("Per-recipient cap: %s ETH\n\n", scan.FormatEther(cap)))
+	}
+	if min := res.Chain.MinPayout; min != nil {
+		report.WriteString(fmt.Sprintf("Minimum payout: %s ETH\n\n", scan.FormatEther(min)))
+	}
+	if maxGasPrice := res.Chain.MaxGasPrice; maxGasPrice != nil {
+		report.WriteString(fmt.Sprintf("Gas price cap: %s gwei\n\n", scan.FormatGwei(maxGasPrice)))
+	}
+	if maxTip := res.Chain.MaxTip; maxTip != nil {
+		report.WriteString(fmt.Sprintf("Priority fee limit: %s gwei per gas; transactions over it are %s\n\n", scan.FormatGwei(maxTip), tipAction(res.Chain)))
+	}
+	if baseFee, tip := res.FeeTotals(); baseFee != nil && len(res.Txs) > 0 {
+		report.WriteString(fmt.Sprintf("Base fees: %s ETH, priority fees: %s ETH", scan.FormatEther(baseFee), scan.FormatEther(tip)))
+		if overhead, ok := res.TipOverhead(); ok {
+			report.WriteString(fmt.Sprintf(" (%s on top of the base fees)", scan.FormatRate(overhead)))
+		}
+		report.WriteString("\n\n")
+	}
+	if len(res.BundleFiles) > 0 {
+		report.WriteString(fmt.Sprintf("Bundle split into %d files:\n\n", len(res.BundleFiles)))
+		for _, f := range res.BundleFiles {
+			report.WriteString(fmt.Sprintf("- `%s`: %d transfers, %s\n", f.Name, len(f.Recipients), res.Payout.Format(bundleFilePayout(res, f))))
+		}
+		report.WriteString("\n")
+	}
+	writeRecipientTable(report, res)
+	if types := res.TypeTotals(); len(types) > 0 {
+		report.WriteString("### By type\n\n")
+		for _, t := range types {
+			rate := ""
+			if t.Rate != 0 {
+				rate = fmt.Sprintf(" (reimbursed at %s)", scan.FormatRate(t.Rate))
+			}
+			report.WriteString(fmt.Sprintf("- %s: %d transactions, %s ETH%s\n", t.Label, t.Txs, scan.FormatEther(t.GasWei), rate))
+		}
+		report.WriteString("\n")
+	}
+	if safes, other := res.SafeTotals(); len(safes) > 0 {
+		report.WriteString("### By Safe\n\n")
+		for _, t := range safes {
+			report.WriteString(fmt.Sprintf("- %s: %d transactions, %s ETH\n", linked(res.Chain.Labels, t.Safe, explorer), t.Txs, scan.FormatEther(t.GasWei)))
+		}
+		if other.Txs > 0 {
+			report.WriteString(fmt.Sprintf("- Not executed by a Safe: %d transactions, %s ETH\n", other.Txs, scan.FormatEther(other.GasWei)))
+		}
+		report.WriteString("\n")
+	}
+	if buckets := res.BucketTotals(); buckets != nil {
+		report.WriteString(fmt.Sprintf("### By %s\n\n", res.Chain.Bucket))
+		for _, k := range scan.SortedAddresses(buckets) {
+			report.WriteString(fmt.Sprintf("- %s\n", labeled(res.Chain.Labels, k)))
+			for _, t := range buckets[k] {
+				if t.Txs > 0 {
+					report.WriteString(fmt.Sprintf("  - %s: %d transactions, %s ETH\n", res.Chain.Bucket.Name(t.Start), t.Txs, scan.FormatEther(t.GasWei)))
+				}
+			}
+		}
+		report.WriteString("\n")
+	}
+	if tipped := res.ExcessiveTips(); len(tipped) > 0 {
+		report.WriteString("### Excessive tips\n\n")
+		report.WriteString("These tipped more per gas than their block's base fee:\n\n")
+		for _, tx := range tipped {
+			report.WriteString(fmt.Sprintf("- [`%s`](%s/tx/%s) from %s: %s gwei tip on a %s gwei base fee, %s ETH in tips\n", tx.Hash.Hex(), explorer, tx.Hash.Hex(),
+				labeled(res.Chain.Labels, tx.From), scan.FormatGwei(tx.TipPerGas()), scan.FormatGwei(tx.BaseFee), scan.FormatEther(tx.Cost.TipWei)))
+		}
+		report.WriteString("\n")
+	}
+	if tipped := res.OverMaxTip(); len(tipped) > 0 {
+		report.WriteString("### Over the priority fee limit\n\n")
+		report.WriteString(fmt.Sprintf("These tipped more than %s gwei per gas, so they're %s:\n\n", scan.FormatGwei(res.Chain.MaxTip), tipAction(res.Chain)))
+		for _, tx := range tipped {
+			report.WriteString(fmt.Sprintf("- [`%s`](%s/tx/%s) from %s: %s gwei tip", tx.Hash.Hex(), explorer, tx.Hash.Hex(), labeled(res.Chain.Labels, tx.From), scan.FormatGwei(tx.TipPerGas())))
+			if tx.ActualWei != nil {
+				report.WriteString(fmt.Sprintf(", reimbursed %s ETH of %s ETH", scan.FormatEther(tx.GasWei), scan.FormatEther(tx.ActualWei)))
+			} else {
+				report.WriteString(fmt.Sprintf(", reimbursed %s ETH", scan.FormatEther(tx.GasWei)))
+			}
+			report.WriteString("\n")
+		}
+		report.WriteString("\n")
+	}
+	if len(over) > 0 {
+		report.WriteString("### Over the per-recipient cap\n\n")
+		report.WriteString("Held back for review, and owed again by the next run unless it runs with --forgive-held:\n\n")
+		for _, k := range scan.SortedAddresses(over) {
+			report.WriteString(fmt.Sprintf("- %s: %s ETH held back for review\n", labeled(res.Chain.Labels, k), scan.FormatEther(over[k])))
+		}
+		report.WriteString("\n")
+	}
+	if denied := res.DeniedOwed(); len(denied) > 0 {
+		report.WriteString("### Denylisted\n\n")
+		report.WriteString("Flagged by sanctions screening, so left out of the bundle for the multisig to handle by hand:\n\n")
+		for _, k := range scan.SortedAddresses(denied) {
+			report.WriteString(fmt.Sprintf("- %s: %s ETH held back (%s)\n", labeled(res.Chain.Labels, k), scan.FormatEther(denied[k]), res.Denied[k]))
+		}
+		report.WriteString("\n")
+	}
+
+	if allocations := res.SplitAllocations(); len(allocations) > 0 {
+		report.WriteString("### Split allocation\n\n")
+		for _, a := range allocations {
+			report.WriteString(fmt.Sprintf("- %s: %s\n", labeled(res.Chain.Labels, a.Account), scan.FormatSplitPercent(a.Percent)))
+		}
+		report.WriteString("\n")
+	}
+
+	if redirects := res.Redirects(); len(redirects) > 0 {
+		report.WriteString("### Paid to beneficiaries\n\n")
+		for _, d := range redirects {
+			report.WriteString(fmt.Sprintf("- %s: %d transactions, %s ETH paid to %s\n", labeled(res.Chain.Labels, d.Sender), d.Txs, scan.FormatEther(d.GasWei), labeled(res.Chain.Labels, d.Beneficiary)))
+		}
+		report.WriteString("\n")
+	}
+	if splits := res.SplitPayouts(); len(splits) > 0 {
+		report.WriteString("### Split senders\n\n")
+		for _, split := range splits {
+			var parts []string
+			for i, s := range split.Shares {
+				parts = append(parts, fmt.Sprintf("%s (%s) to %s", scan.FormatRate(s.Rate), res.Payout.Format(res.Payout.Amount(split.Wei[i])), labeled(res.Chain.Labels, s.To)))
+			}
+			report.WriteString(fmt.Sprintf("- %s: %s\n", labeled(res.Chain.Labels, split.Sender), strings.Join(parts, ", ")))
+		}
+		report.WriteString("\n")
+	}
+	if len(res.CarriedIn) > 0 {
+		report.WriteString("### Carried in from earlier runs\n\n")
+		for _, k := range scan.SortedAddresses(res.CarriedIn) {
+			report.WriteString(fmt.Sprintf("- %s: %s ETH\n", labeled(res.Chain.Labels, k), scan.FormatEther(res.CarriedIn[k])))
+		}
+		report.WriteString("\n")
+	}
+	if len(res.LineItems) > 0 {
+		report.WriteString("### Line items\n\n")
+		for _, item := range res.LineItems {
+			report.WriteString(fmt.Sprintf("- %s: %s ETH, %s (`%s`)\n", labeled(res.Chain.Labels, item.Recipient), scan.FormatEther(item.Wei), item.Memo, item.ID))
+		}
+		report.WriteString("\n")
+	}
+	if carried := res.CarriedOver(); len(carried) > 0 {
+		report.WriteString("### Carried over to the next run\n\n")
+		for _, k := range scan.SortedAddresses(carried) {
+			report.WriteString(fmt.Sprintf("- %s: %s ETH, below the minimum payout\n", labeled(res.Chain.Labels, k), scan.FormatEther(carried[k])))
+		}
+		report.WriteString("\n")
+	}
+
+	reportDetails := make(map[common.Address]string)
+	for _, tx := range res.Txs {
+		detail := fmt.Sprintf("Type: %s", tx.Label)
+		if tx.Failed {
+			detail += " (reverted)"
+		}
+		if tx.Module != nil {
+			detail += fmt.Sprintf("\nModule: %s", linked(res.Chain.Labels, *tx.Module, explorer))
+		}
+		if tx.SentBy != nil {
+			detail += fmt.Sprintf("\nSent by: %s, paid to its beneficiary", linked(res.Chain.Labels, *tx.SentBy, explorer))
+		}
+		if op := tx.UserOp; op != nil {
+			detail += fmt.Sprintf("\nUserOperation: `%s` from %s, sent by bundler %s", op.Hash.Hex(), linked(res.Chain.Labels, op.Sender, explorer), linked(res.Chain.Labels, op.Bundler, explorer))
+			if op.Paymaster != (common.Address{}) {
+				detail += fmt.Sprintf(", gas paid by paymaster %s", linked(res.Chain.Labels, op.Paymaster, explorer))
+			}
+		}
+		if r := tx.Relay; r != nil {
+			detail += fmt.Sprintf("\nRelayed by: %s", linked(res.Chain.Labels, r.Relayer, explorer))
+			if r.Forwarder != (common.Address{}) {
+				detail += fmt.Sprintf(" through %s", linked(res.Chain.Labels, r.Forwarder, explorer))
+			}
+			if r.Signer != (common.Address{}) {
+				detail += fmt.Sprintf(", signed by %s", linked(res.Chain.Labels, r.Signer, explorer))
+			}
+			if r.Sponsor != (common.Address{}) {
+				detail += fmt.Sprintf(", Gelato 1Balance sponsor %s", linked(res.Chain.Labels, r.Sponsor, explorer))
+			}
+		}
+		detail += fmt.Sprintf("\nTxHash: [`%s`](%s/tx/%s)", tx.Hash.Hex(), explorer, tx.Hash.Hex()) +
+			fmt.Sprintf("\nGas: %s ETH", scan.FormatEther(tx.GasWei))
+		if tx.Cost.L1FeeWei != nil {
+			detail += fmt.Sprintf(" (L2 execution: %s ETH, L1 data fee: %s ETH)", scan.FormatEther(tx.Cost.ExecutionWei), scan.FormatEther(tx.Cost.L1FeeWei))
+		}
+		if tx.Cost.BaseFeeWei != nil {
+			detail += fmt.Sprintf("\nBase fee: %s ETH (at %s gwei), priority fee: %s ETH", scan.FormatEther(tx.Cost.BaseFeeWei), scan.FormatGwei(tx.BaseFee), scan.FormatEther(tx.Cost.TipWei))
+			if tx.ExcessiveTip() {
+				detail += " (excessive tip, see Excessive tips)"
+			}
+			if res.Chain.OverMaxTip(tx) {
+				detail += " (over the priority fee limit)"
+			}
+		}
+		if tx.Cost.BlobWei != nil {
+			detail += fmt.Sprintf("\nBlob gas: %d at %s gwei = %s ETH", tx.BlobGasUsed, scan.FormatGwei(tx.BlobGasPrice), scan.FormatEther(tx.Cost.BlobWei))
+		}
+		if tx.CapRule != "" {
+			detail += fmt.Sprintf("\nCapped by rule %s (actual: %s ETH)", tx.CapRule, scan.FormatEther(tx.ActualWei))
+		} else if tx.ActualWei != nil {
+			detail += fmt.Sprintf("\nCapped at %s gwei (actual: %s ETH at %s gwei)", scan.FormatGwei(tx.CappedPrice), scan.FormatEther(tx.ActualWei), scan.FormatGwei(tx.EffectiveGasPrice))
+		}
+		if tx.Rate != 0 {
+			detail += fmt.Sprintf("\nReimbursed at %s of %s ETH", scan.FormatRate(tx.Rate), scan.FormatEther(tx.Cost.Total()))
+		}
+		if tx.USD != nil {
+			detail += fmt.Sprintf("\nUSD: %s (at %s/ETH)", scan.FormatUSD(tx.USD), scan.FormatUSD(tx.ETHUSD))
+		}
+		reportDetails[tx.From] += detail + fmt.Sprintf("\nBlock: %d\n\n", tx.BlockNumber)
+	}
+
+	totals, usdTotals, reportUSD, payable, files := res.Totals(), res.USDTotals(), res.ReportUSDTotals(), res.Payable(), bundleFiles(res)
+	sources, gasStats := res.Sources(), res.GasStats()
+	for _, k := range scan.SortedAddresses(reportDetails) {
+		report.WriteString(fmt.Sprintf("### Summary for %s\n\n", linked(res.Chain.Labels, k, explorer)))
+		report.WriteString("Total gas to reimburse: " + scan.FormatEther(totals[k]) + " ETH")
+		if usdTotals != nil {
+			report.WriteString(fmt.Sprintf(" (%s", scan.FormatUSD(usdTotals[k])))
+			if reportUSD != nil {
+				report.WriteString(fmt.Sprintf(" at spend time, %s at report time", scan.FormatUSD(reportUSD[k])))
+			}
+			report.WriteString(")")
+		}
+		report.WriteString("\n\n")
+		if s := sources[k]; s != nil {
+			report.WriteString(fmt.Sprintf("Paid for %d senders:\n", len(s)))
+			for _, src := range s {
+				report.WriteString(fmt.Sprintf("- %s: %d transactions, %s ETH\n", labeled(res.Chain.Labels, src.Sender), src.Txs, scan.FormatEther(src.GasWei)))
+			}
+			report.WriteString("\n")
+		}
+		if s, ok := gasStats[k]; ok {
+			report.WriteString(fmt.Sprintf("Gas price: %s gwei min, %s average, %s max\n", scan.FormatGwei(s.MinPrice), scan.FormatGwei(s.AvgPrice), scan.FormatGwei(s.MaxPrice)))
+			for _, t := range s.Types {
+				report.WriteString(fmt.Sprintf("- %s: %d transactions, %d gas used and %s gwei on average\n", t.Label, t.Txs, t.AvgGasUsed, scan.FormatGwei(t.AvgPrice)))
+			}
+			report.WriteString("\n")
+		}
+		if over[k] != nil {
+			report.WriteString(fmt.Sprintf("Capped at %s ETH; %s ETH held back for review\n\n", scan.FormatEther(payable[k]), scan.FormatEther(over[k])))
+		}
+		if shares := res.Chain.SenderSplits[k]; shares != nil {
+			report.WriteString(fmt.Sprintf("Payout split between %d addresses (see Split senders)\n\n", len(shares)))
+		} else if res.Payout.Token != nil {
+			report.WriteString("Payout: " + res.Payout.Format(res.Payout.Amount(payable[k])) + "\n\n")
+		}
+		if file, ok := files[k]; ok {
+			report.WriteString("Paid in `" + file + "`\n\n")
+		}
+		report.WriteString("#### Transactions\n\n")
+		report.WriteString(reportDetails[k])
+	}
+}
+
+// Who gets what at a glance: each recipient's transactions and total, from
+// the largest total down
+func writeRecipientTable(report *bytes.Buffer, res *scan.Result) {
+	totals, usdTotals := res.Totals(), res.USDTotals()
+	if len(totals) == 0 {
+		return
+	}
+	txCounts := make(map[common.Address]int)
+	for _, tx := range res.Txs {
+		txCounts[tx.From]++
+	}
+	report.WriteString("### Recipients by total\n\n")
+	if usdTotals != nil {
+		report.WriteString("| Recipient | Transactions | ETH | USD |\n| --- | ---: | ---: | ---: |\n")
+	} else {
+		report.WriteString("| Recipient | Transactions | ETH |\n| --- | ---: | ---: |\n")
+	}
+	for _, addr := range scan.SortedByAmount(totals) {
+		report.WriteString(fmt.Sprintf("| %s | %d | %s |", labeled(res.Chain.Labels, addr), txCounts[addr], scan.FormatEther(totals[addr])))
+		if usdTotals != nil {
+			report.WriteString(" " + scan.FormatUSD(usdTotals[addr]) + " |")
+		}
+		report.WriteString("\n")
+	}
+	total := big.NewInt(0)
+	for _, t := range totals {
+		total.Add(total, t)
+	}
+	report.WriteString(fmt.Sprintf("| **Total** | **%d** | **%s** |", len(res.Txs), scan.FormatEther(total)))
+	if usdTotals != nil {
+		report.WriteString(" **" + scan.FormatUSD(sumUSD(usdTotals)) + "** |")
+	}
+	report.WriteString("\n\n")
+}
+
+// addr in backticks, after its label if it has one
+// The claims' root, if the bundle has been built
+func claimRoot(claim *scan.ClaimContract) string {
+	if claim.Root == (common.Hash{}) {
+		return ""
+	}
+	return fmt.Sprintf(" (root %s)", claim.Root.Hex())
+}
+
+// What signers should know about res before anything else: a Safe that
+// can't afford the bundle, and recipients whose transfer would revert it
+func warnings(res *scan.Result) []string {
+	var out []string
+	if res.Balance != nil {
+		if w := res.Balance.Warning(res.Payout); w != "" {
+			out = append(out, w)
+		}
+	}
+	for _, addr := range scan.SortedAddresses(res.RejectsETH) {
+		name := addr.Hex()
+		if label := res.Chain.Labels[addr]; label != "" {
+			name = fmt.Sprintf("%s (%s)", label, name)
+		}
+		out = append(out, fmt.Sprintf("recipient %s is a contract that rejects plain ETH transfers (%s), so its transfer would revert, along with everything batched with it; pay it another way or exclude it", name, res.RejectsETH[addr]))
+	}
+	if tipped := res.OverMaxTip(); len(tipped) > 0 && !res.Chain.CapTips {
+		out = append(out, fmt.Sprintf("%d transactions tipped more than the %s gwei priority fee limit and are reimbursed in full; review them (see Over the priority fee limit) or set maxTipAction to cap", len(tipped), scan.FormatGwei(res.Chain.MaxTip)))
+	}
+	return out
+}
+
+// What happens to transactions over the chain's priority fee limit
+func tipAction(chain *scan.Chain) string {
+	if chain.CapTips {
+		return "reimbursed as if they'd tipped the limit"
+	}
+	return "flagged for review"
+}
+
+func labeled(labels scan.Labels, addr common.Address) string {
+	if label := labels[addr]; label != "" {
+		return fmt.Sprintf("%s (`%s`)", label, addr.Hex())
+	}
+	return "`" + addr.Hex() + "`"
+}
+
+// addr linked on the explorer, after its label if it has one
+func linked(labels scan.Labels, addr common.Address, explorer string) string {
+	link := fmt.Sprintf("[`%s`](%s/address/%s)", addr.Hex(), explorer, addr.Hex())
+	if label := labels[addr]; label != "" {
+		return fmt.Sprintf("%s (%s)", label, link)
+	}
+	return link
+}
+
+// The bundle file paying each recipient, empty unless the bundle is split
+func bundleFiles(res *scan.Result) map[common.Address]string {
+	files := make(map[common.Address]string)
+	for _, f := range res.BundleFiles {
+		for _, addr := range f.Recipients {
+			files[addr] = f.Name
+		}
+	}
+	return files
+}
+
+// What a bundle file pays in total, in the payout asset's base units
+func bundleFilePayout(res *scan.Result, f scan.BundleFile) *big.Int {
+	payable := res.Payable()
+	total := big.NewInt(0)
+	for _, addr := range f.Recipients {
+		total.Add(total, res.Payout.Amount(payable[addr]))
+	}
+	return total
+}
+
+// The sum of a set of USD totals, or nil if there are none
+func sumUSD(totals map[common.Address]*big.Float) *big.Float {
+	if totals == nil {
+		return nil
+	}
+	sum := new(big.Float)
+	for _, v := range totals {
+		sum.Add(sum, v)
+	}
+	return sum
+}
@@ -0,0 +1,721 @@
+package report
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+)
+
+// Report view model shared by the HTML and template renderers. Amounts are
+// preformatted strings; USD fields are empty when pricing is disabled.
+type Data struct {
+	Title       string
+	GeneratedAt time.Time
+	Chains      []Chain
+	// Totals per recipient across every chain, only set for multi-chain runs
+	Combined []RecipientTotal
+	Priced   bool
+	// Excluded transactions across every chain
+	ExcludedCount int
+	// Transactions and blocks that couldn't be fetched, across every chain
+	ErrorCount int
+	// Set if the run was interrupted before some chain's scan finished
+	Partial bool
+	// What signers should know before anything else, like a Safe that can't
+	// afford its bundle, as "<chain>: <warning>"
+	Warnings []string
+}
+
+type Chain struct {
+	Name       string
+	ChainID    string
+	Explorer   string
+	StartBlock string
+	EndBlock   string
+	StartTime  time.Time
+	EndTime    time.Time
+	// Empty when paying in ETH
+	PayoutToken string
+	PayoutRate  string
+	// Where PayoutRate came from, e.g. "price at block 19000000"
+	PayoutRateSource string
+	// Empty unless paying through a Juicebox terminal
+	Terminal string
+	// Empty unless recipients claim from a Merkle claim contract, e.g.
+	// "0x... (root 0x...)"
+	Claim string
+	// Empty unless paying through Sablier streams
+	Stream string
+	// Empty unless paying through a 0xSplits split, with its SplitMain and
+	// each recipient's share
+	Split       string
+	SplitMain   string
+	Allocations []Allocation
+	TotalETH    string
+	TotalUSD    string
+	// TotalUSD is at each transaction's block; this is at ReportETHUSD, the
+	// end block's price. Both empty unless pricing in USD.
+	ReportUSD    string
+	ReportETHUSD string
+	TxCount      int
+	// Empty when there's no per-recipient cap
+	Cap     string
+	OverCap []CappedRecipient
+	// Recipients flagged by sanctions screening, not paid
+	Denied []DeniedRecipient
+	// Empty when there's no minimum payout
+	MinPayout   string
+	CarriedIn   []Carried
+	CarriedOver []Carried
+	// Reimbursements besides gas from the config
+	LineItems []LineItem
+	// Senders whose transactions were paid to their beneficiaries
+	Redirects []Redirect
+	// Split senders' payouts, a row per share
+	Shares []Share
+	// Empty when there's no gas price cap
+	MaxGasPriceGwei string
+	// Empty when there's no priority fee limit, with CapTips set if
+	// transactions over it are capped rather than only flagged
+	MaxTipGwei string
+	CapTips    bool
+	OverMaxTip []TippedTx
+	// Execution costs split into base and priority fees, empty before EIP-1559
+	BaseFeeETH string
+	TipETH     string
+	// The priority fees as a percentage of the base fees
+	TipOverhead string
+	// Transactions that tipped more per gas than their block's base fee
+	ExcessiveTips []TippedTx
+	Recipients    []Recipient
+	// Recipients from the largest total down, for the summary table
+	ByTotal  []Recipient
+	Excluded []Excluded
+	Errors   []FetchError
+	// Set if the run was interrupted before the chain's scan finished, so
+	// Errors lists what it didn't get to
+	Interrupted bool
+	// Empty unless the bundle is split across several files
+	BundleFiles []BundleFile
+	// What the report was made from on one line, see scan.Manifest
+	Manifest string
+	// The Safe's balance against what the bundle needs, see
+	// scan.BalanceCheck. Empty unless it was checked.
+	Balance string
+	// Gas by transaction type, in group order
+	Types []TypeSummary
+	// week or month when each recipient's gas is broken down by calendar
+	// period, with the periods' names, e.g. 2024-03 or 2024-W09
+	Bucket  string
+	Buckets []string
+	// Empty unless the chain tracks Safes. The last entry has no Address when
+	// some transactions weren't executed by any of them.
+	Safes []SafeSummary
+}
+
+// The transactions of one type
+type TypeSummary struct {
+	Label   string
+	TxCount int
+	ETH     string
+	// Empty unless the group reimburses part of the cost
+	Rate string
+}
+
+// What one Safe executed
+type SafeSummary struct {
+	Address string
+	URL     string
+	Label   string
+	TxCount int
+	ETH     string
+}
+
+type BundleFile struct {
+	Name      string
+	Transfers int
+	Payout    string
+}
+
+// Something the scan couldn't fetch. Hash is empty for a block walked for
+// reverted calls, in which case URL links to the block.
+type FetchError struct {
+	Hash  string
+	URL   string
+	Label string
+	Block uint64
+	Error string
+}
+
+type Excluded struct {
+	Tx
+	From      string
+	FromURL   string
+	FromLabel string
+	Reason    string
+}
+
+type Recipient struct {
+	Address string
+	URL     string
+	// Empty unless the address is in the label book
+	Label    string
+	TotalETH string
+	TotalUSD string
+	// TotalUSD at the chain's ReportETHUSD
+	ReportUSD string
+	Payout    string
+	// Only set when the total is over the per-recipient cap
+	HeldETH string
+	// The bundle file paying them, empty unless the bundle is split
+	BundleFile string
+	// Why a plain ETH transfer to them reverts, empty if it doesn't (or
+	// wasn't checked)
+	RejectsETH string
+	// Gas per calendar period, aligned with the chain's Buckets
+	Buckets []BucketAmount
+	// Each sender's part, empty unless they're paid for other senders
+	Sources []Source
+	// Effective gas prices paid, and gas used per transaction type
+	MinGwei  string
+	AvgGwei  string
+	MaxGwei  string
+	GasTypes []TypeGas
+	Txs      []Tx
+}
+
+type TypeGas struct {
+	Label      string
+	TxCount    int
+	AvgGasUsed uint64
+	AvgGwei    string
+}
+
+type Source struct {
+	Address string
+	URL     string
+	Label   string
+	TxCount int
+	ETH     string
+}
+
+type BucketAmount struct {
+	TxCount int
+	ETH     string
+}
+
+// An amount below the minimum payout, owed from an earlier run or to the
+// next one
+type Carried struct {
+	Address string
+	URL     string
+	Label   string
+	ETH     string
+}
+
+// A sender's transactions paid to their beneficiary
+type Redirect struct {
+	Sender           string
+	SenderURL        string
+	SenderLabel      string
+	Beneficiary      string
+	BeneficiaryURL   string
+	BeneficiaryLabel string
+	TxCount          int
+	ETH              string
+}
+
+// One address's share of a split sender's payout
+type Share struct {
+	Sender      string
+	SenderURL   string
+	SenderLabel string
+	To          string
+	ToURL       string
+	ToLabel     string
+	// e.g. 70%
+	Share  string
+	Payout string
+}
+
+// A line item from the config, paid on top of the recipient's gas
+type LineItem struct {
+	ID      string
+	Address string
+	URL     string
+	Label   string
+	ETH     string
+	Memo    string
+}
+
+// A recipient's share of a 0xSplits split
+type Allocation struct {
+	Address string
+	URL     string
+	Label   string
+	// e.g. 12.3456%
+	Percent string
+}
+
+// A recipient whose total exceeds the cap, and how much is held back
+type DeniedRecipient struct {
+	Address string
+	URL     string
+	Label   string
+	// What they're owed, held back
+	ETH    string
+	Reason string
+}
+
+type TippedTx struct {
+	Tx
+	From      string
+	FromURL   string
+	FromLabel string
+}
+
+type CappedRecipient struct {
+	Address  string
+	URL      string
+	Label    string
+	TotalETH string
+	PaidETH  string
+	HeldETH  string
+}
+
+type Tx struct {
+	Hash         string
+	URL          string
+	Label        string
+	Block        uint64
+	GasUsed      uint64
+	GasPriceGwei string
+	GasETH       string
+	// Only set on L2s
+	ExecutionETH string
+	L1FeeETH     string
+	// Empty before EIP-1559
+	BaseFeeETH  string
+	BaseFeeGwei string
+	TipETH      string
+	TipGwei     string
+	// The tip per gas was more than the base fee
+	ExcessiveTip bool
+	// The tip per gas was over the chain's priority fee limit
+	OverMaxTip bool
+	// Only set for transactions that carry blobs
+	BlobETH          string
+	BlobGasUsed      uint64
+	BlobGasPriceGwei string
+	// Only set when the gas price cap (or a rule, CapRule) reduced GasETH
+	ActualETH string
+	CapRule   string
+	// Only set when the group reimburses part of the cost: the percentage,
+	// and the (capped) cost it's taken of
+	Rate    string
+	CostETH string
+	USD     string
+	ETHUSD  string
+	// The transaction reverted
+	Failed bool
+	// Only set for transactions a Safe module executed
+	Module    string
+	ModuleURL string
+	// Only set for ERC-4337 UserOperations: the operation, its smart account,
+	// the bundler that sent it, and its paymaster if it had one
+	UserOp    string
+	Sender    string
+	Bundler   string
+	Paymaster string
+	// Only set for relayed transactions: the relayer that sent it, and who
+	// signed the forwarded request if that's known
+	Relayer string
+	Signer  string
+	// Only set when the transaction is paid to the sender's beneficiary: who
+	// sent it
+	SentBy    string
+	SentByURL string
+}
+
+type RecipientTotal struct {
+	Address  string
+	Label    string
+	TotalETH string
+	TotalUSD string
+}
+
+func BuildData(results []*scan.Result) Data {
+	data := Data{
+		Title:       "JuiceboxDAO Gas Reimbursements",
+		GeneratedAt: scan.GeneratedAt(results),
+		Priced:      true,
+	}
+
+	combined := make(map[common.Address]*big.Int)
+	combinedUSD := make(map[common.Address]*big.Float)
+
+	for _, res := range results {
+		explorer := res.Chain.Explorer
+		usdTotals, reportUSD := res.USDTotals(), res.ReportUSDTotals()
+		if usdTotals == nil {
+			data.Priced = false
+		}
+
+		chain := Chain{
+			Name:       res.Chain.Name,
+			ChainID:    res.Chain.ChainID.String(),
+			Explorer:   explorer,
+			StartBlock: res.StartBlock.String(),
+			EndBlock:   res.EndBlock.String(),
+			StartTime:  res.StartTime.UTC(),
+			EndTime:    res.EndTime.UTC(),
+			TxCount:    len(res.Txs),
+		}
+		if res.Manifest != nil {
+			chain.Manifest = res.Manifest.String()
+		}
+		if b := res.Balance; b != nil {
+			chain.Balance = b.Summary(res.Payout)
+		}
+		for _, w := range warnings(res) {
+			data.Warnings = append(data.Warnings, res.Chain.Name+": "+w)
+		}
+		if res.Payout.Token != nil {
+			chain.PayoutToken = res.Payout.Token.Symbol
+			chain.PayoutRate = res.Payout.FormatRate()
+			chain.PayoutRateSource = res.Payout.RateSource
+		}
+
+		if res.Payout.Terminal != nil {
+			chain.Terminal = res.Payout.Terminal.String()
+		}
+		if claim := res.Payout.Claim; claim != nil {
+			chain.Claim = claim.Address.Hex() + claimRoot(claim)
+		}
+		if res.Payout.Stream != nil {
+			chain.Stream = res.Payout.Stream.String()
+		}
+		if s := res.Payout.Split; s != nil {
+			chain.Split, chain.SplitMain = s.String(), s.Main.Hex()
+			for _, a := range res.SplitAllocations() {
+				addr := a.Account.Hex()
+				chain.Allocations = append(chain.Allocations, Allocation{Address: addr, URL: explorer + "/address/" + addr, Label: res.Chain.Labels[a.Account], Percent: scan.FormatSplitPercent(a.Percent)})
+			}
+		}
+		if res.Chain.RecipientCap != nil {
+			chain.Cap = scan.FormatEther(res.Chain.RecipientCap)
+		}
+		if res.Chain.MaxGasPrice != nil {
+			chain.MaxGasPriceGwei = scan.FormatGwei(res.Chain.MaxGasPrice)
+		}
+		if res.Chain.MaxTip != nil {
+			chain.MaxTipGwei, chain.CapTips = scan.FormatGwei(res.Chain.MaxTip), res.Chain.CapTips
+		}
+		for _, tx := range res.OverMaxTip() {
+			chain.OverMaxTip = append(chain.OverMaxTip, TippedTx{
+				Tx:        txReport(tx, explorer),
+				From:      tx.From.Hex(),
+				FromURL:   explorer + "/address/" + tx.From.Hex(),
+				FromLabel: res.Chain.Labels[tx.From],
+			})
+		}
+		if res.Chain.MinPayout != nil {
+			chain.MinPayout = scan.FormatEther(res.Chain.MinPayout)
+		}
+		chain.CarriedIn = carriedReport(res.CarriedIn, res.Chain.Labels, explorer)
+		chain.CarriedOver = carriedReport(res.CarriedOver(), res.Chain.Labels, explorer)
+		for _, d := range res.Redirects() {
+			sender, beneficiary := d.Sender.Hex(), d.Beneficiary.Hex()
+			chain.Redirects = append(chain.Redirects, Redirect{
+				Sender:           sender,
+				SenderURL:        explorer + "/address/" + sender,
+				SenderLabel:      res.Chain.Labels[d.Sender],
+				Beneficiary:      beneficiary,
+				BeneficiaryURL:   explorer + "/address/" + beneficiary,
+				BeneficiaryLabel: res.Chain.Labels[d.Beneficiary],
+				TxCount:          d.Txs,
+				ETH:              scan.FormatEther(d.GasWei),
+			})
+		}
+		for _, split := range res.SplitPayouts() {
+			sender := split.Sender.Hex()
+			for i, s := range split.Shares {
+				to := s.To.Hex()
+				chain.Shares = append(chain.Shares, Share{
+					Sender:      sender,
+					SenderURL:   explorer + "/address/" + sender,
+					SenderLabel: res.Chain.Labels[split.Sender],
+					To:          to,
+					ToURL:       explorer + "/address/" + to,
+					ToLabel:     res.Chain.Labels[s.To],
+					Share:       scan.FormatRate(s.Rate),
+					Payout:      res.Payout.Format(res.Payout.Amount(split.Wei[i])),
+				})
+			}
+		}
+		for _, item := range res.LineItems {
+			addr := item.Recipient.Hex()
+			chain.LineItems = append(chain.LineItems, LineItem{ID: item.ID, Address: addr, URL: explorer + "/address/" + addr, Label: res.Chain.Labels[item.Recipient], ETH: scan.FormatEther(item.Wei), Memo: item.Memo})
+		}
+		if baseFee, tip := res.FeeTotals(); baseFee != nil && len(res.Txs) > 0 {
+			chain.BaseFeeETH = scan.FormatEther(baseFee)
+			chain.TipETH = scan.FormatEther(tip)
+			if overhead, ok := res.TipOverhead(); ok {
+				chain.TipOverhead = scan.FormatRate(overhead)
+			}
+		}
+		for _, tx := range res.ExcessiveTips() {
+			chain.ExcessiveTips = append(chain.ExcessiveTips, TippedTx{
+				Tx:        txReport(tx, explorer),
+				From:      tx.From.Hex(),
+				FromURL:   explorer + "/address/" + tx.From.Hex(),
+				FromLabel: res.Chain.Labels[tx.From],
+			})
+		}
+
+		for _, t := range res.TypeTotals() {
+			summary := TypeSummary{Label: t.Label, TxCount: t.Txs, ETH: scan.FormatEther(t.GasWei)}
+			if t.Rate != 0 {
+				summary.Rate = scan.FormatRate(t.Rate)
+			}
+			chain.Types = append(chain.Types, summary)
+		}
+		safes, other := res.SafeTotals()
+		for _, t := range safes {
+			chain.Safes = append(chain.Safes, SafeSummary{
+				Address: t.Safe.Hex(),
+				URL:     explorer + "/address/" + t.Safe.Hex(),
+				Label:   res.Chain.Labels[t.Safe],
+				TxCount: t.Txs,
+				ETH:     scan.FormatEther(t.GasWei),
+			})
+		}
+		if len(safes) > 0 && other.Txs > 0 {
+			chain.Safes = append(chain.Safes, SafeSummary{TxCount: other.Txs, ETH: scan.FormatEther(other.GasWei)})
+		}
+
+		denied := res.DeniedOwed()
+		for _, addr := range scan.SortedAddresses(denied) {
+			chain.Denied = append(chain.Denied, DeniedRecipient{
+				Address: addr.Hex(),
+				URL:     explorer + "/address/" + addr.Hex(),
+				Label:   res.Chain.Labels[addr],
+				ETH:     scan.FormatEther(denied[addr]),
+				Reason:  res.Denied[addr],
+			})
+		}
+
+		for _, f := range res.BundleFiles {
+			chain.BundleFiles = append(chain.BundleFiles, BundleFile{
+				Name:      f.Name,
+				Transfers: len(f.Recipients),
+				Payout:    res.Payout.Format(bundleFilePayout(res, f)),
+			})
+		}
+
+		chain.Bucket = string(res.Chain.Bucket)
+		for _, start := range res.Buckets() {
+			chain.Buckets = append(chain.Buckets, res.Chain.Bucket.Name(start))
+		}
+		buckets := res.BucketTotals()
+
+		// Recipients in address order, each with their transactions in chain order
+		index := make(map[common.Address]int)
+		totals, payable, over, files := res.Totals(), res.Payable(), res.OverCap(), bundleFiles(res)
+		sources, gasStats := res.Sources(), res.GasStats()
+		for _, addr := range scan.SortedAddresses(totals) {
+			index[addr] = len(chain.Recipients)
+			recipient := Recipient{
+				Address:    addr.Hex(),
+				URL:        explorer + "/address/" + addr.Hex(),
+				Label:      res.Chain.Labels[addr],
+				TotalETH:   scan.FormatEther(totals[addr]),
+				Payout:     res.Payout.Format(res.Payout.Amount(payable[addr])),
+				BundleFile: files[addr],
+				RejectsETH: res.RejectsETH[addr],
+			}
+			if shares := res.Chain.SenderSplits[addr]; shares != nil {
+				recipient.Payout = fmt.Sprintf("split between %d addresses", len(shares))
+			}
+			if held := over[addr]; held != nil {
+				recipient.HeldETH = scan.FormatEther(held)
+				chain.OverCap = append(chain.OverCap, CappedRecipient{
+					Address:  recipient.Address,
+					URL:      recipient.URL,
+					Label:    recipient.Label,
+					TotalETH: recipient.TotalETH,
+					PaidETH:  scan.FormatEther(payable[addr]),
+					HeldETH:  recipient.HeldETH,
+				})
+			}
+			if usdTotals != nil {
+				recipient.TotalUSD = scan.FormatUSD(usdTotals[addr])
+			}
+			if reportUSD != nil {
+				recipient.ReportUSD = scan.FormatUSD(reportUSD[addr])
+			}
+			for _, t := range buckets[addr] {
+				recipient.Buckets = append(recipient.Buckets, BucketAmount{TxCount: t.Txs, ETH: scan.FormatEther(t.GasWei)})
+			}
+			for _, s := range sources[addr] {
+				recipient.Sources = append(recipient.Sources, Source{
+					Address: s.Sender.Hex(),
+					URL:     explorer + "/address/" + s.Sender.Hex(),
+					Label:   res.Chain.Labels[s.Sender],
+					TxCount: s.Txs,
+					ETH:     scan.FormatEther(s.GasWei),
+				})
+			}
+			if s, ok := gasStats[addr]; ok {
+				recipient.MinGwei, recipient.AvgGwei, recipient.MaxGwei = scan.FormatGwei(s.MinPrice), scan.FormatGwei(s.AvgPrice), scan.FormatGwei(s.MaxPrice)
+				for _, t := range s.Types {
+					recipient.GasTypes = append(recipient.GasTypes, TypeGas{Label: t.Label, TxCount: t.Txs, AvgGasUsed: t.AvgGasUsed, AvgGwei: scan.FormatGwei(t.AvgPrice)})
+				}
+			}
+			chain.Recipients = append(chain.Recipients, recipient)
+
+			if combined[addr] == nil {
+				combined[addr] = big.NewInt(0)
+				combinedUSD[addr] = new(big.Float)
+			}
+		}
+
+		chainTotal, chainUSD := big.NewInt(0), new(big.Float)
+		for _, tx := range res.Txs {
+			i := index[tx.From]
+			t := txReport(tx, explorer)
+			t.OverMaxTip = res.Chain.OverMaxTip(tx)
+			chain.Recipients[i].Txs = append(chain.Recipients[i].Txs, t)
+			chainTotal.Add(chainTotal, tx.GasWei)
+			combined[tx.From].Add(combined[tx.From], tx.GasWei)
+			if tx.USD != nil {
+				chainUSD.Add(chainUSD, tx.USD)
+				combinedUSD[tx.From].Add(combinedUSD[tx.From], tx.USD)
+			}
+		}
+		for _, addr := range scan.SortedByAmount(totals) {
+			chain.ByTotal = append(chain.ByTotal, chain.Recipients[index[addr]])
+		}
+
+		for _, tx := range res.Excluded {
+			chain.Excluded = append(chain.Excluded, Excluded{
+				Tx:        txReport(tx.TxInfo, explorer),
+				From:      tx.From.Hex(),
+				FromURL:   explorer + "/address/" + tx.From.Hex(),
+				FromLabel: res.Chain.Labels[tx.From],
+				Reason:    tx.Reason,
+			})
+		}
+		data.ExcludedCount += len(res.Excluded)
+
+		for _, e := range res.Errors {
+			fe := FetchError{Label: e.Label, Block: e.BlockNumber, Error: e.Err.Error()}
+			if e.Hash == (common.Hash{}) {
+				fe.URL = fmt.Sprintf("%s/block/%d", explorer, e.BlockNumber)
+			} else {
+				fe.Hash = e.Hash.Hex()
+				fe.URL = explorer + "/tx/" + fe.Hash
+			}
+			chain.Errors = append(chain.Errors, fe)
+		}
+		data.ErrorCount += len(res.Errors)
+		chain.Interrupted = res.Interrupted
+		data.Partial = data.Partial || res.Interrupted
+
+		chain.TotalETH = scan.FormatEther(chainTotal)
+		if usdTotals != nil {
+			chain.TotalUSD = scan.FormatUSD(chainUSD)
+		}
+		if reportUSD != nil {
+			chain.ReportUSD = scan.FormatUSD(sumUSD(reportUSD))
+			chain.ReportETHUSD = scan.FormatUSD(res.ReportETHUSD)
+		}
+		data.Chains = append(data.Chains, chain)
+	}
+
+	if len(results) > 1 {
+		for _, addr := range scan.SortedByAmount(combined) {
+			total := RecipientTotal{Address: addr.Hex(), Label: results[0].Chain.Labels[addr], TotalETH: scan.FormatEther(combined[addr])}
+			if data.Priced {
+				total.TotalUSD = scan.FormatUSD(combinedUSD[addr])
+			}
+			data.Combined = append(data.Combined, total)
+		}
+	}
+
+	return data
+}
+
+func carriedReport(amounts map[common.Address]*big.Int, labels scan.Labels, explorer string) []Carried {
+	var carried []Carried
+	for _, addr := range scan.SortedAddresses(amounts) {
+		carried = append(carried, Carried{Address: addr.Hex(), URL: explorer + "/address/" + addr.Hex(), Label: labels[addr], ETH: scan.FormatEther(amounts[addr])})
+	}
+	return carried
+}
+
+func txReport(tx scan.TxInfo, explorer string) Tx {
+	r := Tx{
+		Hash:         tx.Hash.Hex(),
+		URL:          explorer + "/tx/" + tx.Hash.Hex(),
+		Label:        tx.Label,
+		Block:        tx.BlockNumber,
+		GasUsed:      tx.GasUsed,
+		GasPriceGwei: scan.FormatGwei(tx.EffectiveGasPrice),
+		GasETH:       scan.FormatEther(tx.GasWei),
+		Failed:       tx.Failed,
+	}
+	if tx.Cost.L1FeeWei != nil {
+		r.ExecutionETH = scan.FormatEther(tx.Cost.ExecutionWei)
+		r.L1FeeETH = scan.FormatEther(tx.Cost.L1FeeWei)
+	}
+	if tx.Cost.BaseFeeWei != nil {
+		r.BaseFeeETH = scan.FormatEther(tx.Cost.BaseFeeWei)
+		r.BaseFeeGwei = scan.FormatGwei(tx.BaseFee)
+		r.TipETH = scan.FormatEther(tx.Cost.TipWei)
+		r.TipGwei = scan.FormatGwei(tx.TipPerGas())
+		r.ExcessiveTip = tx.ExcessiveTip()
+	}
+	if tx.Cost.BlobWei != nil {
+		r.BlobETH = scan.FormatEther(tx.Cost.BlobWei)
+		r.BlobGasUsed = tx.BlobGasUsed
+		r.BlobGasPriceGwei = scan.FormatGwei(tx.BlobGasPrice)
+	}
+	if tx.ActualWei != nil {
+		r.ActualETH = scan.FormatEther(tx.ActualWei)
+		r.CapRule = tx.CapRule
+	}
+	if tx.Rate != 0 {
+		r.Rate = scan.FormatRate(tx.Rate)
+		r.CostETH = scan.FormatEther(tx.Cost.Total())
+	}
+	if op := tx.UserOp; op != nil {
+		r.UserOp, r.Sender, r.Bundler = op.Hash.Hex(), op.Sender.Hex(), op.Bundler.Hex()
+		if op.Paymaster != (common.Address{}) {
+			r.Paymaster = op.Paymaster.Hex()
+		}
+	}
+	if relay := tx.Relay; relay != nil {
+		r.Relayer = relay.Relayer.Hex()
+		if relay.Signer != (common.Address{}) {
+			r.Signer = relay.Signer.Hex()
+		}
+	}
+	if tx.SentBy != nil {
+		r.SentBy = tx.SentBy.Hex()
+		r.SentByURL = explorer + "/address/" + r.SentBy
+	}
+	if tx.Module != nil {
+		r.Module = tx.Module.Hex()
+		r.ModuleURL = explorer + "/address/" + r.Module
+	}
+	if tx.USD != nil {
+		r.USD = scan.FormatUSD(tx.USD)
+		r.ETHUSD = scan.FormatUSD(tx.ETHUSD)
+	}
+	return r
+}
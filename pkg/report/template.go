@@ -0,0 +1,41 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"juimburser/pkg/scan"
+)
+
+// Parses a user-supplied report template. It's executed with Data and can use
+// the same functions as the built-in Markdown report.
+func ParseTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Renders a combined report for the scans of one or more chains with a
+// template from ParseTemplate
+func RenderTemplate(t *template.Template, results []*scan.Result) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, BuildData(results)); err != nil {
+		return nil, fmt.Errorf("executing template %s: %w", t.Name(), err)
+	}
+	return buf.Bytes(), nil
+}
+
+// The file a template renders to: its name without any .tmpl extension
+func templateOutput(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".tmpl")
+}
@@ -0,0 +1,123 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"juimburser/pkg/scan"
+)
+
+// The files Write creates, before Suffix is added
+var builtinFiles = []string{"report.txt", "report.md", "report.json", "report.html", "transactions.csv", "recipients.csv", "dune.csv"}
+
+// Writes every report format for a set of scan results into a directory
+type ReportWriter struct {
+	OutDir string
+	// Added to each built-in file's name before its extension, e.g.
+	// report-2024-07-01_18949176-20012345.md, so runs don't overwrite each
+	// other's reports
+	Suffix string
+	// User templates (see ParseTemplate), each rendered to OutDir under its
+	// file name without .tmpl. One named like a built-in report replaces it.
+	Templates []string
+	// Also write inputs.json, the results' raw inputs (see WriteArchive)
+	Archive bool
+}
+
+// Writes report.txt, report.md, report.json, report.html, the CSVs (including
+// dune.csv), inputs.json with Archive, and any user templates, each named
+// with Suffix
+func (w ReportWriter) Write(results []*scan.Result) error {
+	if err := os.MkdirAll(w.OutDir, 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(w.path("report.txt"), RenderText(results), 0644); err != nil {
+		return err
+	}
+
+	renderers := []struct {
+		name   string
+		render func([]*scan.Result) ([]byte, error)
+	}{
+		{"report.md", RenderMarkdown},
+		{"report.json", RenderJSON},
+		{"report.html", RenderHTML},
+	}
+	for _, r := range renderers {
+		data, err := r.render(results)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(w.path(r.name), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteCSVs(w.path("transactions.csv"), w.path("recipients.csv"), results); err != nil {
+		return err
+	}
+	if err := WriteDuneCSV(w.path("dune.csv"), results); err != nil {
+		return err
+	}
+	if w.Archive {
+		if err := WriteArchive(w.path("inputs.json"), results); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range w.Templates {
+		t, err := ParseTemplate(path)
+		if err != nil {
+			return err
+		}
+		data, err := RenderTemplate(t, results)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(w.OutDir, w.templateName(path)), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The names of the files Write creates in OutDir
+func (w ReportWriter) Files() []string {
+	var files []string
+	for _, name := range builtinFiles {
+		files = append(files, w.Name(name))
+	}
+	if w.Archive {
+		files = append(files, w.Name("inputs.json"))
+	}
+	for _, path := range w.Templates {
+		name := w.templateName(path)
+		if !slices.Contains(files, name) {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// The name a built-in file such as report.txt is written under
+func (w ReportWriter) Name(builtin string) string {
+	ext := filepath.Ext(builtin)
+	return strings.TrimSuffix(builtin, ext) + w.Suffix + ext
+}
+
+func (w ReportWriter) path(builtin string) string {
+	return filepath.Join(w.OutDir, w.Name(builtin))
+}
+
+// Where a template is rendered to. One named like a built-in file replaces
+// it, suffix and all.
+func (w ReportWriter) templateName(path string) string {
+	name := templateOutput(path)
+	if slices.Contains(builtinFiles, name) {
+		return w.Name(name)
+	}
+	return name
+}
@@ -0,0 +1,218 @@
+package safe
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"juimburser/pkg/bundle"
+)
+
+// Default Safe Transaction Service URLs by chain ID
+var DefaultServices = map[uint64]string{
+	1:        "https://safe-transaction-mainnet.safe.global",
+	10:       "https://safe-transaction-optimism.safe.global",
+	8453:     "https://safe-transaction-base.safe.global",
+	42161:    "https://safe-transaction-arbitrum.safe.global",
+	11155111: "https://safe-transaction-sepolia.safe.global",
+}
+
+var (
+	domainSeparatorTypehash = crypto.Keccak256Hash([]byte("EIP712Domain(uint256 chainId,address verifyingContract)"))
+	safeTxTypehash          = crypto.Keccak256Hash([]byte("SafeTx(address to,uint256 value,bytes data,uint8 operation,uint256 safeTxGas,uint256 baseGas,uint256 gasPrice,address gasToken,address refundReceiver,uint256 nonce)"))
+)
+
+// A Safe transaction with no gas refund, as proposed to the Transaction Service
+type Tx struct {
+	To        common.Address
+	Value     *big.Int
+	Data      []byte
+	Operation uint8
+	Nonce     uint64
+}
+
+// Turns the transfers in a bundle into a single Safe transaction: the
+// transfer itself if there's only one, otherwise a MultiSendCallOnly
+// delegatecall batching them all
+func FromBundle(b bundle.TransactionBundle, multiSend common.Address) (Tx, error) {
+	if len(b.Transactions) == 0 {
+		return Tx{}, fmt.Errorf("bundle has no transactions")
+	}
+
+	if len(b.Transactions) == 1 {
+		tx := b.Transactions[0]
+		value, data, err := bundle.DecodeTx(tx)
+		if err != nil {
+			return Tx{}, err
+		}
+		return Tx{To: common.HexToAddress(tx.To), Value: value, Data: data, Operation: tx.Operation}, nil
+	}
+
+	data, err := bundle.EncodeMultiSend(b.Transactions)
+	if err != nil {
+		return Tx{}, err
+	}
+	return Tx{To: multiSend, Value: big.NewInt(0), Data: data, Operation: bundle.OperationDelegateCall}, nil
+}
+
+// The EIP-712 hash owners sign, for Safe v1.3.0 and later
+func (tx Tx) Hash(chainID *big.Int, safe common.Address) common.Hash {
+	domainSeparator := crypto.Keccak256(
+		domainSeparatorTypehash.Bytes(),
+		math.U256Bytes(new(big.Int).Set(chainID)),
+		common.LeftPadBytes(safe.Bytes(), 32),
+	)
+
+	zero := make([]byte, 32)
+	structHash := crypto.Keccak256(
+		safeTxTypehash.Bytes(),
+		common.LeftPadBytes(tx.To.Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(tx.Value)),
+		crypto.Keccak256(tx.Data),
+		common.LeftPadBytes([]byte{tx.Operation}, 32),
+		zero, // safeTxGas
+		zero, // baseGas
+		zero, // gasPrice
+		zero, // gasToken
+		zero, // refundReceiver
+		math.U256Bytes(new(big.Int).SetUint64(tx.Nonce)),
+	)
+
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+// Signs a Safe transaction hash as an EOA owner or delegate
+func Sign(hash common.Hash, key *ecdsa.PrivateKey) ([]byte, error) {
+	sig, err := crypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// A minimal client for the Safe Transaction Service API
+type Service struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func NewService(baseURL, apiKey string) *Service {
+	return &Service{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// The next nonce to use, accounting for transactions already queued
+func (s *Service) NextNonce(ctx context.Context, safe common.Address) (uint64, error) {
+	var info struct {
+		Nonce json.Number `json:"nonce"`
+	}
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/safes/%s/", safe.Hex()), nil, &info); err != nil {
+		return 0, err
+	}
+	next, err := info.Nonce.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("unexpected nonce %q from Safe Transaction Service", info.Nonce)
+	}
+
+	var queued struct {
+		Results []struct {
+			Nonce json.Number `json:"nonce"`
+		} `json:"results"`
+	}
+	path := fmt.Sprintf("/api/v1/safes/%s/multisig-transactions/?executed=false&nonce__gte=%d&ordering=-nonce&limit=1", safe.Hex(), next)
+	if err := s.do(ctx, http.MethodGet, path, nil, &queued); err != nil {
+		return 0, err
+	}
+	if len(queued.Results) > 0 {
+		last, err := queued.Results[0].Nonce.Int64()
+		if err == nil && last >= next {
+			next = last + 1
+		}
+	}
+
+	return uint64(next), nil
+}
+
+// Submits a signed transaction so it shows up in the Safe's queue
+func (s *Service) Propose(ctx context.Context, safe common.Address, tx Tx, hash common.Hash, sender common.Address, signature []byte) error {
+	body := map[string]any{
+		"to":                      tx.To.Hex(),
+		"value":                   tx.Value.String(),
+		"data":                    hexutil.Encode(tx.Data),
+		"operation":               tx.Operation,
+		"safeTxGas":               "0",
+		"baseGas":                 "0",
+		"gasPrice":                "0",
+		"gasToken":                common.Address{}.Hex(),
+		"refundReceiver":          common.Address{}.Hex(),
+		"nonce":                   tx.Nonce,
+		"contractTransactionHash": hash.Hex(),
+		"sender":                  sender.Hex(),
+		"signature":               hexutil.Encode(signature),
+		"origin":                  "juimburser",
+	}
+	if len(tx.Data) == 0 {
+		body["data"] = nil
+	}
+
+	return s.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/safes/%s/multisig-transactions/", safe.Hex()), body, nil)
+}
+
+func (s *Service) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Safe Transaction Service %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if out != nil {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package scan
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// What a transaction's cost was derived from, as the node returned it, kept
+// with Options.Archive so an auditor can re-derive the totals without
+// trusting the tool or querying an archive node
+type ArchivedTx struct {
+	Hash  common.Hash `json:"hash"`
+	Label string      `json:"label"`
+	// Who sent it, from eth_getTransactionByHash
+	From common.Address `json:"from"`
+	// The log it matched, omitted for reverted and traced calls found
+	// without one
+	Log *types.Log `json:"log,omitempty"`
+	// The eth_getTransactionReceipt response, L2 fee fields included
+	Receipt json.RawMessage `json:"receipt"`
+	// The parts of its block's header the cost used
+	BlockTimestamp hexutil.Uint64 `json:"blockTimestamp"`
+	BaseFeePerGas  *hexutil.Big   `json:"baseFeePerGas,omitempty"`
+
+	block uint64
+	index uint
+}
+
+func newArchivedTx(p pendingTx, from common.Address, receipt *Receipt, header blockHeader) ArchivedTx {
+	a := ArchivedTx{
+		Hash:           p.log.TxHash,
+		Label:          p.label,
+		From:           from,
+		Receipt:        receipt.raw,
+		BlockTimestamp: hexutil.Uint64(header.time.Unix()),
+		BaseFeePerGas:  (*hexutil.Big)(header.baseFee),
+		block:          receipt.BlockNumber.Uint64(),
+		index:          receipt.TransactionIndex,
+	}
+	if !p.noLog {
+		lg := p.log
+		a.Log = &lg
+	}
+	return a
+}
+
+// Puts archived transactions in chain order, like Result.Txs
+func sortArchive(archive []ArchivedTx) {
+	sort.SliceStable(archive, func(i, j int) bool {
+		if archive[i].block != archive[j].block {
+			return archive[i].block < archive[j].block
+		}
+		return archive[i].index < archive[j].index
+	})
+}
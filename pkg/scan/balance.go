@@ -0,0 +1,78 @@
+package scan
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The paying Safe's balance against what executing a chain's bundle needs,
+// checked at the latest block
+type BalanceCheck struct {
+	Safe common.Address
+	// In wei
+	Balance *big.Int
+	// ETH the bundle sends, in wei
+	Sends *big.Int
+	// Estimated gas of executing the bundle, and the gas price it's costed at
+	Gas      uint64
+	GasPrice *big.Int
+	// Calls that couldn't be estimated on their own (usually because they
+	// depend on an earlier call in the bundle), costed at a flat rate instead
+	Unestimated int
+	// The Safe's payout token balance and what the bundle transfers of it,
+	// in its base units. Nil when paying in ETH.
+	TokenBalance *big.Int
+	TokenSends   *big.Int
+}
+
+// The estimated gas at GasPrice, in wei
+func (b *BalanceCheck) GasCost() *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(b.Gas), b.GasPrice)
+}
+
+// ETH the Safe needs: what the bundle sends plus its execution gas
+func (b *BalanceCheck) Needed() *big.Int {
+	return new(big.Int).Add(b.Sends, b.GasCost())
+}
+
+// Whether the Safe holds less ETH or payout token than the bundle needs
+func (b *BalanceCheck) Short() bool {
+	return b.Balance.Cmp(b.Needed()) < 0 || (b.TokenBalance != nil && b.TokenBalance.Cmp(b.TokenSends) < 0)
+}
+
+// The check on one line, with token amounts formatted by payout
+func (b *BalanceCheck) Summary(payout Payout) string {
+	s := fmt.Sprintf("Safe %s holds %s ETH", b.Safe.Hex(), FormatEther(b.Balance))
+	if b.TokenBalance != nil {
+		s += fmt.Sprintf(" and %s", payout.Format(b.TokenBalance))
+	}
+	s += "; the bundle "
+	if b.TokenSends != nil {
+		s += fmt.Sprintf("transfers %s and ", payout.Format(b.TokenSends))
+	}
+	s += fmt.Sprintf("sends %s ETH", FormatEther(b.Sends))
+	s += fmt.Sprintf(", plus about %s ETH of gas to execute (%d gas at %s gwei", FormatEther(b.GasCost()), b.Gas, FormatGwei(b.GasPrice))
+	if b.Unestimated > 0 {
+		s += fmt.Sprintf(", guessing for %d calls that couldn't be estimated on their own", b.Unestimated)
+	}
+	return s + ")"
+}
+
+// What the Safe is short of, or "" if it holds enough
+func (b *BalanceCheck) Warning(payout Payout) string {
+	if !b.Short() {
+		return ""
+	}
+	var short []string
+	if needed := b.Needed(); b.Balance.Cmp(needed) < 0 {
+		short = append(short, fmt.Sprintf("%s ETH", FormatEther(new(big.Int).Sub(needed, b.Balance))))
+	}
+	if b.TokenBalance != nil && b.TokenBalance.Cmp(b.TokenSends) < 0 {
+		short = append(short, payout.Format(new(big.Int).Sub(b.TokenSends, b.TokenBalance)))
+	}
+	return fmt.Sprintf("Safe %s is %s short of what the bundle pays out plus its estimated execution gas; top it up before executing the bundle, or it will revert",
+		b.Safe.Hex(), strings.Join(short, " and "))
+}
@@ -0,0 +1,175 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Clients that can send several JSON-RPC calls in one request
+type batchCaller interface {
+	BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error
+}
+
+func (c *rpcClient) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	return c.rpc.BatchCallContext(ctx, batch)
+}
+
+// The part of eth_getTransactionByHash's response a scan needs
+type batchedTx struct {
+	From  common.Address `json:"from"`
+	Value *hexutil.Big   `json:"value"`
+}
+
+// Fetches the senders and receipts of pending transactions that aren't
+// cached ahead of fetchTxInfo, filling them in on pending: with
+// eth_getBlockReceipts for blocks several share, then opts.BatchSize at a
+// time (two calls each) in batched requests, with up to opts.Concurrency
+// requests in flight. Anything these don't return is left for fetchTxInfo
+// to fetch on its own, so failures are retried and reported the usual way.
+func prefetchTxs(ctx context.Context, client Client, chainID *big.Int, pending []pendingTx, opts Options) error {
+	var todo []int
+	for i, p := range pending {
+		if p.receipt != nil {
+			opts.Progress.advance(1)
+			continue
+		}
+		if opts.Cache != nil {
+			if from, value, receipt, ok := opts.Cache.Get(chainID, p.log.TxHash, p.log.BlockHash); ok {
+				pending[i].from, pending[i].value, pending[i].receipt = from, value, receipt
+				opts.Progress.advance(1)
+				continue
+			}
+		}
+		todo = append(todo, i)
+	}
+
+	todo, err := fetchBlockReceipts(ctx, client, chainID, pending, todo, opts)
+	if err != nil {
+		return err
+	}
+	return batchFetchTxs(ctx, client, chainID, pending, todo, opts)
+}
+
+// Fetches pending[i] for each i in todo with batched requests. Does nothing
+// without opts.BatchSize or a client that batches.
+func batchFetchTxs(ctx context.Context, client Client, chainID *big.Int, pending []pendingTx, todo []int, opts Options) error {
+	batcher, ok := client.(batchCaller)
+	if !ok || opts.BatchSize < 1 {
+		return nil
+	}
+
+	batches := (len(todo) + opts.BatchSize - 1) / opts.BatchSize
+	_, err := forEach(ctx, batches, opts.Concurrency, 0, func(ctx context.Context, b int) error {
+		items := todo[b*opts.BatchSize : min((b+1)*opts.BatchSize, len(todo))]
+		txs := make([]*batchedTx, len(items))
+		receipts := make([]json.RawMessage, len(items))
+		batch := make([]rpc.BatchElem, 0, 2*len(items))
+		for j, i := range items {
+			hash := pending[i].log.TxHash
+			batch = append(batch,
+				rpc.BatchElem{Method: "eth_getTransactionByHash", Args: []any{hash}, Result: &txs[j]},
+				rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []any{hash}, Result: &receipts[j]},
+			)
+		}
+		if err := batcher.BatchCallContext(ctx, batch); err != nil {
+			return err
+		}
+
+		for j, i := range items {
+			p := &pending[i]
+			tx, raw := txs[j], receipts[j]
+			if batch[2*j].Error != nil || batch[2*j+1].Error != nil || tx == nil || tx.From == (common.Address{}) || len(raw) == 0 || string(raw) == "null" {
+				continue
+			}
+			receipt, err := decodeReceipt(p.log.TxHash, raw)
+			if err != nil {
+				continue
+			}
+			if err := p.prefetched(chainID, tx.From, (*big.Int)(tx.Value), receipt, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// Fills in a prefetched sender, value (nil if it wasn't fetched), and
+// receipt, caching them, unless the transaction was reorged, which is left
+// for fetchTx to report
+func (p *pendingTx) prefetched(chainID *big.Int, from common.Address, value *big.Int, receipt *Receipt, opts Options) error {
+	if receipt.BlockHash != p.log.BlockHash {
+		return nil
+	}
+	if opts.Cache != nil {
+		if err := opts.Cache.Put(chainID, p.log.TxHash, from, value, receipt); err != nil {
+			return err
+		}
+	}
+	p.from, p.value, p.receipt = from, value, receipt
+	opts.Progress.advance(1)
+	return nil
+}
+
+// The parts of eth_getBlockByNumber's response a transaction's cost needs
+type batchedHeader struct {
+	Hash    common.Hash    `json:"hash"`
+	Time    hexutil.Uint64 `json:"timestamp"`
+	BaseFee *hexutil.Big   `json:"baseFeePerGas"`
+}
+
+// Fetches the headers of the blocks pending transactions are in that aren't
+// cached, opts.BatchSize at a time in batched requests. Headers that don't
+// come back, or come back for another block (it was reorged), are left for
+// Get to fetch on its own.
+func (b *blockHeaders) prefetch(ctx context.Context, pending []pendingTx, opts Options) error {
+	batcher, ok := b.client.(batchCaller)
+	if !ok || opts.BatchSize < 1 {
+		return nil
+	}
+
+	var todo []types.Log
+	seen := make(map[common.Hash]bool)
+	for _, p := range pending {
+		hash := p.log.BlockHash
+		if hash == (common.Hash{}) || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		if _, ok := b.cached(hash); !ok {
+			todo = append(todo, p.log)
+		}
+	}
+
+	batches := (len(todo) + opts.BatchSize - 1) / opts.BatchSize
+	_, err := forEach(ctx, batches, opts.Concurrency, 0, func(ctx context.Context, n int) error {
+		blocks := todo[n*opts.BatchSize : min((n+1)*opts.BatchSize, len(todo))]
+		headers := make([]*batchedHeader, len(blocks))
+		batch := make([]rpc.BatchElem, len(blocks))
+		for j, lg := range blocks {
+			batch[j] = rpc.BatchElem{Method: "eth_getBlockByNumber", Args: []any{hexutil.EncodeUint64(lg.BlockNumber), false}, Result: &headers[j]}
+		}
+		if err := batcher.BatchCallContext(ctx, batch); err != nil {
+			return err
+		}
+
+		for j, lg := range blocks {
+			h := headers[j]
+			if batch[j].Error != nil || h == nil || h.Hash != lg.BlockHash {
+				continue
+			}
+			if err := b.put(lg.BlockHash, blockHeader{time: time.Unix(int64(h.Time), 0), baseFee: (*big.Int)(h.BaseFee)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,90 @@
+package scan
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Transactions a sender sent that were paid to their beneficiary instead
+type Redirect struct {
+	Sender      common.Address
+	Beneficiary common.Address
+	Txs         int
+	GasWei      *big.Int
+}
+
+// Credits each transaction from a sender the chain maps to a beneficiary to
+// the beneficiary, keeping the sender in SentBy. Call after the exclusions,
+// which go by who sent it.
+func (r *Result) payBeneficiaries() {
+	for i, tx := range r.Txs {
+		if to, ok := r.Chain.Beneficiaries[tx.From]; ok {
+			sender := tx.From
+			r.Txs[i].SentBy = &sender
+			r.Txs[i].From = to
+		}
+	}
+}
+
+// The senders whose transactions were paid to a beneficiary, in address order
+func (r *Result) Redirects() []Redirect {
+	bySender := make(map[common.Address]*Redirect)
+	for _, tx := range r.Txs {
+		if tx.SentBy == nil {
+			continue
+		}
+		d := bySender[*tx.SentBy]
+		if d == nil {
+			d = &Redirect{Sender: *tx.SentBy, Beneficiary: tx.From, GasWei: big.NewInt(0)}
+			bySender[*tx.SentBy] = d
+		}
+		d.Txs++
+		d.GasWei.Add(d.GasWei, tx.GasWei)
+	}
+	var redirects []Redirect
+	for _, sender := range SortedAddresses(bySender) {
+		redirects = append(redirects, *bySender[sender])
+	}
+	return redirects
+}
+
+// A sender's part of a recipient's reimbursement
+type Source struct {
+	Sender common.Address
+	Txs    int
+	GasWei *big.Int
+}
+
+// Where each recipient paid for other senders' transactions got their
+// reimbursement from, by sender in address order, their own transactions
+// included. Recipients who only sent their own are left out.
+func (r *Result) Sources() map[common.Address][]Source {
+	bySender := make(map[common.Address]map[common.Address]*Source)
+	for _, tx := range r.Txs {
+		sender := tx.From
+		if tx.SentBy != nil {
+			sender = *tx.SentBy
+		}
+		if bySender[tx.From] == nil {
+			bySender[tx.From] = make(map[common.Address]*Source)
+		}
+		s := bySender[tx.From][sender]
+		if s == nil {
+			s = &Source{Sender: sender, GasWei: big.NewInt(0)}
+			bySender[tx.From][sender] = s
+		}
+		s.Txs++
+		s.GasWei.Add(s.GasWei, tx.GasWei)
+	}
+	sources := make(map[common.Address][]Source)
+	for to, senders := range bySender {
+		if len(senders) == 1 && senders[to] != nil {
+			continue
+		}
+		for _, sender := range SortedAddresses(senders) {
+			sources[to] = append(sources[to], *senders[sender])
+		}
+	}
+	return sources
+}
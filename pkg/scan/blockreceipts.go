@@ -0,0 +1,95 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The fields of a receipt that say whose transaction it is
+type receiptSender struct {
+	TxHash common.Hash    `json:"transactionHash"`
+	From   common.Address `json:"from"`
+}
+
+// Fetches pending[i] for each i in todo whose block has other pending
+// transactions with one eth_getBlockReceipts call per block (receipts carry
+// their sender), up to opts.Concurrency at once. Plenty of nodes and
+// providers don't support it, so after the first failure the rest are left
+// alone. Returns the items still to fetch.
+func fetchBlockReceipts(ctx context.Context, client Client, chainID *big.Int, pending []pendingTx, todo []int, opts Options) ([]int, error) {
+	byBlock := make(map[uint64][]int)
+	var blocks []uint64
+	for _, i := range todo {
+		number := pending[i].log.BlockNumber
+		if byBlock[number] == nil {
+			blocks = append(blocks, number)
+		}
+		byBlock[number] = append(byBlock[number], i)
+	}
+	var shared []uint64
+	for _, number := range blocks {
+		if len(byBlock[number]) > 1 {
+			shared = append(shared, number)
+		}
+	}
+	if len(shared) == 0 {
+		return todo, nil
+	}
+
+	var unsupported atomic.Bool
+	_, err := forEach(ctx, len(shared), opts.Concurrency, 0, func(ctx context.Context, b int) error {
+		if unsupported.Load() {
+			return nil
+		}
+		var raws []json.RawMessage
+		if err := client.CallContext(ctx, &raws, "eth_getBlockReceipts", hexutil.EncodeUint64(shared[b])); err != nil {
+			if !unsupported.Swap(true) {
+				slog.Debug("eth_getBlockReceipts failed; fetching receipts one at a time", "block", shared[b], "err", err)
+			}
+			return nil
+		}
+
+		receipts := make(map[common.Hash]json.RawMessage)
+		senders := make(map[common.Hash]common.Address)
+		for _, raw := range raws {
+			var r receiptSender
+			if json.Unmarshal(raw, &r) == nil && r.From != (common.Address{}) {
+				receipts[r.TxHash], senders[r.TxHash] = raw, r.From
+			}
+		}
+		for _, i := range byBlock[shared[b]] {
+			p := &pending[i]
+			raw, ok := receipts[p.log.TxHash]
+			if !ok {
+				continue
+			}
+			receipt, err := decodeReceipt(p.log.TxHash, raw)
+			if err != nil {
+				continue
+			}
+			// Receipts don't have the value, which fetchTxInfo gets if
+			// it's needed
+			if err := p.prefetched(chainID, senders[p.log.TxHash], nil, receipt, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var left []int
+	for _, i := range todo {
+		if pending[i].receipt == nil {
+			left = append(left, i)
+		}
+	}
+	return left, nil
+}
@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Calendar periods totals can be broken down into, in UTC
+type Bucket string
+
+const (
+	// ISO weeks, starting on Monday
+	BucketWeek  Bucket = "week"
+	BucketMonth Bucket = "month"
+)
+
+// The start of the bucket t falls in
+func (b Bucket) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func (b Bucket) next(start time.Time) time.Time {
+	if b == BucketWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// The bucket starting at start, e.g. 2024-03 or 2024-W09
+func (b Bucket) Name(start time.Time) string {
+	if b == BucketWeek {
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return start.Format("2006-01")
+}
+
+// What one recipient spent in one bucket
+type BucketTotal struct {
+	Start  time.Time
+	Txs    int
+	GasWei *big.Int
+}
+
+// The start of every bucket the scanned range touches, in order, or nil if
+// the chain isn't bucketed
+func (r *Result) Buckets() []time.Time {
+	b := r.Chain.Bucket
+	if b == "" || r.StartTime.IsZero() || r.EndTime.IsZero() {
+		return nil
+	}
+	var starts []time.Time
+	for start := b.Start(r.StartTime); !start.After(r.EndTime); start = b.next(start) {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// Each sender's gas per bucket, aligned with Buckets (empty buckets are
+// zero), or nil if the chain isn't bucketed
+func (r *Result) BucketTotals() map[common.Address][]BucketTotal {
+	starts := r.Buckets()
+	if starts == nil {
+		return nil
+	}
+	index := make(map[time.Time]int)
+	for i, start := range starts {
+		index[start] = i
+	}
+
+	totals := make(map[common.Address][]BucketTotal)
+	for _, tx := range r.Txs {
+		buckets, ok := totals[tx.From]
+		if !ok {
+			buckets = make([]BucketTotal, len(starts))
+			for i, start := range starts {
+				buckets[i] = BucketTotal{Start: start, GasWei: big.NewInt(0)}
+			}
+			totals[tx.From] = buckets
+		}
+		// Clamped to the range in case a block time falls outside it
+		i, ok := index[r.Chain.Bucket.Start(tx.BlockTime)]
+		if !ok {
+			if tx.BlockTime.Before(starts[0]) {
+				i = 0
+			} else {
+				i = len(starts) - 1
+			}
+		}
+		buckets[i].Txs++
+		buckets[i].GasWei.Add(buckets[i].GasWei, tx.GasWei)
+	}
+	return totals
+}
@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Decides what a transaction its group matched is and how much of it to
+// reimburse, for detection logic beyond topic matching. Classify is called
+// for every transaction as it's fetched, possibly from several goroutines at
+// once.
+type Classifier interface {
+	// Shown in errors and exclusion reasons
+	Name() string
+	Classify(ctx context.Context, in ClassifyInput) (Classification, error)
+}
+
+// What a classifier is given for each transaction
+type ClassifyInput struct {
+	Chain *Chain
+	// The log its group matched, nil for reverted and traced calls found
+	// without one
+	Log     *types.Log
+	Receipt *Receipt
+	// The transaction as valued so far, with the label earlier classifiers
+	// (or its group) gave it, before its rate is applied
+	Tx TxInfo
+	// Basis points of its cost reimbursed, FullRate for all of it
+	Rate uint64
+}
+
+// A classifier's decision about a transaction. The zero value leaves it as
+// it is.
+type Classification struct {
+	// Replaces the transaction's label if set
+	Label string `json:"label"`
+	// Replaces its rate if set, in basis points (10000 for all of it)
+	Rate uint64 `json:"rate"`
+	// Leave it out of the reimbursement, listed with Reason ("excluded by
+	// classifier <name>" by default)
+	Exclude bool   `json:"exclude"`
+	Reason  string `json:"reason"`
+}
+
+// Runs the chain's classifiers over info in order, each seeing what the ones
+// before it decided, until one excludes it. Returns the rate to reimburse it
+// at, p's unless a classifier changed it.
+func (c *Chain) classify(ctx context.Context, p pendingTx, receipt *Receipt, info *TxInfo) (uint64, error) {
+	rate := p.rate
+	if len(c.Classifiers) == 0 {
+		return rate, nil
+	}
+	in := ClassifyInput{Chain: c, Receipt: receipt}
+	if !p.noLog {
+		lg := p.log
+		in.Log = &lg
+	}
+	for _, cl := range c.Classifiers {
+		in.Tx, in.Rate = *info, cmp.Or(rate, FullRate)
+		class, err := cl.Classify(ctx, in)
+		if err != nil {
+			return 0, fmt.Errorf("classifier %s: %w", cl.Name(), err)
+		}
+		if class.Rate > FullRate {
+			return 0, fmt.Errorf("classifier %s: rate %d is over %d", cl.Name(), class.Rate, FullRate)
+		}
+		if class.Label != "" {
+			info.Label = class.Label
+		}
+		if class.Rate != 0 {
+			rate = class.Rate
+		}
+		if class.Exclude {
+			info.ClassifierExclusion = cmp.Or(class.Reason, "excluded by classifier "+cl.Name())
+			break
+		}
+	}
+	return rate, nil
+}
+
+// Why tx is excluded, if a classifier said to leave it out
+func classifiedOut(tx TxInfo) (string, bool) {
+	return tx.ClassifierExclusion, tx.ClassifierExclusion != ""
+}
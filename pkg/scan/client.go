@@ -0,0 +1,43 @@
+package scan
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// The RPC methods a scan uses. Dial returns one backed by a node; the
+// scantest package has an in-memory fake.
+type Client interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	// A nil number is the latest block
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	// A raw JSON-RPC call, for the receipt and block fields go-ethereum
+	// doesn't decode
+	CallContext(ctx context.Context, result any, method string, args ...any) error
+	Close()
+}
+
+// An ethclient plus raw calls on its connection
+type rpcClient struct {
+	*ethclient.Client
+	rpc *rpc.Client
+}
+
+// Wraps an RPC connection as a Client
+func NewClient(c *rpc.Client) Client {
+	return &rpcClient{Client: ethclient.NewClient(c), rpc: c}
+}
+
+func (c *rpcClient) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	return c.rpc.CallContext(ctx, result, method, args...)
+}
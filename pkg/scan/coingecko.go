@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const coingeckoAPI = "https://api.coingecko.com/api/v3"
+
+// Daily ETH/USD prices from CoinGecko's historical API, cached on disk by
+// date so repeated runs over the same period don't re-hit the API
+type CoinGecko struct {
+	apiKey    string
+	baseURL   string
+	cachePath string
+	http      *http.Client
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+func NewCoinGecko(apiKey, cacheDir string) (*CoinGecko, error) {
+	cg := &CoinGecko{
+		apiKey:    apiKey,
+		baseURL:   coingeckoAPI,
+		cachePath: filepath.Join(cacheDir, "coingecko-eth-usd.json"),
+		http:      &http.Client{Timeout: 30 * time.Second},
+		cache:     make(map[string]string),
+	}
+
+	data, err := os.ReadFile(cg.cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return cg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cg.cache); err != nil {
+		return nil, fmt.Errorf("parsing price cache %s: %w", cg.cachePath, err)
+	}
+	return cg, nil
+}
+
+func (cg *CoinGecko) ETHUSD(ctx context.Context, blockNumber uint64, blockTime time.Time) (*big.Float, error) {
+	day := blockTime.UTC().Format("2006-01-02")
+
+	cg.mu.Lock()
+	cached, ok := cg.cache[day]
+	cg.mu.Unlock()
+	if ok {
+		price, _, err := big.ParseFloat(cached, 10, 256, big.ToNearestEven)
+		return price, err
+	}
+
+	price, err := cg.fetch(ctx, blockTime.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	cg.mu.Lock()
+	defer cg.mu.Unlock()
+	cg.cache[day] = price.Text('f', -1)
+	return price, cg.save()
+}
+
+func (cg *CoinGecko) fetch(ctx context.Context, day time.Time) (*big.Float, error) {
+	url := fmt.Sprintf("%s/coins/ethereum/history?date=%s&localization=false", cg.baseURL, day.Format("02-01-2006"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if cg.apiKey != "" {
+		req.Header.Set("x-cg-demo-api-key", cg.apiKey)
+	}
+
+	resp, err := cg.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching CoinGecko price for %s: %w", day.Format("2006-01-02"), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching CoinGecko price for %s: %s", day.Format("2006-01-02"), resp.Status)
+	}
+
+	var body struct {
+		MarketData struct {
+			CurrentPrice map[string]json.Number `json:"current_price"`
+		} `json:"market_data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding CoinGecko response: %w", err)
+	}
+
+	usd, ok := body.MarketData.CurrentPrice["usd"]
+	if !ok {
+		return nil, fmt.Errorf("CoinGecko has no USD price for %s", day.Format("2006-01-02"))
+	}
+	price, _, err := big.ParseFloat(usd.String(), 10, 256, big.ToNearestEven)
+	return price, err
+}
+
+func (cg *CoinGecko) save() error {
+	if err := os.MkdirAll(filepath.Dir(cg.cachePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cg.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cg.cachePath, data, 0644)
+}
@@ -0,0 +1,483 @@
+package scan
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Etherscan's multichain API, which serves every chain it indexes by chainid
+const EtherscanAPI = "https://api.etherscan.io/v2/api"
+
+const (
+	// Logs per getLogs page, the API's maximum
+	etherscanPageSize = 1000
+	// getLogs only pages through this many results of a query
+	etherscanMaxLogs = 10000
+)
+
+// A Client over an Etherscan-compatible API (Etherscan, or Blockscout's
+// /api), for chains without an archive RPC. Logs come from the logs module
+// and everything else from the proxy module, which forwards JSON-RPC calls
+// to Etherscan's own nodes.
+type Etherscan struct {
+	baseURL string
+	apiKey  string
+	chainID *big.Int
+	policy  RetryPolicy
+	// Least time between requests, to stay under the API's rate limit
+	interval time.Duration
+	http     *http.Client
+
+	mu      sync.Mutex
+	next    time.Time
+	senders map[common.Hash]common.Address
+}
+
+var _ Client = (*Etherscan)(nil)
+
+// Makes at most perSecond requests a second, retrying rate limit and
+// transient HTTP errors according to policy
+func NewEtherscan(baseURL, apiKey string, chainID *big.Int, perSecond int, policy RetryPolicy) *Etherscan {
+	var interval time.Duration
+	if perSecond > 0 {
+		interval = time.Second / time.Duration(perSecond)
+	}
+	return &Etherscan{
+		baseURL:  baseURL,
+		apiKey:   apiKey,
+		chainID:  chainID,
+		policy:   policy,
+		interval: interval,
+		http:     &http.Client{},
+		senders:  make(map[common.Hash]common.Address),
+	}
+}
+
+// The API picks the chain by ID, so there's nothing to check it against
+func (e *Etherscan) ChainID(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(e.chainID), nil
+}
+
+// Only sets the number, time, and base fee, the fields a scan reads
+func (e *Etherscan) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var head *struct {
+		Number  hexutil.Big    `json:"number"`
+		Time    hexutil.Uint64 `json:"timestamp"`
+		BaseFee *hexutil.Big   `json:"baseFeePerGas"`
+	}
+	params := url.Values{"tag": {blockTag(number)}, "boolean": {"false"}}
+	if err := e.proxy(ctx, &head, "eth_getBlockByNumber", params); err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, ethereum.NotFound
+	}
+	return &types.Header{Number: head.Number.ToInt(), Time: uint64(head.Time), BaseFee: (*big.Int)(head.BaseFee)}, nil
+}
+
+// getLogs takes one address and one topic per position, so a query with
+// several of either is split into one per combination
+func (e *Etherscan) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if query.BlockHash != nil {
+		return nil, errors.New("etherscan: getLogs doesn't support block hash queries")
+	}
+
+	base := url.Values{"module": {"logs"}, "action": {"getLogs"}}
+	base.Set("fromBlock", "0")
+	if query.FromBlock != nil {
+		base.Set("fromBlock", query.FromBlock.String())
+	}
+	base.Set("toBlock", "latest")
+	if query.ToBlock != nil {
+		base.Set("toBlock", query.ToBlock.String())
+	}
+
+	addresses := []string{""}
+	if len(query.Addresses) > 0 {
+		addresses = nil
+		for _, a := range query.Addresses {
+			addresses = append(addresses, a.Hex())
+		}
+	}
+
+	type logID struct {
+		tx    common.Hash
+		index uint
+	}
+	seen := make(map[logID]bool)
+	var logs []types.Log
+	for _, addr := range addresses {
+		for _, topics := range topicCombinations(query.Topics) {
+			params := url.Values{}
+			for k, v := range base {
+				params[k] = v
+			}
+			if addr != "" {
+				params.Set("address", addr)
+			}
+			var set []int
+			for i, t := range topics {
+				if t != nil {
+					params.Set(fmt.Sprintf("topic%d", i), t.Hex())
+					set = append(set, i)
+				}
+			}
+			for i, a := range set {
+				for _, b := range set[i+1:] {
+					params.Set(fmt.Sprintf("topic%d_%d_opr", a, b), "and")
+				}
+			}
+
+			found, err := e.getLogs(ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			for _, l := range found {
+				if id := (logID{l.TxHash, l.Index}); !seen[id] {
+					seen[id] = true
+					logs = append(logs, l)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(logs, func(a, b types.Log) int {
+		if a.BlockNumber != b.BlockNumber {
+			return cmp.Compare(a.BlockNumber, b.BlockNumber)
+		}
+		return cmp.Compare(a.Index, b.Index)
+	})
+	return logs, nil
+}
+
+// Every combination of one topic per position, with nil for a wildcard
+func topicCombinations(topics [][]common.Hash) [][]*common.Hash {
+	combos := [][]*common.Hash{{}}
+	for _, options := range topics {
+		var next [][]*common.Hash
+		for _, combo := range combos {
+			if len(options) == 0 {
+				next = append(next, append(slices.Clone(combo), nil))
+				continue
+			}
+			for i := range options {
+				next = append(next, append(slices.Clone(combo), &options[i]))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// A getLogs entry. Etherscan writes zero as "0x", which hexutil rejects.
+type etherscanLog struct {
+	Address     common.Address `json:"address"`
+	Topics      []common.Hash  `json:"topics"`
+	Data        hexutil.Bytes  `json:"data"`
+	BlockNumber string         `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	TxIndex     string         `json:"transactionIndex"`
+	LogIndex    string         `json:"logIndex"`
+}
+
+// Pages through a getLogs query, failing with a log limit error if it has
+// more results than the API pages through so the range gets split
+func (e *Etherscan) getLogs(ctx context.Context, params url.Values) ([]types.Log, error) {
+	params.Set("offset", strconv.Itoa(etherscanPageSize))
+	var logs []types.Log
+	for page := 1; ; page++ {
+		if page*etherscanPageSize > etherscanMaxLogs {
+			return nil, fmt.Errorf("etherscan: query returned more than %d results", etherscanMaxLogs)
+		}
+		params.Set("page", strconv.Itoa(page))
+		raw, err := e.get(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		var entries []etherscanLog
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, fmt.Errorf("etherscan: decoding logs: %w", err)
+		}
+
+		for _, entry := range entries {
+			blockNumber, err1 := parseHexUint(entry.BlockNumber)
+			txIndex, err2 := parseHexUint(entry.TxIndex)
+			logIndex, err3 := parseHexUint(entry.LogIndex)
+			if err := errors.Join(err1, err2, err3); err != nil {
+				return nil, fmt.Errorf("etherscan: decoding log in %s: %w", entry.TxHash.Hex(), err)
+			}
+			logs = append(logs, types.Log{
+				Address:     entry.Address,
+				Topics:      entry.Topics,
+				Data:        entry.Data,
+				BlockNumber: blockNumber,
+				TxHash:      entry.TxHash,
+				TxIndex:     uint(txIndex),
+				BlockHash:   entry.BlockHash,
+				Index:       uint(logIndex),
+			})
+		}
+		if len(entries) < etherscanPageSize {
+			return logs, nil
+		}
+	}
+}
+
+func parseHexUint(s string) (uint64, error) {
+	if s == "0x" {
+		return 0, nil
+	}
+	return hexutil.DecodeUint64(s)
+}
+
+func (e *Etherscan) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	var raw json.RawMessage
+	if err := e.proxy(ctx, &raw, "eth_getTransactionByHash", url.Values{"txhash": {hash.Hex()}}); err != nil {
+		return nil, false, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, false, ethereum.NotFound
+	}
+
+	tx := new(types.Transaction)
+	if err := json.Unmarshal(raw, tx); err != nil {
+		return nil, false, fmt.Errorf("etherscan: decoding transaction %s: %w", hash.Hex(), err)
+	}
+	var extra struct {
+		From        common.Address `json:"from"`
+		BlockNumber *string        `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &extra); err != nil {
+		return nil, false, fmt.Errorf("etherscan: decoding transaction %s: %w", hash.Hex(), err)
+	}
+
+	e.mu.Lock()
+	e.senders[tx.Hash()] = extra.From
+	e.mu.Unlock()
+	return tx, extra.BlockNumber == nil, nil
+}
+
+// The sender the API reported when tx was fetched
+func (e *Etherscan) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	e.mu.Lock()
+	from, ok := e.senders[tx.Hash()]
+	e.mu.Unlock()
+	if ok {
+		return from, nil
+	}
+	if _, _, err := e.TransactionByHash(ctx, tx.Hash()); err != nil {
+		return common.Address{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.senders[tx.Hash()], nil
+}
+
+// Etherscan's proxy only calls at the latest block; Blockscout takes any
+func (e *Etherscan) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if msg.To == nil {
+		return nil, errors.New("etherscan: eth_call needs a to address")
+	}
+	var out hexutil.Bytes
+	params := url.Values{"to": {msg.To.Hex()}, "data": {hexutil.Encode(msg.Data)}, "tag": {blockTag(blockNumber)}}
+	if err := e.proxy(ctx, &out, "eth_call", params); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Supports the raw calls a scan makes: eth_getTransactionReceipt and
+// eth_getBlockByNumber
+func (e *Etherscan) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	params := url.Values{}
+	switch method {
+	case "eth_getTransactionReceipt":
+		hash, ok := args[0].(common.Hash)
+		if !ok {
+			return fmt.Errorf("etherscan: %s takes a common.Hash, got %T", method, args[0])
+		}
+		params.Set("txhash", hash.Hex())
+	case "eth_getBlockByNumber":
+		params.Set("tag", fmt.Sprint(args[0]))
+		params.Set("boolean", strconv.FormatBool(args[1] == true))
+	default:
+		return fmt.Errorf("etherscan: %s isn't available through the API", method)
+	}
+	return e.proxy(ctx, result, method, params)
+}
+
+func (e *Etherscan) Close() {
+	e.http.CloseIdleConnections()
+}
+
+func blockTag(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	// Tags like finalized are passed as negative numbers
+	if number.Sign() < 0 && number.IsInt64() {
+		return rpc.BlockNumber(number.Int64()).String()
+	}
+	return hexutil.EncodeBig(number)
+}
+
+// Calls a JSON-RPC method through the proxy module
+func (e *Etherscan) proxy(ctx context.Context, result any, action string, params url.Values) error {
+	params.Set("module", "proxy")
+	params.Set("action", action)
+	raw, err := e.get(ctx, params)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("etherscan: decoding %s: %w", action, err)
+	}
+	return nil
+}
+
+// Both the API's own responses and proxied JSON-RPC ones
+type etherscanResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Makes a request, retrying rate limit and transient errors, and returns
+// its result
+func (e *Etherscan) get(ctx context.Context, params url.Values) (json.RawMessage, error) {
+	params.Set("chainid", e.chainID.String())
+	if e.apiKey != "" {
+		params.Set("apikey", e.apiKey)
+	}
+	action := params.Get("action")
+	host := redactURL(e.baseURL)
+
+	for retries := 0; ; retries++ {
+		if err := e.wait(ctx); err != nil {
+			return nil, err
+		}
+		result, retry, err := e.do(ctx, e.baseURL+"?"+params.Encode(), host, action)
+		if retry {
+			rpcErrors.Inc(host)
+		}
+		if !retry || retries >= e.policy.Retries || ctx.Err() != nil {
+			return result, err
+		}
+
+		wait := e.policy.delay(retries)
+		slog.Warn("Etherscan request failed, retrying", "host", host, "action", action, "reason", err, "wait", wait.Round(time.Millisecond), "retry", retries+1, "of", e.policy.Retries)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// One attempt at a request, and whether its failure is worth retrying
+func (e *Etherscan) do(ctx context.Context, u, host, action string) (json.RawMessage, bool, error) {
+	attemptCtx := ctx
+	if e.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, e.policy.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	started := time.Now()
+	resp, err := e.http.Do(req)
+	rpcRequests.Inc(host, action)
+	countCall(ctx)
+	logRequest(host, action, 0, time.Since(started), resp, err)
+	if err != nil && attemptCtx.Err() != nil && ctx.Err() == nil {
+		return nil, true, fmt.Errorf("no response within %s", e.policy.Timeout)
+	}
+	if retry, reason := retryable(resp, err); retry {
+		if err == nil {
+			resp.Body.Close()
+			err = errors.New(reason)
+		}
+		return nil, true, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("etherscan %s: HTTP %s", action, resp.Status)
+	}
+
+	var r etherscanResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, false, fmt.Errorf("etherscan %s: decoding response: %w", action, err)
+	}
+	if r.Error != nil {
+		return nil, false, fmt.Errorf("etherscan %s: %s", action, r.Error.Message)
+	}
+	if r.Status == "0" {
+		if strings.HasPrefix(r.Message, "No records found") {
+			return json.RawMessage("[]"), false, nil
+		}
+		// Errors put the detail in result, e.g. "Max rate limit reached"
+		msg := r.Message
+		var detail string
+		if json.Unmarshal(r.Result, &detail) == nil && detail != "" {
+			msg = detail
+		}
+		err := fmt.Errorf("etherscan %s: %s", action, msg)
+		return nil, strings.Contains(strings.ToLower(msg), "rate limit"), err
+	}
+	return r.Result, false, nil
+}
+
+// Blocks until the next request may be made under the rate limit
+func (e *Etherscan) wait(ctx context.Context) error {
+	e.mu.Lock()
+	now := time.Now()
+	at := e.next
+	if at.Before(now) {
+		at = now
+	}
+	e.next = at.Add(e.interval)
+	e.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
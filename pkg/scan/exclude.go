@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Transactions and senders that are never reimbursed, with the reason why
+type Exclusions struct {
+	Txs     map[common.Hash]string
+	Senders map[common.Address]string
+}
+
+// A transaction that matched a group but was left out of the reimbursement
+type ExcludedTx struct {
+	TxInfo
+	Reason string
+}
+
+// The reason tx is excluded, if it is
+func (e Exclusions) Reason(tx TxInfo) (string, bool) {
+	if reason, ok := e.Txs[tx.Hash]; ok {
+		return reason, true
+	}
+	if reason, ok := e.Senders[tx.From]; ok {
+		return reason, true
+	}
+	return "", false
+}
+
+// Why tx is excluded if it was sent by the paying Safe or one of the chain's
+// Safes (e.g. a nested execution), which shouldn't reimburse itself
+func (c *Chain) selfSent(tx TxInfo) (string, bool) {
+	if (c.Safe != nil && tx.From == *c.Safe) || (tx.Safe != nil && tx.From == *tx.Safe) || slices.Contains(c.Safes, tx.From) {
+		return fmt.Sprintf("sent by the Safe %s itself, which isn't reimbursed", c.Labels.Name(tx.From)), true
+	}
+	return "", false
+}
+
+// Moves transactions reason matches out of Txs and into Excluded, returning how many moved
+func (r *Result) Exclude(reason func(TxInfo) (string, bool)) int {
+	kept := r.Txs[:0]
+	dropped := 0
+	for _, tx := range r.Txs {
+		if why, ok := reason(tx); ok {
+			r.Excluded = append(r.Excluded, ExcludedTx{TxInfo: tx, Reason: why})
+			dropped++
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	r.Txs = kept
+	return dropped
+}
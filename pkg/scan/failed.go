@@ -0,0 +1,176 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// A transaction as listed in a block. Decoded loosely so L2 transaction
+// types go-ethereum doesn't know don't break the scan.
+type blockTx struct {
+	Hash  common.Hash     `json:"hash"`
+	From  common.Address  `json:"from"`
+	To    *common.Address `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+	Input hexutil.Bytes   `json:"input"`
+	Index hexutil.Uint    `json:"transactionIndex"`
+}
+
+type rawBlock struct {
+	Number       hexutil.Uint64 `json:"number"`
+	Hash         common.Hash    `json:"hash"`
+	Transactions []blockTx      `json:"transactions"`
+}
+
+// Reverted transactions emit no logs, so groups with IncludeFailed are
+// matched by walking every block in the range for calls to their addresses
+// whose receipts have status 0. Returns the matches for each group, in block
+// order, and the blocks that couldn't be fetched.
+func findFailedCalls(ctx context.Context, client Client, res *Result, groups []TxGroup, opts Options) (map[int][]pendingTx, []ScanError, error) {
+	wanted := false
+	for _, g := range groups {
+		wanted = wanted || g.IncludeFailed
+	}
+	if !wanted {
+		return nil, nil, nil
+	}
+
+	start, end := res.StartBlock.Uint64(), res.EndBlock.Uint64()
+	found := make([]map[int][]pendingTx, end-start+1)
+	opts.Progress.start("reverted calls", "blocks", uint64(len(found)))
+	failed, err := forEach(ctx, len(found), opts.Concurrency, opts.ItemRetries, func(ctx context.Context, i int) error {
+		matches, err := failedCallsInBlock(ctx, client, res.Chain.ChainID, start+uint64(i), groups, opts.Cache)
+		if err != nil {
+			return err
+		}
+		found[i] = matches
+		opts.Progress.advance(1)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []ScanError
+	for i := range found {
+		if err, ok := failed[i]; ok {
+			errs = append(errs, ScanError{BlockNumber: start + uint64(i), Label: "reverted calls", Err: err})
+		}
+	}
+
+	byGroup := make(map[int][]pendingTx)
+	for _, matches := range found {
+		for i, pending := range matches {
+			byGroup[i] = append(byGroup[i], pending...)
+		}
+	}
+	return byGroup, errs, nil
+}
+
+func failedCallsInBlock(ctx context.Context, client Client, chainID *big.Int, number uint64, groups []TxGroup, cache *TxCache) (map[int][]pendingTx, error) {
+	var block rawBlock
+	if err := client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), true); err != nil {
+		return nil, err
+	}
+
+	var matches map[int][]pendingTx
+	for _, tx := range block.Transactions {
+		if tx.To == nil {
+			continue
+		}
+
+		var receipt *Receipt
+		for i, g := range groups {
+			if !g.IncludeFailed || !g.matchesCall(*tx.To, tx.Input) {
+				continue
+			}
+
+			if receipt == nil {
+				var err error
+				if receipt, err = fetchCallReceipt(ctx, client, chainID, tx, block.Hash, cache); err != nil {
+					return nil, err
+				}
+			}
+			if receipt.Status != types.ReceiptStatusFailed {
+				break
+			}
+
+			if matches == nil {
+				matches = make(map[int][]pendingTx)
+			}
+			matches[i] = append(matches[i], pendingTx{
+				log: types.Log{
+					TxHash:      tx.Hash,
+					TxIndex:     uint(tx.Index),
+					BlockNumber: uint64(block.Number),
+					BlockHash:   block.Hash,
+				},
+				label:   g.Label,
+				safe:    g.Safe,
+				rate:    g.Rate,
+				from:    tx.From,
+				value:   (*big.Int)(tx.Value),
+				receipt: receipt,
+				noLog:   true,
+			})
+			break
+		}
+	}
+	return matches, nil
+}
+
+// Gets a block transaction's receipt, from cache if possible
+func fetchCallReceipt(ctx context.Context, client Client, chainID *big.Int, tx blockTx, blockHash common.Hash, cache *TxCache) (*Receipt, error) {
+	if cache != nil {
+		if _, _, receipt, ok := cache.Get(chainID, tx.Hash, blockHash); ok {
+			return receipt, nil
+		}
+	}
+
+	receipt, err := fetchReceipt(ctx, client, tx.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		if err := cache.Put(chainID, tx.Hash, tx.From, (*big.Int)(tx.Value), receipt); err != nil {
+			return nil, err
+		}
+	}
+	return receipt, nil
+}
+
+// Whether a call to to with input is one of the group's calls
+func (g TxGroup) matchesCall(to common.Address, input []byte) bool {
+	matched := false
+	for _, a := range g.Addresses {
+		matched = matched || a == to
+	}
+	if !matched {
+		return false
+	}
+
+	if len(g.CallSelectors) > 0 {
+		if len(input) < 4 || !slices.Contains(g.CallSelectors, [4]byte(input[:4])) {
+			return false
+		}
+	}
+	if len(g.CallArgs) == 0 {
+		return true
+	}
+	if len(input) < 36 {
+		return false
+	}
+	for _, arg := range g.CallArgs {
+		if bytes.Equal(input[4:36], arg.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
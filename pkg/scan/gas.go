@@ -0,0 +1,187 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// How a chain charges for gas
+type GasModel string
+
+const (
+	GasModelEthereum GasModel = "ethereum"
+	// OP stack chains (Optimism, Base) add an L1 data fee on top of L2 execution gas
+	GasModelOptimism GasModel = "optimism"
+	// Arbitrum Nitro folds L1 calldata costs into gasUsed (reported separately
+	// as gasUsedForL1) and charges the base fee rather than the bid price
+	GasModelArbitrum GasModel = "arbitrum"
+)
+
+// Default gas models by chain ID
+var DefaultGasModels = map[uint64]GasModel{
+	10:    GasModelOptimism,
+	8453:  GasModelOptimism,
+	42161: GasModelArbitrum,
+	42170: GasModelArbitrum,
+}
+
+// A receipt plus the fields (L2-specific ones, and to) go-ethereum doesn't
+// decode
+type Receipt struct {
+	*types.Receipt
+	// OP stack only
+	L1Fee *big.Int
+	// Arbitrum only, already included in GasUsed
+	GasUsedForL1 *uint64
+	// The address called, nil for contract deployments
+	To *common.Address
+	// The receipt as returned by the RPC, for caching
+	raw json.RawMessage
+}
+
+type extraReceiptFields struct {
+	L1Fee        *hexutil.Big    `json:"l1Fee"`
+	GasUsedForL1 *hexutil.Uint64 `json:"gasUsedForL1"`
+	To           *common.Address `json:"to"`
+}
+
+// Fetches a receipt with a raw RPC call so L2 fields are kept
+func fetchReceipt(ctx context.Context, client Client, hash common.Hash) (*Receipt, error) {
+	var raw json.RawMessage
+	if err := client.CallContext(ctx, &raw, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, ethereum.NotFound
+	}
+	return decodeReceipt(hash, raw)
+}
+
+func decodeReceipt(hash common.Hash, raw json.RawMessage) (*Receipt, error) {
+	receipt := &Receipt{Receipt: new(types.Receipt), raw: raw}
+	if err := json.Unmarshal(raw, receipt.Receipt); err != nil {
+		return nil, fmt.Errorf("decoding receipt %s: %w", hash.Hex(), err)
+	}
+
+	var fields extraReceiptFields
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("decoding receipt %s: %w", hash.Hex(), err)
+	}
+	receipt.L1Fee = (*big.Int)(fields.L1Fee)
+	receipt.To = fields.To
+	if fields.GasUsedForL1 != nil {
+		gas := uint64(*fields.GasUsedForL1)
+		receipt.GasUsedForL1 = &gas
+	}
+
+	return receipt, nil
+}
+
+// The reimbursable cost of a transaction, split into its components
+type GasCost struct {
+	// L2 execution gas times the effective gas price
+	ExecutionWei *big.Int
+	// L1 data fee on OP stack and Arbitrum chains, nil on others
+	L1FeeWei *big.Int
+	// EIP-4844 blob gas used times the blob gas price, nil for transactions
+	// without blobs
+	BlobWei *big.Int
+	// ExecutionWei split into the block's base fee and the priority fee paid
+	// on top of it, nil before EIP-1559
+	BaseFeeWei *big.Int
+	TipWei     *big.Int
+}
+
+func (c GasCost) Total() *big.Int {
+	total := new(big.Int).Set(c.ExecutionWei)
+	if c.L1FeeWei != nil {
+		total.Add(total, c.L1FeeWei)
+	}
+	if c.BlobWei != nil {
+		total.Add(total, c.BlobWei)
+	}
+	return total
+}
+
+// Splits ExecutionWei, charged at gasPrice per gas, into base fee and tip
+func (c *GasCost) splitFees(gasPrice, baseFee *big.Int) {
+	if baseFee == nil || gasPrice.Sign() == 0 {
+		return
+	}
+	perGas := baseFee
+	if perGas.Cmp(gasPrice) > 0 {
+		perGas = gasPrice
+	}
+	gas := new(big.Int).Div(c.ExecutionWei, gasPrice)
+	c.BaseFeeWei = new(big.Int).Mul(gas, perGas)
+	c.TipWei = new(big.Int).Sub(c.ExecutionWei, c.BaseFeeWei)
+}
+
+// Computes what a transaction cost its sender under the chain's gas model
+func (m GasModel) Cost(receipt *Receipt) (GasCost, error) {
+	if receipt.EffectiveGasPrice == nil {
+		return GasCost{}, fmt.Errorf("receipt %s has no effectiveGasPrice", receipt.TxHash.Hex())
+	}
+	return m.costAt(receipt, receipt.EffectiveGasPrice)
+}
+
+// Like Cost, but with gas priced at no more than maxGasPrice. OP stack L1
+// data fees and blob fees aren't priced in execution gas, so they're left as
+// is.
+func (m GasModel) CappedCost(receipt *Receipt, maxGasPrice *big.Int) (GasCost, error) {
+	if receipt.EffectiveGasPrice == nil {
+		return GasCost{}, fmt.Errorf("receipt %s has no effectiveGasPrice", receipt.TxHash.Hex())
+	}
+	price := receipt.EffectiveGasPrice
+	if price.Cmp(maxGasPrice) > 0 {
+		price = maxGasPrice
+	}
+	return m.costAt(receipt, price)
+}
+
+func (m GasModel) costAt(receipt *Receipt, gasPrice *big.Int) (GasCost, error) {
+	cost := GasCost{
+		ExecutionWei: new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(receipt.GasUsed)),
+	}
+
+	switch m {
+	case GasModelEthereum:
+	case GasModelOptimism:
+		if receipt.L1Fee == nil {
+			return GasCost{}, fmt.Errorf("receipt %s has no l1Fee; is this an OP stack chain?", receipt.TxHash.Hex())
+		}
+		cost.L1FeeWei = receipt.L1Fee
+	case GasModelArbitrum:
+		if receipt.GasUsedForL1 == nil {
+			return GasCost{}, fmt.Errorf("receipt %s has no gasUsedForL1; is this an Arbitrum chain?", receipt.TxHash.Hex())
+		}
+		if *receipt.GasUsedForL1 > receipt.GasUsed {
+			return GasCost{}, fmt.Errorf("receipt %s: gasUsedForL1 %d exceeds gasUsed %d", receipt.TxHash.Hex(), *receipt.GasUsedForL1, receipt.GasUsed)
+		}
+
+		// effectiveGasPrice is what was actually charged (the base fee, since
+		// Nitro doesn't pay priority fees), and gasUsed already covers the L1
+		// portion, so split the total rather than adding to it
+		l2Gas := receipt.GasUsed - *receipt.GasUsedForL1
+		cost.ExecutionWei = new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(l2Gas))
+		cost.L1FeeWei = new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(*receipt.GasUsedForL1))
+	default:
+		return GasCost{}, fmt.Errorf("unknown gas model %q", m)
+	}
+
+	if receipt.BlobGasUsed > 0 {
+		if receipt.BlobGasPrice == nil {
+			return GasCost{}, fmt.Errorf("receipt %s has blobGasUsed but no blobGasPrice", receipt.TxHash.Hex())
+		}
+		cost.BlobWei = new(big.Int).Mul(receipt.BlobGasPrice, new(big.Int).SetUint64(receipt.BlobGasUsed))
+	}
+
+	return cost, nil
+}
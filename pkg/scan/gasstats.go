@@ -0,0 +1,83 @@
+package scan
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The effective gas prices a recipient's transactions paid, and how much gas
+// each type of them used on average
+type GasStats struct {
+	Txs      int
+	MinPrice *big.Int
+	MaxPrice *big.Int
+	// The mean of its transactions' prices, rounded down
+	AvgPrice *big.Int
+	Types    []TypeGasStats
+}
+
+// A recipient's transactions of one type (group label)
+type TypeGasStats struct {
+	Label      string
+	Txs        int
+	AvgGasUsed uint64
+	AvgPrice   *big.Int
+}
+
+// Gas statistics per recipient, each one's types in the order TypeTotals
+// lists them, to spot unusually expensive execution patterns. Prices are
+// what the transactions paid, before any gas price cap.
+func (r *Result) GasStats() map[common.Address]GasStats {
+	type sums struct {
+		txs     int
+		gasUsed uint64
+		price   *big.Int
+	}
+	stats := make(map[common.Address]GasStats)
+	byType := make(map[common.Address]map[string]*sums)
+	for _, tx := range r.Txs {
+		price := tx.EffectiveGasPrice
+		s, ok := stats[tx.From]
+		if !ok {
+			s = GasStats{MinPrice: price, MaxPrice: price, AvgPrice: big.NewInt(0)}
+			byType[tx.From] = make(map[string]*sums)
+		}
+		s.Txs++
+		if price.Cmp(s.MinPrice) < 0 {
+			s.MinPrice = price
+		}
+		if price.Cmp(s.MaxPrice) > 0 {
+			s.MaxPrice = price
+		}
+		// Summed here and divided below
+		s.AvgPrice = new(big.Int).Add(s.AvgPrice, price)
+		stats[tx.From] = s
+
+		t := byType[tx.From][tx.Label]
+		if t == nil {
+			t = &sums{price: big.NewInt(0)}
+			byType[tx.From][tx.Label] = t
+		}
+		t.txs++
+		t.gasUsed += tx.GasUsed
+		t.price.Add(t.price, price)
+	}
+
+	types := r.TypeTotals()
+	for addr, s := range stats {
+		s.AvgPrice.Div(s.AvgPrice, big.NewInt(int64(s.Txs)))
+		for _, tt := range types {
+			if t := byType[addr][tt.Label]; t != nil {
+				s.Types = append(s.Types, TypeGasStats{
+					Label:      tt.Label,
+					Txs:        t.txs,
+					AvgGasUsed: t.gasUsed / uint64(t.txs),
+					AvgPrice:   t.price.Div(t.price, big.NewInt(int64(t.txs))),
+				})
+			}
+		}
+		stats[addr] = s
+	}
+	return stats
+}
@@ -0,0 +1,16 @@
+package scan
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Human names for addresses, shown in reports alongside them
+type Labels map[common.Address]string
+
+// addr's label, or its hex if it has none
+func (l Labels) Name(addr common.Address) string {
+	if label := l[addr]; label != "" {
+		return label
+	}
+	return addr.Hex()
+}
@@ -0,0 +1,29 @@
+package scan
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// An ad-hoc reimbursement from the config, like an ENS renewal or a pinning
+// bill a contributor paid, owed on top of their gas
+type LineItem struct {
+	// Unique on its chain, recorded in the state once paid
+	ID        string
+	Recipient common.Address
+	Wei       *big.Int
+	Memo      string
+}
+
+// What each recipient's line items add up to
+func (r *Result) LineItemTotals() map[common.Address]*big.Int {
+	totals := make(map[common.Address]*big.Int)
+	for _, item := range r.LineItems {
+		if totals[item.Recipient] == nil {
+			totals[item.Recipient] = big.NewInt(0)
+		}
+		totals[item.Recipient].Add(totals[item.Recipient], item.Wei)
+	}
+	return totals
+}
@@ -0,0 +1,79 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Errors providers return when a getLogs query covers too many blocks or
+// results. Rate limits ("429 Too Many Requests", "rate limit exceeded")
+// aren't among them: a smaller window wouldn't help, so they fail the chain.
+var logLimitErrors = []string{
+	"query returned more than",
+	"more than 10000 results",
+	"range is too large",
+	"range too large",
+	"block range is too wide",
+	"exceed maximum block range",
+	"response size exceeded",
+	"query timeout exceeded",
+}
+
+func isLogLimitError(err error) bool {
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range logLimitErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs a FilterLogs query over [query.FromBlock, query.ToBlock] in windows of
+// at most window blocks. A window the provider rejects as too large is halved
+// and retried, and later windows keep the smaller size.
+func filterLogsChunked(ctx context.Context, client Client, query ethereum.FilterQuery, window uint64, progress *Progress) ([]types.Log, error) {
+	if window == 0 {
+		logs, err := client.FilterLogs(ctx, query)
+		progress.advance(query.ToBlock.Uint64() - query.FromBlock.Uint64() + 1)
+		return logs, err
+	}
+
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	var logs []types.Log
+	for from <= to {
+		end := from + window - 1
+		if end > to || end < from {
+			end = to
+		}
+
+		q := query
+		q.FromBlock = new(big.Int).SetUint64(from)
+		q.ToBlock = new(big.Int).SetUint64(end)
+		chunk, err := client.FilterLogs(ctx, q)
+		if err != nil {
+			if isLogLimitError(err) && end > from {
+				window = (end - from + 1) / 2
+				continue
+			}
+			return nil, fmt.Errorf("getting logs for blocks %d-%d: %w", from, end, err)
+		}
+
+		logs = append(logs, chunk...)
+		progress.advance(end - from + 1)
+		from = end + 1
+	}
+	return logs, nil
+}
@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// What a chain's report and bundle were made from, embedded in both so
+// either can be audited and the run reproduced later
+type Manifest struct {
+	ChainID    string `json:"chainId"`
+	StartBlock uint64 `json:"startBlock"`
+	EndBlock   uint64 `json:"endBlock"`
+	// sha256 of the config file, empty if the run had none
+	ConfigSHA256 string `json:"configSha256,omitempty"`
+	// The config's profile the run used, if any
+	Profile string `json:"profile,omitempty"`
+	// The juimburser build, e.g. "v1.4.0 (3f2a9c1b7e0d)"
+	Version string `json:"version"`
+	// The endpoints scanned, as EndpointFingerprints
+	Endpoints   []string  `json:"endpoints"`
+	GeneratedAt time.Time `json:"generatedAt"`
+}
+
+// The manifest of res, scanned with the given config hash and build
+func NewManifest(res *Result, configSHA256, version string, generatedAt time.Time) *Manifest {
+	m := &Manifest{
+		ChainID:      res.Chain.ChainID.String(),
+		StartBlock:   res.StartBlock.Uint64(),
+		EndBlock:     res.EndBlock.Uint64(),
+		ConfigSHA256: configSHA256,
+		Version:      version,
+		Endpoints:    []string{},
+		GeneratedAt:  generatedAt.UTC(),
+	}
+	if res.Chain.EtherscanAPI != "" {
+		m.Endpoints = append(m.Endpoints, EndpointFingerprint(res.Chain.EtherscanAPI))
+	} else {
+		for _, u := range res.Chain.RPCURLs {
+			m.Endpoints = append(m.Endpoints, EndpointFingerprint(u))
+		}
+	}
+	if res.Chain.SubgraphURL != "" {
+		m.Endpoints = append(m.Endpoints, EndpointFingerprint(res.Chain.SubgraphURL))
+	}
+	return m
+}
+
+// When results were generated: their manifests' time, or now for results
+// without manifests (as in a dry run or comparison)
+func GeneratedAt(results []*Result) time.Time {
+	for _, res := range results {
+		if res.Manifest != nil {
+			return res.Manifest.GeneratedAt
+		}
+	}
+	return time.Now().UTC()
+}
+
+// Identifies an endpoint without revealing an API key in its URL: its host
+// and the start of the whole URL's sha256, e.g. eth.llamarpc.com#5b1e09c2
+func EndpointFingerprint(endpoint string) string {
+	host := "endpoint"
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	sum := sha256.Sum256([]byte(endpoint))
+	return fmt.Sprintf("%s#%x", host, sum[:4])
+}
+
+// The manifest on one line, for reports
+func (m *Manifest) String() string {
+	s := "juimburser " + m.Version
+	if m.ConfigSHA256 != "" {
+		s += ", config sha256 " + m.ConfigSHA256
+	}
+	if m.Profile != "" {
+		s += ", profile " + m.Profile
+	}
+	if len(m.Endpoints) > 0 {
+		s += ", endpoints " + strings.Join(m.Endpoints, ", ")
+	}
+	return s + ", generated " + m.GeneratedAt.Format(time.RFC3339)
+}
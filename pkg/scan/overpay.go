@@ -0,0 +1,78 @@
+package scan
+
+import (
+	"math/big"
+)
+
+// What tx paid per gas on top of its block's base fee, nil before EIP-1559
+func (tx TxInfo) TipPerGas() *big.Int {
+	if tx.BaseFee == nil || tx.EffectiveGasPrice == nil {
+		return nil
+	}
+	tip := new(big.Int).Sub(tx.EffectiveGasPrice, tx.BaseFee)
+	if tip.Sign() < 0 {
+		return new(big.Int)
+	}
+	return tip
+}
+
+// Whether tx tipped more per gas than its block's base fee, so most of what
+// the DAO is asked to cover went to the block builder rather than getting it
+// included
+func (tx TxInfo) ExcessiveTip() bool {
+	tip := tx.TipPerGas()
+	return tip != nil && tip.Cmp(tx.BaseFee) > 0
+}
+
+// The transactions with an excessive tip, in chain order
+func (r *Result) ExcessiveTips() []TxInfo {
+	var txs []TxInfo
+	for _, tx := range r.Txs {
+		if tx.ExcessiveTip() {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// The priority fees paid as basis points of the base fees, i.e. how much
+// tipping added to what inclusion cost. ok is false if there are no base
+// fees to compare against.
+func (r *Result) TipOverhead() (bps uint64, ok bool) {
+	baseFee, tip := r.FeeTotals()
+	if baseFee == nil || baseFee.Sign() == 0 {
+		return 0, false
+	}
+	overhead := new(big.Int).Mul(tip, big.NewInt(int64(FullRate)))
+	return overhead.Div(overhead, baseFee).Uint64(), true
+}
+
+// The highest gas price reimbursed in a block with baseFee: MaxGasPrice, or
+// the base fee plus MaxTip when tips are capped and that's lower. nil for no
+// cap.
+func (c *Chain) priceCap(baseFee *big.Int) *big.Int {
+	max := c.MaxGasPrice
+	if c.CapTips && c.MaxTip != nil && baseFee != nil {
+		if tipped := new(big.Int).Add(baseFee, c.MaxTip); max == nil || tipped.Cmp(max) < 0 {
+			max = tipped
+		}
+	}
+	return max
+}
+
+// Whether tx paid more priority fee per gas than the chain allows
+func (c *Chain) OverMaxTip(tx TxInfo) bool {
+	tip := tx.TipPerGas()
+	return c.MaxTip != nil && tip != nil && tip.Cmp(c.MaxTip) > 0
+}
+
+// The transactions over the chain's tip limit, in chain order
+func (r *Result) OverMaxTip() []TxInfo {
+	var txs []TxInfo
+	for _, tx := range r.Txs {
+		if r.Chain.OverMaxTip(tx) {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// getOwners()
+var getOwnersSelector = common.FromHex("0xa0e67e2b")
+
+// The owners of a Safe as of the given block
+func SafeOwners(ctx context.Context, client Client, safe common.Address, block *big.Int) ([]common.Address, error) {
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &safe, Data: getOwnersSelector}, block)
+	if err != nil {
+		return nil, fmt.Errorf("reading owners of %s: %w", safe.Hex(), err)
+	}
+	// (address[]): the array's offset, its length, then one word per owner
+	if len(out) < 64 || new(big.Int).SetBytes(out[:32]).Cmp(big.NewInt(32)) != 0 {
+		return nil, fmt.Errorf("unexpected getOwners() response from %s: %x", safe.Hex(), out)
+	}
+	n := new(big.Int).SetBytes(out[32:64])
+	if !n.IsUint64() || n.Uint64() != uint64(len(out)-64)/32 || len(out)%32 != 0 {
+		return nil, fmt.Errorf("unexpected getOwners() response from %s: %x", safe.Hex(), out)
+	}
+	owners := make([]common.Address, n.Uint64())
+	for i := range owners {
+		owners[i] = common.BytesToAddress(out[64+32*i : 96+32*i])
+	}
+	return owners, nil
+}
+
+// Moves transactions whose sender isn't an owner of their Safe (the one that
+// executed them, or the chain's) as of the end block, and isn't in
+// chain.Allowed, into Excluded to be reviewed by hand. Returns how many moved.
+func excludeNonOwners(ctx context.Context, client Client, res *Result) (int, error) {
+	owners := make(map[common.Address]map[common.Address]bool)
+	safeOf := func(tx TxInfo) *common.Address {
+		if tx.Safe != nil {
+			return tx.Safe
+		}
+		return res.Chain.Safe
+	}
+	for _, tx := range res.Txs {
+		safe := safeOf(tx)
+		if safe == nil || owners[*safe] != nil {
+			continue
+		}
+		list, err := SafeOwners(ctx, client, *safe, res.EndBlock)
+		if err != nil {
+			return 0, err
+		}
+		owners[*safe] = make(map[common.Address]bool)
+		for _, owner := range list {
+			owners[*safe][owner] = true
+		}
+	}
+
+	return res.Exclude(func(tx TxInfo) (string, bool) {
+		if res.Chain.Allowed[tx.From] {
+			return "", false
+		}
+		safe := safeOf(tx)
+		if safe == nil {
+			return "sender isn't on the allowlist and there's no Safe to check owners of; review manually", true
+		}
+		if owners[*safe][tx.From] {
+			return "", false
+		}
+		return fmt.Sprintf("sender isn't an owner of %s as of block %s; review manually", res.Chain.Labels.Name(*safe), res.EndBlock), true
+	}), nil
+}
@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type PayoutToken struct {
+	Symbol   string
+	Address  common.Address
+	Decimals uint8
+}
+
+// How recipients are paid
+type Payout struct {
+	// nil for native ETH transfers
+	Token *PayoutToken
+	// Token units per ETH, nil for ETH
+	Rate *big.Float
+	// Where Rate came from, e.g. "price at block 19000000"
+	RateSource string
+	// ETH is paid into this Juicebox terminal with each recipient as the
+	// beneficiary rather than transferred directly, if set
+	Terminal *JuiceboxTerminal
+	// Recipients claim from this contract with Merkle proofs, the bundle
+	// funding it with the total, rather than being paid directly, if set
+	Claim *ClaimContract
+	// The token is streamed to each recipient through Sablier rather than
+	// transferred at once, if set
+	Stream *SablierStream
+	// Everything is routed through this 0xSplits split, split by each
+	// recipient's share, if set
+	Split *SplitContract
+}
+
+// A Merkle claim contract recipients pull their reimbursements from
+type ClaimContract struct {
+	Address common.Address
+	// The Merkle root of the claims, set once the bundle is built
+	Root common.Hash
+}
+
+// Converts a wei amount to the payout token's base units, rounding down
+func (p Payout) Amount(wei *big.Int) *big.Int {
+	if p.Token == nil {
+		return new(big.Int).Set(wei)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(p.Token.Decimals)), nil)
+	amount := new(big.Float).SetPrec(256).SetInt(wei)
+	amount.Mul(amount, p.Rate)
+	amount.Mul(amount, new(big.Float).SetInt(scale))
+	amount.Quo(amount, new(big.Float).SetInt(big.NewInt(1e18)))
+
+	out, _ := amount.Int(nil)
+	return out
+}
+
+// Formats Rate, in dollars for USDC
+func (p Payout) FormatRate() string {
+	if p.Token.Symbol == "USDC" {
+		return FormatUSD(p.Rate)
+	}
+	return p.Rate.Text('f', 2) + " " + p.Token.Symbol
+}
+
+// Formats an amount in the payout token's base units
+func (p Payout) Format(amount *big.Int) string {
+	if p.Token == nil {
+		return FormatEther(amount) + " ETH"
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(p.Token.Decimals)), nil)
+	f := new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(scale))
+	return f.Text('f', int(p.Token.Decimals)) + " " + p.Token.Symbol
+}
@@ -0,0 +1,169 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// How long a classifier process has to exit once its stdin is closed before
+// it's killed
+const classifierExitTimeout = 5 * time.Second
+
+// A classifier run as an external process, so one can be written in any
+// language without building juimburser. The command is started for the
+// first transaction and sent one JSON request per line on stdin, each
+// answered with one JSON line on stdout: a Classification, or {"error": ...}
+// if it can't classify the transaction. Its stderr goes to ours.
+type ProcessClassifier struct {
+	name    string
+	command []string
+
+	// Held for each request, so they're answered in order
+	mu  sync.Mutex
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+	// Set once the process has stopped, and returned for every later request
+	err error
+}
+
+func NewProcessClassifier(name string, command []string) *ProcessClassifier {
+	return &ProcessClassifier{name: name, command: command}
+}
+
+func (p *ProcessClassifier) Name() string {
+	return p.name
+}
+
+// What a classifier process is sent for each transaction
+type classifyRequest struct {
+	Chain   string      `json:"chain"`
+	ChainID string      `json:"chainId"`
+	Hash    common.Hash `json:"hash"`
+	// Who's reimbursed for it so far: its sender, or the funder of the
+	// UserOperation or payer of the relay it was
+	From    common.Address `json:"from"`
+	Label   string         `json:"label"`
+	Rate    uint64         `json:"rate"`
+	Block   uint64         `json:"block"`
+	CostWei string         `json:"costWei"`
+	// null for reverted and traced calls found without one
+	Log *types.Log `json:"log"`
+	// The eth_getTransactionReceipt response, as the node returned it
+	Receipt json.RawMessage `json:"receipt"`
+}
+
+func (p *ProcessClassifier) Classify(ctx context.Context, in ClassifyInput) (Classification, error) {
+	req, err := json.Marshal(classifyRequest{
+		Chain:   in.Chain.Name,
+		ChainID: in.Chain.ChainID.String(),
+		Hash:    in.Tx.Hash,
+		From:    in.Tx.From,
+		Label:   in.Tx.Label,
+		Rate:    in.Rate,
+		Block:   in.Tx.BlockNumber,
+		CostWei: in.Tx.GasWei.String(),
+		Log:     in.Log,
+		Receipt: in.Receipt.raw,
+	})
+	if err != nil {
+		return Classification{}, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return Classification{}, err
+	}
+	if err := p.start(); err != nil {
+		return Classification{}, err
+	}
+	if _, err := p.in.Write(append(req, '\n')); err != nil {
+		return Classification{}, p.fail(err)
+	}
+	line, err := p.out.ReadBytes('\n')
+	if err != nil {
+		return Classification{}, p.fail(err)
+	}
+
+	var resp struct {
+		Classification
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return Classification{}, fmt.Errorf("invalid response %q: %w", line, err)
+	}
+	if resp.Error != "" {
+		return Classification{}, errors.New(resp.Error)
+	}
+	return resp.Classification, nil
+}
+
+// Starts the process if it isn't running yet
+func (p *ProcessClassifier) start() error {
+	if p.err != nil || p.cmd != nil {
+		return p.err
+	}
+	if len(p.command) == 0 {
+		p.err = errors.New("no command")
+		return p.err
+	}
+	cmd := exec.Command(p.command[0], p.command[1:]...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		p.err = err
+		return err
+	}
+	p.cmd, p.in, p.out = cmd, in, bufio.NewReader(out)
+	return nil
+}
+
+// Stops the process after a request to it failed, returning why
+func (p *ProcessClassifier) fail(err error) error {
+	// Its exit status says more than a closed pipe
+	if exitErr := p.stop(); exitErr != nil {
+		err = exitErr
+	}
+	p.err = fmt.Errorf("process stopped: %w", err)
+	return p.err
+}
+
+// Closes the process's stdin, which should make it exit, and waits for it,
+// killing it if it takes longer than classifierExitTimeout
+func (p *ProcessClassifier) stop() error {
+	p.in.Close()
+	timer := time.AfterFunc(classifierExitTimeout, func() { p.cmd.Process.Kill() })
+	defer timer.Stop()
+	return p.cmd.Wait()
+}
+
+// Stops the process if it was started. Later requests fail.
+func (p *ProcessClassifier) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil || p.err != nil {
+		return nil
+	}
+	err := p.stop()
+	p.err = errors.New("closed")
+	return err
+}
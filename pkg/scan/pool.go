@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Something a scan couldn't fetch even after retrying, so its result is
+// missing it
+type ScanError struct {
+	// The transaction, or the zero hash for a block walked for reverted calls
+	Hash        common.Hash
+	Label       string
+	BlockNumber uint64
+	Err         error
+}
+
+// Runs fn for items 0 to n-1 with up to workers at once. Items that fail are
+// retried after all the others, in up to retries more passes. Returns the
+// last error of each item that never succeeded; a canceled ctx stops early
+// and is returned instead.
+func forEach(ctx context.Context, n, workers, retries int, fn func(ctx context.Context, i int) error) (map[int]error, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	todo := make([]int, n)
+	for i := range todo {
+		todo[i] = i
+	}
+
+	failed := make(map[int]error)
+	for pass := 0; pass <= retries && len(todo) > 0; pass++ {
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		jobs := make(chan int)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					err := fn(ctx, i)
+					mu.Lock()
+					if err != nil {
+						failed[i] = err
+					} else {
+						delete(failed, i)
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+
+	feed:
+		for _, i := range todo {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		todo = todo[:0]
+		for i := 0; i < n; i++ {
+			if _, ok := failed[i]; ok {
+				todo = append(todo, i)
+			}
+		}
+	}
+	return failed, nil
+}
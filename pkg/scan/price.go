@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A source of historical ETH/USD prices
+type PriceSource interface {
+	// USD per ETH as of the given block
+	ETHUSD(ctx context.Context, blockNumber uint64, blockTime time.Time) (*big.Float, error)
+}
+
+const (
+	PriceSourceAuto      = "auto"
+	PriceSourceChainlink = "chainlink"
+	PriceSourceCoinGecko = "coingecko"
+)
+
+// Default Chainlink ETH/USD feeds by chain ID
+var DefaultChainlinkFeeds = map[uint64]string{
+	1:        "0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419",
+	10:       "0x13e3Ee699D1909E989722E753853AE30b17e08c5",
+	8453:     "0x71041dddad3595F9CEd3DcCFBe3D1F4b0a16Bb70",
+	42161:    "0x639Fe6ab55C921f74e7fac1ee960C0B6293ba612",
+	11155111: "0x694AA1769357215DE4FAC081bf1f309aDC325306",
+}
+
+var (
+	// latestRoundData()
+	latestRoundDataSelector = common.FromHex("0xfeaf968c")
+	// decimals()
+	decimalsSelector = common.FromHex("0x313ce567")
+)
+
+// Reads a Chainlink aggregator at historical blocks. Requires an archive node
+// for blocks older than the provider's pruning window.
+type ChainlinkFeed struct {
+	client Client
+	feed   common.Address
+
+	mu       sync.Mutex
+	decimals *uint8
+	cache    map[uint64]*big.Float
+}
+
+func NewChainlinkFeed(client Client, feed common.Address) *ChainlinkFeed {
+	return &ChainlinkFeed{
+		client: client,
+		feed:   feed,
+		cache:  make(map[uint64]*big.Float),
+	}
+}
+
+func (f *ChainlinkFeed) ETHUSD(ctx context.Context, blockNumber uint64, _ time.Time) (*big.Float, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if price, ok := f.cache[blockNumber]; ok {
+		return price, nil
+	}
+
+	block := new(big.Int).SetUint64(blockNumber)
+	if f.decimals == nil {
+		out, err := f.client.CallContract(ctx, ethereum.CallMsg{To: &f.feed, Data: decimalsSelector}, block)
+		if err != nil {
+			return nil, fmt.Errorf("reading decimals from feed %s: %w", f.feed.Hex(), err)
+		}
+		if len(out) != 32 {
+			return nil, fmt.Errorf("unexpected decimals() response from feed %s: %x", f.feed.Hex(), out)
+		}
+		decimals := out[31]
+		f.decimals = &decimals
+	}
+
+	out, err := f.client.CallContract(ctx, ethereum.CallMsg{To: &f.feed, Data: latestRoundDataSelector}, block)
+	if err != nil {
+		return nil, fmt.Errorf("reading feed %s at block %d: %w", f.feed.Hex(), blockNumber, err)
+	}
+	// (uint80 roundId, int256 answer, uint256 startedAt, uint256 updatedAt, uint80 answeredInRound)
+	if len(out) != 5*32 {
+		return nil, fmt.Errorf("unexpected latestRoundData() response from feed %s: %x", f.feed.Hex(), out)
+	}
+	answer := new(big.Int).SetBytes(out[32:64])
+	if answer.Sign() <= 0 || out[32]&0x80 != 0 {
+		return nil, fmt.Errorf("feed %s returned a non-positive price at block %d", f.feed.Hex(), blockNumber)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(*f.decimals)), nil)
+	price := new(big.Float).Quo(new(big.Float).SetInt(answer), new(big.Float).SetInt(scale))
+	f.cache[blockNumber] = price
+	return price, nil
+}
+
+// Converts a wei amount to USD at the given ETH/USD price
+func WeiToUSD(wei *big.Int, ethUSD *big.Float) *big.Float {
+	eth := new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetInt(big.NewInt(1e18)))
+	return eth.Mul(eth, ethUSD)
+}
+
+// Formats a USD amount with cents
+func FormatUSD(usd *big.Float) string {
+	return "$" + usd.Text('f', 2)
+}
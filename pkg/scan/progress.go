@@ -0,0 +1,107 @@
+package scan
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How far a running scan has got, for reporting while it runs. A scan goes
+// through phases one after another (walking blocks, querying each group's
+// logs, fetching transactions), each counting blocks or transactions done
+// out of a total. A nil Progress counts nothing.
+type Progress struct {
+	mu      sync.Mutex
+	phase   string
+	unit    string
+	started time.Time
+	done    uint64
+	total   uint64
+
+	logs  atomic.Int64
+	calls atomic.Int64
+}
+
+// What a Progress has counted so far
+type ProgressSnapshot struct {
+	// Empty until the scan starts its first phase
+	Phase string
+	// "blocks" or "txs"
+	Unit        string
+	Done, Total uint64
+	// Matching logs found, and RPC requests sent (including retries), so far
+	// in the whole scan
+	Logs     int64
+	RPCCalls int64
+	// How long the phase has taken, and how much longer it should take at
+	// its rate so far; zero until some of it is done
+	Elapsed time.Duration
+	ETA     time.Duration
+}
+
+func NewProgress() *Progress {
+	return &Progress{}
+}
+
+// Starts a phase of total units
+func (p *Progress) start(phase, unit string, total uint64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phase, p.unit, p.started, p.done, p.total = phase, unit, time.Now(), 0, total
+}
+
+func (p *Progress) advance(n uint64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = min(p.done+n, p.total)
+}
+
+func (p *Progress) addLogs(n int) {
+	if p != nil {
+		p.logs.Add(int64(n))
+	}
+}
+
+func (p *Progress) Snapshot() ProgressSnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := ProgressSnapshot{
+		Phase:    p.phase,
+		Unit:     p.unit,
+		Done:     p.done,
+		Total:    p.total,
+		Logs:     p.logs.Load(),
+		RPCCalls: p.calls.Load(),
+	}
+	if p.phase != "" {
+		s.Elapsed = time.Since(p.started)
+	}
+	if p.done > 0 {
+		s.ETA = time.Duration(float64(s.Elapsed) * float64(p.total-p.done) / float64(p.done))
+	}
+	return s
+}
+
+type progressKey struct{}
+
+// Requests made with the returned context are counted as p's RPC calls
+func withProgress(ctx context.Context, p *Progress) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// Counts an RPC request made with ctx, if its scan has a Progress
+func countCall(ctx context.Context) {
+	if p, ok := ctx.Value(progressKey{}).(*Progress); ok {
+		p.calls.Add(1)
+	}
+}
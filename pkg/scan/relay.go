@@ -0,0 +1,101 @@
+package scan
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Gelato's relay contracts (GelatoRelay, GelatoRelayERC2771,
+// GelatoRelay1BalanceERC2771, GelatoRelay1Balance), at the same addresses on
+// every chain
+var GelatoRelays = []common.Address{
+	common.HexToAddress("0xaBcC9b596420A9E9172FD5938620E265a0f9Df92"),
+	common.HexToAddress("0xb539068872230f20456CF38EC52EF2f91AF4AE49"),
+	common.HexToAddress("0xd8253782c45a12053594b9deB72d8e8aB2Fca54c"),
+	common.HexToAddress("0x75bA5Af8EFFDCFca32E1e288806d54277D1fde99"),
+}
+
+var (
+	// Emitted by Gelato's 1Balance relays, with the sponsor whose balance
+	// pays the relayer
+	useGelato1BalanceTopic = crypto.Keccak256Hash([]byte("LogUseGelato1Balance(address,address,address,uint256,uint256,uint256,bytes32)"))
+	// Emitted by OpenZeppelin's ERC2771Forwarder, with the request's signer
+	executedForwardRequestTopic = crypto.Keccak256Hash([]byte("ExecutedForwardRequest(address,uint256,bool)"))
+)
+
+// A transaction a relayer sent on someone else's behalf, through an ERC-2771
+// forwarder or a Gelato relay. The relayer paid the gas but was paid back by
+// someone else, so it isn't who's owed.
+type Relay struct {
+	Relayer common.Address
+	// The forwarder or relay contract it went through, zero if it was
+	// recognized by Relayer alone
+	Forwarder common.Address
+	// Who signed the forwarded request, zero if the forwarder doesn't say
+	Signer common.Address
+	// The Gelato 1Balance sponsor that paid the relayer, if any
+	Sponsor common.Address
+	// Who is reimbursed instead of the relayer: the chain's configured payer
+	// for the relayer or forwarder, or else the sponsor. Zero if nobody paying
+	// the relayer is known, in which case the transaction is excluded to be
+	// assigned by hand.
+	Payer common.Address
+}
+
+// The relay a transaction from sender went through, if it was sent to a
+// Gelato relay, an ERC2771Forwarder, or an address in relayers (a forwarder
+// or relayer mapped to who pays it, zero if unknown), or by a relayer in it
+func findRelay(receipt *Receipt, sender common.Address, relayers map[common.Address]common.Address) *Relay {
+	var to common.Address
+	if receipt.To != nil {
+		to = *receipt.To
+	}
+	relay := &Relay{Relayer: sender}
+	payer, configured := relayers[to]
+	if configured || slices.Contains(GelatoRelays, to) {
+		relay.Forwarder = to
+	}
+	for _, lg := range receipt.Logs {
+		if lg.Address != to || len(lg.Topics) < 2 {
+			continue
+		}
+		switch lg.Topics[0] {
+		case executedForwardRequestTopic:
+			relay.Forwarder, relay.Signer = to, common.BytesToAddress(lg.Topics[1].Bytes())
+		case useGelato1BalanceTopic:
+			relay.Sponsor = common.BytesToAddress(lg.Topics[1].Bytes())
+		}
+	}
+	if !configured {
+		payer, configured = relayers[sender]
+	}
+	if !configured && relay.Forwarder == (common.Address{}) {
+		return nil
+	}
+
+	relay.Payer = payer
+	if relay.Payer == (common.Address{}) {
+		relay.Payer = relay.Sponsor
+	}
+	return relay
+}
+
+// Why tx is excluded if a relayer sent it and nobody paying the relayer is
+// known
+func (c *Chain) unpaidRelay(tx TxInfo) (string, bool) {
+	r := tx.Relay
+	if r == nil || r.Payer != (common.Address{}) {
+		return "", false
+	}
+	reason := "relayed by " + c.Labels.Name(r.Relayer)
+	if r.Forwarder != (common.Address{}) {
+		reason += " through " + c.Labels.Name(r.Forwarder)
+	}
+	if r.Signer != (common.Address{}) {
+		reason += " for " + c.Labels.Name(r.Signer)
+	}
+	return fmt.Sprintf("%s; who paid the relayer isn't on-chain, so assign it manually or set a payer under relayers", reason), true
+}
@@ -0,0 +1,244 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"juimburser/pkg/metrics"
+)
+
+var (
+	rpcRequests = metrics.Default.NewCounter("juimburser_rpc_requests_total",
+		"JSON-RPC HTTP requests sent, including retries, by endpoint host and method (batch for batch requests)", "host", "method")
+	rpcErrors = metrics.Default.NewCounter("juimburser_rpc_errors_total",
+		"JSON-RPC HTTP requests that failed with a network error, 429, or 5xx response", "host")
+	matchedLogs = metrics.Default.NewCounter("juimburser_matched_logs_total",
+		"Logs matching a transaction group's filter", "chain", "group")
+)
+
+// How transient RPC failures are retried
+type RetryPolicy struct {
+	// Retries after the first attempt; 0 disables retrying
+	Retries int
+	// Backoff before the first retry, doubled for each one after up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// How long each attempt may take before it's abandoned and retried, or 0
+	// for no limit
+	Timeout time.Duration
+}
+
+// Wait before retry n (starting at 0), with full jitter
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.Backoff << n
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Dials an RPC endpoint, or several to fail over between. HTTP endpoints
+// retry transient failures (network errors, 429s, and 5xx responses): each
+// failure moves the request to the next endpoint, and once every endpoint
+// has failed it backs off according to policy before going around again.
+func Dial(ctx context.Context, urls []string, policy RetryPolicy) (Client, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no RPC URL")
+	}
+	if len(urls) == 1 && !isHTTP(urls[0]) {
+		client, err := rpc.DialContext(ctx, urls[0])
+		if err != nil {
+			return nil, err
+		}
+		return NewClient(client), nil
+	}
+
+	transport := &retryTransport{next: http.DefaultTransport, policy: policy}
+	for _, raw := range urls {
+		if !isHTTP(raw) {
+			return nil, fmt.Errorf("RPC URL %s: only http(s) endpoints can be used for failover", redactURL(raw))
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RPC URL %s: %w", redactURL(raw), err)
+		}
+		transport.endpoints = append(transport.endpoints, u)
+	}
+
+	client, err := rpc.DialOptions(ctx, urls[0], rpc.WithHTTPClient(&http.Client{Transport: transport}))
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(client), nil
+}
+
+func isHTTP(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// The host of an RPC URL, since paths and queries often contain API keys
+func redactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "<invalid>"
+}
+
+type retryTransport struct {
+	next      http.RoundTripper
+	policy    RetryPolicy
+	endpoints []*url.URL
+	// Index of the endpoint requests currently go to
+	current atomic.Int64
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Buffer the body so it can be resent
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	method := rpcMethod(body)
+	retries := 0
+	// Failures since the last backoff
+	failed := 0
+	for {
+		index := int(t.current.Load())
+		endpoint := t.endpoints[index]
+
+		attemptCtx, cancel := req.Context(), context.CancelFunc(func() {})
+		if t.policy.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(req.Context(), t.policy.Timeout)
+		}
+		attemptReq := req.Clone(attemptCtx)
+		attemptReq.URL = endpoint
+		attemptReq.Host = endpoint.Host
+		if body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		started := time.Now()
+		resp, err := t.next.RoundTrip(attemptReq)
+		rpcRequests.Inc(endpoint.Host, method)
+		countCall(req.Context())
+		logRequest(endpoint.Host, method, len(body), time.Since(started), resp, err)
+		retry, reason := retryable(resp, err)
+		if err != nil && attemptCtx.Err() != nil && req.Context().Err() == nil {
+			retry, reason = true, fmt.Sprintf("no response within %s", t.policy.Timeout)
+		}
+		if retry {
+			rpcErrors.Inc(endpoint.Host)
+		}
+		failed++
+		if !retry || req.Context().Err() != nil || (failed >= len(t.endpoints) && retries >= t.policy.Retries) {
+			if resp == nil {
+				cancel()
+				return resp, err
+			}
+			// The attempt's deadline covers reading the body too
+			resp.Body = cancelOnClose{resp.Body, cancel}
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		if len(t.endpoints) > 1 {
+			next := (index + 1) % len(t.endpoints)
+			// Another request may have already failed over
+			if t.current.CompareAndSwap(int64(index), int64(next)) {
+				slog.Warn("RPC request failed, failing over", "host", endpoint.Host, "reason", reason, "to", t.endpoints[next].Host)
+			}
+		}
+		if failed < len(t.endpoints) {
+			continue
+		}
+
+		wait := t.policy.delay(retries)
+		retries++
+		failed = 0
+		slog.Warn("RPC request failed, retrying", "host", endpoint.Host, "reason", reason, "wait", wait.Round(time.Millisecond), "retry", retries, "of", t.policy.Retries)
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// Logs an RPC request at debug level
+func logRequest(host, method string, size int, took time.Duration, resp *http.Response, err error) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []any{"host", host, "method", method, "bytes", size, "duration", took.Round(time.Microsecond)}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	} else {
+		attrs = append(attrs, "status", resp.StatusCode)
+	}
+	slog.Debug("RPC request", attrs...)
+}
+
+// The method of a JSON-RPC request body, or "batch" for a batch
+func rpcMethod(body []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		return "batch"
+	}
+	var req struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Method == "" {
+		return "unknown"
+	}
+	return req.Method
+}
+
+// Whether a response or error looks transient, and why
+func retryable(resp *http.Response, err error) (bool, string) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false, ""
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return true, err.Error()
+		}
+		return false, ""
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return true, fmt.Sprintf("HTTP %s", resp.Status)
+	}
+	return false, ""
+}
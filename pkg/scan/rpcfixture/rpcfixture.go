@@ -0,0 +1,353 @@
+// Package rpcfixture records a node's answers to a run's RPC calls and
+// replays them, so the run can be repeated without the node:
+//
+//	recorder := rpcfixture.Record(client, "rpc/mainnet.json")
+//	// ... scan with recorder, then Close it to write the fixture
+//
+//	fixture, err := rpcfixture.Load("rpc/mainnet.json")
+//	replayer := rpcfixture.Replay(fixture)
+package rpcfixture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"juimburser/pkg/scan"
+)
+
+// A node's answers to the calls of a run, recorded with Record and served
+// back with Replay, so the run can be repeated byte for byte without the node
+// (e.g. an anvil fork pinned to a historical block). Calls are keyed by their
+// method and arguments, so the order they're made in doesn't matter.
+type Fixture struct {
+	Calls map[string]Answer `json:"calls"`
+}
+
+// What a call returned: its raw JSON result, or the JSON-RPC error it failed
+// with. Other failures (timeouts and the like) aren't recorded.
+type Answer struct {
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    *AnswerError    `json:"error,omitempty"`
+	NotFound bool            `json:"notFound,omitempty"`
+}
+
+// A recorded JSON-RPC error, replayed as an rpc.Error with the same code
+type AnswerError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *AnswerError) Error() string  { return e.Message }
+func (e *AnswerError) ErrorCode() int { return e.Code }
+
+// Reads a fixture written by Save
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{Calls: make(map[string]Answer)}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("parsing fixture %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Writes the fixture with its calls in key order, so re-recording a run only
+// changes the calls that changed
+func (f *Fixture) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// The key of a call: its method and its arguments as JSON
+func callKey(method string, args ...any) (string, error) {
+	if args == nil {
+		args = []any{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("%s: encoding arguments: %w", method, err)
+	}
+	return method + " " + string(data), nil
+}
+
+// Block numbers as eth_getBlockByNumber takes them
+func blockArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
+
+// Records next's answers as it's used, adding them to the fixture at path on
+// Close (so several clients for the same chain can record into one file)
+func Record(next scan.Client, path string) *Recorder {
+	return &Recorder{next: next, path: path, fixture: &Fixture{Calls: make(map[string]Answer)}}
+}
+
+type Recorder struct {
+	next scan.Client
+	path string
+
+	mu      sync.Mutex
+	fixture *Fixture
+}
+
+// Records err if it's an answer from the node rather than a failure to get one
+func (r *Recorder) record(key string, result any, err error) error {
+	var answer Answer
+	var rpcErr rpc.Error
+	switch {
+	case errors.Is(err, ethereum.NotFound):
+		answer.NotFound = true
+	case errors.As(err, &rpcErr):
+		answer.Error = &AnswerError{Code: rpcErr.ErrorCode(), Message: rpcErr.Error()}
+	case err != nil:
+		return err
+	default:
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			return fmt.Errorf("recording %s: %w", key, marshalErr)
+		}
+		answer.Result = data
+	}
+	r.mu.Lock()
+	r.fixture.Calls[key] = answer
+	r.mu.Unlock()
+	return err
+}
+
+func (r *Recorder) ChainID(ctx context.Context) (*big.Int, error) {
+	id, err := r.next.ChainID(ctx)
+	key, _ := callKey("eth_chainId")
+	return id, r.record(key, (*hexutil.Big)(id), err)
+}
+
+func (r *Recorder) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	header, err := r.next.HeaderByNumber(ctx, number)
+	key, _ := callKey("eth_getBlockByNumber", blockArg(number), false)
+	return header, r.record(key, header, err)
+}
+
+func (r *Recorder) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	key, err := callKey("eth_getLogs", query)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := r.next.FilterLogs(ctx, query)
+	return logs, r.record(key, logs, err)
+}
+
+func (r *Recorder) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, pending, err := r.next.TransactionByHash(ctx, hash)
+	key, _ := callKey("eth_getTransactionByHash", hash)
+	return tx, pending, r.record(key, tx, err)
+}
+
+func (r *Recorder) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	sender, err := r.next.TransactionSender(ctx, tx, block, index)
+	key, _ := callKey("sender", tx.Hash(), block, index)
+	return sender, r.record(key, sender, err)
+}
+
+func (r *Recorder) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	key, err := callKey("eth_call", msg, blockArg(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.next.CallContract(ctx, msg, blockNumber)
+	return out, r.record(key, hexutil.Bytes(out), err)
+}
+
+// Takes the raw result and decodes it into result itself, the way the rpc
+// package does, so what's recorded is exactly what the node sent
+func (r *Recorder) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	key, err := callKey(method, args...)
+	if err != nil {
+		return err
+	}
+	var raw json.RawMessage
+	if err := r.record(key, &raw, r.next.CallContext(ctx, &raw, method, args...)); err != nil {
+		return err
+	}
+	return decodeResult(raw, result)
+}
+
+// Available when next can batch, as nodes dialed with scan.Dial can
+func (r *Recorder) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	batcher, ok := r.next.(interface {
+		BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error
+	})
+	if !ok {
+		return fmt.Errorf("the recorded client can't batch calls")
+	}
+	raws := make([]json.RawMessage, len(batch))
+	inner := make([]rpc.BatchElem, len(batch))
+	for i, elem := range batch {
+		inner[i] = rpc.BatchElem{Method: elem.Method, Args: elem.Args, Result: &raws[i]}
+	}
+	if err := batcher.BatchCallContext(ctx, inner); err != nil {
+		return err
+	}
+	for i, elem := range inner {
+		key, err := callKey(elem.Method, elem.Args...)
+		if err != nil {
+			return err
+		}
+		if batch[i].Error = r.record(key, &raws[i], elem.Error); batch[i].Error == nil {
+			batch[i].Error = decodeResult(raws[i], batch[i].Result)
+		}
+	}
+	return nil
+}
+
+// Closes next and adds what was recorded to the fixture file
+func (r *Recorder) Close() {
+	r.next.Close()
+	if err := r.save(); err != nil {
+		// Close can't fail, so the recording is lost; say so rather than
+		// leaving a fixture that's silently missing calls
+		fmt.Fprintf(os.Stderr, "recording %s: %v\n", r.path, err)
+	}
+}
+
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fixture, err := Load(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		fixture, err = &Fixture{Calls: make(map[string]Answer)}, nil
+	}
+	if err != nil {
+		return err
+	}
+	for key, answer := range r.fixture.Calls {
+		fixture.Calls[key] = answer
+	}
+	return fixture.Save(r.path)
+}
+
+// A client answering from the fixture. A call it has no answer for fails,
+// naming the call, so a replayed run can't quietly differ from the recording.
+func Replay(f *Fixture) *Replayer {
+	return &Replayer{fixture: f}
+}
+
+type Replayer struct {
+	fixture *Fixture
+}
+
+// Decodes key's answer into result, or returns the error it failed with
+func (r *Replayer) answer(key string, result any) error {
+	answer, ok := r.fixture.Calls[key]
+	switch {
+	case !ok:
+		return fmt.Errorf("no recorded answer for %s", key)
+	case answer.NotFound:
+		return ethereum.NotFound
+	case answer.Error != nil:
+		return answer.Error
+	}
+	return decodeResult(answer.Result, result)
+}
+
+func decodeResult(raw json.RawMessage, result any) error {
+	if result == nil || len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+func (r *Replayer) ChainID(ctx context.Context) (*big.Int, error) {
+	key, _ := callKey("eth_chainId")
+	var id hexutil.Big
+	if err := r.answer(key, &id); err != nil {
+		return nil, err
+	}
+	return id.ToInt(), nil
+}
+
+func (r *Replayer) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	key, _ := callKey("eth_getBlockByNumber", blockArg(number), false)
+	var header *types.Header
+	if err := r.answer(key, &header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func (r *Replayer) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	key, err := callKey("eth_getLogs", query)
+	if err != nil {
+		return nil, err
+	}
+	var logs []types.Log
+	if err := r.answer(key, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *Replayer) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	key, _ := callKey("eth_getTransactionByHash", hash)
+	var tx *types.Transaction
+	if err := r.answer(key, &tx); err != nil {
+		return nil, false, err
+	}
+	return tx, false, nil
+}
+
+func (r *Replayer) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	key, _ := callKey("sender", tx.Hash(), block, index)
+	var sender common.Address
+	err := r.answer(key, &sender)
+	return sender, err
+}
+
+func (r *Replayer) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	key, err := callKey("eth_call", msg, blockArg(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	var out hexutil.Bytes
+	if err := r.answer(key, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *Replayer) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	key, err := callKey(method, args...)
+	if err != nil {
+		return err
+	}
+	return r.answer(key, result)
+}
+
+func (r *Replayer) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	for i, elem := range batch {
+		key, err := callKey(elem.Method, elem.Args...)
+		if err != nil {
+			return err
+		}
+		batch[i].Error = r.answer(key, elem.Result)
+	}
+	return nil
+}
+
+func (r *Replayer) Close() {}
@@ -0,0 +1,192 @@
+package scan
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// What a rule does to the transactions it matches
+type RuleAction string
+
+const (
+	// Keep the transaction without checking later rules
+	RuleInclude RuleAction = "include"
+	RuleExclude RuleAction = "exclude"
+	// Reimburse at most the rule's Cap
+	RuleCap RuleAction = "cap"
+)
+
+// The variables a rule's expression can use, each set per transaction. Ones
+// a transaction doesn't have (base_fee_gwei before EIP-1559, safe, module)
+// are None.
+var RuleVars = []string{
+	"hash", "sender", "label", "chain", "chain_id", "block", "time",
+	"gas_used", "gas_price_gwei", "base_fee_gwei", "tip_gwei",
+	"cost_wei", "cost_eth", "value_wei", "value_eth", "failed", "safe", "module",
+	"user_op", "relayed",
+}
+
+// Most steps one evaluation may take, so a pathological expression can't
+// stall a run
+const ruleMaxSteps = 100000
+
+// A config-defined policy: a Starlark expression over a transaction that
+// decides whether Action applies to it. The first rule matching a
+// transaction is the one applied.
+type Rule struct {
+	Name   string
+	Action RuleAction
+	// In wei, for RuleCap
+	Cap *big.Int
+	// Why RuleExclude excludes, for reports
+	Reason string
+	fn     *starlark.Function
+}
+
+// Compiles expr, a Starlark expression using RuleVars, into a rule
+func CompileRule(name, expr string, action RuleAction, cap *big.Int, reason string) (Rule, error) {
+	opts := &syntax.FileOptions{}
+	// Parsed on its own first, so the expression can't reach outside the
+	// lambda it's wrapped in below
+	if _, err := opts.ParseExpr(name, expr, 0); err != nil {
+		return Rule{}, err
+	}
+	src := fmt.Sprintf("lambda %s: (%s)", strings.Join(RuleVars, ", "), expr)
+	thread := &starlark.Thread{Name: name}
+	lambda, err := starlark.ExprFuncOptions(opts, name, src, nil)
+	var unresolved resolve.ErrorList
+	if errors.As(err, &unresolved) {
+		// Its position is in the wrapped source, so only the message helps
+		return Rule{}, errors.New(unresolved[0].Msg)
+	}
+	if err != nil {
+		return Rule{}, err
+	}
+	v, err := starlark.Call(thread, lambda, nil, nil)
+	if err != nil {
+		return Rule{}, err
+	}
+	return Rule{Name: name, Action: action, Cap: cap, Reason: reason, fn: v.(*starlark.Function)}, nil
+}
+
+// Whether the rule applies to tx on chain
+func (r Rule) Matches(chain *Chain, tx TxInfo) (bool, error) {
+	thread := &starlark.Thread{Name: r.Name}
+	thread.SetMaxExecutionSteps(ruleMaxSteps)
+	v, err := starlark.Call(thread, r.fn, ruleArgs(chain, tx), nil)
+	if err != nil {
+		return false, fmt.Errorf("rule %s on %s: %w", r.Name, tx.Hash.Hex(), err)
+	}
+	b, ok := v.(starlark.Bool)
+	if !ok {
+		return false, fmt.Errorf("rule %s on %s: got %s %s, not a bool", r.Name, tx.Hash.Hex(), v.Type(), v)
+	}
+	return bool(b), nil
+}
+
+// tx's values for RuleVars, in order
+func ruleArgs(chain *Chain, tx TxInfo) starlark.Tuple {
+	gwei := func(wei *big.Int) starlark.Value {
+		if wei == nil {
+			return starlark.None
+		}
+		f, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e9)).Float64()
+		return starlark.Float(f)
+	}
+	eth := func(wei *big.Int) starlark.Value {
+		if wei == nil {
+			return starlark.None
+		}
+		f, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e18)).Float64()
+		return starlark.Float(f)
+	}
+	bigInt := func(n *big.Int) starlark.Value {
+		if n == nil {
+			return starlark.None
+		}
+		return starlark.MakeBigInt(n)
+	}
+	address := func(addr *common.Address) starlark.Value {
+		if addr == nil {
+			return starlark.None
+		}
+		return starlark.String(addr.Hex())
+	}
+	return starlark.Tuple{
+		starlark.String(tx.Hash.Hex()),
+		starlark.String(tx.From.Hex()),
+		starlark.String(tx.Label),
+		starlark.String(chain.Name),
+		bigInt(chain.ChainID),
+		starlark.MakeUint64(tx.BlockNumber),
+		starlark.MakeInt64(tx.BlockTime.Unix()),
+		starlark.MakeUint64(tx.GasUsed),
+		gwei(tx.EffectiveGasPrice),
+		gwei(tx.BaseFee),
+		gwei(tx.TipPerGas()),
+		starlark.MakeBigInt(tx.GasWei),
+		eth(tx.GasWei),
+		bigInt(tx.Value),
+		eth(tx.Value),
+		starlark.Bool(tx.Failed),
+		address(tx.Safe),
+		address(tx.Module),
+		starlark.Bool(tx.UserOp != nil),
+		starlark.Bool(tx.Relay != nil),
+	}
+}
+
+// Applies the chain's rules to each transaction: excluding the ones an
+// exclude rule matches first, and capping the ones a cap rule does
+func (r *Result) applyRules() error {
+	if len(r.Chain.Rules) == 0 {
+		return nil
+	}
+	kept := r.Txs[:0]
+	for _, tx := range r.Txs {
+		rule, err := r.Chain.matchRule(tx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case rule == nil || rule.Action == RuleInclude:
+		case rule.Action == RuleExclude:
+			r.Excluded = append(r.Excluded, ExcludedTx{TxInfo: tx, Reason: cmp.Or(rule.Reason, "excluded by rule "+rule.Name)})
+			continue
+		case tx.GasWei.Cmp(rule.Cap) > 0:
+			if tx.ActualWei == nil {
+				tx.ActualWei = tx.GasWei
+			}
+			tx.GasWei = new(big.Int).Set(rule.Cap)
+			tx.CapRule = rule.Name
+			if tx.ETHUSD != nil {
+				tx.USD = WeiToUSD(tx.GasWei, tx.ETHUSD)
+			}
+		}
+		kept = append(kept, tx)
+	}
+	r.Txs = kept
+	return nil
+}
+
+// The first of the chain's rules matching tx, nil if none does
+func (c *Chain) matchRule(tx TxInfo) (*Rule, error) {
+	for i := range c.Rules {
+		ok, err := c.Rules[i].Matches(c, tx)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &c.Rules[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,38 @@
+package scan
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Sablier V2 LockupLinear streams reimbursements are paid through, each
+// recipient's amount vesting linearly over Duration from when the bundle
+// is executed
+type SablierStream struct {
+	// The LockupLinear contract
+	Lockup common.Address
+	// Before which nothing can be withdrawn, zero for none
+	Cliff    time.Duration
+	Duration time.Duration
+	// Whether the sender (the chain's Safe) can cancel a stream and take
+	// back what hasn't vested
+	Cancelable bool
+}
+
+func (s SablierStream) String() string {
+	out := fmt.Sprintf("Sablier LockupLinear %s over %s", s.Lockup.Hex(), formatDuration(s.Duration))
+	if s.Cliff > 0 {
+		out += fmt.Sprintf(" with a %s cliff", formatDuration(s.Cliff))
+	}
+	return out
+}
+
+// Formats whole days as e.g. "30d", and anything else as a Go duration
+func formatDuration(d time.Duration) string {
+	if d > 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -0,0 +1,1057 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Util structs
+type TxInfo struct {
+	Hash        common.Hash
+	Label       string
+	From        common.Address
+	BlockNumber uint64
+	TxIndex     uint
+	BlockTime   time.Time
+	// Wei the transaction sent, nil unless it came with the transaction or
+	// the chain has rules (which can use it)
+	Value             *big.Int
+	GasUsed           uint64
+	EffectiveGasPrice *big.Int
+	// The block's base fee per gas, nil before EIP-1559
+	BaseFee *big.Int
+	// Only set for transactions that carry blobs
+	BlobGasUsed  uint64
+	BlobGasPrice *big.Int
+	Cost         GasCost
+	// Total reimbursable cost
+	GasWei *big.Int
+	// What the transaction actually cost when GasWei is limited by the gas
+	// price cap (or the tip cap), nil otherwise
+	ActualWei *big.Int
+	// The gas price GasWei is at when it's limited, nil otherwise
+	CappedPrice *big.Int
+	// The config rule that capped GasWei, if one did
+	CapRule string
+	// Why a classifier said to leave it out, if one did, in which case the
+	// scan moves it to Result.Excluded
+	ClassifierExclusion string
+	// Basis points of Cost reimbursed in GasWei when its group has a rate
+	// below 100%, 0 otherwise
+	Rate uint64
+	// Set when USD pricing is enabled
+	ETHUSD *big.Float
+	USD    *big.Float
+	// The transaction reverted
+	Failed bool
+	// The Safe that executed it, if it was found by a Safe's group
+	Safe *common.Address
+	// The Safe module that executed it, if it was found by an
+	// ExecutionFromModuleSuccess event
+	Module *common.Address
+	// Set when it was an ERC-4337 UserOperation, which From (its paymaster
+	// or smart account) funded instead of the bundler that sent the
+	// transaction. Its cost is then the operation's actualGasCost.
+	UserOp *UserOp
+	// Set when a relayer sent it through an ERC-2771 forwarder or Gelato
+	// relay, in which case From is the relayer's payer if one is known
+	Relay *Relay
+	// Set when From is the beneficiary the config pays in place of the one
+	// who sent it (or whose relay paid for it), who this is
+	SentBy *common.Address
+}
+
+// A group of transactions to get, specified by addresses and event topics
+type TxGroup struct {
+	Label     string
+	Addresses []common.Address
+	Topics    [][]common.Hash
+	// Also reimburse reverted calls to Addresses, which emit no logs
+	IncludeFailed bool
+	// Also match calls into Addresses found in transaction traces, for
+	// contracts reached through intermediaries. Logs aren't queried if the
+	// group has no Topics.
+	Traces bool
+	// If set, reverted and traced calls must pass one of these as their first
+	// argument, and call one of these functions
+	CallArgs      []common.Hash
+	CallSelectors [][4]byte
+	// Finds the group's transactions in Options.Subgraph instead of with
+	// getLogs, if both are set
+	Subgraph *SubgraphQuery
+	// Set for a Safe's ExecutionSuccess group, whose transactions it executed
+	Safe *common.Address
+	// Label for transactions found by an ExecutionFromModuleSuccess event,
+	// if the group matches them
+	ModuleLabel string
+	// Share of each transaction's cost reimbursed, in basis points, or 0 for
+	// all of it
+	Rate uint64
+}
+
+// The transaction a matched log belongs to, labeled as a module execution if
+// the log is one
+func (g TxGroup) pending(lg types.Log) pendingTx {
+	p := pendingTx{log: lg, label: g.Label, safe: g.Safe, rate: g.Rate}
+	if g.ModuleLabel != "" && len(lg.Topics) > 1 && lg.Topics[0] == ExecutionFromModuleSuccessTopic {
+		module := common.BytesToAddress(lg.Topics[1].Bytes())
+		p.label, p.module = g.ModuleLabel, &module
+	}
+	return p
+}
+
+// A chain to scan, resolved from config and flags
+type Chain struct {
+	Name    string
+	ChainID *big.Int
+	// RPC endpoints in failover order
+	RPCURLs []string
+	// Etherscan-compatible API to scan through instead of RPCURLs, if set
+	EtherscanAPI string
+	// GraphQL endpoint for groups with a Subgraph query, if set
+	SubgraphURL string
+	Explorer    string
+	GasModel    GasModel
+	// Chainlink ETH/USD feed when pricing in USD. CoinGecko is used if nil.
+	PriceFeed *common.Address
+	// Set when paying out in USDC
+	USDC *common.Address
+	// Set when paying out in JBX
+	JBX *TokenPayout
+	// Set when paying through a Juicebox terminal
+	Terminal *JuiceboxTerminal
+	// Set when recipients claim from a Merkle claim contract
+	ClaimContract *common.Address
+	// Set when paying through Sablier streams
+	Stream *SablierStream
+	// Set when paying through a 0xSplits split
+	Split *SplitContract
+	// The Safe paying reimbursements, if configured
+	Safe *common.Address
+	// The Safes whose executions are reimbursed, in config order
+	Safes      []common.Address
+	MultiSend  common.Address
+	StartBlock *big.Int
+	// If nil, Confirmations behind the latest block, or the finalized block
+	// if that's nil too
+	EndBlock      *big.Int
+	Confirmations *uint64
+	Groups        []TxGroup
+	// Run in order over each transaction the groups match, to relabel it,
+	// change its rate, or exclude it
+	Classifiers []Classifier
+	Exclusions  Exclusions
+	// Config-defined policies, applied in order after Exclusions
+	Rules []Rule
+	// Names shown for addresses in reports
+	Labels Labels
+	// Most each recipient is paid per run, in wei; nil for no cap
+	RecipientCap *big.Int
+	// Smallest payout, in wei; recipients owed less are carried over to the
+	// next run instead. nil for no minimum.
+	MinPayout *big.Int
+	// Highest gas price reimbursed, in wei; nil for no cap
+	MaxGasPrice *big.Int
+	// Highest priority fee per gas a transaction should pay, in wei; those
+	// over it are flagged. nil for no limit.
+	MaxTip *big.Int
+	// Reimburse transactions over MaxTip as if they'd tipped MaxTip, rather
+	// than only flagging them
+	CapTips bool
+	// Calendar periods reports break each recipient's gas down by, if set
+	Bucket Bucket
+	// Only reimburse senders who own the Safe they went through (or the
+	// chain's) at the end block, or are in Allowed; the rest are excluded
+	// for review
+	OwnersOnly bool
+	Allowed    map[common.Address]bool
+	// ERC-2771 forwarders, relay contracts, and relayers (besides Gelato's
+	// relays, which are built in), each mapped to who pays its relayer or to
+	// zero if that's unknown
+	Relayers map[common.Address]common.Address
+	// Reimbursements to add besides gas, in config order
+	LineItems []LineItem
+	// Senders whose reimbursements are paid to another address, e.g. a
+	// bot's hot wallet to its owner's cold wallet
+	Beneficiaries map[common.Address]common.Address
+	// Senders whose payouts are divided among several addresses, e.g. a
+	// wallet several contributors share
+	SenderSplits map[common.Address][]SenderShare
+}
+
+type Result struct {
+	Chain      *Chain
+	StartBlock *big.Int
+	EndBlock   *big.Int
+	StartTime  time.Time
+	EndTime    time.Time
+	// Included transactions, in the order they were found
+	Txs []TxInfo
+	// Matching transactions left out of the reimbursement
+	Excluded []ExcludedTx
+	// How totals are paid out, ETH unless set after the scan
+	Payout Payout
+	// What couldn't be fetched; the totals are incomplete if there's anything here
+	Errors []ScanError
+	// Set after the scan when the bundle is split across several files
+	BundleFiles []BundleFile
+	// Wei owed from earlier runs whose totals were below the minimum payout,
+	// set after the scan
+	CarriedIn map[common.Address]*big.Int
+	// The chain's line items no earlier run paid, set after the scan
+	LineItems []LineItem
+	// ETH/USD at the end block, set after the scan when pricing in USD, to
+	// value totals at report time as well as at spend time
+	ReportETHUSD *big.Float
+	// Set if the scan was interrupted before it finished, in which case
+	// Errors lists what it didn't get to
+	Interrupted bool
+	// What the run was made with, set after the scan
+	Manifest *Manifest
+	// Whether the chain's Safe can afford the bundle, set after the scan
+	// when it's checked
+	Balance *BalanceCheck
+	// Recipients of plain ETH transfers that are contracts whose transfer
+	// would revert, with the revert, set after the scan when they're checked
+	RejectsETH map[common.Address]string
+	// Recipients flagged by sanctions screening, with why, set after the
+	// scan. They aren't paid; what they're owed is listed for the multisig to
+	// handle by hand.
+	Denied map[common.Address]string
+	// The raw inputs of every transaction fetched (included or excluded),
+	// in chain order, with Options.Archive
+	Archive []ArchivedTx
+}
+
+// One of the files a split bundle was written to
+type BundleFile struct {
+	Name string
+	// The recipients it pays
+	Recipients []common.Address
+}
+
+// How to scan a chain
+type Options struct {
+	// Values each transaction in USD if set
+	Prices PriceSource
+	// Senders and receipts are read from and saved to the cache if set
+	Cache *TxCache
+	// Maximum transactions (or batches of them) fetched at once
+	Concurrency int
+	// Transactions whose sender and receipt are fetched per batched JSON-RPC
+	// request, or 0 to fetch each on its own
+	BatchSize int
+	// Blocks per getLogs (and trace_filter) query, or 0 to query the whole
+	// range at once
+	LogRange uint64
+	// How groups with Traces are traced: TraceFilter (the default) or
+	// TraceDebug
+	TraceAPI string
+	// Times to retry a transaction (or block) that failed to fetch, after
+	// trying all the others
+	ItemRetries int
+	// Used for groups with a Subgraph query if set
+	Subgraph *Subgraph
+	// Updated as the scan goes if set
+	Progress *Progress
+	// Keep each transaction's raw log, receipt, and header in Result.Archive
+	Archive bool
+}
+
+// A matching log whose transaction still needs fetching
+type pendingTx struct {
+	log    types.Log
+	label  string
+	safe   *common.Address
+	module *common.Address
+	rate   uint64
+	// Already known for reverted calls found by walking blocks, and filled
+	// in for the rest when they're prefetched. value may stay nil (see
+	// fetchTxInfo).
+	from    common.Address
+	value   *big.Int
+	receipt *Receipt
+	// Found without a log (a reverted or traced call), so log.Index doesn't
+	// place it in the transaction
+	noLog bool
+}
+
+// Scans chains over a single RPC client
+type Scanner struct {
+	client Client
+	opts   Options
+}
+
+func NewScanner(client Client, opts Options) *Scanner {
+	return &Scanner{client: client, opts: opts}
+}
+
+// Finds every transaction on chain matching its groups
+func (s *Scanner) Scan(ctx context.Context, chain *Chain) (*Result, error) {
+	client, opts := s.client, s.opts
+	ctx = withProgress(ctx, opts.Progress)
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if chainID.Cmp(chain.ChainID) != 0 {
+		return nil, fmt.Errorf("%s: RPC reports chain ID %s, expected %s", chain.Name, chainID, chain.ChainID)
+	}
+
+	endBlock, err := chain.endHeader(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if chain.StartBlock.Cmp(endBlock.Number) > 0 {
+		return nil, fmt.Errorf("%s: start block %s is after the end block %s; nothing to scan", chain.Name, chain.StartBlock, endBlock.Number)
+	}
+
+	startBlock, err := client.HeaderByNumber(ctx, chain.StartBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{
+		Chain:      chain,
+		StartBlock: startBlock.Number,
+		EndBlock:   endBlock.Number,
+		StartTime:  time.Unix(int64(startBlock.Time), 0),
+		EndTime:    time.Unix(int64(endBlock.Time), 0),
+	}
+
+	failed, blockErrs, err := findFailedCalls(ctx, client, res, chain.Groups, opts)
+	if err != nil {
+		if interrupted(ctx) {
+			return res.interrupt(chain.Groups), nil
+		}
+		return nil, err
+	}
+	res.Errors = blockErrs
+
+	traced, traceErrs, err := findTracedCalls(ctx, client, res, chain.Groups, opts)
+	if err != nil {
+		if interrupted(ctx) {
+			return res.interrupt(chain.Groups), nil
+		}
+		return nil, err
+	}
+	res.Errors = append(res.Errors, traceErrs...)
+
+	// Collect the matching transactions in order, then fetch them concurrently
+	var pending []pendingTx
+	includedTxs := make(map[common.Hash]bool)
+	for i, txGroup := range chain.Groups {
+		query := ethereum.FilterQuery{
+			FromBlock: res.StartBlock,
+			ToBlock:   res.EndBlock,
+			Addresses: txGroup.Addresses,
+			Topics:    txGroup.Topics,
+		}
+
+		var matched []pendingTx
+		if txGroup.Subgraph != nil && opts.Subgraph != nil {
+			var errs []ScanError
+			if matched, errs, err = subgraphTxs(ctx, client, opts.Subgraph, txGroup, query, res, opts); err != nil {
+				if interrupted(ctx) {
+					return res.interrupt(chain.Groups), nil
+				}
+				return nil, err
+			}
+			res.Errors = append(res.Errors, errs...)
+		} else if len(txGroup.Topics) > 0 || !txGroup.Traces {
+			opts.Progress.start(fmt.Sprintf("logs (%s)", txGroup.Label), "blocks", res.EndBlock.Uint64()-res.StartBlock.Uint64()+1)
+			logs, err := filterLogsChunked(ctx, client, query, opts.LogRange, opts.Progress)
+			if err != nil {
+				if interrupted(ctx) {
+					return res.interrupt(chain.Groups), nil
+				}
+				return nil, err
+			}
+			for _, lg := range logs {
+				// Logs the node has since reorged out
+				if lg.Removed {
+					continue
+				}
+				matched = append(matched, txGroup.pending(lg))
+			}
+		}
+		matchedLogs.Add(float64(len(matched)), chain.Name, txGroup.Label)
+		opts.Progress.addLogs(len(matched))
+
+		for _, p := range matched {
+			// If we've already seen this transaction, skip it
+			if includedTxs[p.log.TxHash] {
+				continue
+			}
+			pending = append(pending, p)
+			includedTxs[p.log.TxHash] = true
+		}
+
+		for _, p := range append(failed[i], traced[i]...) {
+			if includedTxs[p.log.TxHash] {
+				continue
+			}
+			pending = append(pending, p)
+			includedTxs[p.log.TxHash] = true
+		}
+	}
+
+	txs, archive, errs, err := fetchTxInfos(ctx, client, chain, pending, opts)
+	if err != nil {
+		return nil, err
+	}
+	sortArchive(archive)
+	res.Archive = archive
+	res.Errors = append(res.Errors, errs...)
+	res.Interrupted = interrupted(ctx)
+	// Groups are queried one after another, so put everything in chain order
+	sort.SliceStable(txs, func(i, j int) bool {
+		if txs[i].BlockNumber != txs[j].BlockNumber {
+			return txs[i].BlockNumber < txs[j].BlockNumber
+		}
+		return txs[i].TxIndex < txs[j].TxIndex
+	})
+	res.Txs = txs
+	res.Exclude(chain.Exclusions.Reason)
+	res.Exclude(chain.selfSent)
+	res.Exclude(chain.unpaidRelay)
+	res.Exclude(classifiedOut)
+	if err := res.applyRules(); err != nil {
+		return nil, err
+	}
+	if chain.OwnersOnly {
+		// Even a partial result shouldn't list non-owners as owed
+		if res.Interrupted {
+			ctx = context.WithoutCancel(ctx)
+		}
+		if _, err := excludeNonOwners(ctx, client, res); err != nil {
+			return nil, err
+		}
+	}
+	res.payBeneficiaries()
+
+	return res, nil
+}
+
+var errInterrupted = errors.New("interrupted before it was fetched")
+
+// Whether ctx was canceled, as it is when the run is interrupted, rather
+// than timing out
+func interrupted(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// Ends a scan interrupted before its transactions were fetched, with an
+// error for each group in place of its transactions
+func (r *Result) interrupt(groups []TxGroup) *Result {
+	r.Interrupted = true
+	for _, g := range groups {
+		r.Errors = append(r.Errors, ScanError{Label: g.Label, BlockNumber: r.StartBlock.Uint64(), Err: fmt.Errorf("interrupted before blocks %s-%s were scanned", r.StartBlock, r.EndBlock)})
+	}
+	return r
+}
+
+// Fetches and values pending transactions with up to opts.Concurrency in
+// flight, keeping their order. Transactions that still fail after
+// opts.ItemRetries more attempts are returned as errors instead, as are
+// those not fetched before an interrupt. With opts.Archive, also returns the
+// raw inputs of those fetched.
+func fetchTxInfos(ctx context.Context, client Client, chain *Chain, pending []pendingTx, opts Options) ([]TxInfo, []ArchivedTx, []ScanError, error) {
+	opts.Progress.start("transactions", "txs", uint64(len(pending)))
+	if err := prefetchTxs(ctx, client, chain.ChainID, pending, opts); err != nil {
+		return nil, nil, nil, err
+	}
+
+	infos := make([]TxInfo, len(pending))
+	var archive []ArchivedTx
+	if opts.Archive {
+		archive = make([]ArchivedTx, len(pending))
+	}
+	headers := newBlockHeaders(client, chain.ChainID, opts.Cache)
+	if err := headers.prefetch(ctx, pending, opts); err != nil {
+		return nil, nil, nil, err
+	}
+	failed, err := forEach(ctx, len(pending), opts.Concurrency, opts.ItemRetries, func(ctx context.Context, i int) error {
+		// Those prefetched are already counted
+		prefetched := pending[i].receipt != nil
+		var archived *ArchivedTx
+		if archive != nil {
+			archived = &archive[i]
+		}
+		info, err := fetchTxInfo(ctx, client, chain, pending[i], headers, archived, opts)
+		if err != nil {
+			return err
+		}
+		infos[i] = info
+		if !prefetched {
+			opts.Progress.advance(1)
+		}
+		return nil
+	})
+	if err != nil {
+		if !interrupted(ctx) {
+			return nil, nil, nil, err
+		}
+		// Keep what was fetched before the interrupt
+		failed = make(map[int]error)
+		for i, info := range infos {
+			if info.Hash == (common.Hash{}) {
+				failed[i] = errInterrupted
+			}
+		}
+	}
+
+	txs := make([]TxInfo, 0, len(pending))
+	var fetched []ArchivedTx
+	var errs []ScanError
+	for i, p := range pending {
+		if err, ok := failed[i]; ok {
+			errs = append(errs, ScanError{Hash: p.log.TxHash, Label: p.label, BlockNumber: p.log.BlockNumber, Err: err})
+			continue
+		}
+		txs = append(txs, infos[i])
+		if archive != nil {
+			fetched = append(fetched, archive[i])
+		}
+	}
+	return txs, fetched, errs, nil
+}
+
+// Fetches and values p, filling in archived (if it isn't nil) with its raw
+// inputs
+func fetchTxInfo(ctx context.Context, client Client, chain *Chain, p pendingTx, headers *blockHeaders, archived *ArchivedTx, opts Options) (TxInfo, error) {
+	lg := p.log
+	from, value, receipt := p.from, p.value, p.receipt
+	if receipt == nil {
+		var err error
+		if from, value, receipt, err = fetchTx(ctx, client, chain.ChainID, lg, opts.Cache); err != nil {
+			return TxInfo{}, err
+		}
+	}
+	// Only rules use the value, so it's only fetched separately for them
+	// when block receipts (or a cache entry from before values were cached)
+	// left it out
+	if value == nil && len(chain.Rules) > 0 {
+		var err error
+		if value, err = fetchValue(ctx, client, chain.ChainID, lg.TxHash, from, receipt, opts.Cache); err != nil {
+			return TxInfo{}, err
+		}
+	}
+
+	header, err := headers.Get(ctx, lg.BlockNumber, lg.BlockHash)
+	if err != nil {
+		return TxInfo{}, err
+	}
+	if archived != nil {
+		*archived = newArchivedTx(p, from, receipt, header)
+	}
+
+	// get the actual gas used
+	cost, err := chain.GasModel.Cost(receipt)
+	if err != nil {
+		return TxInfo{}, err
+	}
+	cost.splitFees(receipt.EffectiveGasPrice, header.baseFee)
+
+	info := TxInfo{
+		Hash:              lg.TxHash,
+		Label:             p.label,
+		From:              from,
+		BlockNumber:       lg.BlockNumber,
+		TxIndex:           lg.TxIndex,
+		BlockTime:         header.time,
+		Value:             value,
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: receipt.EffectiveGasPrice,
+		BaseFee:           header.baseFee,
+		BlobGasUsed:       receipt.BlobGasUsed,
+		BlobGasPrice:      receipt.BlobGasPrice,
+		Cost:              cost,
+		GasWei:            cost.Total(),
+		Failed:            receipt.Status == types.ReceiptStatusFailed,
+		Safe:              p.safe,
+		Module:            p.module,
+	}
+
+	if op := findUserOp(receipt, lg.Index, !p.noLog); op != nil {
+		// The bundler is refunded, so the funder is who's reimbursed
+		op.Bundler = from
+		price := op.GasPrice()
+		info.From = op.Funder()
+		info.UserOp = op
+		info.GasUsed = op.ActualGasUsed
+		info.EffectiveGasPrice = price
+		info.Cost = GasCost{ExecutionWei: new(big.Int).Set(op.ActualGasCost)}
+		if maxPrice := chain.priceCap(header.baseFee); maxPrice != nil && price.Cmp(maxPrice) > 0 {
+			info.ActualWei = info.Cost.Total()
+			info.CappedPrice = maxPrice
+			info.Cost.ExecutionWei = new(big.Int).Mul(new(big.Int).SetUint64(op.ActualGasUsed), maxPrice)
+			price = maxPrice
+		}
+		info.Cost.splitFees(price, header.baseFee)
+		info.GasWei = info.Cost.Total()
+	} else if maxPrice := chain.priceCap(header.baseFee); maxPrice != nil && receipt.EffectiveGasPrice.Cmp(maxPrice) > 0 {
+		capped, err := chain.GasModel.CappedCost(receipt, maxPrice)
+		if err != nil {
+			return TxInfo{}, err
+		}
+		capped.splitFees(maxPrice, header.baseFee)
+		info.ActualWei = info.GasWei
+		info.CappedPrice = maxPrice
+		info.Cost = capped
+		info.GasWei = capped.Total()
+	}
+	if info.UserOp == nil {
+		if relay := findRelay(receipt, from, chain.Relayers); relay != nil {
+			info.Relay = relay
+			if relay.Payer != (common.Address{}) {
+				info.From = relay.Payer
+			}
+		}
+	}
+	rate, err := chain.classify(ctx, p, receipt, &info)
+	if err != nil {
+		return TxInfo{}, err
+	}
+	if rate != 0 && rate != FullRate {
+		info.Rate = rate
+		info.GasWei = ApplyRate(info.GasWei, rate)
+	}
+
+	if opts.Prices != nil {
+		price, err := opts.Prices.ETHUSD(ctx, lg.BlockNumber, header.time)
+		if err != nil {
+			return TxInfo{}, err
+		}
+		info.ETHUSD = price
+		info.USD = WeiToUSD(info.GasWei, price)
+	}
+
+	return info, nil
+}
+
+// A rate reimbursing all of a transaction's cost, in basis points
+const FullRate = 10000
+
+// wei scaled by a rate in basis points, rounded down
+func ApplyRate(wei *big.Int, rate uint64) *big.Int {
+	scaled := new(big.Int).Mul(wei, new(big.Int).SetUint64(rate))
+	return scaled.Quo(scaled, big.NewInt(FullRate))
+}
+
+// The parts of a block header a transaction's cost depends on
+type blockHeader struct {
+	time    time.Time
+	baseFee *big.Int
+}
+
+// Block headers, fetched once per block and shared between workers. With a
+// cache, they're also kept between runs.
+type blockHeaders struct {
+	client  Client
+	chainID *big.Int
+	cache   *TxCache
+
+	mu      sync.Mutex
+	headers map[common.Hash]blockHeader
+}
+
+func newBlockHeaders(client Client, chainID *big.Int, cache *TxCache) *blockHeaders {
+	return &blockHeaders{client: client, chainID: chainID, cache: cache, headers: make(map[common.Hash]blockHeader)}
+}
+
+// The header of block number, which has hash
+func (b *blockHeaders) Get(ctx context.Context, number uint64, hash common.Hash) (blockHeader, error) {
+	if h, ok := b.cached(hash); ok {
+		return h, nil
+	}
+
+	header, err := b.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return blockHeader{}, err
+	}
+	h := blockHeader{time: time.Unix(int64(header.Time), 0), baseFee: header.BaseFee}
+	return h, b.put(hash, h)
+}
+
+func (b *blockHeaders) cached(hash common.Hash) (blockHeader, bool) {
+	b.mu.Lock()
+	h, ok := b.headers[hash]
+	b.mu.Unlock()
+	if ok || b.cache == nil || hash == (common.Hash{}) {
+		return h, ok
+	}
+	if h, ok = b.cache.getHeader(b.chainID, hash); ok {
+		b.mu.Lock()
+		b.headers[hash] = h
+		b.mu.Unlock()
+	}
+	return h, ok
+}
+
+func (b *blockHeaders) put(hash common.Hash, h blockHeader) error {
+	b.mu.Lock()
+	b.headers[hash] = h
+	b.mu.Unlock()
+	if b.cache == nil || hash == (common.Hash{}) {
+		return nil
+	}
+	return b.cache.putHeader(b.chainID, hash, h)
+}
+
+// The header of the block the scan ends at
+func (c *Chain) endHeader(ctx context.Context, client Client) (*types.Header, error) {
+	switch {
+	case c.EndBlock != nil:
+		return client.HeaderByNumber(ctx, c.EndBlock)
+	case c.Confirmations != nil:
+		latest, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		confirmations := new(big.Int).SetUint64(*c.Confirmations)
+		if latest.Number.Cmp(confirmations) < 0 {
+			return nil, fmt.Errorf("%s: the latest block %s has fewer than %s confirmations", c.Name, latest.Number, confirmations)
+		}
+		return client.HeaderByNumber(ctx, latest.Number.Sub(latest.Number, confirmations))
+	}
+	header, err := client.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: getting the finalized block (set confirmations for nodes that don't have it): %w", c.Name, err)
+	}
+	return header, nil
+}
+
+// Gets a log's transaction sender, value, and receipt, from cache if
+// possible
+func fetchTx(ctx context.Context, client Client, chainID *big.Int, lg types.Log, cache *TxCache) (common.Address, *big.Int, *Receipt, error) {
+	if cache != nil {
+		if from, value, receipt, ok := cache.Get(chainID, lg.TxHash, lg.BlockHash); ok {
+			return from, value, receipt, nil
+		}
+	}
+
+	tx, _, err := client.TransactionByHash(ctx, lg.TxHash)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+
+	from, err := client.TransactionSender(ctx, tx, lg.BlockHash, lg.Index)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+
+	receipt, err := fetchReceipt(ctx, client, lg.TxHash)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	// Reorged since its log was found, so the log no longer exists
+	if receipt.BlockHash != lg.BlockHash {
+		return common.Address{}, nil, nil, fmt.Errorf("transaction %s was reorged out of block %d (%s)", lg.TxHash.Hex(), lg.BlockNumber, lg.BlockHash.Hex())
+	}
+
+	if cache != nil {
+		if err := cache.Put(chainID, lg.TxHash, from, tx.Value(), receipt); err != nil {
+			return common.Address{}, nil, nil, err
+		}
+	}
+	return from, tx.Value(), receipt, nil
+}
+
+// Gets the value of a transaction whose sender and receipt are already
+// known, caching it with them
+func fetchValue(ctx context.Context, client Client, chainID *big.Int, hash common.Hash, from common.Address, receipt *Receipt, cache *TxCache) (*big.Int, error) {
+	tx, _, err := client.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		if err := cache.Put(chainID, hash, from, tx.Value(), receipt); err != nil {
+			return nil, err
+		}
+	}
+	return tx.Value(), nil
+}
+
+// Sums the base fee and tip components of every transaction's execution
+// cost. Both are nil if any transaction predates EIP-1559.
+func (r *Result) FeeTotals() (baseFee, tip *big.Int) {
+	baseFee, tip = new(big.Int), new(big.Int)
+	for _, tx := range r.Txs {
+		if tx.Cost.BaseFeeWei == nil {
+			return nil, nil
+		}
+		baseFee.Add(baseFee, tx.Cost.BaseFeeWei)
+		tip.Add(tip, tx.Cost.TipWei)
+	}
+	return baseFee, tip
+}
+
+// The transactions one Safe executed and what they cost
+type SafeTotal struct {
+	Safe   common.Address
+	Txs    int
+	GasWei *big.Int
+}
+
+// Totals for each of the chain's Safes, in config order, and for the
+// transactions none of them executed. nil if the chain tracks no Safes.
+func (r *Result) SafeTotals() (safes []SafeTotal, other SafeTotal) {
+	if len(r.Chain.Safes) == 0 {
+		return nil, SafeTotal{}
+	}
+	index := make(map[common.Address]int)
+	for i, addr := range r.Chain.Safes {
+		index[addr] = i
+		safes = append(safes, SafeTotal{Safe: addr, GasWei: big.NewInt(0)})
+	}
+	other.GasWei = big.NewInt(0)
+	for _, tx := range r.Txs {
+		total := &other
+		if tx.Safe != nil {
+			if i, ok := index[*tx.Safe]; ok {
+				total = &safes[i]
+			}
+		}
+		total.Txs++
+		total.GasWei.Add(total.GasWei, tx.GasWei)
+	}
+	return safes, other
+}
+
+// The transactions of one type (group label) and what they cost
+type TypeTotal struct {
+	Label  string
+	Txs    int
+	GasWei *big.Int
+	// Basis points of the cost reimbursed, 0 for all of it
+	Rate uint64
+}
+
+// Totals for each transaction type found, in the order of the chain's groups
+func (r *Result) TypeTotals() []TypeTotal {
+	totals := make(map[string]*TypeTotal)
+	for _, tx := range r.Txs {
+		t, ok := totals[tx.Label]
+		if !ok {
+			t = &TypeTotal{Label: tx.Label, GasWei: big.NewInt(0), Rate: tx.Rate}
+			totals[tx.Label] = t
+		}
+		t.Txs++
+		t.GasWei.Add(t.GasWei, tx.GasWei)
+	}
+
+	var labels []string
+	for _, g := range r.Chain.Groups {
+		labels = append(labels, g.Label, g.ModuleLabel)
+	}
+	var rest []string
+	for label := range totals {
+		if !slices.Contains(labels, label) {
+			rest = append(rest, label)
+		}
+	}
+	slices.Sort(rest)
+
+	var out []TypeTotal
+	for _, label := range append(labels, rest...) {
+		if t, ok := totals[label]; ok {
+			out = append(out, *t)
+			delete(totals, label)
+		}
+	}
+	return out
+}
+
+// The keys of an address-keyed map in ascending order, so output built from
+// it is the same on every run
+func SortedAddresses[V any](m map[common.Address]V) []common.Address {
+	addrs := make([]common.Address, 0, len(m))
+	for a := range m {
+		addrs = append(addrs, a)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i].Bytes(), addrs[j].Bytes()) < 0
+	})
+	return addrs
+}
+
+// The keys of an address-keyed amount map from the largest amount down, ties
+// in address order
+func SortedByAmount(m map[common.Address]*big.Int) []common.Address {
+	addrs := SortedAddresses(m)
+	sort.SliceStable(addrs, func(i, j int) bool {
+		return m[addrs[i]].Cmp(m[addrs[j]]) > 0
+	})
+	return addrs
+}
+
+// Sums gas costs per sender
+func (r *Result) Totals() map[common.Address]*big.Int {
+	totals := make(map[common.Address]*big.Int)
+	for _, v := range r.Txs {
+		if totals[v.From] == nil {
+			totals[v.From] = big.NewInt(0)
+		}
+		totals[v.From] = new(big.Int).Add(totals[v.From], v.GasWei)
+	}
+	return totals
+}
+
+// Each sender's total plus anything carried in from earlier runs and their
+// line items
+func (r *Result) Owed() map[common.Address]*big.Int {
+	owed := r.Totals()
+	for _, extra := range []map[common.Address]*big.Int{r.CarriedIn, r.LineItemTotals()} {
+		for k, v := range extra {
+			if owed[k] == nil {
+				owed[k] = big.NewInt(0)
+			}
+			owed[k].Add(owed[k], v)
+		}
+	}
+	return owed
+}
+
+// What the bundle pays: each amount owed limited to the chain's recipient
+// cap, or zero if it's below the minimum payout or the recipient is denied.
+// A split sender's payout goes to its shares' addresses instead (less any
+// denied ones), leaving it zero.
+func (r *Result) Payable() map[common.Address]*big.Int {
+	payable := r.senderPayable()
+	for _, split := range r.SplitPayouts() {
+		payable[split.Sender] = big.NewInt(0)
+		for i, s := range split.Shares {
+			if _, denied := r.Denied[s.To]; denied {
+				continue
+			}
+			if payable[s.To] == nil {
+				payable[s.To] = big.NewInt(0)
+			}
+			payable[s.To].Add(payable[s.To], split.Wei[i])
+		}
+	}
+	return payable
+}
+
+// Payable before split senders' payouts are divided
+func (r *Result) senderPayable() map[common.Address]*big.Int {
+	payable := r.Owed()
+	for k, v := range payable {
+		if _, denied := r.Denied[k]; denied {
+			payable[k] = big.NewInt(0)
+			continue
+		}
+		if cap := r.Chain.RecipientCap; cap != nil && v.Cmp(cap) > 0 {
+			payable[k] = new(big.Int).Set(cap)
+		}
+		if min := r.Chain.MinPayout; min != nil && v.Cmp(min) < 0 {
+			payable[k] = big.NewInt(0)
+		}
+	}
+	return payable
+}
+
+// How far each sender over the recipient cap exceeds it, held back for review
+func (r *Result) OverCap() map[common.Address]*big.Int {
+	over := make(map[common.Address]*big.Int)
+	if cap := r.Chain.RecipientCap; cap != nil {
+		for k, v := range r.Owed() {
+			if _, denied := r.Denied[k]; !denied && v.Cmp(cap) > 0 {
+				over[k] = new(big.Int).Sub(v, cap)
+			}
+		}
+	}
+	return over
+}
+
+// The amounts owed below the minimum payout, carried over to the next run
+func (r *Result) CarriedOver() map[common.Address]*big.Int {
+	carried := make(map[common.Address]*big.Int)
+	if min := r.Chain.MinPayout; min != nil {
+		for k, v := range r.Owed() {
+			if _, denied := r.Denied[k]; !denied && v.Sign() > 0 && v.Cmp(min) < 0 {
+				carried[k] = v
+			}
+		}
+	}
+	return carried
+}
+
+// What each denied recipient is owed, held back for manual handling,
+// including their shares of split senders' payouts
+func (r *Result) DeniedOwed() map[common.Address]*big.Int {
+	held := make(map[common.Address]*big.Int)
+	owed := r.Owed()
+	for k := range r.Denied {
+		if v := owed[k]; v != nil && v.Sign() > 0 {
+			held[k] = new(big.Int).Set(v)
+		}
+	}
+	for _, split := range r.SplitPayouts() {
+		for i, s := range split.Shares {
+			if _, denied := r.Denied[s.To]; denied && split.Wei[i].Sign() > 0 {
+				if held[s.To] == nil {
+					held[s.To] = big.NewInt(0)
+				}
+				held[s.To].Add(held[s.To], split.Wei[i])
+			}
+		}
+	}
+	return held
+}
+
+// Sums USD values per sender, or returns nil if transactions weren't priced
+func (r *Result) USDTotals() map[common.Address]*big.Float {
+	totals := make(map[common.Address]*big.Float)
+	for _, v := range r.Txs {
+		if v.USD == nil {
+			return nil
+		}
+		if totals[v.From] == nil {
+			totals[v.From] = new(big.Float)
+		}
+		totals[v.From] = new(big.Float).Add(totals[v.From], v.USD)
+	}
+	return totals
+}
+
+// Each sender's total valued at ReportETHUSD rather than at each
+// transaction's block, or nil if it isn't set
+func (r *Result) ReportUSDTotals() map[common.Address]*big.Float {
+	if r.ReportETHUSD == nil {
+		return nil
+	}
+	totals := make(map[common.Address]*big.Float)
+	for addr, wei := range r.Totals() {
+		totals[addr] = WeiToUSD(wei, r.ReportETHUSD)
+	}
+	return totals
+}
+
+// Formats a wei amount as ETH
+func FormatEther(wei *big.Int) string {
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetInt(big.NewInt(1e18))).String()
+}
+
+// Formats a wei amount as gwei
+func FormatGwei(wei *big.Int) string {
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetInt(big.NewInt(1e9))).String()
+}
+
+// A rate in basis points as a percentage, e.g. 50% or 12.5%
+func FormatRate(bps uint64) string {
+	return new(big.Float).Quo(new(big.Float).SetUint64(bps), big.NewFloat(100)).String() + "%"
+}
@@ -0,0 +1,298 @@
+package scan_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"juimburser/pkg/scan"
+	"juimburser/pkg/scan/scantest"
+)
+
+var (
+	terminal   = common.HexToAddress("0x000000000000000000000000000000000000fee1")
+	elsewhere  = common.HexToAddress("0x000000000000000000000000000000000000e15e")
+	safe       = common.HexToAddress("0x0000000000000000000000000000000000005afe")
+	alice      = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob        = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol      = common.HexToAddress("0x00000000000000000000000000000000000ca201")
+	payTopic   = crypto.Keccak256Hash([]byte("Pay()"))
+	distribute = crypto.Keccak256Hash([]byte("DistributePayouts()"))
+)
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9))
+}
+
+// The transactions every case scans
+type fixture struct {
+	chain *scantest.Chain
+	// alice pays; bob pays 1 ETH and distributes in one transaction; carol's
+	// log is from another contract; alice distributes; the Safe pays
+	pay, both, other, distribute, fromSafe common.Hash
+}
+
+func newFixture() fixture {
+	chain := scantest.NewChain(1)
+	log := func(topics ...common.Hash) []scantest.Log {
+		var logs []scantest.Log
+		for _, topic := range topics {
+			logs = append(logs, scantest.Log{Address: terminal, Topics: []common.Hash{topic}})
+		}
+		return logs
+	}
+	return fixture{
+		chain:      chain,
+		pay:        chain.AddTx(scantest.Tx{Block: 100, From: alice, To: &terminal, GasUsed: 100000, GasPrice: gwei(20), Logs: log(payTopic)}),
+		both:       chain.AddTx(scantest.Tx{Block: 101, From: bob, To: &terminal, Value: gwei(1e9), GasUsed: 100000, GasPrice: gwei(30), Logs: log(payTopic, distribute)}),
+		other:      chain.AddTx(scantest.Tx{Block: 102, From: carol, To: &elsewhere, GasUsed: 100000, GasPrice: gwei(20), Logs: []scantest.Log{{Address: elsewhere, Topics: []common.Hash{payTopic}}}}),
+		distribute: chain.AddTx(scantest.Tx{Block: 103, From: alice, To: &terminal, GasUsed: 100000, GasPrice: gwei(50), Logs: log(distribute)}),
+		fromSafe:   chain.AddTx(scantest.Tx{Block: 104, From: safe, To: &terminal, GasUsed: 100000, GasPrice: gwei(20), Logs: log(payTopic)}),
+	}
+}
+
+func groups() []scan.TxGroup {
+	return []scan.TxGroup{
+		{Label: "Pay", Addresses: []common.Address{terminal}, Topics: [][]common.Hash{{payTopic}}},
+		{Label: "Distribute", Addresses: []common.Address{terminal}, Topics: [][]common.Hash{{distribute}}},
+	}
+}
+
+// What a case expects of an included transaction
+type wantTx struct {
+	label string
+	wei   *big.Int
+	// What it cost before a cap, nil if it wasn't capped
+	actual *big.Int
+	rate   uint64
+}
+
+func TestScan(t *testing.T) {
+	f := newFixture()
+	valueRule, err := scan.CompileRule("payments", "value_eth >= 1 and value_wei > 0", scan.RuleExclude, nil, "paid the project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		// Changes to a chain scanning groups() over the fixture
+		setup    func(*scan.Chain)
+		want     map[common.Hash]wantTx
+		excluded map[common.Hash]string
+	}{
+		{
+			name: "matches groups by address and topic",
+			want: map[common.Hash]wantTx{
+				f.pay:        {label: "Pay", wei: gwei(2e6)},
+				f.both:       {label: "Pay", wei: gwei(3e6)},
+				f.distribute: {label: "Distribute", wei: gwei(5e6)},
+				f.fromSafe:   {label: "Pay", wei: gwei(2e6)},
+			},
+		},
+		{
+			name: "dedups a transaction to the first group matching it",
+			setup: func(c *scan.Chain) {
+				c.Groups[0], c.Groups[1] = c.Groups[1], c.Groups[0]
+			},
+			want: map[common.Hash]wantTx{
+				f.pay:        {label: "Pay", wei: gwei(2e6)},
+				f.both:       {label: "Distribute", wei: gwei(3e6)},
+				f.distribute: {label: "Distribute", wei: gwei(5e6)},
+				f.fromSafe:   {label: "Pay", wei: gwei(2e6)},
+			},
+		},
+		{
+			name: "excludes listed transactions and senders, and the Safe",
+			setup: func(c *scan.Chain) {
+				c.Safe = &safe
+				c.Exclusions = scan.Exclusions{
+					Txs:     map[common.Hash]string{f.pay: "paid by hand"},
+					Senders: map[common.Address]string{bob: "contractor"},
+				}
+			},
+			want: map[common.Hash]wantTx{
+				f.distribute: {label: "Distribute", wei: gwei(5e6)},
+			},
+			excluded: map[common.Hash]string{
+				f.pay:      "paid by hand",
+				f.both:     "contractor",
+				f.fromSafe: "sent by the Safe " + safe.Hex() + " itself, which isn't reimbursed",
+			},
+		},
+		{
+			name: "lets rules see the value sent",
+			setup: func(c *scan.Chain) {
+				c.Rules = []scan.Rule{valueRule}
+			},
+			want: map[common.Hash]wantTx{
+				f.pay:        {label: "Pay", wei: gwei(2e6)},
+				f.distribute: {label: "Distribute", wei: gwei(5e6)},
+				f.fromSafe:   {label: "Pay", wei: gwei(2e6)},
+			},
+			excluded: map[common.Hash]string{f.both: "paid the project"},
+		},
+		{
+			name: "applies a group's rate",
+			setup: func(c *scan.Chain) {
+				c.Groups[1].Rate = 5000
+			},
+			want: map[common.Hash]wantTx{
+				f.pay:        {label: "Pay", wei: gwei(2e6)},
+				f.both:       {label: "Pay", wei: gwei(3e6)},
+				f.distribute: {label: "Distribute", wei: gwei(2.5e6), rate: 5000},
+				f.fromSafe:   {label: "Pay", wei: gwei(2e6)},
+			},
+		},
+		{
+			name: "caps the gas price",
+			setup: func(c *scan.Chain) {
+				c.MaxGasPrice = gwei(25)
+			},
+			want: map[common.Hash]wantTx{
+				f.pay:        {label: "Pay", wei: gwei(2e6)},
+				f.both:       {label: "Pay", wei: gwei(2.5e6), actual: gwei(3e6)},
+				f.distribute: {label: "Distribute", wei: gwei(2.5e6), actual: gwei(5e6)},
+				f.fromSafe:   {label: "Pay", wei: gwei(2e6)},
+			},
+		},
+		{
+			name: "caps before applying the rate",
+			setup: func(c *scan.Chain) {
+				c.MaxGasPrice = gwei(25)
+				c.Groups[1].Rate = 5000
+			},
+			want: map[common.Hash]wantTx{
+				f.pay:        {label: "Pay", wei: gwei(2e6)},
+				f.both:       {label: "Pay", wei: gwei(2.5e6), actual: gwei(3e6)},
+				f.distribute: {label: "Distribute", wei: gwei(1.25e6), actual: gwei(5e6), rate: 5000},
+				f.fromSafe:   {label: "Pay", wei: gwei(2e6)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &scan.Chain{
+				Name:       "mainnet",
+				ChainID:    big.NewInt(1),
+				GasModel:   scan.GasModelEthereum,
+				StartBlock: big.NewInt(90),
+				EndBlock:   big.NewInt(104),
+				Groups:     groups(),
+			}
+			if tt.setup != nil {
+				tt.setup(chain)
+			}
+			res, err := scan.NewScanner(f.chain, scan.Options{}).Scan(context.Background(), chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Errors) > 0 {
+				t.Fatalf("scan errors: %v", res.Errors)
+			}
+
+			if len(res.Txs) != len(tt.want) {
+				t.Errorf("got %d transactions, want %d", len(res.Txs), len(tt.want))
+			}
+			for i, tx := range res.Txs {
+				if i > 0 && res.Txs[i-1].BlockNumber > tx.BlockNumber {
+					t.Errorf("%s is out of chain order", tx.Hash.Hex())
+				}
+				want, ok := tt.want[tx.Hash]
+				if !ok {
+					t.Errorf("%s shouldn't be included", tx.Hash.Hex())
+					continue
+				}
+				if tx.Label != want.label {
+					t.Errorf("%s: label %q, want %q", tx.Hash.Hex(), tx.Label, want.label)
+				}
+				if tx.GasWei.Cmp(want.wei) != 0 {
+					t.Errorf("%s: reimbursed %s wei, want %s", tx.Hash.Hex(), tx.GasWei, want.wei)
+				}
+				if (tx.ActualWei == nil) != (want.actual == nil) || (want.actual != nil && tx.ActualWei.Cmp(want.actual) != 0) {
+					t.Errorf("%s: actual cost %v, want %v", tx.Hash.Hex(), tx.ActualWei, want.actual)
+				}
+				if tx.Rate != want.rate {
+					t.Errorf("%s: rate %d, want %d", tx.Hash.Hex(), tx.Rate, want.rate)
+				}
+			}
+
+			if len(res.Excluded) != len(tt.excluded) {
+				t.Errorf("got %d exclusions, want %d", len(res.Excluded), len(tt.excluded))
+			}
+			for _, ex := range res.Excluded {
+				if want, ok := tt.excluded[ex.Hash]; !ok {
+					t.Errorf("%s shouldn't be excluded (%s)", ex.Hash.Hex(), ex.Reason)
+				} else if ex.Reason != want {
+					t.Errorf("%s: excluded because %q, want %q", ex.Hash.Hex(), ex.Reason, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTotals(t *testing.T) {
+	txs := []scan.TxInfo{
+		{From: alice, GasWei: gwei(2e6)},
+		{From: bob, GasWei: gwei(3e6)},
+		{From: alice, GasWei: gwei(5e6)},
+		{From: carol, GasWei: gwei(1e6)},
+	}
+	tests := []struct {
+		name         string
+		recipientCap *big.Int
+		minPayout    *big.Int
+		denied       map[common.Address]string
+		totals       map[common.Address]*big.Int
+		payable      map[common.Address]*big.Int
+	}{
+		{
+			name:    "sums per sender",
+			totals:  map[common.Address]*big.Int{alice: gwei(7e6), bob: gwei(3e6), carol: gwei(1e6)},
+			payable: map[common.Address]*big.Int{alice: gwei(7e6), bob: gwei(3e6), carol: gwei(1e6)},
+		},
+		{
+			name:         "limits payouts to the recipient cap",
+			recipientCap: gwei(4e6),
+			totals:       map[common.Address]*big.Int{alice: gwei(7e6), bob: gwei(3e6), carol: gwei(1e6)},
+			payable:      map[common.Address]*big.Int{alice: gwei(4e6), bob: gwei(3e6), carol: gwei(1e6)},
+		},
+		{
+			name:      "pays nothing below the minimum payout",
+			minPayout: gwei(2e6),
+			totals:    map[common.Address]*big.Int{alice: gwei(7e6), bob: gwei(3e6), carol: gwei(1e6)},
+			payable:   map[common.Address]*big.Int{alice: gwei(7e6), bob: gwei(3e6), carol: new(big.Int)},
+		},
+		{
+			name:    "pays denied recipients nothing",
+			denied:  map[common.Address]string{bob: "sanctioned"},
+			totals:  map[common.Address]*big.Int{alice: gwei(7e6), bob: gwei(3e6), carol: gwei(1e6)},
+			payable: map[common.Address]*big.Int{alice: gwei(7e6), bob: new(big.Int), carol: gwei(1e6)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &scan.Result{
+				Chain:  &scan.Chain{ChainID: big.NewInt(1), RecipientCap: tt.recipientCap, MinPayout: tt.minPayout},
+				Txs:    txs,
+				Denied: tt.denied,
+			}
+			assertAmounts(t, "total", res.Totals(), tt.totals)
+			assertAmounts(t, "payable", res.Payable(), tt.payable)
+		})
+	}
+}
+
+func assertAmounts(t *testing.T, what string, got, want map[common.Address]*big.Int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%d %s amounts, want %d", len(got), what, len(want))
+	}
+	for addr, w := range want {
+		if got[addr] == nil || got[addr].Cmp(w) != 0 {
+			t.Errorf("%s %s: %v, want %s", addr.Hex(), what, got[addr], w)
+		}
+	}
+}
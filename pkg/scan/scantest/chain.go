@@ -0,0 +1,442 @@
+// Package scantest provides an in-memory chain implementing scan.Client, so
+// the scanner, payouts, and bundles can be exercised without a node:
+//
+//	chain := scantest.NewChain(1)
+//	hash := chain.AddTx(scantest.Tx{
+//		Block:    100,
+//		From:     alice,
+//		To:       terminal,
+//		GasUsed:  150000,
+//		GasPrice: big.NewInt(20e9),
+//		Logs:     []scantest.Log{{Address: terminal, Topics: []common.Hash{topic}}},
+//	})
+//	res, err := scan.NewScanner(chain, scan.Options{}).Scan(ctx, &scan.Chain{
+//		ChainID:    big.NewInt(1),
+//		StartBlock: big.NewInt(90),
+//		EndBlock:   big.NewInt(110),
+//		Groups:     groups,
+//	})
+package scantest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"juimburser/pkg/scan"
+)
+
+// Blocks not added explicitly are 12 seconds apart from this time
+var Genesis = time.Unix(1700000000, 0)
+
+// A transaction to add to a Chain
+type Tx struct {
+	Block uint64
+	From  common.Address
+	// Nil for a contract creation
+	To    *common.Address
+	Input []byte
+	// Wei sent, 0 if nil
+	Value *big.Int
+	// Receipt fields
+	GasUsed  uint64
+	GasPrice *big.Int
+	Reverted bool
+	Logs     []Log
+	// OP stack L1 data fee and Arbitrum L1 gas, omitted from the receipt if nil
+	L1Fee        *big.Int
+	GasUsedForL1 *uint64
+	// EIP-4844 blob gas, omitted if zero
+	BlobGasUsed  uint64
+	BlobGasPrice *big.Int
+}
+
+type Log struct {
+	Address common.Address
+	Topics  []common.Hash
+	Data    []byte
+}
+
+// An in-memory chain. Safe for concurrent use once set up.
+type Chain struct {
+	// If set, called before every method with its name and arguments; an
+	// error it returns fails the call, e.g. to simulate a flaky RPC
+	Fail func(method string, args ...any) error
+
+	chainID *big.Int
+
+	mu       sync.Mutex
+	latest   uint64
+	headers  map[uint64]*types.Header
+	txs      map[common.Hash]*chainTx
+	blocks   map[uint64][]*chainTx
+	contract map[common.Address]func(data []byte, block *big.Int) ([]byte, error)
+	calls    map[string]int
+}
+
+type chainTx struct {
+	tx      *types.Transaction
+	from    common.Address
+	index   uint
+	receipt json.RawMessage
+	logs    []types.Log
+}
+
+func NewChain(chainID uint64) *Chain {
+	return &Chain{
+		chainID:  new(big.Int).SetUint64(chainID),
+		headers:  make(map[uint64]*types.Header),
+		txs:      make(map[common.Hash]*chainTx),
+		blocks:   make(map[uint64][]*chainTx),
+		contract: make(map[common.Address]func([]byte, *big.Int) ([]byte, error)),
+		calls:    make(map[string]int),
+	}
+}
+
+// The hash of block number
+func BlockHash(number uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte("block"), binary.BigEndian.AppendUint64(nil, number))
+}
+
+// Sets a block's time and base fee, which are otherwise derived from
+// Genesis and nil. Extends the chain to it if it's past the latest block.
+func (c *Chain) AddBlock(number uint64, t time.Time, baseFee *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers[number] = &types.Header{
+		Number:  new(big.Int).SetUint64(number),
+		Time:    uint64(t.Unix()),
+		BaseFee: baseFee,
+	}
+	c.latest = max(c.latest, number)
+}
+
+// Adds a transaction at the end of its block, returning its hash. Extends
+// the chain to its block if needed.
+func (c *Chain) AddTx(t Tx) common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	gasPrice := t.GasPrice
+	if gasPrice == nil {
+		gasPrice = new(big.Int)
+	}
+	// The nonce only keeps hashes unique
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    uint64(len(c.txs)),
+		To:       t.To,
+		Gas:      t.GasUsed,
+		GasPrice: gasPrice,
+		Value:    t.Value,
+		Data:     t.Input,
+	})
+	hash, blockHash := tx.Hash(), BlockHash(t.Block)
+	index := uint(len(c.blocks[t.Block]))
+
+	var logIndex uint
+	for _, txs := range c.blocks[t.Block] {
+		logIndex += uint(len(txs.logs))
+	}
+	logs := make([]types.Log, len(t.Logs))
+	for i, l := range t.Logs {
+		logs[i] = types.Log{
+			Address:     l.Address,
+			Topics:      l.Topics,
+			Data:        l.Data,
+			BlockNumber: t.Block,
+			TxHash:      hash,
+			TxIndex:     index,
+			BlockHash:   blockHash,
+			Index:       logIndex + uint(i),
+		}
+	}
+
+	status := types.ReceiptStatusSuccessful
+	receiptLogs := make([]*types.Log, len(logs))
+	for i := range logs {
+		receiptLogs[i] = &logs[i]
+	}
+	if t.Reverted {
+		status, logs, receiptLogs = types.ReceiptStatusFailed, nil, []*types.Log{}
+	}
+	receipt := &types.Receipt{
+		Status:            status,
+		CumulativeGasUsed: t.GasUsed,
+		Logs:              receiptLogs,
+		TxHash:            hash,
+		GasUsed:           t.GasUsed,
+		EffectiveGasPrice: gasPrice,
+		BlobGasUsed:       t.BlobGasUsed,
+		BlobGasPrice:      t.BlobGasPrice,
+		BlockHash:         blockHash,
+		BlockNumber:       new(big.Int).SetUint64(t.Block),
+		TransactionIndex:  index,
+	}
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+
+	entry := &chainTx{tx: tx, from: t.From, index: index, receipt: encodeReceipt(receipt, t.To, t.L1Fee, t.GasUsedForL1), logs: logs}
+	c.txs[hash] = entry
+	c.blocks[t.Block] = append(c.blocks[t.Block], entry)
+	c.latest = max(c.latest, t.Block)
+	return hash
+}
+
+// Appends the fields (to, and L2 ones) go-ethereum doesn't encode
+func encodeReceipt(receipt *types.Receipt, to *common.Address, l1Fee *big.Int, gasUsedForL1 *uint64) json.RawMessage {
+	raw, err := json.Marshal(receipt)
+	if err != nil {
+		panic(err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		panic(err)
+	}
+	fields["to"] = to
+	if l1Fee != nil {
+		fields["l1Fee"] = (*hexutil.Big)(l1Fee)
+	}
+	if gasUsedForL1 != nil {
+		fields["gasUsedForL1"] = hexutil.Uint64(*gasUsedForL1)
+	}
+	if raw, err = json.Marshal(fields); err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+// Answers eth_call to addr, e.g. a price feed, with fn
+func (c *Chain) HandleCalls(addr common.Address, fn func(data []byte, block *big.Int) ([]byte, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.contract[addr] = fn
+}
+
+// How many times method was called, by the name of the scan.Client method
+// or the JSON-RPC method for CallContext
+func (c *Chain) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[method]
+}
+
+func (c *Chain) call(method string, args ...any) error {
+	c.mu.Lock()
+	c.calls[method]++
+	c.mu.Unlock()
+	if c.Fail != nil {
+		return c.Fail(method, args...)
+	}
+	return nil
+}
+
+func (c *Chain) header(number uint64) *types.Header {
+	if h, ok := c.headers[number]; ok {
+		return types.CopyHeader(h)
+	}
+	return &types.Header{
+		Number: new(big.Int).SetUint64(number),
+		Time:   uint64(Genesis.Unix()) + 12*number,
+	}
+}
+
+func (c *Chain) ChainID(ctx context.Context) (*big.Int, error) {
+	if err := c.call("ChainID"); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(c.chainID), nil
+}
+
+func (c *Chain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if err := c.call("HeaderByNumber", number); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Tags like finalized are negative; everything here is final
+	if number == nil || number.Sign() < 0 {
+		return c.header(c.latest), nil
+	}
+	if !number.IsUint64() || number.Uint64() > c.latest {
+		return nil, ethereum.NotFound
+	}
+	return c.header(number.Uint64()), nil
+}
+
+func (c *Chain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if err := c.call("FilterLogs", query); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	from, to := uint64(0), c.latest
+	if query.BlockHash != nil {
+		return nil, errors.New("scantest: block hash queries aren't supported")
+	}
+	if query.FromBlock != nil {
+		from = query.FromBlock.Uint64()
+	}
+	if query.ToBlock != nil {
+		to = min(query.ToBlock.Uint64(), c.latest)
+	}
+
+	logs := []types.Log{}
+	for n := from; n <= to; n++ {
+		for _, tx := range c.blocks[n] {
+			for _, l := range tx.logs {
+				if matches(query, l) {
+					logs = append(logs, l)
+				}
+			}
+		}
+	}
+	return logs, nil
+}
+
+// Whether l is from one of the query's addresses and has one of the topics
+// in each position
+func matches(query ethereum.FilterQuery, l types.Log) bool {
+	if len(query.Addresses) > 0 && !slices.Contains(query.Addresses, l.Address) {
+		return false
+	}
+	if len(query.Topics) > len(l.Topics) {
+		return false
+	}
+	for i, options := range query.Topics {
+		if len(options) > 0 && !slices.Contains(options, l.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Chain) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if err := c.call("TransactionByHash", hash); err != nil {
+		return nil, false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, ok := c.txs[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx.tx, false, nil
+}
+
+func (c *Chain) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	if err := c.call("TransactionSender", tx.Hash()); err != nil {
+		return common.Address{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.txs[tx.Hash()]
+	if !ok {
+		return common.Address{}, ethereum.NotFound
+	}
+	return t.from, nil
+}
+
+func (c *Chain) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if err := c.call("CallContract", msg, blockNumber); err != nil {
+		return nil, err
+	}
+	if msg.To == nil {
+		return nil, errors.New("scantest: eth_call without a to address")
+	}
+	c.mu.Lock()
+	fn, ok := c.contract[*msg.To]
+	c.mu.Unlock()
+	if !ok {
+		// Like a call to an account without code
+		return nil, nil
+	}
+	return fn(msg.Data, blockNumber)
+}
+
+// Supports eth_getTransactionReceipt and eth_getBlockByNumber with full
+// transactions, the raw calls the scanner makes
+func (c *Chain) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	if err := c.call(method, args...); err != nil {
+		return err
+	}
+
+	var resp any
+	switch method {
+	case "eth_getTransactionReceipt":
+		hash, ok := args[0].(common.Hash)
+		if !ok {
+			return fmt.Errorf("scantest: %s takes a common.Hash, got %T", method, args[0])
+		}
+		c.mu.Lock()
+		tx, found := c.txs[hash]
+		c.mu.Unlock()
+		if found {
+			resp = tx.receipt
+		}
+
+	case "eth_getBlockByNumber":
+		number, err := hexutil.DecodeUint64(fmt.Sprint(args[0]))
+		if err != nil {
+			return fmt.Errorf("scantest: %s takes a hex block number: %w", method, err)
+		}
+		if full, _ := args[1].(bool); !full {
+			return fmt.Errorf("scantest: %s is only supported with full transactions", method)
+		}
+		resp = c.rawBlock(number)
+
+	default:
+		return fmt.Errorf("scantest: %s isn't supported", method)
+	}
+
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// A block as eth_getBlockByNumber returns it, or nil past the latest block
+func (c *Chain) rawBlock(number uint64) map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number > c.latest {
+		return nil
+	}
+
+	txs := []map[string]any{}
+	for _, tx := range c.blocks[number] {
+		txs = append(txs, map[string]any{
+			"hash":             tx.tx.Hash(),
+			"from":             tx.from,
+			"to":               tx.tx.To(),
+			"input":            hexutil.Bytes(tx.tx.Data()),
+			"value":            (*hexutil.Big)(tx.tx.Value()),
+			"transactionIndex": hexutil.Uint(tx.index),
+		})
+	}
+	header := c.header(number)
+	return map[string]any{
+		"number":        hexutil.Uint64(number),
+		"hash":          BlockHash(number),
+		"timestamp":     hexutil.Uint64(header.Time),
+		"baseFeePerGas": (*hexutil.Big)(header.BaseFee),
+		"transactions":  txs,
+	}
+}
+
+func (c *Chain) Close() {}
+
+var _ scan.Client = (*Chain)(nil)
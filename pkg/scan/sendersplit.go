@@ -0,0 +1,65 @@
+package scan
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// One address's share of a split sender's payout
+type SenderShare struct {
+	To common.Address
+	// Basis points of the sender's payout
+	Rate uint64
+}
+
+// A split sender's payout as divided among its shares
+type SplitPayout struct {
+	Sender common.Address
+	Shares []SenderShare
+	// Wei each share gets, aligned with Shares
+	Wei []*big.Int
+}
+
+// Divides wei by the shares' rates, rounding down, with what rounding leaves
+// to the first share so they add up exactly
+func splitWei(wei *big.Int, shares []SenderShare) []*big.Int {
+	parts := make([]*big.Int, len(shares))
+	left := new(big.Int).Set(wei)
+	for i, s := range shares {
+		parts[i] = new(big.Int).Mul(wei, new(big.Int).SetUint64(s.Rate))
+		parts[i].Quo(parts[i], big.NewInt(FullRate))
+		left.Sub(left, parts[i])
+	}
+	if len(parts) > 0 {
+		parts[0].Add(parts[0], left)
+	}
+	return parts
+}
+
+// How each split sender's payout (after its cap and minimum) is divided, in
+// sender order. Shares going to denied addresses are included.
+func (r *Result) SplitPayouts() []SplitPayout {
+	payable := r.senderPayable()
+	var splits []SplitPayout
+	for _, sender := range SortedAddresses(r.Chain.SenderSplits) {
+		wei := payable[sender]
+		if wei == nil || wei.Sign() == 0 {
+			continue
+		}
+		shares := r.Chain.SenderSplits[sender]
+		splits = append(splits, SplitPayout{Sender: sender, Shares: shares, Wei: splitWei(wei, shares)})
+	}
+	return splits
+}
+
+// The addresses split senders' payouts go to, for screening with the senders
+func (r *Result) SplitPayees() []common.Address {
+	payees := make(map[common.Address]bool)
+	for _, split := range r.SplitPayouts() {
+		for _, s := range split.Shares {
+			payees[s.To] = true
+		}
+	}
+	return SortedAddresses(payees)
+}
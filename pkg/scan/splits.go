@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 0xSplits' SplitMain (v1), at the same address on every chain it's on
+var SplitMainAddress = common.HexToAddress("0x2ed6c4B5dA6378c7897AC67Ba9e43102Feb694EE")
+
+// What SplitMain's percent allocations add up to
+const SplitPercentScale = 1_000_000
+
+// A 0xSplits split, controlled by the chain's Safe, that reimbursements are
+// routed through: the bundle sets its recipients to each one's share of the
+// total, funds it, and distributes it
+type SplitContract struct {
+	Address common.Address
+	Main    common.Address
+}
+
+func (s SplitContract) String() string {
+	return fmt.Sprintf("0xSplits split %s", s.Address.Hex())
+}
+
+// One recipient's share of a split
+type SplitAllocation struct {
+	Account common.Address
+	// Out of SplitPercentScale
+	Percent uint32
+}
+
+// Each recipient's share of the chain's payable totals, in address order as
+// SplitMain requires. Shares are rounded to SplitMain's precision by largest
+// remainder so they add up exactly, and none is zero (SplitMain rejects
+// those), so they can be off by a millionth of the total each. Nil unless
+// paying through a split.
+func (r *Result) SplitAllocations() []SplitAllocation {
+	if r.Payout.Split == nil {
+		return nil
+	}
+	payable := r.Payable()
+	total := big.NewInt(0)
+	var accounts []common.Address
+	for _, k := range SortedAddresses(payable) {
+		if payable[k].Sign() > 0 {
+			accounts = append(accounts, k)
+			total.Add(total, payable[k])
+		}
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	allocations := make([]SplitAllocation, len(accounts))
+	remainders := make([]*big.Int, len(accounts))
+	left := int64(SplitPercentScale)
+	for i, k := range accounts {
+		share := new(big.Int).Mul(payable[k], big.NewInt(SplitPercentScale))
+		percent, remainder := new(big.Int).QuoRem(share, total, new(big.Int))
+		allocations[i] = SplitAllocation{Account: k, Percent: uint32(percent.Int64())}
+		remainders[i] = remainder
+		left -= percent.Int64()
+	}
+	// Hand what rounding down left over to the largest remainders
+	order := make([]int, len(accounts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]].Cmp(remainders[order[b]]) > 0 })
+	for _, i := range order {
+		if left == 0 {
+			break
+		}
+		allocations[i].Percent++
+		left--
+	}
+	// Whoever rounded to zero takes a millionth from the largest share
+	for i := range allocations {
+		if allocations[i].Percent == 0 {
+			largest := 0
+			for j := range allocations {
+				if allocations[j].Percent > allocations[largest].Percent {
+					largest = j
+				}
+			}
+			allocations[largest].Percent--
+			allocations[i].Percent = 1
+		}
+	}
+	return allocations
+}
+
+// Formats a percent allocation, e.g. 12.3456%
+func FormatSplitPercent(p uint32) string {
+	return fmt.Sprintf("%d.%04d%%", p/10_000, p%10_000)
+}
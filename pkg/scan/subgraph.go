@@ -0,0 +1,251 @@
+package scan
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Entities per subgraph query, The Graph's maximum
+const subgraphPageSize = 1000
+
+// How a group's events are found in a subgraph instead of with getLogs
+type SubgraphQuery struct {
+	// The collection of event entities, e.g. distributePayoutsEvents. Each
+	// must have id, txHash, and timestamp (in seconds) fields.
+	Entity string
+	// Extra GraphQL where conditions, e.g. `project_: {handle: "juicebox"}`
+	Where string
+}
+
+var graphQLName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+func (q SubgraphQuery) Validate() error {
+	if !graphQLName.MatchString(q.Entity) {
+		return fmt.Errorf("invalid subgraph entity %q", q.Entity)
+	}
+	return nil
+}
+
+// A GraphQL endpoint indexing the events groups match, like the Juicebox
+// subgraph. It only tells the scanner which transactions to look at; their
+// logs and costs still come from the RPC.
+type Subgraph struct {
+	url    string
+	policy RetryPolicy
+	http   *http.Client
+}
+
+func NewSubgraph(url string, policy RetryPolicy) *Subgraph {
+	return &Subgraph{url: url, policy: policy, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// The transactions with an event in q between from and to inclusive, in no
+// particular order
+func (s *Subgraph) TxHashes(ctx context.Context, q SubgraphQuery, from, to time.Time) ([]common.Hash, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	where := "timestamp_gte: $from, timestamp_lte: $to, id_gt: $after"
+	if q.Where != "" {
+		where += ", " + q.Where
+	}
+	query := fmt.Sprintf(`query($from: Int!, $to: Int!, $after: String!) {
+  events: %s(first: %d, orderBy: id, orderDirection: asc, where: {%s}) { id txHash }
+}`, q.Entity, subgraphPageSize, where)
+
+	seen := make(map[common.Hash]bool)
+	var hashes []common.Hash
+	after := ""
+	for {
+		var page struct {
+			Events []struct {
+				ID     string      `json:"id"`
+				TxHash common.Hash `json:"txHash"`
+			} `json:"events"`
+		}
+		vars := map[string]any{"from": from.Unix(), "to": to.Unix(), "after": after}
+		if err := s.query(ctx, &page, query, vars); err != nil {
+			return nil, fmt.Errorf("subgraph %s: %w", q.Entity, err)
+		}
+		for _, e := range page.Events {
+			if !seen[e.TxHash] {
+				seen[e.TxHash] = true
+				hashes = append(hashes, e.TxHash)
+			}
+		}
+		if len(page.Events) < subgraphPageSize {
+			return hashes, nil
+		}
+		after = page.Events[len(page.Events)-1].ID
+	}
+}
+
+// Posts a GraphQL query, retrying transient failures
+func (s *Subgraph) query(ctx context.Context, result any, query string, vars map[string]any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	host := redactURL(s.url)
+
+	for retries := 0; ; retries++ {
+		err := s.post(ctx, result, body, host)
+		var transient *transientError
+		if !errors.As(err, &transient) || retries >= s.policy.Retries || ctx.Err() != nil {
+			return err
+		}
+		rpcErrors.Inc(host)
+
+		wait := s.policy.delay(retries)
+		slog.Warn("Subgraph request failed, retrying", "host", host, "reason", err, "wait", wait.Round(time.Millisecond), "retry", retries+1, "of", s.policy.Retries)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// A failure worth retrying
+type transientError struct{ reason string }
+
+func (e *transientError) Error() string { return e.reason }
+
+func (s *Subgraph) post(ctx context.Context, result any, body []byte, host string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	started := time.Now()
+	resp, err := s.http.Do(req)
+	rpcRequests.Inc(host, "graphql")
+	logRequest(host, "graphql", len(body), time.Since(started), resp, err)
+	if retry, reason := retryable(resp, err); retry {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return &transientError{reason}
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &transientError{err.Error()}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	var r struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if len(r.Errors) > 0 {
+		return errors.New(r.Errors[0].Message)
+	}
+	return json.Unmarshal(r.Data, result)
+}
+
+// Finds a group's transactions through the subgraph, then takes the logs
+// matching query from their receipts. Receipts that can't be fetched are
+// returned as errors.
+func subgraphTxs(ctx context.Context, client Client, sg *Subgraph, group TxGroup, query ethereum.FilterQuery, res *Result, opts Options) ([]pendingTx, []ScanError, error) {
+	hashes, err := sg.TxHashes(ctx, *group.Subgraph, res.StartTime, res.EndTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := make([]*pendingTx, len(hashes))
+	opts.Progress.start(fmt.Sprintf("subgraph (%s)", group.Label), "txs", uint64(len(hashes)))
+	failed, err := forEach(ctx, len(hashes), opts.Concurrency, opts.ItemRetries, func(ctx context.Context, i int) error {
+		receipt, err := fetchReceipt(ctx, client, hashes[i])
+		if err != nil {
+			return err
+		}
+		var sender struct {
+			From common.Address `json:"from"`
+		}
+		if err := json.Unmarshal(receipt.raw, &sender); err != nil {
+			return fmt.Errorf("decoding receipt %s: %w", hashes[i].Hex(), err)
+		}
+		for _, lg := range receipt.Logs {
+			if matchesQuery(query, lg) {
+				p := group.pending(*lg)
+				p.from, p.receipt = sender.From, receipt
+				found[i] = &p
+				break
+			}
+		}
+		opts.Progress.advance(1)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []ScanError
+	for i, err := range failed {
+		errs = append(errs, ScanError{Hash: hashes[i], Label: group.Label, Err: err})
+	}
+	slices.SortFunc(errs, func(a, b ScanError) int { return bytes.Compare(a.Hash[:], b.Hash[:]) })
+
+	var pending []pendingTx
+	for _, p := range found {
+		if p != nil {
+			pending = append(pending, *p)
+		}
+	}
+	slices.SortFunc(pending, func(a, b pendingTx) int {
+		if a.log.BlockNumber != b.log.BlockNumber {
+			return cmp.Compare(a.log.BlockNumber, b.log.BlockNumber)
+		}
+		return cmp.Compare(a.log.Index, b.log.Index)
+	})
+	return pending, errs, nil
+}
+
+// Whether a log is one getLogs would return for query
+func matchesQuery(query ethereum.FilterQuery, lg *types.Log) bool {
+	if query.FromBlock != nil && lg.BlockNumber < query.FromBlock.Uint64() {
+		return false
+	}
+	if query.ToBlock != nil && lg.BlockNumber > query.ToBlock.Uint64() {
+		return false
+	}
+	if len(query.Addresses) > 0 && !slices.Contains(query.Addresses, lg.Address) {
+		return false
+	}
+	if len(query.Topics) > len(lg.Topics) {
+		return false
+	}
+	for i, options := range query.Topics {
+		if len(options) > 0 && !slices.Contains(options, lg.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,19 @@
+package scan
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A Juicebox project terminal reimbursements are paid through, so recipients
+// are also minted the project's tokens
+type JuiceboxTerminal struct {
+	Address   common.Address
+	Version   int
+	ProjectID uint64
+}
+
+func (t JuiceboxTerminal) String() string {
+	return fmt.Sprintf("Juicebox project %d via v%d terminal %s", t.ProjectID, t.Version, t.Address.Hex())
+}
@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// topic0 of the ExecutionSuccess event a Safe emits for each transaction it
+// executes
+var ExecutionSuccessTopic = crypto.Keccak256Hash([]byte("ExecutionSuccess(bytes32,uint256)"))
+
+// topic0 of the ExecutionFromModuleSuccess event a Safe emits instead when one
+// of its modules executes a transaction. topic1 is the module.
+var ExecutionFromModuleSuccessTopic = crypto.Keccak256Hash([]byte("ExecutionFromModuleSuccess(address)"))
+
+// topic0 of the ApproveHash event a Safe emits when an owner approves a
+// transaction hash on-chain. topic2 is the owner.
+var ApproveHashTopic = crypto.Keccak256Hash([]byte("ApproveHash(bytes32,address)"))
+
+// The topic0 hash of an event signature like
+// "Transfer(address indexed from, address indexed to, uint256 value)".
+// Parameter names and indexed keywords are dropped before hashing.
+func EventTopic(signature string) (common.Hash, error) {
+	canonical, err := canonicalSignature(signature)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte(canonical)), nil
+}
+
+// The 4-byte selector of a function signature like
+// "distributePayoutsOf(uint256 projectId, uint256 amount, ...)"
+func FunctionSelector(signature string) ([4]byte, error) {
+	canonical, err := canonicalSignature(strings.TrimPrefix(strings.TrimSpace(signature), "function "))
+	if err != nil {
+		return [4]byte{}, err
+	}
+	return [4]byte(crypto.Keccak256([]byte(canonical))[:4]), nil
+}
+
+// The topic for an indexed uint value
+func UintTopic(n *big.Int) common.Hash {
+	return common.BigToHash(n)
+}
+
+// The topic for an indexed address value
+func AddressTopic(a common.Address) common.Hash {
+	return common.BytesToHash(a.Bytes())
+}
+
+// Reduces an event or function signature to name(type,type,...)
+func canonicalSignature(signature string) (string, error) {
+	signature = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(signature), "event "))
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return "", fmt.Errorf("%q is not a signature like Name(type,...)", signature)
+	}
+	name := strings.TrimSpace(signature[:open])
+	if strings.ContainsAny(name, " \t,()") {
+		return "", fmt.Errorf("%q has an invalid name", signature)
+	}
+
+	params, err := splitParams(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return "", fmt.Errorf("%q: %w", signature, err)
+	}
+	types := make([]string, len(params))
+	for i, p := range params {
+		if types[i], err = canonicalType(p); err != nil {
+			return "", fmt.Errorf("%q: %w", signature, err)
+		}
+	}
+	return name + "(" + strings.Join(types, ",") + ")", nil
+}
+
+// Splits a parameter list on top-level commas
+func splitParams(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var params []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				params = append(params, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses")
+	}
+	return append(params, list[start:]), nil
+}
+
+// The type of a single parameter, without its name or indexed keyword
+func canonicalType(param string) (string, error) {
+	param = strings.TrimSpace(param)
+	if param == "" {
+		return "", fmt.Errorf("empty parameter")
+	}
+
+	// Tuples keep any array suffix, e.g. (uint256,address)[]
+	if strings.HasPrefix(param, "(") {
+		end := strings.LastIndex(param, ")")
+		inner, err := splitParams(param[1:end])
+		if err != nil {
+			return "", err
+		}
+		types := make([]string, len(inner))
+		for i, p := range inner {
+			if types[i], err = canonicalType(p); err != nil {
+				return "", err
+			}
+		}
+		suffix := strings.Fields(param[end+1:])
+		tuple := "(" + strings.Join(types, ",") + ")"
+		if len(suffix) > 0 && strings.HasPrefix(suffix[0], "[") {
+			tuple += suffix[0]
+		}
+		return tuple, nil
+	}
+
+	typ := strings.Fields(param)[0]
+	switch {
+	case typ == "uint":
+		typ = "uint256"
+	case typ == "int":
+		typ = "int256"
+	case strings.HasPrefix(typ, "uint["):
+		typ = "uint256" + typ[4:]
+	case strings.HasPrefix(typ, "int["):
+		typ = "int256" + typ[3:]
+	}
+	return typ, nil
+}
@@ -0,0 +1,310 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// APIs traced calls are found through
+const (
+	// Parity-style trace_filter (Erigon, Nethermind, reth), queried by the
+	// groups' addresses over the whole range
+	TraceFilter = "trace_filter"
+	// geth's callTracer through debug_traceBlockByNumber, run on every block
+	// in the range
+	TraceDebug = "debug"
+)
+
+// A call as trace_filter and trace_transaction return it. Block rewards
+// have no transaction.
+type parityTrace struct {
+	Action struct {
+		To       *common.Address `json:"to"`
+		Input    hexutil.Bytes   `json:"input"`
+		CallType string          `json:"callType"`
+	} `json:"action"`
+	BlockHash           common.Hash  `json:"blockHash"`
+	BlockNumber         uint64       `json:"blockNumber"`
+	TransactionHash     *common.Hash `json:"transactionHash"`
+	TransactionPosition uint         `json:"transactionPosition"`
+	TraceAddress        []int        `json:"traceAddress"`
+	Type                string       `json:"type"`
+	Error               string       `json:"error"`
+}
+
+// A call frame from geth's callTracer
+type callFrame struct {
+	Type  string          `json:"type"`
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+	Error string          `json:"error"`
+	Calls []callFrame     `json:"calls"`
+}
+
+type blockTrace struct {
+	Result callFrame `json:"result"`
+}
+
+// Calls and delegatecalls count; static calls change nothing
+func isStateCall(callType string) bool {
+	switch strings.ToLower(callType) {
+	case "call", "delegatecall":
+		return true
+	}
+	return false
+}
+
+// Some operations reach a group's contracts through intermediaries, so emit
+// no logs from its addresses. Groups with Traces are also matched by the
+// internal (or top-level) calls into their addresses, found with
+// opts.TraceAPI. Calls that reverted, or sit under one that did, don't count.
+// Returns the matches for each group, in block order, and the blocks and
+// transactions that couldn't be traced.
+func findTracedCalls(ctx context.Context, client Client, res *Result, groups []TxGroup, opts Options) (map[int][]pendingTx, []ScanError, error) {
+	var addrs []common.Address
+	for _, g := range groups {
+		if g.Traces {
+			addrs = append(addrs, g.Addresses...)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, nil, nil
+	}
+
+	switch opts.TraceAPI {
+	case "", TraceFilter:
+		return filterTraces(ctx, client, res, groups, addrs, opts)
+	case TraceDebug:
+		return debugTraces(ctx, client, res, groups, opts)
+	default:
+		return nil, nil, fmt.Errorf("unknown trace API %q", opts.TraceAPI)
+	}
+}
+
+// Finds candidate transactions with trace_filter over the range, in windows
+// of at most opts.LogRange blocks (halving a window the node rejects as too
+// large, like filterLogsChunked), then checks each one's full trace, since
+// trace_filter doesn't say whether a matching call's parent reverted
+func filterTraces(ctx context.Context, client Client, res *Result, groups []TxGroup, addrs []common.Address, opts Options) (map[int][]pendingTx, []ScanError, error) {
+	from, to := res.StartBlock.Uint64(), res.EndBlock.Uint64()
+	window := opts.LogRange
+	if window == 0 {
+		window = to - from + 1
+	}
+
+	opts.Progress.start("traces", "blocks", to-from+1)
+	var candidates []parityTrace
+	seen := make(map[common.Hash]bool)
+	for from <= to {
+		end := from + window - 1
+		if end > to || end < from {
+			end = to
+		}
+
+		var traces []parityTrace
+		filter := map[string]any{
+			"fromBlock": hexutil.EncodeUint64(from),
+			"toBlock":   hexutil.EncodeUint64(end),
+			"toAddress": addrs,
+		}
+		if err := client.CallContext(ctx, &traces, "trace_filter", filter); err != nil {
+			if isLogLimitError(err) && end > from {
+				window = (end - from + 1) / 2
+				continue
+			}
+			return nil, nil, fmt.Errorf("tracing blocks %d-%d: %w", from, end, err)
+		}
+		for _, t := range traces {
+			if t.Type == "call" && t.TransactionHash != nil && !seen[*t.TransactionHash] {
+				seen[*t.TransactionHash] = true
+				candidates = append(candidates, t)
+			}
+		}
+		opts.Progress.advance(end - from + 1)
+		from = end + 1
+	}
+
+	matched := make([][]int, len(candidates))
+	failed, err := forEach(ctx, len(candidates), opts.Concurrency, opts.ItemRetries, func(ctx context.Context, i int) error {
+		var traces []parityTrace
+		if err := client.CallContext(ctx, &traces, "trace_transaction", *candidates[i].TransactionHash); err != nil {
+			return err
+		}
+		matched[i] = groupsCalledIn(groups, traces)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []ScanError
+	byGroup := make(map[int][]pendingTx)
+	for i, t := range candidates {
+		if err, ok := failed[i]; ok {
+			errs = append(errs, ScanError{Hash: *t.TransactionHash, Label: "traces", BlockNumber: t.BlockNumber, Err: err})
+			continue
+		}
+		for _, g := range matched[i] {
+			byGroup[g] = append(byGroup[g], pendingTx{
+				// Index is what TransactionSender looks the sender up by if
+				// the node didn't return it with the transaction
+				log: types.Log{
+					TxHash:      *t.TransactionHash,
+					TxIndex:     t.TransactionPosition,
+					Index:       t.TransactionPosition,
+					BlockNumber: t.BlockNumber,
+					BlockHash:   t.BlockHash,
+				},
+				label: groups[g].Label,
+				safe:  groups[g].Safe,
+				rate:  groups[g].Rate,
+				noLog: true,
+			})
+		}
+	}
+	return byGroup, errs, nil
+}
+
+// The groups with Traces that one of a transaction's successful calls
+// matches, given all of its traces
+func groupsCalledIn(groups []TxGroup, traces []parityTrace) []int {
+	// A call's effects are undone if it or any call above it reverted
+	reverted := make(map[string]bool)
+	for _, t := range traces {
+		if t.Error != "" {
+			reverted[fmt.Sprint(t.TraceAddress)] = true
+		}
+	}
+	succeeded := func(t parityTrace) bool {
+		for n := 0; n <= len(t.TraceAddress); n++ {
+			if reverted[fmt.Sprint(t.TraceAddress[:n])] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var matched []int
+	for i, g := range groups {
+		if !g.Traces {
+			continue
+		}
+		for _, t := range traces {
+			if t.Type == "call" && t.Action.To != nil && isStateCall(t.Action.CallType) && g.matchesCall(*t.Action.To, t.Action.Input) && succeeded(t) {
+				matched = append(matched, i)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// Traces every block in the range with callTracer, concurrently like
+// findFailedCalls
+func debugTraces(ctx context.Context, client Client, res *Result, groups []TxGroup, opts Options) (map[int][]pendingTx, []ScanError, error) {
+	start, end := res.StartBlock.Uint64(), res.EndBlock.Uint64()
+	found := make([]map[int][]pendingTx, end-start+1)
+	opts.Progress.start("traces", "blocks", uint64(len(found)))
+	failed, err := forEach(ctx, len(found), opts.Concurrency, opts.ItemRetries, func(ctx context.Context, i int) error {
+		matches, err := tracedCallsInBlock(ctx, client, res.Chain.ChainID, start+uint64(i), groups, opts.Cache)
+		if err != nil {
+			return err
+		}
+		found[i] = matches
+		opts.Progress.advance(1)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []ScanError
+	for i := range found {
+		if err, ok := failed[i]; ok {
+			errs = append(errs, ScanError{BlockNumber: start + uint64(i), Label: "traces", Err: err})
+		}
+	}
+
+	byGroup := make(map[int][]pendingTx)
+	for _, matches := range found {
+		for i, pending := range matches {
+			byGroup[i] = append(byGroup[i], pending...)
+		}
+	}
+	return byGroup, errs, nil
+}
+
+func tracedCallsInBlock(ctx context.Context, client Client, chainID *big.Int, number uint64, groups []TxGroup, cache *TxCache) (map[int][]pendingTx, error) {
+	var block rawBlock
+	if err := client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), true); err != nil {
+		return nil, err
+	}
+	if len(block.Transactions) == 0 {
+		return nil, nil
+	}
+	var traces []blockTrace
+	if err := client.CallContext(ctx, &traces, "debug_traceBlockByNumber", hexutil.EncodeUint64(number), map[string]any{"tracer": "callTracer"}); err != nil {
+		return nil, err
+	}
+	if len(traces) != len(block.Transactions) {
+		return nil, fmt.Errorf("got %d traces for %d transactions", len(traces), len(block.Transactions))
+	}
+
+	var matches map[int][]pendingTx
+	for j, trace := range traces {
+		tx := block.Transactions[j]
+		for i, g := range groups {
+			if !g.Traces || !g.calledIn(trace.Result) {
+				continue
+			}
+
+			receipt, err := fetchCallReceipt(ctx, client, chainID, tx, block.Hash, cache)
+			if err != nil {
+				return nil, err
+			}
+			if matches == nil {
+				matches = make(map[int][]pendingTx)
+			}
+			matches[i] = append(matches[i], pendingTx{
+				log: types.Log{
+					TxHash:      tx.Hash,
+					TxIndex:     uint(tx.Index),
+					BlockNumber: uint64(block.Number),
+					BlockHash:   block.Hash,
+				},
+				label:   g.Label,
+				safe:    g.Safe,
+				rate:    g.Rate,
+				from:    tx.From,
+				value:   (*big.Int)(tx.Value),
+				receipt: receipt,
+				noLog:   true,
+			})
+		}
+	}
+	return matches, nil
+}
+
+// Whether the frame or any call under it is one of the group's calls. A
+// frame that reverted undid everything under it, and nothing under a static
+// call changes state.
+func (g TxGroup) calledIn(frame callFrame) bool {
+	if frame.Error != "" || strings.EqualFold(frame.Type, "staticcall") {
+		return false
+	}
+	if isStateCall(frame.Type) && frame.To != nil && g.matchesCall(*frame.To, frame.Input) {
+		return true
+	}
+	for _, child := range frame.Calls {
+		if g.calledIn(child) {
+			return true
+		}
+	}
+	return false
+}
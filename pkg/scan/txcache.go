@@ -0,0 +1,129 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	bolt "go.etcd.io/bbolt"
+)
+
+// A persistent cache of transaction senders, values, and raw receipts, keyed
+// by chain ID and tx hash, and of block timestamps and base fees, keyed by
+// block hash, so re-runs over overlapping ranges skip the RPC.
+type TxCache struct {
+	db *bolt.DB
+}
+
+type cachedTx struct {
+	From common.Address `json:"from"`
+	// Omitted if it wasn't known when the transaction was cached
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Receipt json.RawMessage `json:"receipt"`
+}
+
+func OpenTxCache(cacheDir string) (*TxCache, error) {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(cacheDir, "txs.db")
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening tx cache %s: %w", path, err)
+	}
+	return &TxCache{db: db}, nil
+}
+
+func (c *TxCache) Close() error {
+	return c.db.Close()
+}
+
+// Returns the cached sender, value (nil if it isn't known), and receipt for
+// a transaction included in blockHash. Entries from another block (the tx
+// was reorged) are ignored.
+func (c *TxCache) Get(chainID *big.Int, hash, blockHash common.Hash) (common.Address, *big.Int, *Receipt, bool) {
+	var entry cachedTx
+	found := false
+	c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(chainID.String()))
+		if bucket == nil {
+			return nil
+		}
+		if data := bucket.Get(hash.Bytes()); data != nil {
+			found = json.Unmarshal(data, &entry) == nil
+		}
+		return nil
+	})
+	if !found {
+		return common.Address{}, nil, nil, false
+	}
+
+	receipt, err := decodeReceipt(hash, entry.Receipt)
+	if err != nil || receipt.BlockHash != blockHash {
+		return common.Address{}, nil, nil, false
+	}
+	return entry.From, (*big.Int)(entry.Value), receipt, true
+}
+
+func (c *TxCache) Put(chainID *big.Int, hash common.Hash, from common.Address, value *big.Int, receipt *Receipt) error {
+	data, err := json.Marshal(cachedTx{From: from, Value: (*hexutil.Big)(value), Receipt: receipt.raw})
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(chainID.String()))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(hash.Bytes(), data)
+	})
+}
+
+// A block's timestamp and base fee, keyed by block hash so a reorged block's
+// entry is never used for its replacement
+type cachedHeader struct {
+	Time    uint64       `json:"time"`
+	BaseFee *hexutil.Big `json:"baseFee,omitempty"`
+}
+
+func headersBucket(chainID *big.Int) []byte {
+	return []byte(chainID.String() + "/headers")
+}
+
+func (c *TxCache) getHeader(chainID *big.Int, blockHash common.Hash) (blockHeader, bool) {
+	var entry cachedHeader
+	found := false
+	c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(headersBucket(chainID))
+		if bucket == nil {
+			return nil
+		}
+		if data := bucket.Get(blockHash.Bytes()); data != nil {
+			found = json.Unmarshal(data, &entry) == nil
+		}
+		return nil
+	})
+	if !found {
+		return blockHeader{}, false
+	}
+	return blockHeader{time: time.Unix(int64(entry.Time), 0), baseFee: (*big.Int)(entry.BaseFee)}, true
+}
+
+func (c *TxCache) putHeader(chainID *big.Int, blockHash common.Hash, h blockHeader) error {
+	data, err := json.Marshal(cachedHeader{Time: uint64(h.time.Unix()), BaseFee: (*hexutil.Big)(h.baseFee)})
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(headersBucket(chainID))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(blockHash.Bytes(), data)
+	})
+}
@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// observe(uint32[])
+	observeSelector = common.FromHex("0x883bdbfd")
+	// token0() and token1()
+	token0Selector = common.FromHex("0x0dfe1681")
+	token1Selector = common.FromHex("0xd21220a7")
+)
+
+// Paying out in a token priced against ETH, at a fixed rate or a Uniswap V3
+// pool's time-weighted average price
+type TokenPayout struct {
+	Token PayoutToken
+	// Token units per ETH. If nil, the TWAP of Pool over Window is used.
+	Rate   *big.Float
+	Pool   common.Address
+	WETH   common.Address
+	Window time.Duration
+}
+
+// The payout as of block, with where its rate came from
+func (t TokenPayout) Payout(ctx context.Context, client Client, block *big.Int) (Payout, error) {
+	p := Payout{Token: &t.Token, Rate: t.Rate, RateSource: "fixed rate"}
+	if t.Rate == nil {
+		rate, err := UniswapTWAP(ctx, client, t.Pool, t.WETH, t.Token.Decimals, t.Window, block)
+		if err != nil {
+			return Payout{}, err
+		}
+		p.Rate = rate
+		p.RateSource = fmt.Sprintf("Uniswap TWAP over %s to block %s", t.Window, block)
+	}
+	return p, nil
+}
+
+// Whole units of the pool's other token per ETH, from the average tick over
+// the window ending at block. The pool must pair weth with a token with the
+// given decimals and have enough observations to cover the window.
+func UniswapTWAP(ctx context.Context, client Client, pool, weth common.Address, decimals uint8, window time.Duration, block *big.Int) (*big.Float, error) {
+	seconds := uint64(window / time.Second)
+	if seconds == 0 || seconds > math.MaxUint32 {
+		return nil, fmt.Errorf("TWAP window %s must be between 1s and %ds", window, uint64(math.MaxUint32))
+	}
+
+	token0, err := poolToken(ctx, client, pool, "token0", token0Selector, block)
+	if err != nil {
+		return nil, err
+	}
+	token1, err := poolToken(ctx, client, pool, "token1", token1Selector, block)
+	if err != nil {
+		return nil, err
+	}
+	// Any other pool's price isn't per ETH at all
+	if token0 != weth && token1 != weth {
+		return nil, fmt.Errorf("pool %s pairs %s and %s, neither of which is WETH %s", pool.Hex(), token0.Hex(), token1.Hex(), weth.Hex())
+	}
+	wethIsToken0 := token0 == weth
+
+	// observe([window, 0]): one word for the array's offset, one for its
+	// length, then the two ages
+	data := append([]byte{}, observeSelector...)
+	data = append(data, common.BigToHash(big.NewInt(32)).Bytes()...)
+	data = append(data, common.BigToHash(big.NewInt(2)).Bytes()...)
+	data = append(data, common.BigToHash(new(big.Int).SetUint64(seconds)).Bytes()...)
+	data = append(data, common.Hash{}.Bytes()...)
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &pool, Data: data}, block)
+	if err != nil {
+		return nil, fmt.Errorf("observing pool %s over %s: %w", pool.Hex(), window, err)
+	}
+	// (int56[] tickCumulatives, uint160[] secondsPerLiquidityCumulativeX128s):
+	// two offsets, then each array's length and two elements
+	if len(out) != 8*32 || new(big.Int).SetBytes(out[:32]).Uint64() != 64 || new(big.Int).SetBytes(out[64:96]).Uint64() != 2 {
+		return nil, fmt.Errorf("unexpected observe() response from pool %s: %x", pool.Hex(), out)
+	}
+	then, now := signedWord(out[96:128]), signedWord(out[128:160])
+
+	// The mean tick, rounded toward negative infinity like Uniswap's
+	// OracleLibrary.consult
+	delta := new(big.Int).Sub(now, then)
+	tick := new(big.Int).Quo(delta, new(big.Int).SetUint64(seconds))
+	if delta.Sign() < 0 && new(big.Int).Rem(delta, new(big.Int).SetUint64(seconds)).Sign() != 0 {
+		tick.Sub(tick, big.NewInt(1))
+	}
+
+	// token1 base units per token0 base unit, so the token's base units per
+	// wei if WETH is token0
+	perWei := math.Pow(1.0001, float64(tick.Int64()))
+	if !wethIsToken0 {
+		perWei = 1 / perWei
+	}
+	rate := new(big.Float).SetFloat64(perWei * math.Pow10(18-int(decimals)))
+	return rate, nil
+}
+
+// One of the pool's tokens, read with the named getter
+func poolToken(ctx context.Context, client Client, pool common.Address, name string, selector []byte, block *big.Int) (common.Address, error) {
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &pool, Data: selector}, block)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("reading %s of pool %s: %w", name, pool.Hex(), err)
+	}
+	if len(out) != 32 {
+		return common.Address{}, fmt.Errorf("unexpected %s() response from pool %s: %x", name, pool.Hex(), out)
+	}
+	return common.BytesToAddress(out), nil
+}
+
+// A 32-byte two's complement word as a signed integer
+func signedWord(word []byte) *big.Int {
+	v := new(big.Int).SetBytes(word)
+	if word[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package scan_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+	"juimburser/pkg/scan/scantest"
+)
+
+func TestUniswapTWAP(t *testing.T) {
+	pool := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	jbx := common.HexToAddress("0x4554CC10898f92D45378b98D6D6c2dD54c687Fb2")
+	word := func(v *big.Int) []byte {
+		return common.BigToHash(v).Bytes()
+	}
+
+	tests := []struct {
+		name           string
+		token0, token1 common.Address
+		// Per ETH, or 0 if it should fail
+		want float64
+	}{
+		{name: "WETH is token0", token0: weth, token1: usdc, want: math.Pow(1.0001, 10000)},
+		{name: "WETH is token1", token0: usdc, token1: weth, want: math.Pow(1.0001, -10000)},
+		{name: "no WETH", token0: jbx, token1: usdc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := scantest.NewChain(1)
+			chain.HandleCalls(pool, func(data []byte, block *big.Int) ([]byte, error) {
+				switch {
+				case bytes.HasPrefix(data, common.FromHex("0x0dfe1681")):
+					return word(tt.token0.Big()), nil
+				case bytes.HasPrefix(data, common.FromHex("0xd21220a7")):
+					return word(tt.token1.Big()), nil
+				}
+				// A mean tick of 10000 over the minute: the tick cumulatives,
+				// then seconds per liquidity, which aren't used
+				var out []byte
+				for _, v := range []int64{64, 160, 2, 0, 600000, 2, 0, 0} {
+					out = append(out, word(big.NewInt(v))...)
+				}
+				return out, nil
+			})
+
+			rate, err := scan.UniswapTWAP(context.Background(), chain, pool, weth, 18, time.Minute, big.NewInt(1))
+			if tt.want == 0 {
+				if err == nil || !strings.Contains(err.Error(), "neither of which is WETH") {
+					t.Errorf("got %v, %v, want an error", rate, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := rate.Float64(); math.Abs(got-tt.want) > 1e-9*tt.want {
+				t.Errorf("got %v per ETH, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ERC-4337 EntryPoint deployments (v0.6 and v0.7), at the same address on
+// every chain
+var EntryPoints = []common.Address{
+	common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789"),
+	common.HexToAddress("0x0000000071727De22E5E87d17846b6644f8c2e7E"),
+}
+
+var userOperationEventTopic = crypto.Keccak256Hash([]byte("UserOperationEvent(bytes32,address,address,uint256,bool,uint256,uint256)"))
+
+// A UserOperation a bundler executed through an EntryPoint. Its gas was
+// paid for by its paymaster if it had one, or by its sender (the smart
+// account) otherwise; the bundler is refunded by the EntryPoint.
+type UserOp struct {
+	Hash      common.Hash
+	Sender    common.Address
+	Paymaster common.Address
+	Bundler   common.Address
+	// What the EntryPoint charged the funder for the operation, in wei, and
+	// the gas it was charged for
+	ActualGasCost *big.Int
+	ActualGasUsed uint64
+}
+
+// Who funded the operation's gas
+func (u UserOp) Funder() common.Address {
+	if u.Paymaster != (common.Address{}) {
+		return u.Paymaster
+	}
+	return u.Sender
+}
+
+// The gas price the operation was charged at
+func (u UserOp) GasPrice() *big.Int {
+	if u.ActualGasUsed == 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Div(u.ActualGasCost, new(big.Int).SetUint64(u.ActualGasUsed))
+}
+
+// The UserOperation a matched log belongs to, if its transaction was an
+// EntryPoint bundle. An operation's logs come before its UserOperationEvent,
+// so it's the first one at or after logIndex. Without a log (reverted or
+// traced calls), a bundle of exactly one operation is still attributed.
+func findUserOp(receipt *Receipt, logIndex uint, hasLog bool) *UserOp {
+	var ops []*UserOp
+	for _, lg := range receipt.Logs {
+		if len(lg.Topics) != 4 || lg.Topics[0] != userOperationEventTopic || len(lg.Data) != 4*32 || !slices.Contains(EntryPoints, lg.Address) {
+			continue
+		}
+		// (uint256 nonce, bool success, uint256 actualGasCost, uint256 actualGasUsed)
+		used := new(big.Int).SetBytes(lg.Data[96:128])
+		if !used.IsUint64() {
+			continue
+		}
+		op := &UserOp{
+			Hash:          lg.Topics[1],
+			Sender:        common.BytesToAddress(lg.Topics[2].Bytes()),
+			Paymaster:     common.BytesToAddress(lg.Topics[3].Bytes()),
+			ActualGasCost: new(big.Int).SetBytes(lg.Data[64:96]),
+			ActualGasUsed: used.Uint64(),
+		}
+		if hasLog && lg.Index >= logIndex {
+			return op
+		}
+		ops = append(ops, op)
+	}
+	if !hasLog && len(ops) == 1 {
+		return ops[0]
+	}
+	return nil
+}
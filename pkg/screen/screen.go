@@ -0,0 +1,146 @@
+package screen
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const ChainalysisAPI = "https://public.chainalysis.com"
+
+// Flags addresses that mustn't be paid, like sanctioned ones
+type Screener interface {
+	// The flagged addresses among addrs, with why each was flagged
+	Screen(ctx context.Context, addrs []common.Address) (map[common.Address]string, error)
+}
+
+// A local denylist: addresses mapped to why they're on it
+type List map[common.Address]string
+
+// Reads a denylist file of one address per line, optionally followed by why
+// it's listed (e.g. "0x... OFAC SDN: Lazarus Group"). Blank lines and
+// anything after a # are ignored.
+func LoadList(path string) (List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := make(List)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		addr, reason, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if addr == "" {
+			continue
+		}
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("%s:%d: %q is not an address", path, n, addr)
+		}
+		if reason = strings.TrimSpace(reason); reason == "" {
+			reason = "on the denylist " + path
+		}
+		list[common.HexToAddress(addr)] = reason
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return list, nil
+}
+
+func (l List) Screen(ctx context.Context, addrs []common.Address) (map[common.Address]string, error) {
+	flagged := make(map[common.Address]string)
+	for _, addr := range addrs {
+		if reason, ok := l[addr]; ok {
+			flagged[addr] = reason
+		}
+	}
+	return flagged, nil
+}
+
+// Chainalysis's sanctions screening API, which flags addresses on
+// sanctions lists like OFAC's SDN list
+type Chainalysis struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func NewChainalysis(baseURL, apiKey string) *Chainalysis {
+	return &Chainalysis{baseURL: strings.TrimSuffix(baseURL, "/"), apiKey: apiKey, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (c *Chainalysis) Screen(ctx context.Context, addrs []common.Address) (map[common.Address]string, error) {
+	flagged := make(map[common.Address]string)
+	for _, addr := range addrs {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/address/"+addr.Hex(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-API-Key", c.apiKey)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("screening %s with Chainalysis: %s: %s", addr.Hex(), resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		var result struct {
+			Identifications []struct {
+				Category string `json:"category"`
+				Name     string `json:"name"`
+			} `json:"identifications"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("screening %s with Chainalysis: decoding response: %w", addr.Hex(), err)
+		}
+		if len(result.Identifications) > 0 {
+			names := make([]string, len(result.Identifications))
+			for i, id := range result.Identifications {
+				names[i] = id.Name
+				if names[i] == "" {
+					names[i] = id.Category
+				}
+			}
+			flagged[addr] = "Chainalysis: " + strings.Join(names, "; ")
+		}
+	}
+	return flagged, nil
+}
+
+// Screens with each screener in turn, flagging what any of them flag (with
+// the first one's reason)
+type All []Screener
+
+func (all All) Screen(ctx context.Context, addrs []common.Address) (map[common.Address]string, error) {
+	flagged := make(map[common.Address]string)
+	for _, s := range all {
+		found, err := s.Screen(ctx, addrs)
+		if err != nil {
+			return nil, err
+		}
+		for addr, reason := range found {
+			if _, ok := flagged[addr]; !ok {
+				flagged[addr] = reason
+			}
+		}
+	}
+	return flagged, nil
+}
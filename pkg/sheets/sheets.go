@@ -0,0 +1,293 @@
+package sheets
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+)
+
+const (
+	API   = "https://sheets.googleapis.com"
+	scope = "https://www.googleapis.com/auth/spreadsheets"
+)
+
+// The tabs rows are appended to, with their header rows
+var (
+	TransactionsHeader = []any{"period_start", "period_end", "chain", "tx_hash", "sender", "label", "block", "block_time", "cost_eth", "cost_usd"}
+	RecipientsHeader   = []any{"period_start", "period_end", "chain", "recipient", "tx_count", "total_eth", "total_usd", "held_eth", "payout"}
+)
+
+// The fields of a service account key file that are needed to sign in
+type Credentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// Reads a service account JSON key downloaded from the Google Cloud console
+func LoadCredentials(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if creds.ClientEmail == "" || creds.PrivateKey == "" || creds.TokenURI == "" {
+		return nil, fmt.Errorf("%s: not a service account key (client_email, private_key, and token_uri are required)", path)
+	}
+	return &creds, nil
+}
+
+// Appends rows to one spreadsheet as a service account. The sheet has to be
+// shared with the account's client_email.
+type Client struct {
+	baseURL       string
+	spreadsheetID string
+	creds         *Credentials
+	key           *rsa.PrivateKey
+	http          *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func New(baseURL, spreadsheetID string, creds *Credentials) (*Client, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("service account private_key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("service account private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("service account private_key is not an RSA key")
+	}
+
+	return &Client{
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		spreadsheetID: spreadsheetID,
+		creds:         creds,
+		key:           key,
+		http:          &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Appends a row per transaction to the transactions tab and a row per
+// recipient to the recipients tab, writing each tab's header first if it's
+// empty
+func (c *Client) Export(ctx context.Context, transactionsTab, recipientsTab string, results []*scan.Result) error {
+	txRows, recipientRows := Rows(results)
+	if err := c.append(ctx, transactionsTab, TransactionsHeader, txRows); err != nil {
+		return fmt.Errorf("%s tab: %w", transactionsTab, err)
+	}
+	if err := c.append(ctx, recipientsTab, RecipientsHeader, recipientRows); err != nil {
+		return fmt.Errorf("%s tab: %w", recipientsTab, err)
+	}
+	return nil
+}
+
+// The rows Export appends. Amounts are strings in ETH and USD, which Sheets
+// reads as numbers.
+func Rows(results []*scan.Result) (txRows, recipientRows [][]any) {
+	for _, res := range results {
+		start, end := res.StartTime.UTC().Format(time.DateOnly), res.EndTime.UTC().Format(time.DateOnly)
+		counts := make(map[common.Address]int)
+		for _, tx := range res.Txs {
+			counts[tx.From]++
+			blockTime := ""
+			if !tx.BlockTime.IsZero() {
+				blockTime = tx.BlockTime.UTC().Format(time.DateTime)
+			}
+			txRows = append(txRows, []any{start, end, res.Chain.Name, tx.Hash.Hex(), tx.From.Hex(), tx.Label,
+				tx.BlockNumber, blockTime, scan.FormatEther(tx.GasWei), usd(tx.USD)})
+		}
+
+		usdTotals, payable, over := res.USDTotals(), res.Payable(), res.OverCap()
+		totals := res.Totals()
+		for _, k := range scan.SortedAddresses(totals) {
+			var total *big.Float
+			if usdTotals != nil {
+				total = usdTotals[k]
+			}
+			held := ""
+			if over[k] != nil {
+				held = scan.FormatEther(over[k])
+			}
+			recipientRows = append(recipientRows, []any{start, end, res.Chain.Name, k.Hex(), counts[k],
+				scan.FormatEther(totals[k]), usd(total), held, res.Payout.Format(res.Payout.Amount(payable[k]))})
+		}
+	}
+	return txRows, recipientRows
+}
+
+func usd(v *big.Float) string {
+	if v == nil {
+		return ""
+	}
+	return v.Text('f', 2)
+}
+
+func (c *Client) append(ctx context.Context, tab string, header []any, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var existing struct {
+		Values [][]any `json:"values"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.valuesURL(tab, "A1:A1", ""), nil, &existing); err != nil {
+		return err
+	}
+	if len(existing.Values) == 0 {
+		rows = append([][]any{header}, rows...)
+	}
+
+	body := map[string]any{"values": rows}
+	return c.do(ctx, http.MethodPost, c.valuesURL(tab, "A1", ":append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS"), body, nil)
+}
+
+func (c *Client) valuesURL(tab, cells, suffix string) string {
+	a1 := "'" + strings.ReplaceAll(tab, "'", "''") + "'!" + cells
+	return fmt.Sprintf("%s/v4/spreadsheets/%s/values/%s%s", c.baseURL, url.PathEscape(c.spreadsheetID), url.PathEscape(a1), suffix)
+}
+
+func (c *Client) do(ctx context.Context, method, endpoint string, body, out any) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Sheets API %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	if out != nil {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
+
+// An OAuth token for the service account, from a signed JWT grant. Reused
+// until a minute before it expires.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires.Add(-time.Minute)) {
+		return c.token, nil
+	}
+
+	assertion, err := c.signJWT(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.creds.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("getting an access token: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(respBody, &token); err != nil {
+		return "", fmt.Errorf("decoding access token: %w", err)
+	}
+
+	c.token = token.AccessToken
+	c.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// An RS256 JWT asking for the spreadsheets scope, valid for an hour
+func (c *Client) signJWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   c.creds.ClientEmail,
+		"scope": scope,
+		"aud":   c.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(nil, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
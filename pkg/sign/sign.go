@@ -0,0 +1,122 @@
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A detached EIP-191 signature over a file's keccak256 hash, as written to
+// <file>.sig
+type Signature struct {
+	File      string         `json:"file"`
+	Keccak256 common.Hash    `json:"keccak256"`
+	Signer    common.Address `json:"signer"`
+	// 65 bytes with v of 27 or 28, as personal_sign returns
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+// Signs the file at path with personal_sign over its keccak256 hash, so the
+// signature can also be checked with any wallet tool that verifies messages
+func File(path string, key *ecdsa.PrivateKey) (Signature, error) {
+	hash, err := fileHash(path)
+	if err != nil {
+		return Signature{}, err
+	}
+	sig, err := crypto.Sign(accounts.TextHash(hash.Bytes()), key)
+	if err != nil {
+		return Signature{}, err
+	}
+	sig[64] += 27
+	return Signature{File: filepath.Base(path), Keccak256: hash, Signer: crypto.PubkeyToAddress(key.PublicKey), Signature: sig}, nil
+}
+
+// Writes the signature for the file at path to path.sig, returning its path
+func WriteFile(path string, key *ecdsa.PrivateKey) (string, error) {
+	sig, err := File(path, key)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path+".sig", data, 0644); err != nil {
+		return "", err
+	}
+	return path + ".sig", nil
+}
+
+// Reads a signature written by WriteFile
+func ReadFile(path string) (Signature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Signature{}, err
+	}
+	var sig Signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return Signature{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return sig, nil
+}
+
+// Checks that sig is over the file at path as it is now and was made by
+// its signer, returning the recovered address
+func Verify(path string, sig Signature) (common.Address, error) {
+	hash, err := fileHash(path)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if hash != sig.Keccak256 {
+		return common.Address{}, fmt.Errorf("%s has keccak256 %s, but the signature is over %s", path, hash.Hex(), sig.Keccak256.Hex())
+	}
+	if len(sig.Signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("signature is %d bytes, expected %d", len(sig.Signature), crypto.SignatureLength)
+	}
+
+	rsv := bytes.Clone(sig.Signature)
+	if rsv[64] >= 27 {
+		rsv[64] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(hash.Bytes()), rsv)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+	if signer != sig.Signer {
+		return signer, fmt.Errorf("signature is from %s, not the listed signer %s", signer.Hex(), sig.Signer.Hex())
+	}
+	return signer, nil
+}
+
+// Writes an ASCII-armored detached GPG signature for the file at path to
+// path.asc with the local gpg, returning its path
+func GPG(ctx context.Context, path, keyID string) (string, error) {
+	out := path + ".asc"
+	cmd := exec.CommandContext(ctx, "gpg", "--batch", "--yes", "--armor", "--local-user", keyID, "--output", out, "--detach-sign", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gpg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func fileHash(path string) (common.Hash, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(data), nil
+}
@@ -0,0 +1,98 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"juimburser/pkg/bundle"
+	"juimburser/pkg/scan"
+)
+
+const (
+	// A Safe's execTransaction overhead on top of the calls it makes: checking
+	// a few owners' signatures, the nonce, and events
+	safeTxGas = 50_000
+	// What a call that can't be estimated on its own is costed at
+	fallbackCallGas = 150_000
+)
+
+var balanceOfSelector = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+
+// Checks the Safe paying res against the bundle parts built for it at the
+// latest block: its ETH against what they send plus the estimated gas of
+// executing them (each call estimated on its own from the Safe, plus each
+// Safe transaction's overhead), and its payout token against what they
+// transfer
+func CheckBalance(ctx context.Context, client scan.Client, res *scan.Result, parts []bundle.Part) (*scan.BalanceCheck, error) {
+	safe := *res.Chain.Safe
+	check := &scan.BalanceCheck{Safe: safe, Sends: big.NewInt(0)}
+
+	var balance hexutil.Big
+	if err := client.CallContext(ctx, &balance, "eth_getBalance", safe, "latest"); err != nil {
+		return nil, err
+	}
+	check.Balance = balance.ToInt()
+	var gasPrice hexutil.Big
+	if err := client.CallContext(ctx, &gasPrice, "eth_gasPrice"); err != nil {
+		return nil, err
+	}
+	check.GasPrice = gasPrice.ToInt()
+
+	var token *common.Address
+	if t := res.Payout.Token; t != nil {
+		token = &t.Address
+		out, err := client.CallContract(ctx, ethereum.CallMsg{To: token, Data: append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(safe.Bytes(), 32)...)}, nil)
+		if err != nil {
+			return nil, err
+		}
+		check.TokenBalance = new(big.Int).SetBytes(out)
+		check.TokenSends = big.NewInt(0)
+	}
+
+	for _, part := range parts {
+		if token != nil {
+			transfers, err := bundle.Transfers(part.Bundle)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range transfers {
+				if t.Token == *token {
+					check.TokenSends.Add(check.TokenSends, t.Amount)
+				}
+			}
+		}
+
+		calls, err := bundle.Calls(part.Bundle)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := decodeCalls(calls)
+		if err != nil {
+			return nil, err
+		}
+		check.Gas += safeTxGas * uint64(len(part.Bundle.Transactions))
+		for _, c := range decoded {
+			check.Sends.Add(check.Sends, c.value)
+			msg := map[string]any{"from": safe, "to": c.to, "value": (*hexutil.Big)(c.value), "data": hexutil.Bytes(c.data)}
+			var gas hexutil.Uint64
+			if err := client.CallContext(ctx, &gas, "eth_estimateGas", msg); err != nil {
+				var rpcErr rpc.Error
+				if !errors.As(err, &rpcErr) {
+					return nil, err
+				}
+				check.Gas += fallbackCallGas
+				check.Unestimated++
+				continue
+			}
+			check.Gas += uint64(gas)
+		}
+	}
+	return check, nil
+}
@@ -0,0 +1,114 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"juimburser/pkg/bundle"
+)
+
+// Simulates on a local anvil fork (anvil --fork-url ...), sending each call
+// from the impersonated Safe with a gas price of zero so the Safe only needs
+// what the calls send. The fork is reverted to a snapshot afterwards, so it
+// can be reused.
+type Fork struct {
+	rpc *rpc.Client
+}
+
+func DialFork(ctx context.Context, url string) (*Fork, error) {
+	c, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return &Fork{rpc: c}, nil
+}
+
+func (f *Fork) Close() {
+	f.rpc.Close()
+}
+
+func (f *Fork) Simulate(ctx context.Context, chainID *big.Int, from common.Address, calls []bundle.Transaction) (err error) {
+	decoded, err := decodeCalls(calls)
+	if err != nil {
+		return err
+	}
+
+	var forkChainID hexutil.Big
+	if err := f.rpc.CallContext(ctx, &forkChainID, "eth_chainId"); err != nil {
+		return err
+	}
+	if forkChainID.ToInt().Cmp(chainID) != 0 {
+		return fmt.Errorf("fork has chain ID %s, not %s", forkChainID.ToInt(), chainID)
+	}
+
+	var snapshot hexutil.Big
+	if err := f.rpc.CallContext(ctx, &snapshot, "evm_snapshot"); err != nil {
+		return err
+	}
+	defer func() {
+		var reverted bool
+		if revertErr := f.rpc.CallContext(context.WithoutCancel(ctx), &reverted, "evm_revert", &snapshot); revertErr != nil && err == nil {
+			err = fmt.Errorf("reverting the fork: %w", revertErr)
+		}
+	}()
+	if err := f.rpc.CallContext(ctx, nil, "anvil_impersonateAccount", from); err != nil {
+		return err
+	}
+
+	for i, c := range decoded {
+		// Checked first, since a node rejects a transaction sending more than
+		// its sender has rather than reverting it
+		var balance hexutil.Big
+		if err := f.rpc.CallContext(ctx, &balance, "eth_getBalance", from, "latest"); err != nil {
+			return err
+		}
+		if balance.ToInt().Cmp(c.value) < 0 {
+			return &RevertError{Index: i, To: c.to, Value: c.value, Reason: fmt.Sprintf("the Safe only has %s wei", balance.ToInt())}
+		}
+
+		msg := map[string]any{
+			"from":     from,
+			"to":       c.to,
+			"value":    (*hexutil.Big)(c.value),
+			"data":     hexutil.Bytes(c.data),
+			"gasPrice": "0x0",
+		}
+		// eth_call first for the revert reason, which a mined transaction's
+		// receipt doesn't have
+		var out hexutil.Bytes
+		if err := f.rpc.CallContext(ctx, &out, "eth_call", msg, "latest"); err != nil {
+			var rpcErr rpc.Error
+			if !errors.As(err, &rpcErr) {
+				return err
+			}
+			return &RevertError{Index: i, To: c.to, Value: c.value, Reason: err.Error()}
+		}
+
+		if err := f.rpc.CallContext(ctx, nil, "anvil_setNextBlockBaseFeePerGas", "0x0"); err != nil {
+			return err
+		}
+		var hash common.Hash
+		if err := f.rpc.CallContext(ctx, &hash, "eth_sendTransaction", msg); err != nil {
+			return &RevertError{Index: i, To: c.to, Value: c.value, Reason: err.Error()}
+		}
+		var receipt *struct {
+			Status hexutil.Uint64 `json:"status"`
+		}
+		if err := f.rpc.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+			return err
+		}
+		if receipt == nil {
+			return fmt.Errorf("call %d to %s wasn't mined; is the fork automining?", i, c.to.Hex())
+		}
+		if receipt.Status != 1 {
+			return &RevertError{Index: i, To: c.to, Value: c.value, Reason: "reverted"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"juimburser/pkg/scan"
+)
+
+// The recipients that are contracts rejecting plain ETH transfers (like old
+// multisigs without a receive function), with the revert each gave, found by
+// calling each contract with 1 wei and no data from the Safe at the latest
+// block. A transfer to one reverts its Safe transaction, and with a MultiSend
+// batch every other transfer with it.
+func RejectingRecipients(ctx context.Context, client scan.Client, from common.Address, recipients []common.Address) (map[common.Address]string, error) {
+	rejecting := make(map[common.Address]string)
+	for _, addr := range recipients {
+		var code hexutil.Bytes
+		if err := client.CallContext(ctx, &code, "eth_getCode", addr, "latest"); err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			continue
+		}
+		to := addr
+		if _, err := client.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Value: big.NewInt(1)}, nil); err != nil {
+			var rpcErr rpc.Error
+			if !errors.As(err, &rpcErr) {
+				return nil, err
+			}
+			rejecting[addr] = err.Error()
+		}
+	}
+	return rejecting, nil
+}
@@ -0,0 +1,54 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/bundle"
+)
+
+// Runs the calls a Safe makes executing bundles, one after another on the
+// chain's latest state, so a transfer that would revert (or that the Safe
+// can't afford) fails the run instead of the Safe transaction
+type Simulator interface {
+	// Returns a *RevertError for the first call that fails
+	Simulate(ctx context.Context, chainID *big.Int, from common.Address, calls []bundle.Transaction) error
+}
+
+// A simulated call that reverted
+type RevertError struct {
+	// Its index in the calls simulated
+	Index  int
+	To     common.Address
+	Value  *big.Int
+	Reason string
+}
+
+func (e *RevertError) Error() string {
+	return fmt.Sprintf("call %d to %s (value %s wei) would revert: %s", e.Index, e.To.Hex(), e.Value, e.Reason)
+}
+
+// A call's target, value, and calldata
+type call struct {
+	to    common.Address
+	value *big.Int
+	data  []byte
+}
+
+func decodeCalls(calls []bundle.Transaction) ([]call, error) {
+	decoded := make([]call, len(calls))
+	for i, tx := range calls {
+		if tx.Operation != bundle.OperationCall {
+			return nil, fmt.Errorf("call %d to %s is a delegatecall", i, tx.To)
+		}
+		value, data, err := bundle.DecodeTx(tx)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		decoded[i] = call{to: common.HexToAddress(tx.To), value: value, data: data}
+	}
+	return decoded, nil
+}
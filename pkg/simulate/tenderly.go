@@ -0,0 +1,131 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"juimburser/pkg/bundle"
+)
+
+const TenderlyAPI = "https://api.tenderly.co"
+
+// Gas each simulated call may use. Simulations run with a gas price of zero,
+// so the Safe only needs what the calls themselves send.
+const tenderlyGas = 8_000_000
+
+// Simulates with a Tenderly project's simulate-bundle endpoint, which runs
+// each simulation on the state the one before it left
+type Tenderly struct {
+	baseURL          string
+	account, project string
+	accessKey        string
+	http             *http.Client
+}
+
+func NewTenderly(baseURL, account, project, accessKey string) *Tenderly {
+	return &Tenderly{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		account:   account,
+		project:   project,
+		accessKey: accessKey,
+		http:      &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+type tenderlySimulation struct {
+	NetworkID      string `json:"network_id"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+	Input          string `json:"input"`
+	Value          string `json:"value"`
+	Gas            uint64 `json:"gas"`
+	GasPrice       string `json:"gas_price"`
+	Save           bool   `json:"save"`
+	SimulationType string `json:"simulation_type"`
+}
+
+func (t *Tenderly) Simulate(ctx context.Context, chainID *big.Int, from common.Address, calls []bundle.Transaction) error {
+	decoded, err := decodeCalls(calls)
+	if err != nil {
+		return err
+	}
+	if len(decoded) == 0 {
+		return nil
+	}
+
+	sims := make([]tenderlySimulation, len(decoded))
+	for i, c := range decoded {
+		sims[i] = tenderlySimulation{
+			NetworkID:      chainID.String(),
+			From:           from.Hex(),
+			To:             c.to.Hex(),
+			Input:          hexutil.Encode(c.data),
+			Value:          c.value.String(),
+			Gas:            tenderlyGas,
+			GasPrice:       "0",
+			SimulationType: "quick",
+		}
+	}
+	body, err := json.Marshal(map[string]any{"simulations": sims})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/account/%s/project/%s/simulate-bundle", t.baseURL, url.PathEscape(t.account), url.PathEscape(t.project))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Access-Key", t.accessKey)
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Tenderly simulation: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var results struct {
+		SimulationResults []struct {
+			Transaction struct {
+				Status       bool   `json:"status"`
+				ErrorMessage string `json:"error_message"`
+			} `json:"transaction"`
+		} `json:"simulation_results"`
+	}
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return fmt.Errorf("Tenderly simulation: decoding response: %w", err)
+	}
+	if len(results.SimulationResults) != len(decoded) {
+		return fmt.Errorf("Tenderly simulation: got %d results for %d calls", len(results.SimulationResults), len(decoded))
+	}
+	for i, r := range results.SimulationResults {
+		if !r.Transaction.Status {
+			reason := r.Transaction.ErrorMessage
+			if reason == "" {
+				reason = "reverted"
+			}
+			return &RevertError{Index: i, To: decoded[i].to, Value: decoded[i].value, Reason: reason}
+		}
+	}
+	return nil
+}
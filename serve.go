@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"juimburser/pkg/metrics"
+)
+
+var serveFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "listen",
+		Usage:   "address to serve the HTTP API on",
+		Value:   "127.0.0.1:8080",
+		EnvVars: []string{"LISTEN_ADDR"},
+	},
+	&cli.StringFlag{
+		Name:    "api-token",
+		Usage:   "bearer token required to trigger runs",
+		EnvVars: []string{"API_TOKEN"},
+	},
+}
+
+// The HTTP API. Runs it triggers scan with the server's flags, as `run
+// --since-last-run`, writing to a dated directory in the out dir.
+type server struct {
+	ctx     context.Context
+	c       *cli.Context
+	cfg     *Config
+	history History
+	token   string
+
+	mu sync.Mutex
+	// A snapshot of the run in progress, if there is one
+	running *RunRecord
+}
+
+func serveAction(c *cli.Context) error {
+	cfg, err := loadProfile(c)
+	if err != nil {
+		return err
+	}
+	if err := c.Set("since-last-run", "true"); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := &server{
+		ctx:     ctx,
+		c:       c,
+		cfg:     cfg,
+		history: History{path: c.String("history")},
+		token:   c.String("api-token"),
+	}
+	if s.token == "" {
+		slog.Warn("No --api-token, so anyone who can reach the server can trigger runs", "listen", c.String("listen"))
+	}
+
+	srv := &http.Server{
+		Addr:              c.String("listen"),
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Serving", "url", "http://"+srv.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Default.Handler())
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /runs/{id}", s.handleRunPage)
+	mux.HandleFunc("GET /recipients/{address}", s.handleRecipientPage)
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("GET /api/runs", s.handleRuns)
+	mux.HandleFunc("POST /api/runs", s.handleTrigger)
+	mux.HandleFunc("GET /api/runs/{id}", s.handleRun)
+	mux.HandleFunc("GET /api/runs/{id}/files/{name}", s.handleFile)
+	return mux
+}
+
+type statusResponse struct {
+	// The run in progress, if any
+	Running *RunRecord `json:"running"`
+	LastRun *RunRecord `json:"lastRun"`
+	// Where each chain's next run will start, from the state file
+	Chains map[string]*ChainState `json:"chains"`
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	state, err := loadState(s.cfg.State(s.c))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	runs, err := s.history.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := statusResponse{Chains: state.Chains}
+	s.mu.Lock()
+	resp.Running = s.running
+	s.mu.Unlock()
+	for i := range runs {
+		if runs[i].Status != RunRunning {
+			resp.LastRun = &runs[i]
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.history.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// Starts a run in the background, responding with its record
+func (s *server) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		given, ok := bearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil {
+		writeError(w, http.StatusConflict, errors.New("a run is already in progress"))
+		return
+	}
+
+	name, dir := datedDir(s.c.String("out-dir"), time.Now())
+	run := &RunRecord{Trigger: "api", Status: RunRunning, StartedAt: time.Now().UTC(), OutDir: dir}
+	if err := s.history.Start(run); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	snapshot := *run
+	s.running = &snapshot
+
+	go func() {
+		slog.Info("Starting run", "id", run.ID, "outDir", dir)
+		if _, err := recordedRun(s.ctx, s.c, configForDir(s.cfg, name), run, true, true); err != nil {
+			slog.Error("Run failed", "id", run.ID, "err", err)
+		} else {
+			slog.Info("Run finished", "id", run.ID)
+		}
+		s.mu.Lock()
+		s.running = nil
+		s.mu.Unlock()
+	}()
+
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+func (s *server) handleRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookupRun(w, r)
+	if ok {
+		writeJSON(w, http.StatusOK, run)
+	}
+}
+
+// Downloads one of a run's artifacts, e.g. bundle.json or report.md
+func (s *server) handleFile(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.lookupRun(w, r)
+	if !ok {
+		return
+	}
+	// Only serve files the run recorded writing, never arbitrary paths
+	name := r.PathValue("name")
+	if !slices.Contains(run.Artifacts, name) {
+		writeError(w, http.StatusNotFound, errors.New("no artifact "+name+" in this run"))
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name))
+	http.ServeFile(w, r, filepath.Join(run.OutDir, name))
+}
+
+func (s *server) lookupRun(w http.ResponseWriter, r *http.Request) (*RunRecord, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid run ID"))
+		return nil, false
+	}
+	run, err := s.history.Get(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	if run == nil {
+		writeError(w, http.StatusNotFound, errors.New("no such run"))
+		return nil, false
+	}
+	return run, true
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"juimburser/pkg/scan"
+)
+
+// What previous runs reimbursed, persisted between runs so they can't pay a
+// transaction twice and --since-last-run can pick up where the last one stopped
+type State struct {
+	// Keyed by chain ID
+	Chains map[string]*ChainState `json:"chains"`
+}
+
+type ChainState struct {
+	Name      string    `json:"name"`
+	LastBlock uint64    `json:"lastBlock"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Every transaction included in a bundle so far
+	Txs []common.Hash `json:"txs"`
+	// Transactions whose recipient was owed less than the minimum payout,
+	// still to be paid
+	Deferred []DeferredTx `json:"deferred,omitempty"`
+	// What recipients were owed over the per-recipient cap, held back from
+	// the last bundle and still to be paid
+	Held []HeldAmount `json:"held,omitempty"`
+	// The IDs of every line item included in a bundle so far
+	LineItems []string `json:"lineItems,omitempty"`
+}
+
+type DeferredTx struct {
+	Hash common.Hash    `json:"hash"`
+	From common.Address `json:"from"`
+	Wei  string         `json:"wei"`
+}
+
+type HeldAmount struct {
+	From common.Address `json:"from"`
+	Wei  string         `json:"wei"`
+}
+
+// Reads the state at path, or returns an empty state if there isn't one yet
+func loadState(path string) (*State, error) {
+	state := &State{Chains: make(map[string]*ChainState)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("parsing state %s: %w", path, err)
+	}
+	if state.Chains == nil {
+		state.Chains = make(map[string]*ChainState)
+	}
+	return state, nil
+}
+
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Records a scan's end block and included transactions
+func (s *State) Record(res *scan.Result) {
+	key := res.Chain.ChainID.String()
+	chain := s.Chains[key]
+	if chain == nil {
+		chain = &ChainState{}
+		s.Chains[key] = chain
+	}
+
+	// Recipients still under the minimum keep what they were owed before
+	// plus this run's transactions
+	carried := res.CarriedOver()
+	var deferred []DeferredTx
+	for _, d := range chain.carriedIn(res) {
+		if carried[d.From] != nil {
+			deferred = append(deferred, d)
+		}
+	}
+	for _, tx := range res.Txs {
+		if carried[tx.From] != nil {
+			deferred = append(deferred, DeferredTx{Hash: tx.Hash, From: tx.From, Wei: tx.GasWei.String()})
+		}
+	}
+	chain.Deferred = deferred
+
+	// What's over the cap includes what was held before and carried in, so
+	// it replaces it, except for recipients now under the minimum, who are
+	// still owed it
+	var held []HeldAmount
+	for _, h := range chain.Held {
+		if carried[h.From] != nil {
+			held = append(held, h)
+		}
+	}
+	over := res.OverCap()
+	for _, k := range scan.SortedAddresses(over) {
+		held = append(held, HeldAmount{From: k, Wei: over[k].String()})
+	}
+	chain.Held = held
+
+	chain.Name = res.Chain.Name
+	if end := res.EndBlock.Uint64(); end > chain.LastBlock {
+		chain.LastBlock = end
+	}
+	chain.UpdatedAt = time.Now().UTC()
+
+	seen := chain.included()
+	for _, tx := range res.Txs {
+		if !seen[tx.Hash] {
+			chain.Txs = append(chain.Txs, tx.Hash)
+			seen[tx.Hash] = true
+		}
+	}
+	// A carried over recipient's line items stay in the config, so they're
+	// added again next run
+	for _, item := range res.LineItems {
+		if carried[item.Recipient] == nil && !slices.Contains(chain.LineItems, item.ID) {
+			chain.LineItems = append(chain.LineItems, item.ID)
+		}
+	}
+}
+
+func (c *ChainState) included() map[common.Hash]bool {
+	seen := make(map[common.Hash]bool)
+	for _, hash := range c.Txs {
+		seen[hash] = true
+	}
+	return seen
+}
+
+// The deferred transactions a scan doesn't include itself, so are owed on
+// top of its totals
+func (c *ChainState) carriedIn(res *scan.Result) []DeferredTx {
+	scanned := make(map[common.Hash]bool)
+	for _, tx := range res.Txs {
+		scanned[tx.Hash] = true
+	}
+	var owed []DeferredTx
+	for _, d := range c.Deferred {
+		if !scanned[d.Hash] {
+			owed = append(owed, d)
+		}
+	}
+	return owed
+}
+
+// Credits a scan with what earlier runs carried over below the minimum
+// payout or held back over the cap, returning how many recipients are owed
+// something. Call after Exclude.
+func (s *State) CarryIn(res *scan.Result) (int, error) {
+	chain := s.Chains[res.Chain.ChainID.String()]
+	if chain == nil {
+		return 0, nil
+	}
+
+	res.CarriedIn = nil
+	add := func(from common.Address, amount string) error {
+		wei, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return fmt.Errorf("state: invalid amount %q carried over for %s", amount, from.Hex())
+		}
+		if res.CarriedIn == nil {
+			res.CarriedIn = make(map[common.Address]*big.Int)
+		}
+		if res.CarriedIn[from] == nil {
+			res.CarriedIn[from] = big.NewInt(0)
+		}
+		res.CarriedIn[from].Add(res.CarriedIn[from], wei)
+		return nil
+	}
+	for _, d := range chain.carriedIn(res) {
+		if err := add(d.From, d.Wei); err != nil {
+			return 0, fmt.Errorf("%w (deferred %s)", err, d.Hash.Hex())
+		}
+	}
+	for _, h := range chain.Held {
+		if err := add(h.From, h.Wei); err != nil {
+			return 0, fmt.Errorf("%w (held over the cap)", err)
+		}
+	}
+	return len(res.CarriedIn), nil
+}
+
+// Drops what earlier runs held back over the cap on chain, so it's never
+// paid, returning how many recipients it was held for
+func (s *State) ForgiveHeld(chain *scan.Chain) int {
+	c := s.Chains[chain.ChainID.String()]
+	if c == nil {
+		return 0
+	}
+	n := len(c.Held)
+	c.Held = nil
+	return n
+}
+
+// Adds the chain's line items to a scan, unless a previous run already
+// bundled them, returning how many were skipped
+func (s *State) AddLineItems(res *scan.Result, force bool) int {
+	var paid []string
+	if chain := s.Chains[res.Chain.ChainID.String()]; chain != nil && !force {
+		paid = chain.LineItems
+	}
+	res.LineItems = nil
+	skipped := 0
+	for _, item := range res.Chain.LineItems {
+		if slices.Contains(paid, item.ID) {
+			skipped++
+			continue
+		}
+		res.LineItems = append(res.LineItems, item)
+	}
+	return skipped
+}
+
+// How many of a scan's transactions a previous run already reimbursed
+func (s *State) CountReimbursed(res *scan.Result) int {
+	chain := s.Chains[res.Chain.ChainID.String()]
+	if chain == nil {
+		return 0
+	}
+
+	seen := chain.included()
+	count := 0
+	for _, tx := range res.Txs {
+		if seen[tx.Hash] {
+			count++
+		}
+	}
+	return count
+}
+
+// Excludes transactions a previous run already reimbursed, returning how many were excluded
+func (s *State) Exclude(res *scan.Result) int {
+	chain := s.Chains[res.Chain.ChainID.String()]
+	if chain == nil {
+		return 0
+	}
+
+	seen := chain.included()
+	return res.Exclude(func(tx scan.TxInfo) (string, bool) {
+		return "already reimbursed by a previous run", seen[tx.Hash]
+	})
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli/v2"
+
+	"juimburser/pkg/scan"
+)
+
+// Events groups commonly match, with how many of their parameters are
+// indexed (so can be filtered on in topics[1] onwards)
+var knownEvents = []struct {
+	Signature string
+	Indexed   int
+}{
+	{"ExecutionSuccess(bytes32,uint256)", 0},
+	{"ExecutionFromModuleSuccess(address)", 1},
+	{"ApproveHash(bytes32,address)", 2},
+	{juiceboxEvents[3].Payouts, 3},
+	{juiceboxEvents[3].Reserved, 3},
+	{juiceboxEvents[4].Payouts, 3},
+	{juiceboxEvents[4].Reserved, 3},
+	{"UserOperationEvent(bytes32,address,address,uint256,bool,uint256,uint256)", 3},
+}
+
+// The known event with topic, if there is one
+func knownEvent(topic common.Hash) (string, int, bool) {
+	for _, e := range knownEvents {
+		if hash, _ := scan.EventTopic(e.Signature); hash == topic {
+			return e.Signature, e.Indexed, true
+		}
+	}
+	return "", 0, false
+}
+
+// Checks the config without scanning, printing every problem and warning.
+// Fails if there are problems.
+func configValidateAction(c *cli.Context) error {
+	path := c.String("config")
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+
+	var problems []error
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, unjoin(err)...)
+	}
+	problems = append(problems, cfg.checksumErrors()...)
+	warnings := cfg.lint()
+	for _, name := range cfg.profileNames() {
+		own := cfg.Profiles[name].config()
+		for _, err := range own.checksumErrors() {
+			problems = append(problems, fmt.Errorf("profiles.%s: %w", name, err))
+		}
+		for _, err := range own.lint() {
+			warnings = append(warnings, fmt.Errorf("profiles.%s: %w", name, err))
+		}
+	}
+
+	printValidation(c.App.Writer, path, problems, warnings)
+	if len(problems) > 0 {
+		return fmt.Errorf("%s has %d problems", path, len(problems))
+	}
+	return nil
+}
+
+func printValidation(w io.Writer, path string, problems, warnings []error) {
+	for _, err := range problems {
+		fmt.Fprintf(w, "error: %s\n", err)
+	}
+	for _, err := range warnings {
+		fmt.Fprintf(w, "warning: %s\n", err)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s OK, %d warnings\n", path, len(warnings))
+	}
+}
+
+// The errors joined into err, one per problem
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// Addresses written in mixed case whose EIP-55 checksum is wrong, which
+// usually means a typo. All-lowercase and all-uppercase addresses have no
+// checksum to check.
+func (c *Config) checksumErrors() []error {
+	var errs []error
+	check := func(field, addr string) {
+		addr = strings.TrimSpace(addr)
+		if !common.IsHexAddress(addr) || !strings.HasPrefix(addr, "0x") {
+			return
+		}
+		digits := addr[2:]
+		if digits == strings.ToLower(digits) || digits == strings.ToUpper(digits) {
+			return
+		}
+		if want := common.HexToAddress(addr).Hex(); want != addr {
+			errs = append(errs, fmt.Errorf("%s: %s has a bad checksum; check it for typos (checksummed, it would be %s)", field, addr, want))
+		}
+	}
+
+	for i, chain := range c.Chains {
+		name := fmt.Sprintf("chains[%d]", i)
+		if chain.Name != "" {
+			name += fmt.Sprintf(" (%q)", chain.Name)
+		}
+		for _, f := range []struct{ field, addr string }{
+			{"priceFeed", chain.PriceFeed}, {"usdc", chain.USDC}, {"jbx", chain.JBX}, {"jbxPool", chain.JBXPool},
+			{"weth", chain.WETH}, {"safe", chain.Safe}, {"multiSend", chain.MultiSend}, {"terminal", chain.Terminal},
+			{"claimContract", chain.ClaimContract}, {"sablierLockup", chain.SablierLockup}, {"split", chain.Split}, {"splitMain", chain.SplitMain},
+		} {
+			check(name+": "+f.field, f.addr)
+		}
+		for j, s := range chain.Safes {
+			check(fmt.Sprintf("%s: safes[%d]", name, j), s.Address)
+		}
+		for j, r := range chain.Relayers {
+			check(fmt.Sprintf("%s: relayers[%d]", name, j), r.Address)
+			check(fmt.Sprintf("%s: relayers[%d]: payer", name, j), r.Payer)
+		}
+		for j, g := range chain.Groups {
+			for k, a := range g.Addresses {
+				check(fmt.Sprintf("%s: groups[%d]: addresses[%d]", name, j, k), a)
+			}
+			for k, position := range g.Topics {
+				for l, t := range position {
+					if kind, value, ok := strings.Cut(strings.TrimSpace(t), ":"); ok && strings.TrimSpace(kind) == "address" {
+						check(fmt.Sprintf("%s: groups[%d]: topics[%d][%d]", name, j, k, l), value)
+					}
+				}
+			}
+		}
+	}
+	for i, e := range c.Exclude {
+		check(fmt.Sprintf("exclude[%d]", i), e.Address)
+	}
+	for i, addr := range c.Allow {
+		check(fmt.Sprintf("allow[%d]", i), addr)
+	}
+	var labeled []string
+	for addr := range c.Labels {
+		labeled = append(labeled, addr)
+	}
+	slices.Sort(labeled)
+	for _, addr := range labeled {
+		check("labels", addr)
+	}
+	return errs
+}
+
+// Things that are valid but probably not what was meant: events in the wrong
+// topic position, filters on parameters the event doesn't index, signatures
+// that almost match a known event, and groups that match the same logs
+func (c *Config) lint() []error {
+	var warnings []error
+	for i, chain := range c.Chains {
+		name := fmt.Sprintf("chains[%d]", i)
+		if chain.Name != "" {
+			name += fmt.Sprintf(" (%q)", chain.Name)
+		}
+		for j, g := range chain.Groups {
+			group := fmt.Sprintf("%s: groups[%d]", name, j)
+			if g.Label != "" {
+				group += fmt.Sprintf(" (%q)", g.Label)
+			}
+			for _, err := range g.lint() {
+				warnings = append(warnings, fmt.Errorf("%s: %w", group, err))
+			}
+		}
+		for _, err := range overlappingGroups(chain.TxGroups()) {
+			warnings = append(warnings, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return warnings
+}
+
+func (g GroupConfig) lint() []error {
+	if g.Type == GroupApproveHash {
+		return nil
+	}
+
+	var errs []error
+	// The fewest parameters the group's events index, if they're all known
+	var events []string
+	if len(g.Topics) > 0 {
+		events = g.Topics[0]
+	}
+	indexed, known := 3, len(events) > 0
+	for k, t := range events {
+		topic, err := parseTopic(t)
+		if err != nil {
+			known = false
+			continue
+		}
+		if kind, _, ok := strings.Cut(t, ":"); ok && !strings.Contains(t, "(") {
+			errs = append(errs, fmt.Errorf("topics[0][%d] is a %s value, but topics[0] holds event signatures; indexed values go in topics[1] onwards", k, strings.TrimSpace(kind)))
+			known = false
+			continue
+		}
+		_, n, ok := knownEvent(topic)
+		if !ok {
+			if similar := similarEvent(t); similar != "" {
+				errs = append(errs, fmt.Errorf("topics[0][%d] %s isn't the known %s, so it won't match its logs", k, strings.TrimSpace(t), similar))
+			}
+			known = false
+			continue
+		}
+		indexed = min(indexed, n)
+	}
+
+	for j := 1; j < len(g.Topics); j++ {
+		for k, t := range g.Topics[j] {
+			topic, err := parseTopic(t)
+			if err != nil {
+				continue
+			}
+			if _, _, ok := knownEvent(topic); ok || strings.Contains(t, "(") {
+				errs = append(errs, fmt.Errorf("topics[%d][%d] looks like an event, but events go in topics[0]; topics[%d] matches the event's %s indexed parameter", j, k, j, ordinal(j)))
+			}
+		}
+		if known && j > indexed && len(g.Topics[j]) > 0 {
+			errs = append(errs, fmt.Errorf("topics[%d] is set, but the group's events have only %d indexed parameters, so nothing will match", j, indexed))
+		}
+	}
+	if len(g.ProjectIDs) > 0 && known && g.projectIDTopic() > indexed {
+		errs = append(errs, fmt.Errorf("projectIdTopic is %d, but the group's events have only %d indexed parameters, so nothing will match", g.projectIDTopic(), indexed))
+	}
+	return errs
+}
+
+// A known event with the same name as signature's, if it has one
+func similarEvent(signature string) string {
+	name, _, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(signature), "event ")), "(")
+	if !ok {
+		return ""
+	}
+	for _, e := range knownEvents {
+		if strings.HasPrefix(e.Signature, strings.TrimSpace(name)+"(") {
+			return e.Signature
+		}
+	}
+	return ""
+}
+
+func ordinal(n int) string {
+	switch n {
+	case 1:
+		return "first"
+	case 2:
+		return "second"
+	case 3:
+		return "third"
+	}
+	return fmt.Sprintf("#%d", n)
+}
+
+// Pairs of groups that match the same logs: an address in common, an event
+// in common, and no topic position where both filter on different values.
+// Transactions that match both only count under the first.
+func overlappingGroups(groups []scan.TxGroup) []error {
+	var errs []error
+	for i, a := range groups {
+		for _, b := range groups[i+1:] {
+			if a.Subgraph != nil || b.Subgraph != nil || len(a.Topics) == 0 || len(b.Topics) == 0 {
+				continue
+			}
+			addr, ok := common.Address{}, false
+			for _, x := range a.Addresses {
+				if slices.Contains(b.Addresses, x) {
+					addr, ok = x, true
+					break
+				}
+			}
+			if !ok || !topicsOverlap(a.Topics, b.Topics) {
+				continue
+			}
+			event := ""
+			for _, t := range a.Topics[0] {
+				if slices.Contains(b.Topics[0], t) {
+					if event, _, ok = knownEvent(t); !ok {
+						event = t.Hex()
+					}
+					break
+				}
+			}
+			errs = append(errs, fmt.Errorf("groups %q and %q both match %s events from %s; transactions matching both count under %q only", a.Label, b.Label, event, addr.Hex(), a.Label))
+		}
+	}
+	return errs
+}
+
+// Whether some log could match both sets of topic filters. An empty
+// position matches anything.
+func topicsOverlap(a, b [][]common.Hash) bool {
+	for i := 0; i < min(len(a), len(b)); i++ {
+		if len(a[i]) == 0 || len(b[i]) == 0 {
+			continue
+		}
+		if !slices.ContainsFunc(a[i], func(t common.Hash) bool { return slices.Contains(b[i], t) }) {
+			return false
+		}
+	}
+	return true
+}